@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/internal/importer"
+)
+
+// importBatchSize is how many URLs are sent per batch request, below the server's limit.
+const importBatchSize = 500
+
+// batchItem mirrors an entry of the POST /v1/targets/batch response.
+type batchItem struct {
+	URL    string `json:"url"`
+	Status string `json:"status"`
+}
+
+// runImport implements `linkwatch import`: it parses an export from another monitor and
+// creates the targets through the batch API of a running linkwatch server.
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", importer.FormatURLs, "input format: "+strings.Join(importer.Formats, ", "))
+	server := flags.String("server", "http://localhost:8080", "base URL of the linkwatch server")
+	dryRun := flags.Bool("dry-run", false, "report what would be imported without creating targets")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: linkwatch import [flags] <file | ->")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected exactly one input file")
+	}
+
+	in := os.Stdin
+	if path := flags.Arg(0); path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	parsed, err := importer.Parse(*format, in)
+	if err != nil {
+		return err
+	}
+	for _, s := range parsed.Skipped {
+		fmt.Printf("skipped %s: not an http(s) monitor\n", s)
+	}
+
+	client := &http.Client{Timeout: time.Minute}
+	endpoint := strings.TrimRight(*server, "/") + "/v1/targets/batch"
+	counts := make(map[string]int)
+	for start := 0; start < len(parsed.URLs); start += importBatchSize {
+		end := min(start+importBatchSize, len(parsed.URLs))
+		items, err := postBatch(client, endpoint, parsed.URLs[start:end], *dryRun)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			counts[item.Status]++
+			if item.Status == "invalid" {
+				fmt.Printf("invalid url %s\n", item.URL)
+			}
+		}
+	}
+
+	if *dryRun {
+		fmt.Printf("%d targets would be imported, %d invalid, %d skipped\n",
+			counts["preview"], counts["invalid"], len(parsed.Skipped))
+		return nil
+	}
+	fmt.Printf("%d created, %d already existed, %d invalid, %d skipped\n",
+		counts["created"], counts["existing"], counts["invalid"], len(parsed.Skipped))
+	return nil
+}
+
+func postBatch(client *http.Client, endpoint string, urls []string, dryRun bool) ([]batchItem, error) {
+	body, err := json.Marshal(struct {
+		URLs   []string `json:"urls"`
+		DryRun bool     `json:"dry_run"`
+	}{urls, dryRun})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to send batch: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("server returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var out struct {
+		Items []batchItem `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode batch response: %w", err)
+	}
+	return out.Items, nil
+}
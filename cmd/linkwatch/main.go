@@ -2,21 +2,56 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
+	_ "time/tzdata" // Target schedule time zones, on images without a zoneinfo database
 
-	"linkwatch/internal/api"
-	"linkwatch/internal/checker"
-	"linkwatch/internal/config"
-	"linkwatch/internal/storage/sqlite"
+	"github.com/zeng-yichen/linkwatch/internal/api"
+	"github.com/zeng-yichen/linkwatch/internal/archive"
+	"github.com/zeng-yichen/linkwatch/internal/awssig"
+	"github.com/zeng-yichen/linkwatch/internal/cloudwatch"
+	"github.com/zeng-yichen/linkwatch/internal/config"
+	"github.com/zeng-yichen/linkwatch/internal/crawler"
+	"github.com/zeng-yichen/linkwatch/internal/cron"
+	"github.com/zeng-yichen/linkwatch/internal/discovery"
+	"github.com/zeng-yichen/linkwatch/internal/domains"
+	"github.com/zeng-yichen/linkwatch/internal/jobs"
+	"github.com/zeng-yichen/linkwatch/internal/redisqueue"
+	"github.com/zeng-yichen/linkwatch/internal/report"
+	"github.com/zeng-yichen/linkwatch/internal/selfmonitor"
+	"github.com/zeng-yichen/linkwatch/internal/statecache"
+	"github.com/zeng-yichen/linkwatch/pkg/checker"
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/metrics"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/notify"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+	"github.com/zeng-yichen/linkwatch/pkg/storage/sqlite"
+	"github.com/zeng-yichen/linkwatch/pkg/urlutil"
 )
 
+// queueRedis is the QUEUE_BACKEND sharing scheduled checks between processes through Redis.
+const queueRedis = "redis"
+
 func main() {
 	// The main function is the entry point of the application.
 	// It's responsible for initializing components, starting the server,
 	// and handling graceful shutdown.
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImport(os.Args[2:]); err != nil {
+			log.Fatalf("import failed: %v", err)
+		}
+		return
+	}
 	if err := run(); err != nil {
 		log.Fatalf("application failed: %v", err)
 	}
@@ -27,27 +62,428 @@ func run() error {
 	// Load application configuration from environment variables.
 	cfg := config.Load()
 
+	// Log levels are set before anything logs. The admin API can change them later.
+	level, err := logging.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return fmt.Errorf("invalid LOG_LEVEL: %w", err)
+	}
+	logging.SetAll(level)
+	levels, err := logging.ParseLevels(cfg.LogLevels)
+	if err != nil {
+		return fmt.Errorf("invalid LOG_LEVELS: %w", err)
+	}
+	for name, level := range levels {
+		l, _ := logging.Component(name)
+		l.SetLevel(level)
+	}
+
 	// Create a context that is canceled on OS signals like SIGINT or SIGTERM.
 	// This is the foundation for graceful shutdown.
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
+	// The API server starts first, so probes are answered while the database is migrated.
+	// Until the router is installed below, /readyz reports the startup phase with a 503 and
+	// other requests are refused.
+	startup := api.NewStartup()
+	addr := cfg.HTTPListen
+	if addr == "" {
+		addr = ":" + cfg.HTTPPort
+	}
+	selfURL, err := selfmonitor.Config{
+		Enabled: cfg.SelfMonitor,
+		URL:     cfg.SelfMonitorURL,
+		Addr:    addr,
+		TLS:     cfg.TLSCertFile != "" || cfg.TLSSelfSigned || len(cfg.ACMEDomains) > 0,
+	}.ProbeURL()
+	if err != nil {
+		return err
+	}
+	socketMode, err := strconv.ParseUint(cfg.HTTPSocketMode, 8, 32)
+	if err != nil || socketMode > 0o777 {
+		return fmt.Errorf("invalid HTTP_SOCKET_MODE %q, expected octal permissions like 0660", cfg.HTTPSocketMode)
+	}
+	server, err := api.NewStartingServer(api.ServerConfig{
+		Addr:              addr,
+		SocketMode:        os.FileMode(socketMode),
+		CertFile:          cfg.TLSCertFile,
+		KeyFile:           cfg.TLSKeyFile,
+		SelfSigned:        cfg.TLSSelfSigned,
+		ACMEDomains:       cfg.ACMEDomains,
+		ACMECacheDir:      cfg.ACMECacheDir,
+		ACMEEmail:         cfg.ACMEEmail,
+		ACMEDirectoryURL:  cfg.ACMEDirectoryURL,
+		RedirectAddr:      cfg.HTTPRedirectAddr,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+	}, startup)
+	if err != nil {
+		return fmt.Errorf("failed to configure HTTP server: %w", err)
+	}
+	if err := server.Start(); err != nil {
+		return err
+	}
+
+	// Metrics are discarded unless an exporter is configured.
+	var recorder metrics.Recorder = metrics.Nop{}
+	if cfg.StatsDAddr != "" {
+		statsd, err := metrics.NewStatsD(cfg.StatsDAddr, cfg.StatsDPrefix, metrics.ParseTags(cfg.StatsDTags))
+		if err != nil {
+			return err
+		}
+		defer statsd.Close()
+		recorder = statsd
+		log.Printf("exporting metrics to statsd at %s", cfg.StatsDAddr)
+	}
+
 	// Initialize the SQLite storage layer.
 	log.Println("initializing SQLite database connection...")
-	store, err := sqlite.New(ctx, cfg.DatabaseURL)
+	var storeOpts []sqlite.Option
+	if cfg.DatabaseReadURL != "" {
+		storeOpts = append(storeOpts, sqlite.WithReadReplica(cfg.DatabaseReadURL))
+	}
+	if cfg.DatabaseContractMigrations {
+		storeOpts = append(storeOpts, sqlite.WithContractMigrations())
+	}
+	if cfg.DatabaseCompressAbove > 0 {
+		storeOpts = append(storeOpts, sqlite.WithCompression(cfg.DatabaseCompressAbove))
+	}
+	if cfg.StatsDAddr != "" {
+		storeOpts = append(storeOpts, sqlite.WithMetrics(recorder))
+	}
+	if cfg.DatabaseSlowQuery > 0 {
+		storeOpts = append(storeOpts, sqlite.WithSlowQueryLog(cfg.DatabaseSlowQuery))
+	}
+	storeOpts = append(storeOpts, sqlite.WithPool(sqlite.PoolConfig{
+		MaxOpen:     cfg.DatabaseMaxOpenConns,
+		MaxIdle:     cfg.DatabaseMaxIdleConns,
+		MaxLifetime: cfg.DatabaseConnMaxLifetime,
+		MaxIdleTime: cfg.DatabaseConnMaxIdleTime,
+	}))
+	switch cfg.DatabaseIntegrityCheck {
+	case "", "off":
+	case sqlite.IntegrityQuick, sqlite.IntegrityFull:
+		storeOpts = append(storeOpts, sqlite.WithIntegrityCheck(cfg.DatabaseIntegrityCheck))
+	default:
+		return fmt.Errorf("invalid DATABASE_INTEGRITY_CHECK %q, expected off, %s, or %s", cfg.DatabaseIntegrityCheck, sqlite.IntegrityQuick, sqlite.IntegrityFull)
+	}
+	switch cfg.DatabaseAutoVacuum {
+	case "":
+	case sqlite.AutoVacuumNone, sqlite.AutoVacuumFull, sqlite.AutoVacuumIncremental:
+		storeOpts = append(storeOpts, sqlite.WithAutoVacuum(cfg.DatabaseAutoVacuum))
+	default:
+		return fmt.Errorf("invalid DATABASE_AUTO_VACUUM %q, expected %s, %s, or %s", cfg.DatabaseAutoVacuum, sqlite.AutoVacuumNone, sqlite.AutoVacuumFull, sqlite.AutoVacuumIncremental)
+	}
+	if cfg.DatabasePageSize != 0 {
+		if !sqlite.ValidPageSize(cfg.DatabasePageSize) {
+			return fmt.Errorf("invalid DATABASE_PAGE_SIZE %d, expected a power of two from 512 to 65536", cfg.DatabasePageSize)
+		}
+		storeOpts = append(storeOpts, sqlite.WithPageSize(cfg.DatabasePageSize))
+	}
+	if cfg.ClientCertSecret != "" {
+		storeOpts = append(storeOpts, sqlite.WithClientCertSecret(cfg.ClientCertSecret))
+	}
+	store, err := openStore(ctx, cfg, storeOpts)
 	if err != nil {
 		return fmt.Errorf("failed to initialize sqlite storage: %w", err)
 	}
 	defer store.Close()
 	log.Println("database connection successful")
 
-	// Initialize the background checker and the API server.
-	checkerSvc := checker.New(store, cfg.CheckInterval, cfg.MaxConcurrency, cfg.HTTPTimeout)
-	server := api.NewServer(cfg.HTTPPort, store)
+	// Initialize the reporter; emailing is only enabled when an SMTP relay is configured.
+	var mailer report.Mailer
+	if cfg.SMTPAddr != "" {
+		mailer = report.NewSMTPMailer(cfg.SMTPAddr, cfg.SMTPUsername, cfg.SMTPPassword)
+	}
+	reporter := report.New(store, mailer, cfg.ReportFrom, cfg.ReportRecipients)
+	var reportSchedule *cron.Schedule
+	if cfg.ReportSchedule != "" {
+		if reportSchedule, err = cron.Parse(cfg.ReportSchedule); err != nil {
+			return fmt.Errorf("invalid REPORT_SCHEDULE: %w", err)
+		}
+		if _, err := report.PeriodWindow(cfg.ReportPeriod); err != nil {
+			return fmt.Errorf("invalid REPORT_PERIOD: %w", err)
+		}
+		if !reporter.CanSend() {
+			return fmt.Errorf("REPORT_SCHEDULE requires SMTP_ADDR and REPORT_RECIPIENTS")
+		}
+	}
+
+	// Alerts are always logged, and additionally posted to a webhook when configured. In
+	// digest mode the webhook, and ALERT_RECIPIENTS by email, get one summary per window.
+	notifier := notify.Multi{notify.LogNotifier{}}
+	switch cfg.AlertMode {
+	case notify.AlertImmediate:
+		if cfg.AlertWebhookURL != "" {
+			notifier = append(notifier, notify.NewWebhookNotifier(cfg.AlertWebhookURL, cfg.AlertWebhookSecret, cfg.HTTPTimeout))
+		}
+	case notify.AlertDigest:
+		var senders []notify.DigestSender
+		if cfg.AlertWebhookURL != "" {
+			senders = append(senders, notify.NewWebhookNotifier(cfg.AlertWebhookURL, cfg.AlertWebhookSecret, cfg.HTTPTimeout))
+		}
+		if len(cfg.AlertRecipients) > 0 {
+			if mailer == nil {
+				return fmt.Errorf("ALERT_RECIPIENTS requires SMTP_ADDR")
+			}
+			senders = append(senders, report.NewDigestMailer(mailer, cfg.ReportFrom, cfg.AlertRecipients))
+		}
+		if cfg.AlertDigestWindow <= 0 {
+			return fmt.Errorf("ALERT_DIGEST_WINDOW must be positive")
+		}
+		digest := notify.NewDigestNotifier(cfg.AlertDigestWindow, cfg.HTTPTimeout, senders...)
+		digest.Start()
+		defer digest.Stop()
+		notifier = append(notifier, digest)
+		log.Printf("sending alert digests every %s", cfg.AlertDigestWindow)
+	default:
+		return fmt.Errorf("invalid ALERT_MODE %q, expected %s or %s", cfg.AlertMode, notify.AlertImmediate, notify.AlertDigest)
+	}
+
+	// Every stored check result invalidates the target's cached state, and is optionally
+	// streamed to a webhook in batches and to CloudWatch. Sinks are registered here, once;
+	// the checker and API only see the registry.
+	states := statecache.New(store, cfg.TargetStateCacheTTL, recorder)
+	sinks := &notify.Sinks{}
+	sinks.Register(states)
+	if cfg.ResultWebhookURL != "" {
+		sinks.Register(notify.NewResultWebhook(cfg.ResultWebhookURL, cfg.ResultWebhookSecret,
+			cfg.ResultWebhookBatchSize, cfg.ResultWebhookInterval, cfg.HTTPTimeout))
+	}
+	if cfg.CloudWatchEnabled {
+		cw, err := newCloudWatchPublisher(cfg)
+		if err != nil {
+			return err
+		}
+		sinks.Register(cw)
+		log.Printf("publishing check metrics to cloudwatch namespace %s", cfg.CloudWatchNamespace)
+	}
+	sinks.Start()
+	defer sinks.Stop()
+
+	redactor := urlutil.NewRedactor(cfg.RedactQueryParams)
+	checkerOpts := []checker.Option{
+		checker.WithNotifier(notifier),
+		checker.WithMetrics(recorder),
+		checker.WithResultSinks(sinks),
+		checker.WithBatchSize(cfg.SchedulerBatchSize),
+		checker.WithTargetCache(store, cfg.SchedulerTargetCache),
+		checker.WithBodyLimits(cfg.CheckMaxBodyBytes, cfg.CheckBodyContentTypes),
+		checker.WithMaxRedirects(cfg.CheckMaxRedirects),
+		checker.WithQueueSize(cfg.CheckQueueSize),
+		checker.WithDNSFailureCache(cfg.CheckDNSFailureTTL),
+		checker.WithTimeoutBudget(cfg.CheckTimeoutBudget),
+		checker.WithWarmup(cfg.CheckWarmup),
+		checker.WithRedactor(redactor),
+	}
+	apiOpts := []api.Option{
+		api.WithNotifier(notifier),
+		api.WithResultPublisher(sinks),
+		api.WithStateCache(states),
+		api.WithDegradedLatency(cfg.DegradedLatency),
+		api.WithDatabaseHealth(store),
+		api.WithDatabaseMaintenance(store),
+		api.WithAdminToken(cfg.AdminToken),
+		api.WithRedactor(redactor),
+		api.WithURLLimits(urlutil.Limits{
+			MaxLength:      cfg.URLMaxLength,
+			MaxPathLength:  cfg.URLMaxPathLength,
+			MaxQueryLength: cfg.URLMaxQueryLength,
+			RequireTLD:     cfg.URLRequireTLD,
+		}),
+	}
+	if cfg.CheckMinTLSVersion != "" {
+		if !slices.Contains(models.TLSVersions, cfg.CheckMinTLSVersion) {
+			return fmt.Errorf("invalid CHECK_MIN_TLS_VERSION %q, expected one of %s", cfg.CheckMinTLSVersion, strings.Join(models.TLSVersions, ", "))
+		}
+		checkerOpts = append(checkerOpts, checker.WithMinTLSVersion(cfg.CheckMinTLSVersion))
+	}
+	if cfg.SchedulerLagThreshold > 0 && cfg.SchedulerLagThreshold <= cfg.CheckInterval {
+		return fmt.Errorf("SCHEDULER_LAG_THRESHOLD (%s) must be longer than CHECK_INTERVAL (%s)", cfg.SchedulerLagThreshold, cfg.CheckInterval)
+	}
+	if cfg.QueueSaturation < 0 || cfg.QueueSaturation > 100 {
+		return fmt.Errorf("invalid QUEUE_SATURATION_THRESHOLD %d, expected a percentage from 0 to 100", cfg.QueueSaturation)
+	}
+	checkerOpts = append(checkerOpts, checker.WithQueueMonitor(cfg.QueueMonitorInterval, checker.QueueThresholds{
+		SchedulerLag: cfg.SchedulerLagThreshold,
+		OldestQueued: cfg.QueueAgeThreshold,
+		Saturation:   float64(cfg.QueueSaturation) / 100,
+	}))
+	if cfg.ExecChecksDir != "" {
+		checkerOpts = append(checkerOpts, checker.WithExecChecks(cfg.ExecChecksDir, cfg.ExecCheckTimeout))
+		apiOpts = append(apiOpts, api.WithExecChecks(cfg.ExecChecksDir))
+	}
+	if cfg.WasmPluginsDir != "" {
+		plugins, err := checker.LoadWasmPlugins(ctx, cfg.WasmPluginsDir)
+		if err != nil {
+			return fmt.Errorf("invalid WASM_PLUGINS_DIR: %w", err)
+		}
+		defer plugins.Close(context.Background())
+		checkerOpts = append(checkerOpts, checker.WithWasmChecks(plugins, cfg.WasmCheckTimeout))
+		apiOpts = append(apiOpts, api.WithWasmChecks(cfg.WasmPluginsDir))
+		log.Printf("loaded %d wasm plugins from %s: %s", len(plugins.Names()), cfg.WasmPluginsDir, strings.Join(plugins.Names(), ", "))
+	}
+	if cfg.ClientCertSecret != "" {
+		checkerOpts = append(checkerOpts, checker.WithClientCertificates(store))
+		apiOpts = append(apiOpts, api.WithClientCertificates(store))
+	}
+	if cfg.PageTokenKey != "" {
+		apiOpts = append(apiOpts, api.WithPageTokenSecret(cfg.PageTokenKey))
+	}
+	if cfg.StrictQuery {
+		apiOpts = append(apiOpts, api.WithStrictQueryParams())
+	}
+	routeTimeouts, err := api.ParseRouteTimeouts(cfg.RequestTimeouts)
+	if err != nil {
+		return fmt.Errorf("invalid REQUEST_TIMEOUTS: %w", err)
+	}
+	apiOpts = append(apiOpts, api.WithRequestTimeouts(api.RequestTimeouts{
+		Read:   cfg.RequestReadTimeout,
+		Write:  cfg.RequestWriteTimeout,
+		Routes: routeTimeouts,
+	}))
+	switch cfg.ResultStorageMode {
+	case checker.StoreAll:
+	case checker.StoreOnChange:
+		checkerOpts = append(checkerOpts, checker.WithChangeOnlyStorage(cfg.ResultKeepalive))
+		apiOpts = append(apiOpts, api.WithSparseResults(cfg.ResultKeepalive))
+		log.Printf("storing check results on change, with a keepalive every %s", cfg.ResultKeepalive)
+	default:
+		return fmt.Errorf("invalid RESULT_STORAGE_MODE %q, expected %s or %s", cfg.ResultStorageMode, checker.StoreAll, checker.StoreOnChange)
+	}
+	if cfg.ResultSampleEvery > 0 {
+		checkerOpts = append(checkerOpts, checker.WithResultSampling(store, cfg.ResultSampleEvery))
+	}
+	if cfg.ArchiveAfter > 0 {
+		archiver, err := newArchiver(cfg, store)
+		if err != nil {
+			return err
+		}
+		checkerOpts = append(checkerOpts, checker.WithResultArchiving(archiver, cfg.ArchiveInterval))
+		apiOpts = append(apiOpts, api.WithArchive(archiver))
+		log.Printf("archiving check results older than %s to bucket %s", cfg.ArchiveAfter, cfg.ArchiveBucket)
+	}
+	if cfg.DomainCheckInterval > 0 {
+		monitor, err := domains.New(store, notifier, domains.Config{
+			RDAPURL: cfg.RDAPURL,
+			Refresh: cfg.DomainCheckInterval,
+			Warning: cfg.DomainExpiryWarning,
+		}, &http.Client{Timeout: cfg.HTTPTimeout})
+		if err != nil {
+			return fmt.Errorf("invalid domain monitoring configuration: %w", err)
+		}
+		checkerOpts = append(checkerOpts, checker.WithDomainMonitoring(monitor, cfg.DomainCheckInterval))
+		apiOpts = append(apiOpts, api.WithDomains(monitor))
+		log.Printf("looking up target domains over RDAP every %s", cfg.DomainCheckInterval)
+	}
+	switch cfg.QueueBackend {
+	case checker.QueueMemory:
+	case checker.QueueDB:
+		checkerOpts = append(checkerOpts, checker.WithStoreQueue(store))
+		log.Printf("keeping scheduled checks in the database")
+	case queueRedis:
+		if cfg.RedisURL == "" {
+			return fmt.Errorf("REDIS_URL is required with QUEUE_BACKEND=%s", queueRedis)
+		}
+		q, err := redisqueue.New(redisqueue.Config{URL: cfg.RedisURL})
+		if err != nil {
+			return err
+		}
+		checkerOpts = append(checkerOpts, checker.WithQueue(q))
+		log.Printf("sharing scheduled checks through redis")
+	default:
+		return fmt.Errorf("invalid QUEUE_BACKEND %q, expected %s, %s, or %s", cfg.QueueBackend, checker.QueueMemory, checker.QueueDB, queueRedis)
+	}
+	switch cfg.CheckerRole {
+	case checker.RoleAll:
+	case checker.RoleScheduler, checker.RoleWorker:
+		if cfg.QueueBackend != queueRedis {
+			return fmt.Errorf("CHECKER_ROLE=%s needs a queue shared between processes, set QUEUE_BACKEND=%s", cfg.CheckerRole, queueRedis)
+		}
+		checkerOpts = append(checkerOpts, checker.WithRole(cfg.CheckerRole))
+	default:
+		return fmt.Errorf("invalid CHECKER_ROLE %q, expected %s, %s, or %s", cfg.CheckerRole, checker.RoleAll, checker.RoleScheduler, checker.RoleWorker)
+	}
+	if cfg.ShardTotal < 1 || cfg.ShardIndex < 0 || cfg.ShardIndex >= cfg.ShardTotal {
+		return fmt.Errorf("invalid SHARD_INDEX %d of SHARD_TOTAL %d, expected 0 to %d", cfg.ShardIndex, cfg.ShardTotal, cfg.ShardTotal-1)
+	}
+	if cfg.ShardTotal > 1 {
+		checkerOpts = append(checkerOpts, checker.WithShard(cfg.ShardIndex, cfg.ShardTotal))
+		log.Printf("scheduling checks for shard %d of %d", cfg.ShardIndex, cfg.ShardTotal)
+	}
+	if cfg.LeaderElection {
+		host, err := os.Hostname()
+		if err != nil {
+			host = "linkwatch"
+		}
+		holder := fmt.Sprintf("%s-%d", host, os.Getpid())
+		checkerOpts = append(checkerOpts, checker.WithLeaderElection(store, holder, cfg.LeaderLeaseTTL))
+		log.Printf("scheduling checks only while holding the scheduler lease, as %s", holder)
+	}
+	if cfg.APIV1DeprecatedAt != "" || cfg.APIV1Sunset != "" {
+		dep := api.Deprecation{Link: cfg.APIV1DeprecationLink}
+		if cfg.APIV1DeprecatedAt != "" {
+			if dep.At, err = time.Parse(time.RFC3339, cfg.APIV1DeprecatedAt); err != nil {
+				return fmt.Errorf("invalid API_V1_DEPRECATED_AT: %w", err)
+			}
+		}
+		if cfg.APIV1Sunset != "" {
+			if dep.Sunset, err = time.Parse(time.RFC3339, cfg.APIV1Sunset); err != nil {
+				return fmt.Errorf("invalid API_V1_SUNSET: %w", err)
+			}
+		}
+		apiOpts = append(apiOpts, api.WithDeprecation(api.V1, dep))
+		log.Printf("serving the v1 API as deprecated")
+	}
+
+	// Initialize the background checker and the API's dependencies.
+	checkerSvc := checker.New(store, cfg.CheckInterval, cfg.MaxConcurrency, cfg.HTTPTimeout, checkerOpts...)
+	discoverer := discovery.New(&http.Client{Timeout: cfg.HTTPTimeout}, cfg.DiscoveryMaxURLs)
+	crawl := crawler.New(&http.Client{Timeout: cfg.HTTPTimeout}, cfg.CrawlMaxLinks)
+	jobManager := jobs.NewManager(store)
 
-	// Start the services.
+	// Only start checking once the first targets load, then serve the API.
+	startup.Set(models.StartupWarmingCache)
+	if err := warmCache(ctx, store, states); err != nil {
+		return fmt.Errorf("failed to load targets: %w", err)
+	}
+	startup.Set(models.StartupStartingChecker)
+	if err := jobManager.Recover(ctx); err != nil {
+		return fmt.Errorf("failed to recover jobs: %w", err)
+	}
+	defer jobManager.Stop()
 	checkerSvc.Start()
-	server.Start()
+	server.SetHandler(api.NewRouter(store, append(apiOpts,
+		api.WithStartup(startup),
+		api.WithReporter(reporter),
+		api.WithDiscoverer(discoverer),
+		api.WithCrawler(crawl),
+		api.WithJobManager(jobManager),
+		api.WithQueue(checkerSvc),
+		api.WithWorkers(checkerSvc),
+		api.WithHosts(checkerSvc),
+		api.WithInitialChecks(checkerSvc),
+		api.WithCheckerHealth(checkerSvc),
+	)...))
+	startup.Set(models.StartupReady)
+	recordServiceEvent(store, models.SystemEventServiceStarted, "service started", map[string]string{
+		"schema_version": strconv.Itoa(sqlite.SchemaVersion),
+		"role":           cfg.CheckerRole,
+	})
+
+	self, err := selfmonitor.Register(ctx, store, selfURL)
+	if err != nil {
+		return fmt.Errorf("failed to register the self-monitoring target: %w", err)
+	}
+	if self != nil {
+		checkerSvc.CheckNow(*self)
+		log.Printf("monitoring this service as target %s at %s", self.ID, self.URL)
+	}
+
+	if reportSchedule != nil {
+		reporter.Start(reportSchedule, cfg.ReportPeriod)
+		defer reporter.Stop()
+	}
 
 	log.Println("application is running...")
 
@@ -56,6 +492,7 @@ func run() error {
 
 	// --- Graceful shutdown logic ---
 	log.Println("shutdown signal received, starting graceful shutdown...")
+	recordServiceEvent(store, models.SystemEventServiceStopping, "service stopping", nil)
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.ShutdownGrace)
 	defer shutdownCancel()
 
@@ -69,3 +506,109 @@ func run() error {
 
 	return nil
 }
+
+// recordServiceEvent stores a system event about the process; a failure is only logged.
+func recordServiceEvent(store storage.EventStore, eventType, message string, details map[string]string) {
+	event := &models.SystemEvent{Type: eventType, At: time.Now().UTC(), Message: message, Details: details}
+	if err := store.RecordSystemEvent(context.Background(), event); err != nil {
+		log.Printf("error recording %s event: %v", eventType, err)
+	}
+}
+
+// warmCachePage is how many targets warmCache loads.
+const warmCachePage = 500
+
+// warmCache loads the first targets and their states, which fails if the database can't
+// serve the target list yet, and fills the state cache for the first list requests.
+func warmCache(ctx context.Context, store storage.TargetReader, states *statecache.Cache) error {
+	targets, err := store.ListTargetsPage(ctx, "", warmCachePage)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, len(targets))
+	for i, t := range targets {
+		ids[i] = t.ID
+	}
+	_, err = states.States(ctx, ids)
+	return err
+}
+
+// openStore opens the database, retrying with backoff for up to DATABASE_CONNECT_RETRY
+// while it can't be reached, e.g. until a network volume is mounted. Migration errors are
+// returned straight away.
+func openStore(ctx context.Context, cfg *config.Config, opts []sqlite.Option) (*sqlite.Store, error) {
+	deadline := time.Now().Add(cfg.DatabaseConnectRetry)
+	backoff := 500 * time.Millisecond
+	for {
+		store, err := sqlite.New(ctx, cfg.DatabaseURL, opts...)
+		if err == nil || !errors.Is(err, sqlite.ErrUnreachable) || time.Now().Add(backoff).After(deadline) {
+			return store, err
+		}
+		log.Printf("database unreachable, retrying in %s: %v", backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff = min(backoff*2, 10*time.Second)
+	}
+}
+
+// newArchiver builds the result archiver from config, taking credentials from the
+// ARCHIVE_S3_* settings or else the standard AWS environment.
+func newArchiver(cfg *config.Config, store storage.ResultArchiveStore) (*archive.Archiver, error) {
+	creds := awssig.Credentials{AccessKeyID: cfg.ArchiveAccessKeyID, SecretAccessKey: cfg.ArchiveSecretKey}
+	if creds.AccessKeyID == "" && creds.SecretAccessKey == "" {
+		var err error
+		if creds, err = awssig.CredentialsFromEnv(); err != nil {
+			return nil, fmt.Errorf("ARCHIVE_AFTER requires object storage credentials: %w", err)
+		}
+	}
+	region := cfg.ArchiveRegion
+	if region == "" {
+		region = awssig.RegionFromEnv()
+	}
+	if region == "" {
+		region = "us-east-1" // What most S3-compatible stores expect in signatures
+	}
+	a, err := archive.New(store, archive.Config{
+		After:      cfg.ArchiveAfter,
+		RestoreTTL: cfg.ArchiveRestoreTTL,
+		Prefix:     cfg.ArchivePrefix,
+		S3: archive.S3Config{
+			Bucket:      cfg.ArchiveBucket,
+			Region:      region,
+			Endpoint:    cfg.ArchiveEndpoint,
+			PathStyle:   cfg.ArchivePathStyle,
+			Credentials: creds,
+		},
+	}, &http.Client{Timeout: 5 * time.Minute})
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive configuration: %w", err)
+	}
+	return a, nil
+}
+
+// newCloudWatchPublisher builds the CloudWatch publisher from config and the standard AWS environment.
+func newCloudWatchPublisher(cfg *config.Config) (*cloudwatch.Publisher, error) {
+	creds, err := awssig.CredentialsFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("CLOUDWATCH_ENABLED requires AWS credentials: %w", err)
+	}
+	region := awssig.RegionFromEnv()
+	if region == "" {
+		return nil, fmt.Errorf("CLOUDWATCH_ENABLED requires AWS_REGION")
+	}
+	dims, err := cloudwatch.ParseDimensions(cfg.CloudWatchDimensions)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CLOUDWATCH_DIMENSIONS: %w", err)
+	}
+	return cloudwatch.New(cloudwatch.Config{
+		Namespace:     cfg.CloudWatchNamespace,
+		Region:        region,
+		Endpoint:      cfg.CloudWatchEndpoint,
+		Dimensions:    dims,
+		FlushInterval: cfg.CloudWatchInterval,
+		Credentials:   creds,
+	}, &http.Client{Timeout: cfg.HTTPTimeout}), nil
+}
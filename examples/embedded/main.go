@@ -0,0 +1,72 @@
+// Command embedded runs the linkwatch checker inside another program: it registers a few
+// URLs in an in-memory SQLite store, checks them every 30 seconds, and prints each result as
+// it is stored.
+//
+//	go run ./examples/embedded https://example.com https://go.dev
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/checker"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+	"github.com/zeng-yichen/linkwatch/pkg/storage/sqlite"
+)
+
+// printer is a notify.ResultSink that writes every result to stdout.
+type printer struct{}
+
+func (printer) Publish(r models.CheckResult) {
+	switch {
+	case r.Error != nil:
+		fmt.Printf("%s  %-24s error: %s\n", r.CheckedAt.Format(time.TimeOnly), r.TargetID, *r.Error)
+	case r.StatusCode != nil:
+		fmt.Printf("%s  %-24s %d in %dms\n", r.CheckedAt.Format(time.TimeOnly), r.TargetID, *r.StatusCode, r.LatencyMS)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal("usage: embedded URL...")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	store, err := sqlite.New(ctx, ":memory:")
+	if err != nil {
+		log.Fatalf("failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	for _, raw := range os.Args[1:] {
+		target, err := models.NewHTTPTarget(raw)
+		if err != nil {
+			log.Fatalf("invalid url %q: %v", raw, err)
+		}
+		if _, err := store.CreateTarget(ctx, target, nil); err != nil && !errors.Is(err, storage.ErrDuplicateKey) {
+			log.Fatalf("failed to add %s: %v", raw, err)
+		}
+	}
+
+	c := checker.New(store, 30*time.Second, 4, 10*time.Second,
+		checker.WithResultSinks(printer{}),
+		checker.WithHooks(checker.HookFuncs{
+			Before: func(ctx context.Context, t models.Target, req *http.Request) error {
+				req.Header.Set("User-Agent", "linkwatch-embedded")
+				return nil
+			},
+		}),
+	)
+	c.Start()
+	<-ctx.Done()
+	c.Stop()
+}
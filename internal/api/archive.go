@@ -0,0 +1,32 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// ArchiveReader restores archived check results, so statistics over a range that reaches
+// back past the archive age can still be computed from the store.
+type ArchiveReader interface {
+	RestoreCheckResults(ctx context.Context, since, until time.Time) (int, error)
+}
+
+// WithArchive makes the statistics endpoints read archived results through, restoring the
+// days their range covers before querying.
+func WithArchive(a ArchiveReader) Option {
+	return func(h *Handlers) { h.archive = a }
+}
+
+// readArchive restores the archived results in [since, until), if archiving is enabled. It
+// writes a 500 and returns false when they can't be read.
+func (h *Handlers) readArchive(w http.ResponseWriter, r *http.Request, since, until time.Time) bool {
+	if h.archive == nil {
+		return true
+	}
+	if _, err := h.archive.RestoreCheckResults(r.Context(), since, until); err != nil {
+		h.internalError(w, r, "restore archived results error", err)
+		return false
+	}
+	return true
+}
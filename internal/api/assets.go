@@ -0,0 +1,21 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// maxAssets caps how many assets an asset check may request per check.
+const maxAssets = 200
+
+// normalizeAssetCheck validates an asset check. A max_assets of zero uses the checker's default.
+func normalizeAssetCheck(c *models.AssetCheck) (*models.AssetCheck, error) {
+	if c == nil {
+		return nil, nil
+	}
+	if c.MaxAssets < 0 || c.MaxAssets > maxAssets {
+		return nil, fmt.Errorf("asset_check.max_assets must be between 0 and %d", maxAssets)
+	}
+	return &models.AssetCheck{MaxAssets: c.MaxAssets}, nil
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// BandwidthReport handles reporting the response body bytes checks downloaded per UTC day over
+// a window (default 7 days), per host or, with ?group_by=target, per target. host and
+// target_id narrow the report. Only stored results count, so with on-change storage the
+// checks.bytes_downloaded metric is the complete figure.
+func (h *Handlers) BandwidthReport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	since, until, err := parseWindow(q, 7*24*time.Hour, h.clock.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	groupBy := q.Get("group_by")
+	switch groupBy {
+	case "":
+		groupBy = storage.BandwidthByHost
+	case storage.BandwidthByHost, storage.BandwidthByTarget:
+	default:
+		http.Error(w, "group_by must be one of: host, target", http.StatusBadRequest)
+		return
+	}
+
+	if !h.readArchive(w, r, since, until) {
+		return
+	}
+	usage, err := h.store.GetBandwidthUsage(r.Context(), storage.BandwidthParams{
+		Since:    since,
+		Until:    until,
+		GroupBy:  groupBy,
+		Host:     q.Get("host"),
+		TargetID: q.Get("target_id"),
+	})
+	if err != nil {
+		h.internalError(w, r, "bandwidth report error", err)
+		return
+	}
+	var total int64
+	for _, u := range usage {
+		total += u.BytesDownloaded
+	}
+	if usage == nil {
+		usage = []models.BandwidthUsage{}
+	}
+
+	resp := struct {
+		GroupBy         string                  `json:"group_by"`
+		Since           time.Time               `json:"since"`
+		Until           time.Time               `json:"until"`
+		BytesDownloaded int64                   `json:"bytes_downloaded"`
+		Items           []models.BandwidthUsage `json:"items"`
+	}{GroupBy: groupBy, Since: since, Until: until, BytesDownloaded: total, Items: usage}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// WithClientCertificates enables the client certificate endpoints, which save the
+// certificates checks present for mutual TLS in s. Without it they answer 503.
+func WithClientCertificates(s storage.ClientCertStore) Option {
+	return func(h *Handlers) { h.clientCerts = s }
+}
+
+// clientCertsEnabled writes a 503 and returns false when client certificates aren't enabled.
+func (h *Handlers) clientCertsEnabled(w http.ResponseWriter) bool {
+	if h.clientCerts == nil {
+		http.Error(w, "client certificates are not enabled", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// PutClientCertificate handles saving the certificate and key checks of a host present when
+// it asks for a client certificate, replacing any it had. Both are PEM; the certificate may be
+// followed by its intermediates. The key is stored encrypted and never returned. It requires
+// the admin token.
+func (h *Handlers) PutClientCertificate(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) || !h.clientCertsEnabled(w) {
+		return
+	}
+	host := strings.ToLower(strings.TrimSpace(r.PathValue("host")))
+	var reqBody struct {
+		CertPEM string `json:"cert_pem"`
+		KeyPEM  string `json:"key_pem"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	pair, err := tls.X509KeyPair([]byte(reqBody.CertPEM), []byte(reqBody.KeyPEM))
+	if err != nil {
+		http.Error(w, "cert_pem and key_pem must be a PEM certificate and its private key: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	cert := models.ClientCertificate{
+		Host:      host,
+		CertPEM:   reqBody.CertPEM,
+		KeyPEM:    reqBody.KeyPEM,
+		Subject:   pair.Leaf.Subject.String(),
+		NotAfter:  pair.Leaf.NotAfter.UTC(),
+		UpdatedAt: h.clock.Now().UTC(),
+	}
+	if err := h.clientCerts.PutClientCertificate(r.Context(), cert); err != nil {
+		h.internalError(w, r, "save client certificate error", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cert)
+}
+
+// GetClientCertificate handles looking up a host's client certificate, without its key. It
+// requires the admin token.
+func (h *Handlers) GetClientCertificate(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) || !h.clientCertsEnabled(w) {
+		return
+	}
+	host := strings.ToLower(strings.TrimSpace(r.PathValue("host")))
+	cert, err := h.clientCerts.GetClientCertificate(r.Context(), host)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "host has no client certificate", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.internalError(w, r, "get client certificate error", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cert)
+}
+
+// DeleteClientCertificate handles forgetting a host's client certificate; its checks present
+// none from then on. It requires the admin token.
+func (h *Handlers) DeleteClientCertificate(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) || !h.clientCertsEnabled(w) {
+		return
+	}
+	host := strings.ToLower(strings.TrimSpace(r.PathValue("host")))
+	if err := h.clientCerts.DeleteClientCertificate(r.Context(), host); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "host has no client certificate", http.StatusNotFound)
+			return
+		}
+		h.internalError(w, r, "delete client certificate error", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListClientCertificates handles listing every host's client certificate, without keys,
+// ordered by host. It requires the admin token.
+func (h *Handlers) ListClientCertificates(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) || !h.clientCertsEnabled(w) {
+		return
+	}
+	certs, err := h.clientCerts.ListClientCertificates(r.Context())
+	if err != nil {
+		h.internalError(w, r, "list client certificates error", err)
+		return
+	}
+	if certs == nil {
+		certs = []models.ClientCertificate{}
+	}
+	resp := struct {
+		Items []models.ClientCertificate `json:"items"`
+	}{Items: certs}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// windowSummary is the summary of one window compared by CompareResults.
+type windowSummary struct {
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+	*models.ResultSummary
+}
+
+// summaryDelta is how the to window of a comparison differs from the from window: each
+// field is to minus from. Rates and latencies are null unless both windows have checks.
+type summaryDelta struct {
+	CheckCount   int64                      `json:"check_count"`
+	SuccessRate  *float64                   `json:"success_rate"`
+	AvgLatencyMS *float64                   `json:"avg_latency_ms"`
+	LatencyMS    *models.LatencyPercentiles `json:"latency_ms"`
+	// ErrorRates is the change in the fraction of checks that failed with each error
+	// category, so windows of different lengths compare fairly.
+	ErrorRates map[string]float64 `json:"error_rates"`
+}
+
+// CompareResults handles comparing a target's results in two windows, e.g. before and after a
+// deploy. Both from and to are intervals of two timestamps, <start>/<end>.
+func (h *Handlers) CompareResults(w http.ResponseWriter, r *http.Request) {
+	targetID := r.PathValue("target_id")
+	if _, err := h.store.GetTargetByID(r.Context(), targetID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "target not found", http.StatusNotFound)
+			return
+		}
+		h.internalError(w, r, "get target error", err)
+		return
+	}
+
+	q := r.URL.Query()
+	fromSince, fromUntil, err := parseInterval(q, "from")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	toSince, toUntil, err := parseInterval(q, "to")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	summarize := func(since, until time.Time) (*windowSummary, bool) {
+		if !h.readArchive(w, r, since, until) {
+			return nil, false
+		}
+		summary, err := h.store.GetResultSummary(r.Context(), storage.ResultSummaryParams{TargetID: targetID, Since: since, Until: until})
+		if err != nil {
+			h.internalError(w, r, "result summary error", err)
+			return nil, false
+		}
+		return &windowSummary{Since: since, Until: until, ResultSummary: summary}, true
+	}
+	from, ok := summarize(fromSince, fromUntil)
+	if !ok {
+		return
+	}
+	to, ok := summarize(toSince, toUntil)
+	if !ok {
+		return
+	}
+
+	resp := struct {
+		TargetID string         `json:"target_id"`
+		From     *windowSummary `json:"from"`
+		To       *windowSummary `json:"to"`
+		Delta    summaryDelta   `json:"delta"`
+	}{TargetID: targetID, From: from, To: to, Delta: compareSummaries(from.ResultSummary, to.ResultSummary)}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// compareSummaries returns how to differs from from.
+func compareSummaries(from, to *models.ResultSummary) summaryDelta {
+	d := summaryDelta{CheckCount: to.CheckCount - from.CheckCount, ErrorRates: map[string]float64{}}
+	if from.CheckCount == 0 || to.CheckCount == 0 {
+		return d
+	}
+	rate := *to.SuccessRate - *from.SuccessRate
+	latency := to.AvgLatencyMS - from.AvgLatencyMS
+	d.SuccessRate, d.AvgLatencyMS = &rate, &latency
+	d.LatencyMS = &models.LatencyPercentiles{
+		P50: to.LatencyMS.P50 - from.LatencyMS.P50,
+		P90: to.LatencyMS.P90 - from.LatencyMS.P90,
+		P95: to.LatencyMS.P95 - from.LatencyMS.P95,
+		P99: to.LatencyMS.P99 - from.LatencyMS.P99,
+	}
+	share := func(s *models.ResultSummary, category string) float64 {
+		return float64(s.ErrorCategories[category]) / float64(s.CheckCount)
+	}
+	for _, s := range []*models.ResultSummary{from, to} {
+		for category := range s.ErrorCategories {
+			d.ErrorRates[category] = share(to, category) - share(from, category)
+		}
+	}
+	return d
+}
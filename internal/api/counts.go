@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// targetCountTTL is how long a target count is reused. Counts back "page N of M" displays,
+// so being a few seconds stale is fine and saves a COUNT per page load.
+const targetCountTTL = 10 * time.Second
+
+// countCache keeps recent target counts, keyed by filter (see countKey).
+type countCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedCount
+}
+
+type cachedCount struct {
+	n         int
+	countedAt time.Time
+}
+
+// countKey identifies a filter: the host, then each metadata pair in key order.
+func countKey(host string, metadata map[string]string) string {
+	key := host
+	for _, k := range slices.Sorted(maps.Keys(metadata)) {
+		key += "\x00" + k + "=" + metadata[k]
+	}
+	return key
+}
+
+// targetCount returns how many targets there are on host with the metadata pairs, or in
+// total when neither is set.
+func (h *Handlers) targetCount(ctx context.Context, host string, metadata map[string]string) (int, error) {
+	c := &h.counts
+	key := countKey(host, metadata)
+	now := h.clock.Now()
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Sub(e.countedAt) < targetCountTTL {
+		return e.n, nil
+	}
+
+	n, err := h.store.CountTargets(ctx, host, metadata)
+	if err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]cachedCount)
+	}
+	// Filters come from clients, so expired entries are dropped rather than kept forever.
+	for k, e := range c.entries {
+		if now.Sub(e.countedAt) >= targetCountTTL {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cachedCount{n: n, countedAt: now}
+	return n, nil
+}
+
+// reset drops every cached count, e.g. after targets are deleted.
+func (c *countCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
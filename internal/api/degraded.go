@@ -0,0 +1,240 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// Degraded mode response headers. DegradedHeader carries the primary database's status
+// (read_only or unavailable) on every versioned response while it is not ok, or "stale" when
+// a cached target list is served for another reason. CachedAtHeader is when a cached target
+// list was stored.
+const (
+	DegradedHeader = "X-Linkwatch-Degraded"
+	CachedAtHeader = "X-Linkwatch-Cached-At"
+)
+
+const (
+	// healthCheckInterval is how long a database probe is reused by request handling.
+	healthCheckInterval = 5 * time.Second
+	// healthCheckTimeout bounds a probe so a hung database doesn't stall requests.
+	healthCheckTimeout = 2 * time.Second
+	// maxCachedTargetLists bounds how many distinct target list queries are kept for degraded mode.
+	maxCachedTargetLists = 64
+	// degradedRetryAfter is the Retry-After, in seconds, sent when writes are refused.
+	degradedRetryAfter = "30"
+)
+
+// DatabaseHealthChecker reports on the databases behind the store.
+type DatabaseHealthChecker interface {
+	DatabaseHealth(ctx context.Context) []models.DatabaseHealth
+}
+
+// WithDatabaseHealth enables database status in /readyz and degraded read-only mode: while
+// the primary database is read-only or unreachable, writes are refused with 503 and target
+// lists that fail are served from the last successful response for the same query.
+func WithDatabaseHealth(c DatabaseHealthChecker) Option {
+	return func(h *Handlers) { h.dbHealth = &dbHealthMonitor{checker: c} }
+}
+
+// dbHealthMonitor caches database probes for healthCheckInterval.
+type dbHealthMonitor struct {
+	checker   DatabaseHealthChecker
+	mu        sync.Mutex
+	checkedAt time.Time
+	health    []models.DatabaseHealth
+
+	// onChange is called with a database whose status differs from the previous probe.
+	onChange func(ctx context.Context, db models.DatabaseHealth, previous string)
+}
+
+// get returns the latest probe, refreshing it when it is older than healthCheckInterval or
+// when force is set.
+func (m *dbHealthMonitor) get(ctx context.Context, now time.Time, force bool) []models.DatabaseHealth {
+	m.mu.Lock()
+	if !force && !m.checkedAt.IsZero() && now.Sub(m.checkedAt) < healthCheckInterval {
+		defer m.mu.Unlock()
+		return m.health
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	previous := m.health
+	m.health, m.checkedAt = m.checker.DatabaseHealth(probeCtx), now
+	health := m.health
+	m.mu.Unlock()
+
+	// Changes are reported outside the lock, since recording one may wait on the database.
+	if m.onChange != nil {
+		for _, db := range health {
+			for _, p := range previous {
+				if p.Name == db.Name && p.Status != db.Status {
+					m.onChange(ctx, db, p.Status)
+				}
+			}
+		}
+	}
+	return health
+}
+
+// recordDatabaseChange records a database becoming degraded or healthy again. An event
+// about the primary going read-only or unavailable usually can't be stored, so the one
+// recording its recovery says what it recovered from.
+func (h *Handlers) recordDatabaseChange(ctx context.Context, db models.DatabaseHealth, previous string) {
+	event := &models.SystemEvent{
+		Type:    models.SystemEventDatabaseDegraded,
+		At:      h.clock.Now().UTC(),
+		Message: fmt.Sprintf("%s database is %s", db.Name, db.Status),
+		Details: map[string]string{"database": db.Name, "status": db.Status, "previous": previous},
+	}
+	if db.Error != "" {
+		event.Details["error"] = db.Error
+	}
+	if db.Status == models.DatabaseOK {
+		event.Type = models.SystemEventDatabaseRestored
+		event.Message = fmt.Sprintf("%s database recovered from %s", db.Name, previous)
+	}
+	if err := h.store.RecordSystemEvent(ctx, event); err != nil {
+		logging.API.Errorf("error recording %s event: %v", event.Type, err)
+	}
+}
+
+// primaryStatus returns the primary database's status, or ok when health isn't monitored.
+func (h *Handlers) primaryStatus(ctx context.Context) string {
+	if h.dbHealth == nil {
+		return models.DatabaseOK
+	}
+	for _, db := range h.dbHealth.get(ctx, h.clock.Now(), false) {
+		if db.Name == "primary" {
+			return db.Status
+		}
+	}
+	return models.DatabaseOK
+}
+
+// withDegradedMode marks responses while the primary database is degraded and refuses
+// requests that write, which would otherwise fail one by one with 500s.
+func (h *Handlers) withDegradedMode(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := h.primaryStatus(r.Context())
+		if status == models.DatabaseOK {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set(DegradedHeader, status)
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Retry-After", degradedRetryAfter)
+			http.Error(w, "database is "+status+"; writes are temporarily unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Readyz reports whether the server can serve traffic, with the status of each database and
+// of the checker. A degraded database still reports ready, since reads keep being served in
+// degraded mode, and so does a checker that isn't running, since the API doesn't need it.
+// Until startup completes (see WithStartup), it answers 503 with the startup phase. With
+// ?fail_degraded=true it answers 503 while degraded too, for monitors that only see the status
+// code, such as linkwatch checking itself.
+func (h *Handlers) Readyz(w http.ResponseWriter, r *http.Request) {
+	if h.startup != nil && !h.startup.Ready() {
+		writeStarting(w, h.startup.Phase())
+		return
+	}
+	resp := struct {
+		Status    string                  `json:"status"` // ok or degraded
+		Phase     string                  `json:"phase,omitempty"`
+		Databases []models.DatabaseHealth `json:"databases"`
+		Checker   *models.CheckerHealth   `json:"checker,omitempty"`
+	}{Status: "ok", Databases: []models.DatabaseHealth{}}
+	if h.startup != nil {
+		resp.Phase = models.StartupReady
+	}
+	if h.checker != nil {
+		health := h.checker.Health()
+		resp.Checker = &health
+		if health.Status != models.CheckerOK {
+			resp.Status = "degraded"
+		}
+	}
+	if h.dbHealth != nil {
+		resp.Databases = h.dbHealth.get(r.Context(), h.clock.Now(), true)
+	}
+	for _, db := range resp.Databases {
+		if db.Status != models.DatabaseOK {
+			resp.Status = "degraded"
+		}
+		if db.Name == "primary" && db.Status != models.DatabaseOK {
+			w.Header().Set(DegradedHeader, db.Status)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if failDegraded, _ := strconv.ParseBool(r.URL.Query().Get("fail_degraded")); failDegraded && resp.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// listCache keeps the last successful target list response per query, oldest evicted first.
+type listCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedList
+	order   []string
+}
+
+type cachedList struct {
+	body     []byte
+	cachedAt time.Time
+}
+
+func (c *listCache) put(key string, body []byte, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]cachedList)
+	}
+	if _, ok := c.entries[key]; !ok {
+		if len(c.order) == maxCachedTargetLists {
+			delete(c.entries, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = cachedList{body: body, cachedAt: now}
+}
+
+func (c *listCache) get(key string) (cachedList, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	return e, ok
+}
+
+// targetListKey identifies a target list query for the cache.
+func targetListKey(r *http.Request) string {
+	return apiVersion(r.Context()) + "?" + r.URL.RawQuery
+}
+
+// serveCachedTargetList writes the cached response for a target list query that failed,
+// reporting whether there was one.
+func (h *Handlers) serveCachedTargetList(w http.ResponseWriter, r *http.Request) bool {
+	e, ok := h.targetLists.get(targetListKey(r))
+	if !ok {
+		return false
+	}
+	if w.Header().Get(DegradedHeader) == "" {
+		w.Header().Set(DegradedHeader, "stale")
+	}
+	w.Header().Set(CachedAtHeader, e.cachedAt.UTC().Format(time.RFC3339))
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(e.body)
+	return true
+}
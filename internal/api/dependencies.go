@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// maxDependencies caps how many targets a target may depend on.
+const maxDependencies = 10
+
+// normalizeDependencies validates a target's dependencies, trimming and deduplicating their
+// IDs. A target can't depend on itself, and clearing the list normalizes to nil.
+func normalizeDependencies(targetID string, d *models.Dependencies) (*models.Dependencies, error) {
+	if d == nil {
+		return nil, nil
+	}
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range d.TargetIDs {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			return nil, errors.New("dependencies.target_ids must not contain empty IDs")
+		}
+		if id == targetID {
+			return nil, errors.New("a target can't depend on itself")
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > maxDependencies {
+		return nil, fmt.Errorf("dependencies.target_ids must not contain more than %d targets", maxDependencies)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return &models.Dependencies{TargetIDs: ids, Skip: d.Skip}, nil
+}
+
+// dependencyProblem checks that every dependency of targetID exists and that none of them
+// depends on targetID in turn, directly or through others, which would leave the scheduler
+// no target to check first. It returns a description of the problem, or an empty string.
+func (h *Handlers) dependencyProblem(ctx context.Context, targetID string, d *models.Dependencies) (string, error) {
+	if d == nil {
+		return "", nil
+	}
+	visited := map[string]bool{}
+	// reaches reports whether t depends on targetID. Deleted targets further down the chain
+	// no longer hold anything back, so they are skipped.
+	var reaches func(t *models.Target) (bool, error)
+	reaches = func(t *models.Target) (bool, error) {
+		if t.Dependencies == nil {
+			return false, nil
+		}
+		for _, id := range t.Dependencies.TargetIDs {
+			if id == targetID {
+				return true, nil
+			}
+			if visited[id] {
+				continue
+			}
+			visited[id] = true
+			next, err := h.store.GetTargetByID(ctx, id)
+			if errors.Is(err, storage.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return false, err
+			}
+			if found, err := reaches(next); found || err != nil {
+				return found, err
+			}
+		}
+		return false, nil
+	}
+	for _, id := range d.TargetIDs {
+		dep, err := h.store.GetTargetByID(ctx, id)
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Sprintf("dependency %s not found", id), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if cycle, err := reaches(dep); err != nil {
+			return "", err
+		} else if cycle {
+			return fmt.Sprintf("dependency %s depends on this target, which would form a cycle", id), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// DomainLister lists the registrations of the domains targets are on.
+type DomainLister interface {
+	ListDomains(ctx context.Context) ([]models.Domain, error)
+}
+
+// WithDomains serves the domain registrations d has looked up at GET /domains. Without it the
+// endpoint reports that domain monitoring is off.
+func WithDomains(d DomainLister) Option {
+	return func(h *Handlers) { h.domains = d }
+}
+
+// ListDomains handles listing the registrable domains of targets, with their registrar and
+// expiry date as last looked up over RDAP and whether they expire within the warning threshold.
+func (h *Handlers) ListDomains(w http.ResponseWriter, r *http.Request) {
+	if h.domains == nil {
+		http.Error(w, "domain monitoring is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	domains, err := h.domains.ListDomains(r.Context())
+	if err != nil {
+		h.internalError(w, r, "list domains error", err)
+		return
+	}
+	if domains == nil {
+		domains = []models.Domain{}
+	}
+	resp := struct {
+		Items []models.Domain `json:"items"`
+	}{Items: domains}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
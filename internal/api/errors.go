@@ -0,0 +1,195 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/urlutil"
+)
+
+// RequestIDHeader carries the request ID. A client-supplied ID is kept when it is well
+// formed; otherwise one is generated. It is echoed on every versioned response.
+const RequestIDHeader = "X-Request-ID"
+
+const (
+	// errorLogSize is how many recent internal errors are kept for lookup by request ID.
+	errorLogSize = 256
+	// maxRequestIDLength bounds client-supplied request IDs.
+	maxRequestIDLength = 128
+)
+
+// WithAdminToken sets the bearer token required by authenticated admin endpoints such as
+// the error log. Without one those endpoints are disabled.
+func WithAdminToken(token string) Option {
+	return func(h *Handlers) { h.adminToken = token }
+}
+
+type requestIDKey struct{}
+
+// withRequestID assigns the request its ID and echoes it in the response headers.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = generateID("req_")
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// validRequestID accepts IDs of printable ASCII without spaces, so they are safe to log
+// and echo.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// requestID returns the ID assigned to the request, if any.
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// withRecovery turns a panicking handler into a 500 carrying the request ID, recorded in the
+// error log like any other internal error, instead of a dropped connection. The stack is
+// logged. http.ErrAbortHandler is passed on, since it is how a handler aborts on purpose.
+func (h *Handlers) withRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			logging.API.Errorf("panic serving %s %s: %v\n%s", r.Method, r.URL.Path, p, debug.Stack())
+			h.internalError(w, r, "panic", fmt.Errorf("%v", p))
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// internalError logs err with the request ID, records it in the error log, and responds
+// with a 500 whose body carries the request ID for support to look up. A request that ran
+// past its deadline, which is the likely cause of err, gets a 503 timeout error instead.
+func (h *Handlers) internalError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	id := requestID(r.Context())
+	errMsg := h.redactor.Text(err.Error())
+	logging.API.Errorf("%s: %s (request %s)", msg, errMsg, id)
+	h.errorLog.add(models.RequestError{
+		RequestID: id,
+		At:        h.clock.Now().UTC(),
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Message:   msg,
+		Error:     errMsg,
+	})
+	if deadlineExceeded(r) {
+		timeoutError(w, r)
+		return
+	}
+	body := "internal server error"
+	if id != "" {
+		body += " (request_id: " + id + ")"
+	}
+	http.Error(w, body, http.StatusInternalServerError)
+}
+
+// errorLog is a ring buffer of recent internal errors.
+type errorLog struct {
+	mu      sync.Mutex
+	entries []models.RequestError
+	next    int
+}
+
+func (l *errorLog) add(e models.RequestError) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) < errorLogSize {
+		l.entries = append(l.entries, e)
+		return
+	}
+	l.entries[l.next] = e
+	l.next = (l.next + 1) % errorLogSize
+}
+
+// recent returns the logged errors newest first, optionally only those for one request.
+func (l *errorLog) recent(requestID string) []models.RequestError {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := []models.RequestError{}
+	for i := 0; i < len(l.entries); i++ {
+		// Walk backwards from the most recently written slot.
+		e := l.entries[(l.next-1-i+2*len(l.entries))%len(l.entries)]
+		if requestID == "" || e.RequestID == requestID {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// authorizeAdmin checks the request's bearer token against the admin token, writing an
+// error response and returning false when it doesn't match.
+func (h *Handlers) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if h.adminToken == "" {
+		http.Error(w, "admin token is not configured", http.StatusServiceUnavailable)
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="linkwatch"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// ListErrors handles looking up recent internal errors, newest first. With ?request_id=
+// it returns the errors for that request, or 404 if none are still logged.
+func (h *Handlers) ListErrors(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+	id := r.URL.Query().Get("request_id")
+	items := h.errorLog.recent(id)
+	if id != "" && len(items) == 0 {
+		http.Error(w, "no error logged for request "+id, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Items []models.RequestError `json:"items"`
+	}{items})
+}
+
+// invalidURLCode is the error code of a target URL rejected by the URL limits.
+const invalidURLCode = "invalid_url"
+
+// invalidURL responds with a 422 naming the rule the URL broke, so clients can tell a URL
+// that is too long from one with a malformed host without parsing the message.
+func invalidURL(w http.ResponseWriter, err *urlutil.ValidationError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(struct {
+		Error   string `json:"error"`
+		Rule    string `json:"rule"`
+		Message string `json:"message"`
+	}{invalidURLCode, err.Rule, err.Message})
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// ListSystemEvents handles listing what the service itself did, newest first: the checker
+// starting, stopping, and restarting, migrations, queue overflows, and database status
+// changes. Events are kept in the database, so they outlive the process's logs.
+func (h *Handlers) ListSystemEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	params := storage.ListSystemEventsParams{Type: q.Get("type"), Limit: 100}
+	if l := q.Get("limit"); l != "" {
+		v, err := strconv.Atoi(l)
+		if err != nil || v <= 0 || v > 1000 {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		params.Limit = v
+	}
+	var err error
+	if params.Since, params.Until, err = parseTimeRange(q); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, err := h.store.ListSystemEvents(r.Context(), params)
+	if err != nil {
+		h.internalError(w, r, "list system events error", err)
+		return
+	}
+
+	resp := struct {
+		Items []models.SystemEvent `json:"items"`
+	}{Items: events}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// Exec check limits, so a target's command line stays a command line.
+const (
+	maxExecArgs   = 32
+	maxExecArgLen = 1024
+)
+
+// WithExecChecks allows exec targets, whose commands must be executables in dir, the
+// directory the checker runs them from. Without it creating an exec target is refused.
+func WithExecChecks(dir string) Option {
+	return func(h *Handlers) { h.execDir = dir }
+}
+
+// normalizeExecCheck validates an exec target's command: a plain name of an executable file
+// in the exec directory, with a bounded number of arguments.
+func (h *Handlers) normalizeExecCheck(e *models.ExecCheck) (*models.ExecCheck, error) {
+	if h.execDir == "" {
+		return nil, errors.New("exec targets are disabled")
+	}
+	if e == nil || e.Command == "" {
+		return nil, errors.New("exec.command is required for exec targets")
+	}
+	if e.Command != filepath.Base(e.Command) || e.Command == "." || e.Command == ".." {
+		return nil, fmt.Errorf("invalid exec.command %q, expected the name of a command in the exec directory", e.Command)
+	}
+	info, err := os.Stat(filepath.Join(h.execDir, e.Command))
+	if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0o111 == 0 {
+		return nil, fmt.Errorf("exec.command %q is not an executable in the exec directory", e.Command)
+	}
+	if len(e.Args) > maxExecArgs {
+		return nil, fmt.Errorf("exec.args must not contain more than %d arguments", maxExecArgs)
+	}
+	for _, arg := range e.Args {
+		if len(arg) > maxExecArgLen {
+			return nil, fmt.Errorf("exec.args must not be longer than %d bytes each", maxExecArgLen)
+		}
+	}
+	return &models.ExecCheck{Command: e.Command, Args: e.Args}, nil
+}
@@ -1,31 +1,227 @@
 package api
 
 import (
+	"context"
 	"crypto/rand"
-	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
-	"linkwatch/internal/models"
-	"linkwatch/internal/storage"
-	"linkwatch/internal/urlutil"
+	"github.com/zeng-yichen/linkwatch/internal/crawler"
+	"github.com/zeng-yichen/linkwatch/internal/discovery"
+	"github.com/zeng-yichen/linkwatch/internal/jobs"
+	"github.com/zeng-yichen/linkwatch/internal/report"
+	"github.com/zeng-yichen/linkwatch/internal/statecache"
+	"github.com/zeng-yichen/linkwatch/pkg/clock"
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/metrics"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/notify"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+	"github.com/zeng-yichen/linkwatch/pkg/urlutil"
 )
 
 // Handlers holds dependencies for the API handlers.
 type Handlers struct {
-	store storage.Storer
+	store       storage.Storer
+	reporter    *report.Reporter
+	notifier    notify.Notifier
+	publisher   notify.ResultSink
+	states      *statecache.Cache
+	discoverer  *discovery.Discoverer
+	crawler     *crawler.Crawler
+	jobs        *jobs.Manager
+	queue       QueueInspector
+	workers     WorkerScaler
+	hosts       HostInspector
+	checks      CheckTrigger
+	checker     CheckerHealthReporter
+	archive     ArchiveReader
+	domains     DomainLister
+	maintainer  storage.DatabaseMaintainer
+	clientCerts storage.ClientCertStore // Nil refuses client certificates
+	startup     *Startup
+	keepalive   time.Duration // Non-zero when results are stored only on change
+	clock       clock.Clock
+	redactor    *urlutil.Redactor
+	urlLimits   urlutil.Limits
+	execDir     string // Directory of exec target commands; empty refuses exec targets
+	wasmDir     string // Directory of wasm target modules; empty refuses wasm targets
+
+	dbHealth    *dbHealthMonitor // Set when database health is monitored
+	targetLists listCache        // Last good target list responses, for degraded mode
+	counts      countCache       // Recent target counts, for include_total
+	errorLog    errorLog         // Recent internal errors, by request ID
+	adminToken  string           // Bearer token for authenticated admin endpoints
+	pageTokens  pageTokenSigner
+	strictQuery bool // Reject unknown and malformed query parameters
+
+	degradedLatency time.Duration
+	deprecations    map[string]Deprecation // Keyed by API version
+	middleware      []Middleware           // Wraps the whole router, see WithMiddleware
+	timeouts        *RequestTimeouts       // Nil leaves requests without a deadline
+}
+
+// defaultDegradedLatency is the latency at which a passing target counts as degraded.
+const defaultDegradedLatency = time.Second
+
+// Job types run through the jobs manager.
+const (
+	jobTypeCrawl       = "crawl"
+	jobTypeDiscovery   = "discovery"
+	jobTypeMaintenance = "database_maintenance"
+)
+
+// Option configures optional Handlers dependencies.
+type Option func(*Handlers)
+
+// WithReporter enables the on-demand report endpoint.
+func WithReporter(r *report.Reporter) Option {
+	return func(h *Handlers) { h.reporter = r }
+}
+
+// WithNotifier sets the notifier used for alerts raised by the API (e.g. heartbeat recovery).
+func WithNotifier(n notify.Notifier) Option {
+	return func(h *Handlers) { h.notifier = n }
+}
+
+// WithResultPublisher sets the sink that receives check results recorded by the API (heartbeat pings).
+func WithResultPublisher(p notify.ResultSink) Option {
+	return func(h *Handlers) { h.publisher = p }
+}
+
+// WithStateCache sets the cache used to attach each target's latest state to list responses.
+func WithStateCache(c *statecache.Cache) Option {
+	return func(h *Handlers) { h.states = c }
+}
+
+// WithSparseResults tells the API that results are stored only on change, with a keepalive
+// result at least every keepalive interval. Timeseries responses then carry the last stored
+// bucket forward over gaps of up to keepalive, so charts show continuous data.
+func WithSparseResults(keepalive time.Duration) Option {
+	return func(h *Handlers) { h.keepalive = keepalive }
+}
+
+// WithDegradedLatency sets the latency at or above which a passing target sorts as degraded
+// when listing targets by health.
+func WithDegradedLatency(d time.Duration) Option {
+	return func(h *Handlers) {
+		if d > 0 {
+			h.degradedLatency = d
+		}
+	}
+}
+
+// WithDiscoverer overrides the discoverer used by the sitemap discovery endpoint.
+func WithDiscoverer(d *discovery.Discoverer) Option {
+	return func(h *Handlers) { h.discoverer = d }
+}
+
+// WithCrawler overrides the crawler used by the broken-link crawl endpoint.
+func WithCrawler(c *crawler.Crawler) Option {
+	return func(h *Handlers) { h.crawler = c }
+}
+
+// WithJobManager sets the manager that runs background jobs such as crawls and async discovery.
+func WithJobManager(m *jobs.Manager) Option {
+	return func(h *Handlers) { h.jobs = m }
+}
+
+// QueueInspector reports on the checker's job queue.
+type QueueInspector interface {
+	QueueStats() models.QueueStats
+}
+
+// WithQueue enables the queue admin endpoint.
+func WithQueue(q QueueInspector) Option {
+	return func(h *Handlers) { h.queue = q }
+}
+
+// WorkerScaler reports on and resizes the checker's worker pool.
+type WorkerScaler interface {
+	WorkerStats() models.WorkerStats
+	ResizeWorkers(n int) error
+}
+
+// WithWorkers enables the worker pool admin endpoints.
+func WithWorkers(s WorkerScaler) Option {
+	return func(h *Handlers) { h.workers = s }
+}
+
+// CheckTrigger queues checks outside the checker's schedule.
+type CheckTrigger interface {
+	CheckNow(target models.Target) bool
+}
+
+// WithInitialChecks makes target creation queue the new target's first check right away
+// instead of leaving it to the next scheduling pass.
+func WithInitialChecks(t CheckTrigger) Option {
+	return func(h *Handlers) { h.checks = t }
+}
+
+// CheckerHealthReporter reports on the background checker.
+type CheckerHealthReporter interface {
+	Health() models.CheckerHealth
+}
+
+// WithCheckerHealth adds the checker's health to /readyz.
+func WithCheckerHealth(c CheckerHealthReporter) Option {
+	return func(h *Handlers) { h.checker = c }
+}
+
+// WithStartup adds the startup phase to /readyz, which answers 503 until it is ready.
+func WithStartup(s *Startup) Option {
+	return func(h *Handlers) { h.startup = s }
+}
+
+// WithClock sets the time source for heartbeat deadlines and report windows. It defaults to
+// the system clock.
+func WithClock(c clock.Clock) Option {
+	return func(h *Handlers) { h.clock = c }
+}
+
+// WithRedactor sets how credentials are stripped from URLs in logged and recorded errors. By
+// default userinfo and the urlutil.DefaultSensitiveParams query parameters are.
+func WithRedactor(r *urlutil.Redactor) Option {
+	return func(h *Handlers) { h.redactor = r }
+}
+
+// WithURLLimits sets the limits target URLs must meet, urlutil.DefaultLimits by default.
+func WithURLLimits(l urlutil.Limits) Option {
+	return func(h *Handlers) { h.urlLimits = l }
 }
 
 // NewHandlers creates a new Handlers struct.
-func NewHandlers(store storage.Storer) *Handlers {
-	return &Handlers{store: store}
+func NewHandlers(store storage.Storer, opts ...Option) *Handlers {
+	client := &http.Client{Timeout: 10 * time.Second}
+	h := &Handlers{
+		store:      store,
+		notifier:   notify.LogNotifier{},
+		states:     statecache.New(store, 0, metrics.Nop{}),
+		discoverer: discovery.New(client, 500),
+		crawler:    crawler.New(client, 500),
+		jobs:       jobs.NewManager(store),
+		clock:      clock.Real,
+		pageTokens: newPageTokenSigner(),
+		redactor:   urlutil.NewRedactor(nil),
+		urlLimits:  urlutil.DefaultLimits,
+
+		degradedLatency: defaultDegradedLatency,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.dbHealth != nil {
+		h.dbHealth.onChange = h.recordDatabaseChange
+	}
+	return h
 }
 
 func generateID(prefix string) string {
@@ -36,173 +232,1947 @@ func generateID(prefix string) string {
 	return prefix + hex.EncodeToString(b)
 }
 
+// maxCaptureHeaders caps how many response headers a target may capture.
+const maxCaptureHeaders = 10
+
+// validHeaderName reports whether name is a valid HTTP header field name (an RFC 7230 token).
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeCaptureHeaders validates header names and returns them canonicalized and deduplicated.
+func normalizeCaptureHeaders(names []string) ([]string, error) {
+	var headers []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if !validHeaderName(name) {
+			return nil, fmt.Errorf("invalid header name %q", name)
+		}
+		name = http.CanonicalHeaderKey(name)
+		if !seen[name] {
+			seen[name] = true
+			headers = append(headers, name)
+		}
+	}
+	if len(headers) > maxCaptureHeaders {
+		return nil, fmt.Errorf("capture_headers must not contain more than %d headers", maxCaptureHeaders)
+	}
+	return headers, nil
+}
+
+// normalizeStatusPolicy validates a status policy's rules, lowercasing classes and dropping
+// duplicates. A rule may not appear in more than one list. An empty policy normalizes to nil.
+func normalizeStatusPolicy(p *models.StatusPolicy) (*models.StatusPolicy, error) {
+	if p == nil {
+		return nil, nil
+	}
+	seen := make(map[string]string)
+	normalize := func(outcome string, rules []string) ([]string, error) {
+		var out []string
+		for _, rule := range rules {
+			rule = strings.ToLower(strings.TrimSpace(rule))
+			if len(rule) != 3 || rule[0] < '1' || rule[0] > '5' ||
+				!(rule[1:] == "xx" || (rule[1] >= '0' && rule[1] <= '9' && rule[2] >= '0' && rule[2] <= '9')) {
+				return nil, fmt.Errorf("invalid status rule %q, expected a code like 401 or a class like 4xx", rule)
+			}
+			if prev, ok := seen[rule]; ok {
+				if prev != outcome {
+					return nil, fmt.Errorf("status rule %q is listed as both %s and %s", rule, prev, outcome)
+				}
+				continue
+			}
+			seen[rule] = outcome
+			out = append(out, rule)
+		}
+		return out, nil
+	}
+	var policy models.StatusPolicy
+	var err error
+	if policy.Success, err = normalize(models.OutcomeSuccess, p.Success); err != nil {
+		return nil, err
+	}
+	if policy.Warning, err = normalize(models.OutcomeWarning, p.Warning); err != nil {
+		return nil, err
+	}
+	if policy.Failure, err = normalize(models.OutcomeFailure, p.Failure); err != nil {
+		return nil, err
+	}
+	if len(seen) == 0 {
+		return nil, nil
+	}
+	return &policy, nil
+}
+
+// Metadata limits, so labels stay labels rather than a document store.
+const (
+	maxMetadataEntries  = 20
+	maxMetadataKeyLen   = 64
+	maxMetadataValueLen = 256
+)
+
+// validMetadataKey reports whether key is usable as a metadata key: letters, digits, and
+// "_", "-", or ".". Keys appear in metadata.<key> query parameters.
+func validMetadataKey(key string) bool {
+	if key == "" || len(key) > maxMetadataKeyLen {
+		return false
+	}
+	for _, c := range key {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '-', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeMetadata validates a target's metadata. An empty map normalizes to nil.
+func normalizeMetadata(metadata map[string]string) (map[string]string, error) {
+	if len(metadata) > maxMetadataEntries {
+		return nil, fmt.Errorf("metadata must not contain more than %d entries", maxMetadataEntries)
+	}
+	for k, v := range metadata {
+		if !validMetadataKey(k) {
+			return nil, fmt.Errorf("invalid metadata key %q, expected up to %d letters, digits, or _ - .", k, maxMetadataKeyLen)
+		}
+		if len(v) > maxMetadataValueLen {
+			return nil, fmt.Errorf("metadata value for %q must not be longer than %d bytes", k, maxMetadataValueLen)
+		}
+	}
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	return metadata, nil
+}
+
+// parseMetadataFilter collects metadata.<key>=<value> query parameters.
+func parseMetadataFilter(q url.Values) (map[string]string, error) {
+	var filter map[string]string
+	for param, values := range q {
+		key, ok := strings.CutPrefix(param, "metadata.")
+		if !ok {
+			continue
+		}
+		if !validMetadataKey(key) {
+			return nil, fmt.Errorf("invalid metadata key %q", key)
+		}
+		if filter == nil {
+			filter = make(map[string]string)
+		}
+		filter[key] = values[0]
+	}
+	return filter, nil
+}
+
+// maxSampleRate bounds result_sampling.keep_one_in, and minSamplingWindow its keep_all_seconds.
+const (
+	maxSampleRate     = 10000
+	minSamplingWindow = time.Hour
+)
+
+// normalizeResultSampling validates a result sampling policy. Keeping one in one keeps every
+// result, the same as no policy.
+func normalizeResultSampling(p *models.ResultSampling) (*models.ResultSampling, error) {
+	if p == nil {
+		return nil, nil
+	}
+	if p.KeepOneIn < 1 || p.KeepOneIn > maxSampleRate {
+		return nil, fmt.Errorf("result_sampling.keep_one_in must be between 1 and %d", maxSampleRate)
+	}
+	if p.KeepAllSeconds != 0 && (p.KeepAllSeconds < 0 || time.Duration(p.KeepAllSeconds)*time.Second < minSamplingWindow) {
+		return nil, fmt.Errorf("result_sampling.keep_all_seconds must be at least %d", int64(minSamplingWindow/time.Second))
+	}
+	if p.KeepOneIn == 1 {
+		return nil, nil
+	}
+	return &models.ResultSampling{KeepOneIn: p.KeepOneIn, KeepAllSeconds: p.KeepAllSeconds}, nil
+}
+
+// maxTimeoutBudget bounds a target's timeout_budget.
+const maxTimeoutBudget = 10 * time.Minute
+
+// parseTimeoutBudget parses a timeout_budget duration; zero restores the checker's budget.
+func parseTimeoutBudget(raw string) (time.Duration, error) {
+	v, err := parseDuration(raw)
+	if err != nil || v < 0 || v > maxTimeoutBudget {
+		return 0, fmt.Errorf("timeout_budget must be a duration between 0s and %s", maxTimeoutBudget)
+	}
+	return v, nil
+}
+
+// maxLatencyThreshold bounds a target's latency_threshold.
+const maxLatencyThreshold = 10 * time.Minute
+
+// parseLatencyThreshold parses a latency_threshold duration; zero removes the threshold.
+func parseLatencyThreshold(raw string) (time.Duration, error) {
+	v, err := parseDuration(raw)
+	if err != nil || v < 0 || v > maxLatencyThreshold {
+		return 0, fmt.Errorf("latency_threshold must be a duration between 0s and %s", maxLatencyThreshold)
+	}
+	return v, nil
+}
+
+// defaultHeartbeatGrace is the grace period used when a heartbeat target doesn't specify one.
+const defaultHeartbeatGrace = 5 * time.Minute
+
 // CreateTarget handles the creation of a new target.
 func (h *Handlers) CreateTarget(w http.ResponseWriter, r *http.Request) {
 	// 1. Parse request body
 	var reqBody struct {
-		URL string `json:"url"`
+		URL            string                 `json:"url"`
+		Type           string                 `json:"type"`
+		GracePeriod    string                 `json:"grace_period"`
+		CaptureHeaders []string               `json:"capture_headers"`
+		StatusPolicy   *models.StatusPolicy   `json:"status_policy"`
+		TimeoutBudget  string                 `json:"timeout_budget"`
+		Metadata       map[string]string      `json:"metadata"`
+		ResultSampling *models.ResultSampling `json:"result_sampling"`
+		Threshold      string                 `json:"latency_threshold"`
+		Exec           *models.ExecCheck      `json:"exec"`
+		Wasm           *models.WasmCheck      `json:"wasm"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
 		http.Error(w, "invalid request body", http.StatusBadRequest)
 		return
 	}
-
-	// 2. Canonicalize URL
-	canonicalURL, err := urlutil.Canonicalize(reqBody.URL)
+	metadata, err := normalizeMetadata(reqBody.Metadata)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	captureHeaders, err := normalizeCaptureHeaders(reqBody.CaptureHeaders)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	statusPolicy, err := normalizeStatusPolicy(reqBody.StatusPolicy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sampling, err := normalizeResultSampling(reqBody.ResultSampling)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	var budget time.Duration
+	if reqBody.TimeoutBudget != "" {
+		if budget, err = parseTimeoutBudget(reqBody.TimeoutBudget); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	var threshold time.Duration
+	if reqBody.Threshold != "" {
+		if threshold, err = parseLatencyThreshold(reqBody.Threshold); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 
-	// 3. Parse URL to get host
-	parsedURL, _ := url.Parse(canonicalURL)
+	if reqBody.Exec != nil && reqBody.Type != models.TargetTypeExec {
+		http.Error(w, "exec is only supported for exec targets", http.StatusBadRequest)
+		return
+	}
+	if reqBody.Wasm != nil && reqBody.Type != models.TargetTypeWasm {
+		http.Error(w, "wasm is only supported for wasm targets", http.StatusBadRequest)
+		return
+	}
 
-	// 4. Create target
-	target := &models.Target{
-		ID:           generateID("t_"),
-		URL:          reqBody.URL,
-		CanonicalURL: canonicalURL,
-		Host:         parsedURL.Hostname(),
-		CreatedAt:    time.Now().UTC(),
+	var target *models.Target
+	switch reqBody.Type {
+	case "", models.TargetTypeHTTP:
+		// 2. Check the URL's limits, then canonicalize it and build the target
+		var invalid *urlutil.ValidationError
+		if errors.As(h.urlLimits.Check(reqBody.URL), &invalid) {
+			invalidURL(w, invalid)
+			return
+		}
+		if target, err = models.NewHTTPTarget(reqBody.URL); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		target.CaptureHeaders = captureHeaders
+		target.StatusPolicy = statusPolicy
+		target.TimeoutBudgetMS = budget.Milliseconds()
+		target.ResultSampling = sampling
+		target.LatencyThresholdMS = threshold.Milliseconds()
+	case models.TargetTypeExec, models.TargetTypeWasm:
+		if len(captureHeaders) > 0 {
+			http.Error(w, "capture_headers is only supported for http targets", http.StatusBadRequest)
+			return
+		}
+		if statusPolicy != nil {
+			http.Error(w, "status_policy is only supported for http targets", http.StatusBadRequest)
+			return
+		}
+		if reqBody.Type == models.TargetTypeExec {
+			check, err := h.normalizeExecCheck(reqBody.Exec)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			target = models.NewExecTarget(*check)
+		} else {
+			check, err := h.normalizeWasmCheck(reqBody.Wasm)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			target = models.NewWasmTarget(*check)
+		}
+		target.CreatedAt = h.clock.Now().UTC()
+		target.TimeoutBudgetMS = budget.Milliseconds()
+		target.ResultSampling = sampling
+		target.LatencyThresholdMS = threshold.Milliseconds()
+	case models.TargetTypeHeartbeat:
+		if len(captureHeaders) > 0 {
+			http.Error(w, "capture_headers is only supported for http targets", http.StatusBadRequest)
+			return
+		}
+		if statusPolicy != nil {
+			http.Error(w, "status_policy is only supported for http targets", http.StatusBadRequest)
+			return
+		}
+		if budget > 0 {
+			http.Error(w, "timeout_budget is not supported for heartbeat targets", http.StatusBadRequest)
+			return
+		}
+		if sampling != nil {
+			http.Error(w, "result_sampling is not supported for heartbeat targets", http.StatusBadRequest)
+			return
+		}
+		if threshold > 0 {
+			http.Error(w, "latency_threshold is not supported for heartbeat targets", http.StatusBadRequest)
+			return
+		}
+		grace := defaultHeartbeatGrace
+		if reqBody.GracePeriod != "" {
+			v, err := parseDuration(reqBody.GracePeriod)
+			if err != nil || v < time.Second {
+				http.Error(w, "grace_period must be a duration of at least 1s", http.StatusBadRequest)
+				return
+			}
+			grace = v
+		}
+		// Heartbeat targets have no URL to check; they are identified by their ping path.
+		token := generateID("hb_")
+		target = &models.Target{
+			ID:                 generateID("t_"),
+			URL:                "/" + apiVersion(r.Context()) + "/heartbeats/" + token,
+			CanonicalURL:       "heartbeat:" + token,
+			CreatedAt:          h.clock.Now().UTC(),
+			Type:               models.TargetTypeHeartbeat,
+			HeartbeatToken:     token,
+			GracePeriodSeconds: int64(grace / time.Second),
+		}
+	default:
+		http.Error(w, "type must be one of: http, heartbeat, exec, wasm", http.StatusBadRequest)
+		return
 	}
+	target.Metadata = metadata
 
-	// 5. Handle idempotency key
+	// 3. Handle idempotency key
 	idempotencyKey := r.Header.Get("Idempotency-Key")
 	var keyPtr *string
 	if idempotencyKey != "" {
 		keyPtr = &idempotencyKey
 	}
 
-	// 6. Create the target
+	// 4. Create the target
 	createdTarget, err := h.store.CreateTarget(r.Context(), target, keyPtr)
 	if err != nil && !errors.Is(err, storage.ErrDuplicateKey) {
-		log.Printf("error creating target: %v", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+		h.internalError(w, r, "error creating target", err)
 		return
 	}
 
-	// 7. Set the status code
+	// 5. Set the status code
 	statusCode := http.StatusCreated
 	if errors.Is(err, storage.ErrDuplicateKey) {
 		statusCode = http.StatusOK
+	} else {
+		h.states.Invalidate(createdTarget.ID)
+		if h.checks != nil {
+			h.checks.CheckNow(*createdTarget)
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(createdTarget)
+	json.NewEncoder(w).Encode(versionedTarget(r.Context(), createdTarget))
 }
 
-// ListTargets handles listing targets with pagination.
-func (h *Handlers) ListTargets(w http.ResponseWriter, r *http.Request) {
+// DeleteTargets deletes every target matching the host and metadata filters of ListTargets,
+// with their results and history, in one transaction. At least one filter is required, and
+// so is either confirm=true or dry_run=true, which only counts the matching targets.
+func (h *Handlers) DeleteTargets(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
-	limit := 50
-	if l := q.Get("limit"); l != "" {
-		if v, err := strconv.Atoi(l); err == nil && v > 0 && v <= 500 {
-			limit = v
+	host := strings.ToLower(strings.TrimSpace(q.Get("host")))
+	metadata, err := parseMetadataFilter(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if host == "" && len(metadata) == 0 {
+		http.Error(w, "host or a metadata filter is required", http.StatusBadRequest)
+		return
+	}
+	var confirm, dryRun bool
+	if v := q.Get("confirm"); v != "" {
+		if confirm, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "confirm must be true or false", http.StatusBadRequest)
+			return
 		}
 	}
-	// host filter (case-insensitive)
-	host := strings.ToLower(strings.TrimSpace(q.Get("host")))
-
-	var afterTime time.Time
-	var afterID string
-	if token := q.Get("page_token"); token != "" {
-		// token is base64 of "<rfc3339nano>|<id>"
-		if decoded, err := base64.URLEncoding.DecodeString(token); err == nil {
-			parts := strings.SplitN(string(decoded), "|", 2)
-			if len(parts) == 2 {
-				if t, err := time.Parse(time.RFC3339Nano, parts[0]); err == nil {
-					afterTime = t
-					afterID = parts[1]
-				}
-			}
+	if v := q.Get("dry_run"); v != "" {
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "dry_run must be true or false", http.StatusBadRequest)
+			return
 		}
 	}
-
-	items, err := h.store.ListTargets(r.Context(), storage.ListTargetsParams{
-		Host:      host,
-		AfterTime: afterTime,
-		AfterID:   afterID,
-		Limit:     limit,
-	})
-	if err != nil {
-		log.Printf("list targets error: %v", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+	if !confirm && !dryRun {
+		http.Error(w, "confirm=true or dry_run=true is required", http.StatusBadRequest)
 		return
 	}
 
 	resp := struct {
-		Items         []models.Target `json:"items"`
-		NextPageToken string          `json:"next_page_token"`
-	}{
-		Items: items,
-	}
-
-	if len(items) == limit {
-		last := items[len(items)-1]
-		cursor := last.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + last.ID
-		resp.NextPageToken = base64.URLEncoding.EncodeToString([]byte(cursor))
+		Matched int  `json:"matched"`
+		Deleted int  `json:"deleted"`
+		DryRun  bool `json:"dry_run"`
+	}{DryRun: dryRun}
+	if dryRun {
+		if resp.Matched, err = h.store.CountTargets(r.Context(), host, metadata); err != nil {
+			h.internalError(w, r, "count targets error", err)
+			return
+		}
+	} else {
+		ids, err := h.store.DeleteTargets(r.Context(), host, metadata)
+		if err != nil {
+			h.internalError(w, r, "delete targets error", err)
+			return
+		}
+		for _, id := range ids {
+			h.states.Invalidate(id)
+		}
+		h.counts.reset()
+		resp.Matched, resp.Deleted = len(ids), len(ids)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
-// ListCheckResults handles listing check results for a target.
-func (h *Handlers) ListCheckResults(w http.ResponseWriter, r *http.Request) {
-	// path: /v1/targets/{target_id}/results
-	path := r.URL.Path
-	parts := strings.Split(path, "/")
-	if len(parts) < 5 {
-		http.Error(w, "not found", http.StatusNotFound)
+// UpdateTarget handles changing a target's settings. Only capture_headers, status_policy,
+// timeout_budget, metadata, result_sampling, latency_threshold, dependencies, schedule,
+// security_audit, asset_check, and min_tls_version can be changed; fields left out of the
+// request are kept, and the rest are written together or not at all.
+func (h *Handlers) UpdateTarget(w http.ResponseWriter, r *http.Request) {
+	var reqBody struct {
+		CaptureHeaders *[]string       `json:"capture_headers"`
+		StatusPolicy   json.RawMessage `json:"status_policy"`
+		TimeoutBudget  *string         `json:"timeout_budget"`
+		Metadata       json.RawMessage `json:"metadata"`
+		ResultSampling json.RawMessage `json:"result_sampling"`
+		Threshold      *string         `json:"latency_threshold"`
+		Dependencies   json.RawMessage `json:"dependencies"`
+		Schedule       json.RawMessage `json:"schedule"`
+		SecurityAudit  json.RawMessage `json:"security_audit"`
+		AssetCheck     json.RawMessage `json:"asset_check"`
+		MinTLSVersion  *string         `json:"min_tls_version"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
 		return
 	}
-	targetID := parts[3]
-
-	// ensure target exists
-	if _, err := h.store.GetTargetByID(r.Context(), targetID); err != nil {
-		if errors.Is(err, storage.ErrNotFound) {
-			http.Error(w, "target not found", http.StatusNotFound)
+	if reqBody.CaptureHeaders == nil && reqBody.StatusPolicy == nil && reqBody.TimeoutBudget == nil && reqBody.Metadata == nil && reqBody.ResultSampling == nil && reqBody.Threshold == nil && reqBody.Dependencies == nil && reqBody.Schedule == nil && reqBody.SecurityAudit == nil && reqBody.AssetCheck == nil && reqBody.MinTLSVersion == nil {
+		http.Error(w, "capture_headers, status_policy, timeout_budget, metadata, result_sampling, latency_threshold, dependencies, schedule, security_audit, asset_check, or min_tls_version is required", http.StatusBadRequest)
+		return
+	}
+	var metadata map[string]string
+	if reqBody.Metadata != nil {
+		// Metadata is replaced as a whole; null clears it.
+		if err := json.Unmarshal(reqBody.Metadata, &metadata); err != nil {
+			http.Error(w, "metadata must be an object of string values", http.StatusBadRequest)
+			return
+		}
+		var err error
+		if metadata, err = normalizeMetadata(metadata); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		log.Printf("get target error: %v", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
 	}
-
-	q := r.URL.Query()
-	limit := 100
-	if l := q.Get("limit"); l != "" {
-		if v, err := strconv.Atoi(l); err == nil && v > 0 && v <= 1000 {
-			limit = v
+	var headers []string
+	if reqBody.CaptureHeaders != nil {
+		var err error
+		if headers, err = normalizeCaptureHeaders(*reqBody.CaptureHeaders); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 	}
-
-	var sincePtr *time.Time
-	if s := q.Get("since"); s != "" {
-		if t, err := time.Parse(time.RFC3339, s); err == nil {
-			utc := t.UTC()
-			sincePtr = &utc
+	var policy *models.StatusPolicy
+	if reqBody.StatusPolicy != nil {
+		// A null policy restores the default classification.
+		if err := json.Unmarshal(reqBody.StatusPolicy, &policy); err != nil {
+			http.Error(w, "invalid status_policy", http.StatusBadRequest)
+			return
+		}
+		var err error
+		if policy, err = normalizeStatusPolicy(policy); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 	}
-
-	results, err := h.store.ListCheckResultsByTargetID(r.Context(), storage.ListCheckResultsParams{
-		TargetID: targetID,
-		Since:    sincePtr,
-		Limit:    limit,
-	})
-	if err != nil {
-		log.Printf("list results error: %v", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
+	var sampling *models.ResultSampling
+	if reqBody.ResultSampling != nil {
+		// A null policy keeps every result again.
+		if err := json.Unmarshal(reqBody.ResultSampling, &sampling); err != nil {
+			http.Error(w, "invalid result_sampling", http.StatusBadRequest)
+			return
+		}
+		var err error
+		if sampling, err = normalizeResultSampling(sampling); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 	}
 
-	resp := struct {
-		Items []models.CheckResult `json:"items"`
-	}{Items: results}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	var schedule *models.CheckSchedule
+	if reqBody.Schedule != nil {
+		// A null schedule checks the target around the clock again.
+		if err := json.Unmarshal(reqBody.Schedule, &schedule); err != nil {
+			http.Error(w, "invalid schedule", http.StatusBadRequest)
+			return
+		}
+		var err error
+		if schedule, err = normalizeSchedule(schedule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	var audit *models.SecurityAudit
+	if reqBody.SecurityAudit != nil {
+		// A null audit turns it off.
+		if err := json.Unmarshal(reqBody.SecurityAudit, &audit); err != nil {
+			http.Error(w, "invalid security_audit", http.StatusBadRequest)
+			return
+		}
+		var err error
+		if audit, err = normalizeSecurityAudit(audit); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	var assets *models.AssetCheck
+	if reqBody.AssetCheck != nil {
+		// A null check turns it off.
+		if err := json.Unmarshal(reqBody.AssetCheck, &assets); err != nil {
+			http.Error(w, "invalid asset_check", http.StatusBadRequest)
+			return
+		}
+		var err error
+		if assets, err = normalizeAssetCheck(assets); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	// An empty version restores the checker's minimum.
+	if v := reqBody.MinTLSVersion; v != nil && *v != "" && !slices.Contains(models.TLSVersions, *v) {
+		http.Error(w, fmt.Sprintf("min_tls_version must be one of: %s", strings.Join(models.TLSVersions, ", ")), http.StatusBadRequest)
+		return
+	}
+
+	var budget time.Duration
+	if reqBody.TimeoutBudget != nil {
+		var err error
+		if budget, err = parseTimeoutBudget(*reqBody.TimeoutBudget); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	var threshold time.Duration
+	if reqBody.Threshold != nil {
+		var err error
+		if threshold, err = parseLatencyThreshold(*reqBody.Threshold); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	targetID := r.PathValue("target_id")
+	var deps *models.Dependencies
+	if reqBody.Dependencies != nil {
+		// A null list makes the target independent again.
+		if err := json.Unmarshal(reqBody.Dependencies, &deps); err != nil {
+			http.Error(w, "invalid dependencies", http.StatusBadRequest)
+			return
+		}
+		var err error
+		if deps, err = normalizeDependencies(targetID, deps); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	target, err := h.store.GetTargetByID(r.Context(), targetID)
+	if err == nil && reqBody.Dependencies != nil {
+		var problem string
+		if problem, err = h.dependencyProblem(r.Context(), targetID, deps); problem != "" {
+			http.Error(w, problem, http.StatusBadRequest)
+			return
+		}
+	}
+	if err == nil && target.Type != models.TargetTypeHTTP {
+		if len(headers) > 0 {
+			http.Error(w, "capture_headers is only supported for http targets", http.StatusBadRequest)
+			return
+		}
+		if policy != nil {
+			http.Error(w, "status_policy is only supported for http targets", http.StatusBadRequest)
+			return
+		}
+		if audit != nil {
+			http.Error(w, "security_audit is only supported for http targets", http.StatusBadRequest)
+			return
+		}
+		if assets != nil {
+			http.Error(w, "asset_check is only supported for http targets", http.StatusBadRequest)
+			return
+		}
+		if reqBody.MinTLSVersion != nil && *reqBody.MinTLSVersion != "" {
+			http.Error(w, "min_tls_version is only supported for http targets", http.StatusBadRequest)
+			return
+		}
+	}
+	if err == nil && target.Type == models.TargetTypeHeartbeat {
+		if budget > 0 {
+			http.Error(w, "timeout_budget is not supported for heartbeat targets", http.StatusBadRequest)
+			return
+		}
+		if sampling != nil {
+			http.Error(w, "result_sampling is not supported for heartbeat targets", http.StatusBadRequest)
+			return
+		}
+		if threshold > 0 {
+			http.Error(w, "latency_threshold is not supported for heartbeat targets", http.StatusBadRequest)
+			return
+		}
+	}
+	if err == nil {
+		var patch storage.TargetPatch
+		if reqBody.CaptureHeaders != nil {
+			patch.CaptureHeaders = storage.To(headers)
+		}
+		if reqBody.StatusPolicy != nil {
+			patch.StatusPolicy = storage.To(policy)
+		}
+		if reqBody.TimeoutBudget != nil {
+			patch.TimeoutBudget = storage.To(budget)
+		}
+		if reqBody.Threshold != nil {
+			patch.LatencyThreshold = storage.To(threshold)
+		}
+		if reqBody.Metadata != nil {
+			patch.Metadata = storage.To(metadata)
+		}
+		if reqBody.ResultSampling != nil {
+			patch.ResultSampling = storage.To(sampling)
+		}
+		if reqBody.Dependencies != nil {
+			patch.Dependencies = storage.To(deps)
+		}
+		if reqBody.Schedule != nil {
+			patch.Schedule = storage.To(schedule)
+		}
+		if reqBody.SecurityAudit != nil {
+			patch.SecurityAudit = storage.To(audit)
+		}
+		if reqBody.AssetCheck != nil {
+			patch.AssetCheck = storage.To(assets)
+		}
+		if reqBody.MinTLSVersion != nil {
+			patch.MinTLSVersion = storage.To(*reqBody.MinTLSVersion)
+		}
+		target, err = h.store.UpdateTarget(r.Context(), targetID, patch)
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "target not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.internalError(w, r, "update target error", err)
+		return
+	}
+	h.states.Invalidate(targetID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versionedTarget(r.Context(), target))
+}
+
+// ListTargets handles listing targets with pagination.
+func (h *Handlers) ListTargets(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := 50
+	if l := q.Get("limit"); l != "" {
+		if v, err := strconv.Atoi(l); err == nil && v > 0 && v <= 500 {
+			limit = v
+		}
+	}
+	// host filter (case-insensitive)
+	host := strings.ToLower(strings.TrimSpace(q.Get("host")))
+	fields, err := parseFields(q.Get("fields"), versionedTargetFields(r.Context()))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	metadata, err := parseMetadataFilter(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	includeTotal := false
+	if v := q.Get("include_total"); v != "" {
+		if includeTotal, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "include_total must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+	orderBy := q.Get("order_by")
+	if orderBy == "" {
+		orderBy = targetOrderCreated
+	}
+
+	var items []models.Target
+	var nextPageToken string
+	switch orderBy {
+	case targetOrderCreated:
+		if items, nextPageToken, err = h.listTargetsByCreation(r.Context(), host, metadata, q.Get("page_token"), limit, fields); err != nil {
+			if errors.Is(err, errInvalidPageToken) {
+				invalidPageToken(w)
+				return
+			}
+			if h.serveCachedTargetList(w, r) {
+				logging.API.Warnf("list targets error, serving cached list: %s", h.redactor.Text(err.Error()))
+				return
+			}
+			h.internalError(w, r, "list targets error", err)
+			return
+		}
+	case targetOrderHealth:
+		if items, nextPageToken, err = h.listTargetsByHealth(r.Context(), host, metadata, q.Get("page_token"), limit); err != nil {
+			if errors.Is(err, errInvalidPageToken) {
+				invalidPageToken(w)
+				return
+			}
+			if h.serveCachedTargetList(w, r) {
+				logging.API.Warnf("list targets error, serving cached list: %s", h.redactor.Text(err.Error()))
+				return
+			}
+			h.internalError(w, r, "list targets error", err)
+			return
+		}
+	default:
+		http.Error(w, "order_by must be one of: created_at, health", http.StatusBadRequest)
+		return
+	}
+
+	projected, err := projectFields(versionedTargets(r.Context(), items), fields)
+	if err != nil {
+		h.internalError(w, r, "project targets error", err)
+		return
+	}
+
+	resp := struct {
+		Items         interface{}       `json:"items"`
+		NextPageToken string            `json:"next_page_token"`
+		TotalCount    *int              `json:"total_count,omitempty"`
+		FilteredCount *int              `json:"filtered_count,omitempty"`
+		Filters       targetListFilters `json:"filters"`
+	}{
+		Items:         projected,
+		NextPageToken: nextPageToken,
+		Filters:       targetListFilters{Host: host, Metadata: metadata, OrderBy: orderBy, Limit: limit, Fields: fields},
+	}
+	if includeTotal {
+		total, err := h.targetCount(r.Context(), "", nil)
+		if err != nil {
+			h.internalError(w, r, "count targets error", err)
+			return
+		}
+		filtered := total
+		if host != "" || metadata != nil {
+			if filtered, err = h.targetCount(r.Context(), host, metadata); err != nil {
+				h.internalError(w, r, "count targets error", err)
+				return
+			}
+		}
+		resp.TotalCount, resp.FilteredCount = &total, &filtered
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		h.internalError(w, r, "encode targets error", err)
+		return
+	}
+	body = append(body, '\n')
+	h.targetLists.put(targetListKey(r), body, h.clock.Now())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// targetListFilters echoes the filters a target list was produced with, after defaults.
+type targetListFilters struct {
+	Host     string            `json:"host,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	OrderBy  string            `json:"order_by"`
+	Limit    int               `json:"limit"`
+	Fields   []string          `json:"fields,omitempty"`
+}
+
+// Target list orderings.
+const (
+	targetOrderCreated = "created_at"
+	targetOrderHealth  = "health"
+)
+
+// listTargetsByCreation returns a page of targets in (created_at, id) order using a keyset
+// cursor. States are attached only when they are part of the requested fields.
+func (h *Handlers) listTargetsByCreation(ctx context.Context, host string, metadata map[string]string, pageToken string, limit int, fields []string) ([]models.Target, string, error) {
+	var afterTime time.Time
+	var afterID string
+	if pageToken != "" {
+		payload, err := h.pageTokens.decode(pageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		if afterTime, afterID, err = parseCreationCursor(payload); err != nil {
+			return nil, "", err
+		}
+	}
+
+	items, err := h.store.ListTargets(ctx, storage.ListTargetsParams{
+		Host:      host,
+		AfterTime: afterTime,
+		AfterID:   afterID,
+		Limit:     limit,
+		Metadata:  metadata,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Latest states are only looked up when they are part of the response.
+	if fields == nil || slices.Contains(fields, "state") {
+		if err := h.attachStates(ctx, items); err != nil {
+			return nil, "", err
+		}
+	}
+
+	var next string
+	if len(items) == limit {
+		last := items[len(items)-1]
+		next = h.pageTokens.encode(creationCursor(last.CreatedAt, last.ID))
+	}
+	return items, next, nil
+}
+
+// listTargetsByHealth returns a page of targets ordered for triage: failing first, then
+// degraded, then healthy, then never checked (see storage.HealthRank). The store ranks them,
+// so each page reads only its own targets. Pages are addressed by (rank, created_at, id):
+// a target whose health changes between requests may be listed twice or not at all, but
+// the others keep their place.
+func (h *Handlers) listTargetsByHealth(ctx context.Context, host string, metadata map[string]string, pageToken string, limit int) ([]models.Target, string, error) {
+	params := storage.ListTargetsByHealthParams{Host: host, Metadata: metadata, DegradedLatency: h.degradedLatency, Limit: limit}
+	if pageToken != "" {
+		payload, err := h.pageTokens.decode(pageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		if params.AfterRank, params.AfterTime, params.AfterID, err = parseHealthCursor(payload); err != nil {
+			return nil, "", err
+		}
+	}
+
+	ranked, err := h.store.ListTargetsByHealth(ctx, params)
+	if err != nil {
+		return nil, "", err
+	}
+	items := make([]models.Target, len(ranked))
+	for i := range ranked {
+		items[i] = ranked[i].Target
+	}
+	if err := h.attachStates(ctx, items); err != nil {
+		return nil, "", err
+	}
+	var next string
+	if len(ranked) == limit {
+		last := ranked[len(ranked)-1]
+		next = h.pageTokens.encode(healthCursor(last.Rank, last.CreatedAt, last.ID))
+	}
+	return items, next, nil
+}
+
+// attachStates sets each target's State from the state cache.
+func (h *Handlers) attachStates(ctx context.Context, items []models.Target) error {
+	ids := make([]string, len(items))
+	for i, t := range items {
+		ids[i] = t.ID
+	}
+	states, err := h.states.States(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("failed to get target states: %w", err)
+	}
+	for i := range items {
+		state := states[items[i].ID]
+		items[i].State = &state
+	}
+	return nil
+}
+
+// ListCheckResults handles listing check results for a target.
+func (h *Handlers) ListCheckResults(w http.ResponseWriter, r *http.Request) {
+	// path: /v1/targets/{target_id}/results
+	path := r.URL.Path
+	parts := strings.Split(path, "/")
+	if len(parts) < 5 {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	targetID := parts[3]
+
+	// ensure target exists
+	if _, err := h.store.GetTargetByID(r.Context(), targetID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "target not found", http.StatusNotFound)
+			return
+		}
+		h.internalError(w, r, "get target error", err)
+		return
+	}
+
+	q := r.URL.Query()
+	limit := 100
+	if l := q.Get("limit"); l != "" {
+		if v, err := strconv.Atoi(l); err == nil && v > 0 && v <= 1000 {
+			limit = v
+		}
+	}
+
+	since, until, err := parseTimeRange(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fields, err := parseFields(q.Get("fields"), storage.ResultFields)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params := storage.ListCheckResultsParams{TargetID: targetID, Since: since, Until: until, Limit: limit, Fields: fields}
+	if hdr := q.Get("header"); hdr != "" {
+		name, value, ok := strings.Cut(hdr, ":")
+		if name = strings.TrimSpace(name); !ok || !validHeaderName(name) {
+			http.Error(w, "header must be of the form Name:Value", http.StatusBadRequest)
+			return
+		}
+		params.HeaderName, params.HeaderValue = http.CanonicalHeaderKey(name), strings.TrimSpace(value)
+	}
+
+	results, err := h.store.ListCheckResultsByTargetID(r.Context(), params)
+	if err != nil {
+		h.internalError(w, r, "list results error", err)
+		return
+	}
+
+	projected, err := projectFields(results, fields)
+	if err != nil {
+		h.internalError(w, r, "project results error", err)
+		return
+	}
+
+	resp := struct {
+		Items interface{} `json:"items"`
+	}{Items: projected}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ListTargetAliases returns every URL submitted for a target, oldest first, including the
+// one stored as its url.
+func (h *Handlers) ListTargetAliases(w http.ResponseWriter, r *http.Request) {
+	targetID := r.PathValue("target_id")
+	if _, err := h.store.GetTargetByID(r.Context(), targetID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "target not found", http.StatusNotFound)
+			return
+		}
+		h.internalError(w, r, "get target error", err)
+		return
+	}
+
+	aliases, err := h.store.ListTargetAliases(r.Context(), targetID)
+	if err != nil {
+		h.internalError(w, r, "list target aliases error", err)
+		return
+	}
+
+	resp := struct {
+		Items []models.TargetAlias `json:"items"`
+	}{Items: aliases}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ListTransitions returns a target's state transitions, newest first, optionally limited to
+// [since, until).
+func (h *Handlers) ListTransitions(w http.ResponseWriter, r *http.Request) {
+	targetID := r.PathValue("target_id")
+	if _, err := h.store.GetTargetByID(r.Context(), targetID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "target not found", http.StatusNotFound)
+			return
+		}
+		h.internalError(w, r, "get target error", err)
+		return
+	}
+
+	q := r.URL.Query()
+	params := storage.ListTransitionsParams{TargetID: targetID, Limit: 100}
+	if l := q.Get("limit"); l != "" {
+		v, err := strconv.Atoi(l)
+		if err != nil || v <= 0 || v > 1000 {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		params.Limit = v
+	}
+	var err error
+	if params.Since, params.Until, err = parseTimeRange(q); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	transitions, err := h.store.ListStateTransitions(r.Context(), params)
+	if err != nil {
+		h.internalError(w, r, "list transitions error", err)
+		return
+	}
+
+	resp := struct {
+		Items []models.StateTransition `json:"items"`
+	}{Items: transitions}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// maxDowntimeTransitions bounds how many transitions a downtime report reads.
+const maxDowntimeTransitions = 10000
+
+// GetDowntime returns the periods a target spent down within a window (30 days by default)
+// and their total, computed from its state transitions.
+func (h *Handlers) GetDowntime(w http.ResponseWriter, r *http.Request) {
+	targetID := r.PathValue("target_id")
+	if _, err := h.store.GetTargetByID(r.Context(), targetID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "target not found", http.StatusNotFound)
+			return
+		}
+		h.internalError(w, r, "get target error", err)
+		return
+	}
+
+	since, until, err := parseWindow(r.URL.Query(), 30*24*time.Hour, h.clock.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	transitions, err := h.store.ListStateTransitions(r.Context(), storage.ListTransitionsParams{TargetID: targetID, Since: &since, Until: &until, Limit: maxDowntimeTransitions + 1})
+	if err != nil {
+		h.internalError(w, r, "list transitions error", err)
+		return
+	}
+	if len(transitions) > maxDowntimeTransitions {
+		http.Error(w, fmt.Sprintf("window has more than %d state transitions; use a shorter window", maxDowntimeTransitions), http.StatusBadRequest)
+		return
+	}
+	slices.Reverse(transitions)
+
+	// The target's state at the start of the window is where its last earlier transition left it.
+	var initial models.StateTransition
+	before, err := h.store.ListStateTransitions(r.Context(), storage.ListTransitionsParams{TargetID: targetID, Until: &since, Limit: 1})
+	if err != nil {
+		h.internalError(w, r, "list transitions error", err)
+		return
+	}
+	switch {
+	case len(before) > 0:
+		initial = before[0]
+	case len(transitions) > 0:
+		initial.ToStatus = transitions[0].FromStatus
+	}
+
+	outages := models.Outages(initial, transitions, since, until)
+	var total int64
+	for _, o := range outages {
+		total += o.DurationSeconds
+	}
+
+	resp := struct {
+		Since                time.Time       `json:"since"`
+		Until                time.Time       `json:"until"`
+		TotalDowntimeSeconds int64           `json:"total_downtime_seconds"`
+		Outages              []models.Outage `json:"outages"`
+	}{Since: since, Until: until, TotalDowntimeSeconds: total, Outages: outages}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GetStatusBreakdown returns how a target's checks within a window (7 days by default) ended,
+// counted per status class and error category.
+func (h *Handlers) GetStatusBreakdown(w http.ResponseWriter, r *http.Request) {
+	targetID := r.PathValue("target_id")
+	if _, err := h.store.GetTargetByID(r.Context(), targetID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "target not found", http.StatusNotFound)
+			return
+		}
+		h.internalError(w, r, "get target error", err)
+		return
+	}
+
+	since, until, err := parseWindow(r.URL.Query(), 7*24*time.Hour, h.clock.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !h.readArchive(w, r, since, until) {
+		return
+	}
+	breakdown, err := h.store.GetStatusBreakdown(r.Context(), storage.StatusBreakdownParams{TargetID: targetID, Since: since, Until: until})
+	if err != nil {
+		h.internalError(w, r, "status breakdown error", err)
+		return
+	}
+
+	resp := struct {
+		Since time.Time `json:"since"`
+		Until time.Time `json:"until"`
+		*models.StatusBreakdown
+	}{Since: since, Until: until, StatusBreakdown: breakdown}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// targetFields lists the target fields selectable with ?fields=.
+var targetFields = []string{"id", "url", "created_at", "type", "heartbeat_token", "grace_period_seconds", "last_ping_at", "capture_headers", "status_policy", "timeout_budget_ms", "metadata", "result_sampling", "snoozed_until", "latency_threshold_ms", "min_tls_version", "exec", "wasm", "security_audit", "asset_check", "dependencies", "schedule", "state"}
+
+// parseFields parses a comma-separated ?fields= value, checking each name against allowed.
+// It returns nil when no fields were requested.
+func parseFields(raw string, allowed []string) ([]string, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var fields []string
+	for _, f := range strings.Split(raw, ",") {
+		f = strings.TrimSpace(f)
+		if !slices.Contains(allowed, f) {
+			return nil, fmt.Errorf("unknown field %q, expected any of: %s", f, strings.Join(allowed, ", "))
+		}
+		if !slices.Contains(fields, f) {
+			fields = append(fields, f)
+		}
+	}
+	return fields, nil
+}
+
+// projectFields re-encodes items keeping only the given JSON fields. Items are returned as-is
+// when no fields were requested.
+func projectFields[T any](items []T, fields []string) (interface{}, error) {
+	if fields == nil {
+		return items, nil
+	}
+	projected := make([]map[string]json.RawMessage, len(items))
+	for i, item := range items {
+		b, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(b, &all); err != nil {
+			return nil, err
+		}
+		projected[i] = make(map[string]json.RawMessage, len(fields))
+		for _, f := range fields {
+			if v, ok := all[f]; ok {
+				projected[i][f] = v
+			}
+		}
+	}
+	return projected, nil
+}
+
+// maxBulkResultTargets caps how many targets a single bulk results request may name.
+const maxBulkResultTargets = 100
+
+// ListRecentResults handles listing recent results for several targets at once, grouped by target.
+func (h *Handlers) ListRecentResults(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var ids []string
+	for _, id := range strings.Split(q.Get("target_ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 || len(ids) > maxBulkResultTargets {
+		http.Error(w, fmt.Sprintf("target_ids must contain between 1 and %d ids", maxBulkResultTargets), http.StatusBadRequest)
+		return
+	}
+	limit := 20
+	if l := q.Get("limit"); l != "" {
+		v, err := strconv.Atoi(l)
+		if err != nil || v <= 0 || v > 1000 {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = v
+	}
+	since, until, err := parseTimeRange(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fields, err := parseFields(q.Get("fields"), storage.ResultFields)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	grouped, err := h.store.ListRecentResults(r.Context(), storage.RecentResultsParams{
+		TargetIDs: ids,
+		Since:     since,
+		Until:     until,
+		Limit:     limit,
+		Fields:    fields,
+	})
+	if err != nil {
+		h.internalError(w, r, "list recent results error", err)
+		return
+	}
+
+	type targetResults struct {
+		TargetID string      `json:"target_id"`
+		Results  interface{} `json:"results"`
+	}
+	items := make([]targetResults, len(ids))
+	for i, id := range ids {
+		results := grouped[id]
+		if results == nil {
+			results = []models.CheckResult{}
+		}
+		projected, err := projectFields(results, fields)
+		if err != nil {
+			h.internalError(w, r, "project results error", err)
+			return
+		}
+		items[i] = targetResults{TargetID: id, Results: projected}
+	}
+
+	resp := struct {
+		Items []targetResults `json:"items"`
+	}{Items: items}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// maxTimeseriesBuckets caps the number of buckets a single timeseries request may produce.
+const maxTimeseriesBuckets = 10000
+
+// parseDuration parses a Go duration string, additionally accepting a whole number of days (e.g. "30d").
+func parseDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// GetTimeseries handles returning bucketed check result aggregates for a target.
+func (h *Handlers) GetTimeseries(w http.ResponseWriter, r *http.Request) {
+	targetID := r.PathValue("target_id")
+
+	// ensure target exists
+	if _, err := h.store.GetTargetByID(r.Context(), targetID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "target not found", http.StatusNotFound)
+			return
+		}
+		h.internalError(w, r, "get target error", err)
+		return
+	}
+
+	q := r.URL.Query()
+	bucket := 5 * time.Minute
+	if b := q.Get("bucket"); b != "" {
+		v, err := parseDuration(b)
+		if err != nil || v < time.Second {
+			http.Error(w, "bucket must be a duration of at least 1s", http.StatusBadRequest)
+			return
+		}
+		bucket = v
+	}
+	since, until, err := parseWindow(q, 24*time.Hour, h.clock.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if until.Sub(since)/bucket > maxTimeseriesBuckets {
+		http.Error(w, fmt.Sprintf("window/bucket must not exceed %d buckets", maxTimeseriesBuckets), http.StatusBadRequest)
+		return
+	}
+	fill := "none"
+	if h.keepalive > 0 {
+		fill = "previous"
+	}
+	if f := q.Get("fill"); f != "" {
+		if f != "none" && f != "previous" {
+			http.Error(w, "fill must be one of: none, previous", http.StatusBadRequest)
+			return
+		}
+		fill = f
+	}
+
+	if !h.readArchive(w, r, since, until) {
+		return
+	}
+	buckets, err := h.store.GetTimeseries(r.Context(), storage.TimeseriesParams{
+		TargetID: targetID,
+		Bucket:   bucket,
+		Since:    since,
+		Until:    until,
+	})
+	if err != nil {
+		h.internalError(w, r, "timeseries error", err)
+		return
+	}
+	if fill == "previous" && h.keepalive > 0 {
+		buckets = fillForward(buckets, bucket, h.keepalive, until)
+	}
+	if buckets == nil {
+		buckets = []models.TimeseriesBucket{}
+	}
+
+	resp := struct {
+		Bucket string                    `json:"bucket"`
+		Window string                    `json:"window"`
+		Since  time.Time                 `json:"since"`
+		Until  time.Time                 `json:"until"`
+		Items  []models.TimeseriesBucket `json:"items"`
+	}{Bucket: bucket.String(), Window: until.Sub(since).String(), Since: since, Until: until, Items: buckets}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// fillForward inserts synthetic buckets into gaps, carrying the preceding bucket forward for
+// up to maxGap (the keepalive interval) and never past until. Each synthetic bucket counts a
+// single check with the preceding bucket's majority outcome. Gaps longer than maxGap are left
+// empty, since a keepalive result would have been stored had checks still been running.
+func fillForward(buckets []models.TimeseriesBucket, bucket, maxGap time.Duration, until time.Time) []models.TimeseriesBucket {
+	if len(buckets) == 0 {
+		return buckets
+	}
+	filled := make([]models.TimeseriesBucket, 0, len(buckets))
+	for i, cur := range buckets {
+		filled = append(filled, cur)
+		end := until
+		if i+1 < len(buckets) {
+			end = buckets[i+1].BucketStart.Add(-time.Nanosecond)
+		}
+		if limit := cur.BucketStart.Add(bucket + maxGap - time.Nanosecond); limit.Before(end) {
+			end = limit
+		}
+
+		synthetic := models.TimeseriesBucket{AvgLatencyMS: cur.AvgLatencyMS, MaxLatencyMS: cur.MaxLatencyMS, Synthetic: true}
+		if cur.SuccessCount >= cur.FailureCount {
+			synthetic.SuccessCount = 1
+		} else {
+			synthetic.FailureCount = 1
+		}
+		for t := cur.BucketStart.Add(bucket); !t.After(end); t = t.Add(bucket) {
+			synthetic.BucketStart = t
+			filled = append(filled, synthetic)
+		}
+	}
+	return filled
+}
+
+// TopTargets handles reporting the worst-performing targets across all targets.
+func (h *Handlers) TopTargets(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	metric := q.Get("metric")
+	if metric == "" {
+		metric = storage.StatsOrderByLatency
+	}
+	if metric != storage.StatsOrderByLatency && metric != storage.StatsOrderByFailures {
+		http.Error(w, "metric must be one of: latency, failures", http.StatusBadRequest)
+		return
+	}
+	since, until, err := parseWindow(q, 24*time.Hour, h.clock.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit := 20
+	if l := q.Get("limit"); l != "" {
+		if v, err := strconv.Atoi(l); err == nil && v > 0 && v <= 500 {
+			limit = v
+		}
+	}
+
+	if !h.readArchive(w, r, since, until) {
+		return
+	}
+	stats, err := h.store.ListTargetStats(r.Context(), storage.TargetStatsParams{
+		Since:   since,
+		Until:   until,
+		OrderBy: metric,
+		Limit:   limit,
+	})
+	if err != nil {
+		h.internalError(w, r, "top targets error", err)
+		return
+	}
+	if stats == nil {
+		stats = []models.TargetStats{}
+	}
+
+	resp := struct {
+		Metric string               `json:"metric"`
+		Window string               `json:"window"`
+		Since  time.Time            `json:"since"`
+		Until  time.Time            `json:"until"`
+		Items  []models.TargetStats `json:"items"`
+	}{Metric: metric, Window: until.Sub(since).String(), Since: since, Until: until, Items: stats}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// SendReport handles generating a summary report on demand and emailing it to the configured recipients.
+func (h *Handlers) SendReport(w http.ResponseWriter, r *http.Request) {
+	if h.reporter == nil || !h.reporter.CanSend() {
+		http.Error(w, "reporting is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = report.PeriodDaily
+	}
+	if _, err := report.PeriodWindow(period); err != nil {
+		http.Error(w, "period must be one of: daily, weekly", http.StatusBadRequest)
+		return
+	}
+
+	summary, err := h.reporter.Send(r.Context(), period)
+	if err != nil {
+		h.internalError(w, r, "send report error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// Heartbeat handles a ping for a heartbeat target, marking it up and clearing any missed-heartbeat state.
+func (h *Handlers) Heartbeat(w http.ResponseWriter, r *http.Request) {
+	now := h.clock.Now().UTC()
+	target, err := h.store.RecordHeartbeat(r.Context(), r.PathValue("token"), now)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "heartbeat not found", http.StatusNotFound)
+			return
+		}
+		h.internalError(w, r, "record heartbeat error", err)
+		return
+	}
+
+	// A heartbeat target is down when its latest result is a missed heartbeat.
+	latest, err := h.store.ListCheckResultsByTargetID(r.Context(), storage.ListCheckResultsParams{TargetID: target.ID, Limit: 1})
+	if err != nil {
+		h.internalError(w, r, "list results error", err)
+		return
+	}
+	wasDown := len(latest) > 0 && latest[0].Error != nil
+
+	result := models.CheckResult{TargetID: target.ID, CheckedAt: now}
+	if err := h.store.CreateCheckResult(r.Context(), &result); err != nil {
+		h.internalError(w, r, "create check result error", err)
+		return
+	}
+	h.states.Invalidate(target.ID)
+	if h.publisher != nil {
+		h.publisher.Publish(result)
+	}
+
+	// Snoozed targets record pings but raise no alerts.
+	if wasDown && !target.Snoozed(now) {
+		event := notify.Event{
+			Type:     notify.EventTargetUp,
+			TargetID: target.ID,
+			URL:      target.URL,
+			Message:  "heartbeat received",
+			At:       now,
+		}
+		if err := h.notifier.Notify(r.Context(), event); err != nil {
+			logging.API.Errorf("error sending alert for target %s: %v", target.ID, err)
+		}
+	}
+
+	resp := struct {
+		TargetID     string    `json:"target_id"`
+		ReceivedAt   time.Time `json:"received_at"`
+		NextDeadline time.Time `json:"next_deadline"`
+	}{
+		TargetID:     target.ID,
+		ReceivedAt:   now,
+		NextDeadline: now.Add(time.Duration(target.GracePeriodSeconds) * time.Second),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Discovery outcomes reported per URL.
+const (
+	discoverPreview  = "preview"
+	discoverCreated  = "created"
+	discoverExisting = "existing"
+	discoverInvalid  = "invalid"
+)
+
+// Discover handles extracting URLs from a site's sitemaps and creating targets for them in bulk.
+func (h *Handlers) Discover(w http.ResponseWriter, r *http.Request) {
+	var reqBody struct {
+		URL    string `json:"url"`
+		DryRun bool   `json:"dry_run"`
+		Max    int    `json:"max"`
+		Async  bool   `json:"async"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	root, err := urlutil.Canonicalize(reqBody.URL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if reqBody.Max < 0 {
+		http.Error(w, "max must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	if reqBody.Async {
+		dryRun, max := reqBody.DryRun, reqBody.Max
+		h.submitJob(w, r, jobTypeDiscovery, func(ctx context.Context, progress func(int)) (interface{}, error) {
+			found, err := h.discoverer.Discover(ctx, root, max)
+			if err != nil {
+				return nil, err
+			}
+			return h.applyDiscovery(ctx, root, found, dryRun, progress)
+		})
+		return
+	}
+
+	found, err := h.discoverer.Discover(r.Context(), root, reqBody.Max)
+	if err != nil && deadlineExceeded(r) {
+		timeoutError(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	resp, err := h.applyDiscovery(r.Context(), root, found, reqBody.DryRun, nil)
+	if err != nil {
+		h.internalError(w, r, "error creating discovered target", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+type discoverItem struct {
+	URL      string `json:"url"`
+	Status   string `json:"status"`
+	TargetID string `json:"target_id,omitempty"`
+}
+
+type discoverResponse struct {
+	Root      string         `json:"root"`
+	DryRun    bool           `json:"dry_run"`
+	Sitemaps  []string       `json:"sitemaps"`
+	Truncated bool           `json:"truncated"`
+	Created   int            `json:"created"`
+	Existing  int            `json:"existing"`
+	Items     []discoverItem `json:"items"`
+}
+
+// applyDiscovery classifies discovered URLs and, unless dryRun is set, creates targets for them.
+func (h *Handlers) applyDiscovery(ctx context.Context, root string, found *discovery.Result, dryRun bool, progress func(int)) (*discoverResponse, error) {
+	resp := &discoverResponse{Root: root, DryRun: dryRun, Sitemaps: found.Sitemaps, Truncated: found.Truncated}
+	var err error
+	if resp.Items, resp.Created, resp.Existing, err = h.createTargets(ctx, found.URLs, dryRun, progress); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// createTargets classifies URLs and, unless dryRun is set, creates HTTP targets for them.
+// URLs that already have a target are reported as existing rather than duplicated.
+func (h *Handlers) createTargets(ctx context.Context, urls []string, dryRun bool, progress func(int)) (items []discoverItem, created, existing int, err error) {
+	items = []discoverItem{}
+	for i, rawURL := range urls {
+		if progress != nil {
+			progress(i * 100 / len(urls))
+		}
+		if h.urlLimits.Check(rawURL) != nil {
+			items = append(items, discoverItem{URL: rawURL, Status: discoverInvalid})
+			continue
+		}
+		target, err := models.NewHTTPTarget(rawURL)
+		if err != nil {
+			items = append(items, discoverItem{URL: rawURL, Status: discoverInvalid})
+			continue
+		}
+		if dryRun {
+			items = append(items, discoverItem{URL: rawURL, Status: discoverPreview})
+			continue
+		}
+		target, err = h.store.CreateTarget(ctx, target, nil)
+		switch {
+		case errors.Is(err, storage.ErrDuplicateKey):
+			existing++
+			items = append(items, discoverItem{URL: rawURL, Status: discoverExisting, TargetID: target.ID})
+		case err != nil:
+			return nil, 0, 0, err
+		default:
+			created++
+			h.states.Invalidate(target.ID)
+			items = append(items, discoverItem{URL: rawURL, Status: discoverCreated, TargetID: target.ID})
+		}
+	}
+	return items, created, existing, nil
+}
+
+// maxBatchURLs caps how many URLs a single batch request may contain.
+const maxBatchURLs = 1000
+
+// CreateTargetsBatch handles creating HTTP targets for a list of URLs in one request.
+func (h *Handlers) CreateTargetsBatch(w http.ResponseWriter, r *http.Request) {
+	var reqBody struct {
+		URLs   []string `json:"urls"`
+		DryRun bool     `json:"dry_run"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(reqBody.URLs) == 0 || len(reqBody.URLs) > maxBatchURLs {
+		http.Error(w, fmt.Sprintf("urls must contain between 1 and %d entries", maxBatchURLs), http.StatusBadRequest)
+		return
+	}
+
+	items, created, existing, err := h.createTargets(r.Context(), reqBody.URLs, reqBody.DryRun, nil)
+	if err != nil {
+		h.internalError(w, r, "error creating batch target", err)
+		return
+	}
+	resp := struct {
+		DryRun   bool           `json:"dry_run"`
+		Created  int            `json:"created"`
+		Existing int            `json:"existing"`
+		Items    []discoverItem `json:"items"`
+	}{DryRun: reqBody.DryRun, Created: created, Existing: existing, Items: items}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// UpdateTargetsBatch handles changing many targets at once: every target matching the filter's
+// host, metadata pairs, and ids gets the same metadata entries set or removed, and is snoozed
+// or unsnoozed, in one transaction. At least one filter and one change are required. The
+// response counts the targets that matched and those that changed.
+//
+// Targets have no tags, pause, or interval of their own, so tags are metadata entries, pausing
+// is a snooze for a duration, and the interval is CHECK_INTERVAL for every target. Requests
+// naming tags, pause, or interval are refused rather than partly applied.
+func (h *Handlers) UpdateTargetsBatch(w http.ResponseWriter, r *http.Request) {
+	var reqBody struct {
+		Filter struct {
+			Host     string            `json:"host"`
+			Metadata map[string]string `json:"metadata"`
+			IDs      []string          `json:"ids"`
+			Tag      json.RawMessage   `json:"tag"`
+		} `json:"filter"`
+		SetMetadata    map[string]string `json:"set_metadata"`
+		RemoveMetadata []string          `json:"remove_metadata"`
+		Snooze         *string           `json:"snooze"`
+		Unsnooze       bool              `json:"unsnooze"`
+		AddTags        json.RawMessage   `json:"add_tags"`
+		RemoveTags     json.RawMessage   `json:"remove_tags"`
+		Pause          json.RawMessage   `json:"pause"`
+		Interval       json.RawMessage   `json:"interval"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case reqBody.Filter.Tag != nil:
+		http.Error(w, "filter.tag is not supported; tags are metadata entries, use filter.metadata", http.StatusBadRequest)
+		return
+	case reqBody.AddTags != nil || reqBody.RemoveTags != nil:
+		http.Error(w, "add_tags and remove_tags are not supported; tags are metadata entries, use set_metadata and remove_metadata", http.StatusBadRequest)
+		return
+	case reqBody.Pause != nil:
+		http.Error(w, "pause is not supported; use snooze with a duration", http.StatusBadRequest)
+		return
+	case reqBody.Interval != nil:
+		http.Error(w, "interval can't be set per target; every target is checked every CHECK_INTERVAL", http.StatusBadRequest)
+		return
+	}
+	filter := storage.TargetFilter{
+		Host:     strings.ToLower(strings.TrimSpace(reqBody.Filter.Host)),
+		Metadata: reqBody.Filter.Metadata,
+		IDs:      slices.Compact(slices.Sorted(slices.Values(reqBody.Filter.IDs))),
+	}
+	if filter.Host == "" && len(filter.Metadata) == 0 && len(filter.IDs) == 0 {
+		http.Error(w, "filter.host, filter.metadata, or filter.ids is required", http.StatusBadRequest)
+		return
+	}
+	if len(filter.IDs) > maxBatchURLs {
+		http.Error(w, fmt.Sprintf("filter.ids must not contain more than %d entries", maxBatchURLs), http.StatusBadRequest)
+		return
+	}
+	for k := range filter.Metadata {
+		if !validMetadataKey(k) {
+			http.Error(w, fmt.Sprintf("invalid metadata key %q", k), http.StatusBadRequest)
+			return
+		}
+	}
+
+	mutation := storage.TargetMutation{RemoveMetadata: reqBody.RemoveMetadata, Unsnooze: reqBody.Unsnooze, MaxMetadataEntries: maxMetadataEntries}
+	var err error
+	if mutation.SetMetadata, err = normalizeMetadata(reqBody.SetMetadata); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, k := range reqBody.RemoveMetadata {
+		if !validMetadataKey(k) {
+			http.Error(w, fmt.Sprintf("invalid metadata key %q in remove_metadata", k), http.StatusBadRequest)
+			return
+		}
+	}
+	if reqBody.Snooze != nil {
+		if reqBody.Unsnooze {
+			http.Error(w, "snooze and unsnooze can't be combined", http.StatusBadRequest)
+			return
+		}
+		d, err := parseDuration(*reqBody.Snooze)
+		if err != nil || d <= 0 || d > maxSnooze {
+			http.Error(w, fmt.Sprintf("snooze must be a positive duration of at most %s", maxSnooze), http.StatusBadRequest)
+			return
+		}
+		until := h.clock.Now().UTC().Add(d)
+		mutation.SnoozedUntil = &until
+	}
+	if len(mutation.SetMetadata) == 0 && len(mutation.RemoveMetadata) == 0 && mutation.SnoozedUntil == nil && !mutation.Unsnooze {
+		http.Error(w, "set_metadata, remove_metadata, snooze, or unsnooze is required", http.StatusBadRequest)
+		return
+	}
+
+	matched, updated, err := h.store.UpdateTargets(r.Context(), filter, mutation)
+	if errors.Is(err, storage.ErrMetadataLimit) {
+		http.Error(w, fmt.Sprintf("%v, the most is %d", err, maxMetadataEntries), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.internalError(w, r, "update targets error", err)
+		return
+	}
+
+	resp := struct {
+		Matched int `json:"matched"`
+		Updated int `json:"updated"`
+	}{Matched: matched, Updated: updated}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// StartCrawl handles queuing an asynchronous broken-link crawl of a page.
+func (h *Handlers) StartCrawl(w http.ResponseWriter, r *http.Request) {
+	var reqBody struct {
+		URL   string `json:"url"`
+		Scope string `json:"scope"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	pageURL, err := urlutil.Canonicalize(reqBody.URL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if reqBody.Scope == "" {
+		reqBody.Scope = crawler.ScopeSameHost
+	}
+	if reqBody.Scope != crawler.ScopeSameHost && reqBody.Scope != crawler.ScopeAll {
+		http.Error(w, "scope must be one of: same_host, all", http.StatusBadRequest)
+		return
+	}
+
+	scope := reqBody.Scope
+	h.submitJob(w, r, jobTypeCrawl, func(ctx context.Context, progress func(int)) (interface{}, error) {
+		return h.crawler.Crawl(ctx, pageURL, scope, func(done, total int) {
+			progress(done * 100 / total)
+		})
+	})
+}
+
+// GetCrawl handles returning the status and report of a crawl job.
+// It is kept for compatibility; GET /v1/jobs/{job_id} returns the same job.
+func (h *Handlers) GetCrawl(w http.ResponseWriter, r *http.Request) {
+	job, err := h.jobs.Get(r.Context(), r.PathValue("job_id"))
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && job.Type != jobTypeCrawl) {
+		http.Error(w, "crawl job not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.internalError(w, r, "error getting job", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// submitJob queues a background job and responds with 202 Accepted and its Location.
+func (h *Handlers) submitJob(w http.ResponseWriter, r *http.Request, jobType string, run jobs.RunFunc) {
+	job, err := h.jobs.Submit(r.Context(), jobType, run)
+	if err != nil {
+		h.internalError(w, r, "error submitting "+jobType+" job", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/"+apiVersion(r.Context())+"/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// GetJob handles returning the status, progress, and result of a background job.
+func (h *Handlers) GetJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.jobs.Get(r.Context(), r.PathValue("job_id"))
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.internalError(w, r, "error getting job", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// CancelJob handles cancelling a queued or running background job.
+func (h *Handlers) CancelJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.jobs.Cancel(r.Context(), r.PathValue("job_id"))
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	case errors.Is(err, jobs.ErrFinished):
+		http.Error(w, "job already finished", http.StatusConflict)
+		return
+	case err != nil:
+		h.internalError(w, r, "error cancelling job", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// GetQueue handles reporting the checker queue's capacity, depth, and drop count.
+func (h *Handlers) GetQueue(w http.ResponseWriter, r *http.Request) {
+	if h.queue == nil {
+		http.Error(w, "queue stats are not available", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.queue.QueueStats())
+}
+
+// maxWorkers caps the worker pool size that can be set through the API.
+const maxWorkers = 1000
+
+// GetWorkers handles reporting the checker's worker pool size.
+func (h *Handlers) GetWorkers(w http.ResponseWriter, r *http.Request) {
+	if h.workers == nil {
+		http.Error(w, "worker pool is not available", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.workers.WorkerStats())
+}
+
+// ResizeWorkers handles changing the checker's worker pool size.
+func (h *Handlers) ResizeWorkers(w http.ResponseWriter, r *http.Request) {
+	if h.workers == nil {
+		http.Error(w, "worker pool is not available", http.StatusServiceUnavailable)
+		return
+	}
+	var reqBody struct {
+		Size int `json:"size"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if reqBody.Size < 1 || reqBody.Size > maxWorkers {
+		http.Error(w, fmt.Sprintf("size must be between 1 and %d", maxWorkers), http.StatusBadRequest)
+		return
+	}
+	if err := h.workers.ResizeWorkers(reqBody.Size); err != nil {
+		h.internalError(w, r, "resize workers error", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.workers.WorkerStats())
 }
 
 // Healthz is a simple health check endpoint.
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// HostInspector reports the checker's current load on each host.
+type HostInspector interface {
+	HostLoad() map[string]models.HostLoad
+}
+
+// WithHosts adds the checker's per-host load to the hosts endpoint.
+func WithHosts(i HostInspector) Option {
+	return func(h *Handlers) { h.hosts = i }
+}
+
+// Host list orderings.
+const (
+	hostOrderName    = "host"
+	hostOrderLatency = "latency"
+)
+
+// ListHosts handles listing every host with targets, its check statistics over a window
+// (default 24h), whether it is paused, and the checker's current in-flight and queued checks
+// on it. With ?order_by=latency the slowest hosts come first.
+func (h *Handlers) ListHosts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	since, until, err := parseWindow(q, 24*time.Hour, h.clock.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	orderBy := q.Get("order_by")
+	switch orderBy {
+	case "", hostOrderName, hostOrderLatency:
+	default:
+		http.Error(w, "order_by must be one of: host, latency", http.StatusBadRequest)
+		return
+	}
+	if !h.readArchive(w, r, since, until) {
+		return
+	}
+	hosts, err := h.store.ListHostStats(r.Context(), storage.HostStatsParams{Since: since, Until: until})
+	if err != nil {
+		h.internalError(w, r, "list hosts error", err)
+		return
+	}
+	if h.hosts != nil {
+		load := h.hosts.HostLoad()
+		for i := range hosts {
+			l := load[hosts[i].Host]
+			hosts[i].InFlight, hosts[i].Queued = l.InFlight, l.Queued
+		}
+	}
+	if orderBy == hostOrderLatency {
+		sort.SliceStable(hosts, func(i, j int) bool { return hosts[i].AvgLatencyMS > hosts[j].AvgLatencyMS })
+	}
+	if hosts == nil {
+		hosts = []models.HostStats{}
+	}
+
+	resp := struct {
+		Since time.Time          `json:"since"`
+		Until time.Time          `json:"until"`
+		Items []models.HostStats `json:"items"`
+	}{Since: since, Until: until, Items: hosts}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// PauseHost handles suspending checks for every target on a host. The scheduler skips the
+// host's targets, including heartbeat deadline checks, until it is resumed. Pausing a paused
+// host is a no-op that returns the original pause.
+func (h *Handlers) PauseHost(w http.ResponseWriter, r *http.Request) {
+	host := strings.ToLower(strings.TrimSpace(r.PathValue("host")))
+	targets, err := h.store.ListTargets(r.Context(), storage.ListTargetsParams{Host: host, Limit: 1})
+	if err != nil {
+		h.internalError(w, r, "list targets error", err)
+		return
+	}
+	if len(targets) == 0 {
+		http.Error(w, "no targets on host "+host, http.StatusNotFound)
+		return
+	}
+	pause, err := h.store.PauseHost(r.Context(), host, h.clock.Now().UTC())
+	if err != nil {
+		h.internalError(w, r, "pause host error", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pause)
+}
+
+// ResumeHost handles lifting a host pause. Its targets are checked again from the next
+// scheduling cycle.
+func (h *Handlers) ResumeHost(w http.ResponseWriter, r *http.Request) {
+	host := strings.ToLower(strings.TrimSpace(r.PathValue("host")))
+	if err := h.store.ResumeHost(r.Context(), host); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "host is not paused", http.StatusNotFound)
+			return
+		}
+		h.internalError(w, r, "resume host error", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+)
+
+// GetLogLevels handles listing the log level of every component.
+func (h *Handlers) GetLogLevels(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+	writeLogLevels(w)
+}
+
+// SetLogLevels handles changing the log level of some components, e.g. {"checker": "debug"},
+// until the next restart. Components missing from the body keep their level. Nothing changes
+// unless every component and level is valid.
+func (h *Handlers) SetLogLevels(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+	var reqBody map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	var pairs []string
+	for name, level := range reqBody {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", name, level))
+	}
+	levels, err := logging.ParseLevels(pairs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for name, level := range levels {
+		l, _ := logging.Component(name)
+		if l.Level() != level {
+			logging.API.Infof("log level of %s changed from %s to %s", name, l.Level(), level)
+			l.SetLevel(level)
+		}
+	}
+	writeLogLevels(w)
+}
+
+func writeLogLevels(w http.ResponseWriter) {
+	levels := make(map[string]string)
+	for name, level := range logging.Levels() {
+		levels[name] = level.String()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(levels)
+}
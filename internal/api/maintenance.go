@@ -0,0 +1,46 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// WithDatabaseMaintenance enables POST /admin/database/maintenance, which runs maintenance
+// operations on the database through m. Without it the endpoint answers 503.
+func WithDatabaseMaintenance(m storage.DatabaseMaintainer) Option {
+	return func(h *Handlers) { h.maintainer = m }
+}
+
+// MaintainDatabase handles running a database maintenance operation (vacuum,
+// incremental_vacuum, or analyze) as a background job, whose result reports the database's
+// size before and after. It requires the admin token.
+func (h *Handlers) MaintainDatabase(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+	if h.maintainer == nil {
+		http.Error(w, "database maintenance is not available", http.StatusServiceUnavailable)
+		return
+	}
+	var reqBody struct {
+		Operation string `json:"operation"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	switch reqBody.Operation {
+	case models.MaintenanceVacuum, models.MaintenanceIncrementalVacuum, models.MaintenanceAnalyze:
+	default:
+		http.Error(w, "operation must be one of: vacuum, incremental_vacuum, analyze", http.StatusBadRequest)
+		return
+	}
+	operation := reqBody.Operation
+	h.submitJob(w, r, jobTypeMaintenance, func(ctx context.Context, progress func(int)) (interface{}, error) {
+		return h.maintainer.MaintainDatabase(ctx, operation)
+	})
+}
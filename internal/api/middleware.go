@@ -0,0 +1,46 @@
+package api
+
+import "net/http"
+
+// Middleware wraps a handler with behaviour of its own, e.g. logging, authentication, or CORS.
+type Middleware func(http.Handler) http.Handler
+
+// Chain composes middleware so that the first is outermost: a request passes through each in
+// the order given before it reaches the handler.
+func Chain(mws ...Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			next = mws[i](next)
+		}
+		return next
+	}
+}
+
+// WithMiddleware wraps every request the router serves, the health checks included, in mws,
+// outside the API's own middleware. Middleware added first runs first.
+func WithMiddleware(mws ...Middleware) Option {
+	return func(h *Handlers) { h.middleware = append(h.middleware, mws...) }
+}
+
+// routeMiddleware returns the middleware around one route of version, outermost first. A
+// request gets the version's deprecation headers and a request ID before anything can fail,
+// so even a recovered panic carries both. It then gets its deadline and learns its version,
+// has its query validated in strict mode, and is refused if it writes while the database is
+// degraded.
+func (h *Handlers) routeMiddleware(version string, rt route) []Middleware {
+	var mws []Middleware
+	if dep, ok := h.deprecations[version]; ok {
+		mws = append(mws, withDeprecation(dep))
+	}
+	mws = append(mws, withRequestID, h.withRecovery)
+	if h.timeouts != nil {
+		if d := h.timeouts.routeTimeout(rt); d > 0 {
+			mws = append(mws, withDeadline(d))
+		}
+	}
+	mws = append(mws, withVersion(version))
+	if h.strictQuery {
+		mws = append(mws, h.withStrictQuery(rt.method+" "+rt.pattern))
+	}
+	return append(mws, h.withDegradedMode)
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pageTokenVersion prefixes every page token so the cursor format can change without
+// misreading tokens issued by an older server.
+const pageTokenVersion = "v1"
+
+// invalidPageTokenCode starts the 400 response body for a page token the server didn't
+// issue, so clients can tell it apart from other bad requests and restart from page one.
+const invalidPageTokenCode = "invalid_page_token"
+
+// errInvalidPageToken is returned for a page token that is malformed, of an unknown version,
+// signed with another key, or issued for a different ordering.
+var errInvalidPageToken = errors.New("page_token is invalid or was not issued by this server")
+
+// WithPageTokenSecret sets the key page tokens are signed with. Instances sharing a key
+// accept each other's tokens, and tokens stay valid across restarts.
+func WithPageTokenSecret(secret string) Option {
+	return func(h *Handlers) { h.pageTokens = pageTokenSigner{key: []byte(secret)} }
+}
+
+// pageTokenSigner signs page tokens so clients can't forge cursors. A token is
+// "<version>.<base64 payload>.<base64 HMAC-SHA256 of version and payload>".
+type pageTokenSigner struct {
+	key []byte
+}
+
+// newPageTokenSigner returns a signer with a random key, so without WithPageTokenSecret
+// tokens are valid only for the process that issued them.
+func newPageTokenSigner() pageTokenSigner {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return pageTokenSigner{key: key}
+}
+
+func (s pageTokenSigner) mac(version, payload string) []byte {
+	m := hmac.New(sha256.New, s.key)
+	m.Write([]byte(version + "." + payload))
+	return m.Sum(nil)
+}
+
+// encode returns a signed token for payload.
+func (s pageTokenSigner) encode(payload string) string {
+	enc := base64.RawURLEncoding
+	p := enc.EncodeToString([]byte(payload))
+	return pageTokenVersion + "." + p + "." + enc.EncodeToString(s.mac(pageTokenVersion, p))
+}
+
+// decode returns the payload of a token issued by encode, or errInvalidPageToken unless the
+// token is well formed, of the current version, and correctly signed.
+func (s pageTokenSigner) decode(token string) (string, error) {
+	version, rest, _ := strings.Cut(token, ".")
+	p, sig, ok := strings.Cut(rest, ".")
+	if !ok || version != pageTokenVersion {
+		return "", errInvalidPageToken
+	}
+	enc := base64.RawURLEncoding
+	gotMAC, err := enc.DecodeString(sig)
+	if err != nil || !hmac.Equal(gotMAC, s.mac(version, p)) {
+		return "", errInvalidPageToken
+	}
+	payload, err := enc.DecodeString(p)
+	if err != nil {
+		return "", errInvalidPageToken
+	}
+	return string(payload), nil
+}
+
+// invalidPageToken writes the 400 response for errInvalidPageToken.
+func invalidPageToken(w http.ResponseWriter) {
+	http.Error(w, invalidPageTokenCode+": "+errInvalidPageToken.Error(), http.StatusBadRequest)
+}
+
+// creationCursor encodes a (created_at, id) keyset position. The time is carried as unix
+// nanoseconds so no precision is lost between pages.
+func creationCursor(createdAt time.Time, id string) string {
+	return targetOrderCreated + "|" + strconv.FormatInt(createdAt.UnixNano(), 10) + "|" + id
+}
+
+// parseCreationCursor is the inverse of creationCursor.
+func parseCreationCursor(payload string) (time.Time, string, error) {
+	parts := strings.SplitN(payload, "|", 3)
+	if len(parts) != 3 || parts[0] != targetOrderCreated || parts[2] == "" {
+		return time.Time{}, "", errInvalidPageToken
+	}
+	nanos, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return time.Time{}, "", errInvalidPageToken
+	}
+	return time.Unix(0, nanos).UTC(), parts[2], nil
+}
+
+// healthCursor encodes a (rank, created_at, id) keyset position in the health ordering.
+func healthCursor(rank int, createdAt time.Time, id string) string {
+	return targetOrderHealth + "|" + strconv.Itoa(rank) + "|" + strconv.FormatInt(createdAt.UnixNano(), 10) + "|" + id
+}
+
+// parseHealthCursor is the inverse of healthCursor.
+func parseHealthCursor(payload string) (int, time.Time, string, error) {
+	parts := strings.SplitN(payload, "|", 4)
+	if len(parts) != 4 || parts[0] != targetOrderHealth || parts[3] == "" {
+		return 0, time.Time{}, "", errInvalidPageToken
+	}
+	rank, err := strconv.Atoi(parts[1])
+	if err != nil || rank < 0 {
+		return 0, time.Time{}, "", errInvalidPageToken
+	}
+	nanos, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return 0, time.Time{}, "", errInvalidPageToken
+	}
+	return rank, time.Unix(0, nanos).UTC(), parts[3], nil
+}
@@ -3,18 +3,18 @@ package api
 import (
 	"net/http"
 
-	"linkwatch/internal/storage"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
 )
 
-// NewRouter creates a new http.ServeMux and registers the API handlers.
-func NewRouter(store storage.Storer) *http.ServeMux {
+// NewRouter registers the API handlers under each API version, and the health checks, on a
+// new http.ServeMux, and returns it wrapped in the middleware added with WithMiddleware.
+func NewRouter(store storage.Storer, opts ...Option) http.Handler {
 	mux := http.NewServeMux()
-	h := NewHandlers(store)
+	h := NewHandlers(store, opts...)
 
-	mux.HandleFunc("POST /v1/targets", h.CreateTarget)
-	mux.HandleFunc("GET /v1/targets", h.ListTargets)
-	mux.HandleFunc("GET /v1/targets/{target_id}/results", h.ListCheckResults)
+	h.register(mux)
 	mux.HandleFunc("GET /healthz", h.Healthz)
+	mux.HandleFunc("GET /readyz", h.Readyz)
 
-	return mux
+	return Chain(h.middleware...)(mux)
 }
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// maxScheduleWindows caps how many windows a target's schedule may have.
+const maxScheduleWindows = 14
+
+// normalizeSchedule validates a check schedule, lowercasing and deduplicating its days. A
+// schedule needs at least one window, since one without would never check the target.
+func normalizeSchedule(s *models.CheckSchedule) (*models.CheckSchedule, error) {
+	if s == nil {
+		return nil, nil
+	}
+	tz := strings.TrimSpace(s.Timezone)
+	if tz != "" {
+		if _, err := time.LoadLocation(tz); err != nil {
+			return nil, fmt.Errorf("unknown schedule.timezone %q", tz)
+		}
+	}
+	if len(s.Windows) == 0 {
+		return nil, errors.New("schedule.windows must contain at least one window")
+	}
+	if len(s.Windows) > maxScheduleWindows {
+		return nil, fmt.Errorf("schedule.windows must not contain more than %d windows", maxScheduleWindows)
+	}
+	schedule := &models.CheckSchedule{Timezone: tz, Windows: make([]models.ScheduleWindow, 0, len(s.Windows))}
+	for _, w := range s.Windows {
+		if !validClock(w.Start, false) || !validClock(w.End, true) {
+			return nil, fmt.Errorf("invalid schedule window %s-%s, expected times like 09:00 and 17:00", w.Start, w.End)
+		}
+		var days []string
+		for _, d := range w.Days {
+			d = strings.ToLower(strings.TrimSpace(d))
+			if !slices.Contains(models.ScheduleDays, d) {
+				return nil, fmt.Errorf("invalid schedule day %q, expected one of %s", d, strings.Join(models.ScheduleDays, ", "))
+			}
+			if !slices.Contains(days, d) {
+				days = append(days, d)
+			}
+		}
+		schedule.Windows = append(schedule.Windows, models.ScheduleWindow{Days: days, Start: w.Start, End: w.End})
+	}
+	return schedule, nil
+}
+
+// validClock reports whether s is an "HH:MM" time of day. "24:00" is only valid as an end.
+func validClock(s string, end bool) bool {
+	if s == "24:00" {
+		return end
+	}
+	_, err := time.Parse("15:04", s)
+	return err == nil && len(s) == len("15:04")
+}
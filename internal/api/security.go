@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// normalizeSecurityAudit validates a security audit, canonicalizing and deduplicating its
+// required headers.
+func normalizeSecurityAudit(a *models.SecurityAudit) (*models.SecurityAudit, error) {
+	if a == nil {
+		return nil, nil
+	}
+	var required []string
+	for _, name := range a.Required {
+		name = strings.TrimSpace(name)
+		if !validHeaderName(name) {
+			return nil, fmt.Errorf("invalid header name %q", name)
+		}
+		name = http.CanonicalHeaderKey(name)
+		if !slices.Contains(required, name) {
+			required = append(required, name)
+		}
+	}
+	if len(required) > maxCaptureHeaders {
+		return nil, fmt.Errorf("security_audit.required must not contain more than %d headers", maxCaptureHeaders)
+	}
+	return &models.SecurityAudit{Required: required}, nil
+}
+
+// securityHeader is one audited header in a security report.
+type securityHeader struct {
+	Name     string  `json:"name"`
+	Present  bool    `json:"present"`
+	Value    *string `json:"value"`
+	Required bool    `json:"required"`
+}
+
+// GetSecurity handles reporting the security headers found by a target's latest audited
+// check. Headers are judged against the target's current audit, so a header made required
+// since shows as missing right away.
+func (h *Handlers) GetSecurity(w http.ResponseWriter, r *http.Request) {
+	targetID := r.PathValue("target_id")
+	target, err := h.store.GetTargetByID(r.Context(), targetID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "target not found", http.StatusNotFound)
+			return
+		}
+		h.internalError(w, r, "get target error", err)
+		return
+	}
+	if target.SecurityAudit == nil {
+		http.Error(w, "target has no security audit", http.StatusNotFound)
+		return
+	}
+	latest, err := h.store.ListCheckResultsByTargetID(r.Context(), storage.ListCheckResultsParams{
+		TargetID: targetID,
+		Limit:    1,
+		Audited:  true,
+		Fields:   []string{"checked_at", "security"},
+	})
+	if err != nil {
+		h.internalError(w, r, "list results error", err)
+		return
+	}
+
+	resp := struct {
+		TargetID  string           `json:"target_id"`
+		CheckedAt *time.Time       `json:"checked_at"` // Null until an audited check got a response
+		Headers   []securityHeader `json:"headers"`
+		Missing   []string         `json:"missing"`
+	}{TargetID: targetID, Headers: []securityHeader{}, Missing: []string{}}
+	var found map[string]string
+	if len(latest) > 0 {
+		resp.CheckedAt = &latest[0].CheckedAt
+		found = latest[0].Security.Headers
+	}
+	for _, name := range target.SecurityAudit.Headers() {
+		header := securityHeader{Name: name, Required: slices.Contains(target.SecurityAudit.Required, name)}
+		if value, ok := found[name]; ok {
+			header.Present, header.Value = true, &value
+		} else if header.Required && resp.CheckedAt != nil {
+			resp.Missing = append(resp.Missing, name)
+		}
+		resp.Headers = append(resp.Headers, header)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -2,40 +2,278 @@ package api
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
 
-	"linkwatch/internal/storage"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// Server timeouts used when a ServerConfig leaves them unset. Without a header timeout a
+// client can hold a connection open indefinitely by sending its headers slowly.
+const (
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultIdleTimeout       = 2 * time.Minute
 )
 
+// defaultSocketMode lets the socket's owner and group, e.g. a reverse proxy, connect.
+const defaultSocketMode os.FileMode = 0o660
+
+// unixScheme prefixes an Addr naming a Unix domain socket.
+const unixScheme = "unix://"
+
+// ServerConfig configures how a Server listens.
+type ServerConfig struct {
+	// Addr is host:port, where ":8080" listens on every interface, or unix:///path/to.sock
+	// for a Unix domain socket, e.g. behind a reverse proxy on the same machine.
+	Addr       string
+	SocketMode os.FileMode // Permissions of a Unix socket; defaults to 0660
+
+	// CertFile and KeyFile serve HTTPS with that certificate; SelfSigned generates one for
+	// localhost instead, for development. Plain HTTP is served when neither is set.
+	CertFile   string
+	KeyFile    string
+	SelfSigned bool
+	// ACMEDomains serves HTTPS with certificates obtained automatically for those domains
+	// from Let's Encrypt, or the CA at ACMEDirectoryURL, and kept in ACMECacheDir. The CA
+	// has to reach the server on port 443, or on port 80 through RedirectAddr.
+	ACMEDomains      []string
+	ACMECacheDir     string
+	ACMEEmail        string // Contact for expiry notices from the CA
+	ACMEDirectoryURL string
+	// RedirectAddr, when set with HTTPS, also listens for plain HTTP there and redirects
+	// every request to the HTTPS address.
+	RedirectAddr string
+
+	ReadHeaderTimeout time.Duration // Defaults to 10s
+	IdleTimeout       time.Duration // Defaults to 2m
+}
+
 // Server wraps the http.Server to provide graceful shutdown.
 type Server struct {
 	httpServer *http.Server
+	redirect   *http.Server // Set when plain HTTP is redirected to HTTPS
+	listener   net.Listener
+	socketMode os.FileMode
+	handler    atomic.Pointer[http.Handler]
 }
 
 // NewServer creates and configures a new API server.
-func NewServer(port string, store storage.Storer) *Server {
-	router := NewRouter(store)
-	return &Server{
-		httpServer: &http.Server{
-			Addr:    ":" + port,
-			Handler: router,
-		},
+func NewServer(cfg ServerConfig, store storage.Storer, opts ...Option) (*Server, error) {
+	s, err := newServer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s.SetHandler(NewRouter(store, opts...))
+	return s, nil
+}
+
+// NewStartingServer creates a server that can be started before the store is open. Until
+// SetHandler installs the router, it only answers /healthz, and /readyz with the phase of
+// startup; other requests get 503.
+func NewStartingServer(cfg ServerConfig, startup *Startup) (*Server, error) {
+	s, err := newServer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s.SetHandler(startup.handler())
+	return s, nil
+}
+
+// SetHandler replaces the handler serving requests, taking effect for the next request.
+func (s *Server) SetHandler(h http.Handler) {
+	s.handler.Store(&h)
+}
+
+func newServer(cfg ServerConfig) (*Server, error) {
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, errors.New("a TLS certificate and key must be set together")
+	}
+	if cfg.SelfSigned && cfg.CertFile != "" {
+		return nil, errors.New("a self-signed certificate can't be used with a certificate file")
+	}
+	useACME := len(cfg.ACMEDomains) > 0
+	if useACME && (cfg.CertFile != "" || cfg.SelfSigned) {
+		return nil, errors.New("ACME certificates can't be used with a certificate file or a self-signed certificate")
+	}
+	if useACME && cfg.ACMECacheDir == "" {
+		return nil, errors.New("ACME certificates require a cache directory")
+	}
+	useTLS := cfg.CertFile != "" || cfg.SelfSigned || useACME
+	if cfg.RedirectAddr != "" && !useTLS {
+		return nil, errors.New("redirecting to HTTPS requires TLS")
+	}
+	if cfg.ReadHeaderTimeout <= 0 {
+		cfg.ReadHeaderTimeout = defaultReadHeaderTimeout
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = defaultIdleTimeout
+	}
+	if cfg.SocketMode == 0 {
+		cfg.SocketMode = defaultSocketMode
+	}
+	if path, ok := strings.CutPrefix(cfg.Addr, unixScheme); ok && path == "" {
+		return nil, errors.New("a unix socket address needs a path")
+	}
+
+	s := &Server{socketMode: cfg.SocketMode}
+	s.httpServer = &http.Server{
+		Addr: cfg.Addr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			(*s.handler.Load()).ServeHTTP(w, r)
+		}),
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+	var manager *autocert.Manager
+	switch {
+	case useACME:
+		manager = acmeManager(cfg.ACMEDomains, cfg.ACMECacheDir, cfg.ACMEEmail, cfg.ACMEDirectoryURL)
+		s.httpServer.TLSConfig = manager.TLSConfig()
+		s.httpServer.TLSConfig.MinVersion = tls.VersionTLS12
+	case cfg.CertFile != "":
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		s.httpServer.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	case cfg.SelfSigned:
+		cert, err := selfSignedCert(time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate a self-signed certificate: %w", err)
+		}
+		s.httpServer.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
 	}
+	if cfg.RedirectAddr != "" {
+		var handler http.Handler = httpsRedirect(cfg.Addr)
+		if manager != nil {
+			handler = manager.HTTPHandler(handler) // Answers the CA's http-01 challenges
+		}
+		s.redirect = &http.Server{
+			Addr:              cfg.RedirectAddr,
+			Handler:           handler,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+		}
+	}
+	return s, nil
+}
+
+// httpsRedirect redirects every request to the same host and path over HTTPS, on the port of
+// addr unless it is 443. 308 keeps the method and body, so API writes are redirected too.
+func httpsRedirect(addr string) http.Handler {
+	_, port, _ := net.SplitHostPort(addr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]") // IPv6 literals are bracketed again below
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
 }
 
-// Start runs the HTTP server in a new goroutine.
-func (s *Server) Start() {
-	log.Printf("starting HTTP server on port %s", s.httpServer.Addr)
+// Start listens on the configured address and serves in a new goroutine. It fails if the
+// address can't be listened on.
+func (s *Server) Start() error {
+	ln, err := s.listen()
+	if err != nil {
+		return fmt.Errorf("could not listen on %s: %w", s.httpServer.Addr, err)
+	}
+	s.listener = ln
+	scheme := "HTTP"
+	if s.httpServer.TLSConfig != nil {
+		scheme = "HTTPS"
+	}
+	logging.API.Infof("starting %s server on %s", scheme, ln.Addr())
 	go func() {
-		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if s.httpServer.TLSConfig != nil {
+			err = s.httpServer.ServeTLS(ln, "", "")
+		} else {
+			err = s.httpServer.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("could not start HTTP server: %v", err)
 		}
 	}()
+	if s.redirect != nil {
+		rln, err := net.Listen("tcp", s.redirect.Addr)
+		if err != nil {
+			return fmt.Errorf("could not listen on %s: %w", s.redirect.Addr, err)
+		}
+		logging.API.Infof("redirecting HTTP on %s to HTTPS", rln.Addr())
+		go func() {
+			if err := s.redirect.Serve(rln); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("could not start HTTP redirect server: %v", err)
+			}
+		}()
+	}
+	return nil
+}
+
+// listen opens the configured TCP address or Unix socket. A socket file left behind by a
+// process that didn't shut down cleanly is replaced, but one that still accepts connections
+// is not. The socket file is removed again when the server shuts down.
+func (s *Server) listen() (net.Listener, error) {
+	path, ok := strings.CutPrefix(s.httpServer.Addr, unixScheme)
+	if !ok {
+		return net.Listen("tcp", s.httpServer.Addr)
+	}
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, errors.New("file exists and isn't a socket")
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, errors.New("socket is in use by another process")
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, s.socketMode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+	return ln, nil
+}
+
+// Addr returns the address the server listens on, once started; with port 0 in the
+// configured address this is where the port chosen by the system shows up.
+func (s *Server) Addr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
 }
 
 // Shutdown gracefully shuts down the HTTP server.
 func (s *Server) Shutdown(ctx context.Context) error {
-	log.Println("shutting down HTTP server...")
+	logging.API.Infof("shutting down HTTP server...")
+	if s.redirect != nil {
+		if err := s.redirect.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
 	return s.httpServer.Shutdown(ctx)
 }
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// snapshotStatuses are the statuses GET /snapshot can filter by.
+var snapshotStatuses = []string{models.TargetStatusUp, models.TargetStatusWarning, models.TargetStatusDown, models.TargetStatusUnknown}
+
+// Snapshot handles reporting every target's state at ?at=, e.g. for a post-incident review of
+// what was down at a given minute. The status is where the target's last transition up to
+// then left it, and the details come from its last stored result up to then. Targets created
+// later are left out. Targets are paged in creation order like GET /targets; with ?status= a
+// page holds the next limit targets that were in that status.
+func (h *Handlers) Snapshot(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	at, err := parseTimestamp(q, "at")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if at == nil {
+		http.Error(w, "at is required", http.StatusBadRequest)
+		return
+	}
+	if at.After(h.clock.Now()) {
+		http.Error(w, "at must not be in the future", http.StatusBadRequest)
+		return
+	}
+	limit := 50
+	if l := q.Get("limit"); l != "" {
+		if v, err := strconv.Atoi(l); err == nil && v > 0 && v <= 500 {
+			limit = v
+		}
+	}
+	status := q.Get("status")
+	if status != "" && !slices.Contains(snapshotStatuses, status) {
+		http.Error(w, "status must be one of: "+strings.Join(snapshotStatuses, ", "), http.StatusBadRequest)
+		return
+	}
+	host := strings.ToLower(strings.TrimSpace(q.Get("host")))
+	metadata, err := parseMetadataFilter(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var afterTime time.Time
+	var afterID string
+	if token := q.Get("page_token"); token != "" {
+		payload, err := h.pageTokens.decode(token)
+		if err == nil {
+			afterTime, afterID, err = parseCreationCursor(payload)
+		}
+		if err != nil {
+			invalidPageToken(w)
+			return
+		}
+	}
+
+	items := []models.TargetSnapshot{}
+	var next string
+scan:
+	for {
+		targets, err := h.store.ListTargets(r.Context(), storage.ListTargetsParams{
+			Host:      host,
+			AfterTime: afterTime,
+			AfterID:   afterID,
+			Limit:     limit,
+			Metadata:  metadata,
+		})
+		if err != nil {
+			h.internalError(w, r, "list targets error", err)
+			return
+		}
+		more := len(targets) == limit
+		// Targets come in creation order, so the first one created after at ends the list.
+		if later := slices.IndexFunc(targets, func(t models.Target) bool { return t.CreatedAt.After(*at) }); later >= 0 {
+			targets, more = targets[:later], false
+		}
+		snapshots, err := h.snapshotTargets(r.Context(), targets, *at)
+		if err != nil {
+			h.internalError(w, r, "snapshot error", err)
+			return
+		}
+		for i, s := range snapshots {
+			if status != "" && s.State.Status != status {
+				continue
+			}
+			items = append(items, s)
+			if len(items) == limit {
+				if more || i < len(targets)-1 {
+					next = h.pageTokens.encode(creationCursor(targets[i].CreatedAt, targets[i].ID))
+				}
+				break scan
+			}
+		}
+		if !more {
+			break
+		}
+		last := targets[len(targets)-1]
+		afterTime, afterID = last.CreatedAt, last.ID
+	}
+
+	resp := struct {
+		At            time.Time               `json:"at"`
+		Items         []models.TargetSnapshot `json:"items"`
+		NextPageToken string                  `json:"next_page_token"`
+	}{At: *at, Items: items, NextPageToken: next}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// snapshotTargets reconstructs the state of each of targets at at.
+func (h *Handlers) snapshotTargets(ctx context.Context, targets []models.Target, at time.Time) ([]models.TargetSnapshot, error) {
+	ids := make([]string, len(targets))
+	for i, t := range targets {
+		ids[i] = t.ID
+	}
+	results, err := h.store.GetLatestResultsAt(ctx, ids, at)
+	if err != nil {
+		return nil, err
+	}
+	transitions, err := h.store.GetLatestTransitionsAt(ctx, ids, at)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]models.TargetSnapshot, len(targets))
+	for i, t := range targets {
+		var latest *models.CheckResult
+		if result, ok := results[t.ID]; ok {
+			latest = &result
+		}
+		s := models.TargetSnapshot{TargetID: t.ID, URL: t.URL, Host: t.Host, State: models.StateFromResult(latest)}
+		// Transitions outlive the results that sampling and archiving delete, so they decide
+		// the status.
+		if tr, ok := transitions[t.ID]; ok {
+			since := tr.At
+			s.State.Status, s.StatusSince, s.Cause = tr.ToStatus, &since, tr.Cause
+		}
+		snapshots[i] = s
+	}
+	return snapshots, nil
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// maxSnooze bounds how long a target can be snoozed at once.
+const maxSnooze = 30 * 24 * time.Hour
+
+// SnoozeTarget handles suspending a target's checks and alerts for a duration. The scheduler
+// skips the target until the deadline passes, after which it is checked again without
+// another request. Snoozing a snoozed target replaces its deadline.
+func (h *Handlers) SnoozeTarget(w http.ResponseWriter, r *http.Request) {
+	var reqBody struct {
+		Duration string `json:"duration"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	d, err := parseDuration(reqBody.Duration)
+	if err != nil || d <= 0 || d > maxSnooze {
+		http.Error(w, fmt.Sprintf("duration must be a positive duration of at most %s", maxSnooze), http.StatusBadRequest)
+		return
+	}
+
+	until := h.clock.Now().UTC().Add(d)
+	target, err := h.store.SetSnooze(r.Context(), r.PathValue("target_id"), &until)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "target not found", http.StatusNotFound)
+			return
+		}
+		h.internalError(w, r, "snooze target error", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versionedTarget(r.Context(), target))
+}
+
+// UnsnoozeTarget handles ending a target's snooze early. Its checks resume from the next
+// scheduling cycle.
+func (h *Handlers) UnsnoozeTarget(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.store.SetSnooze(r.Context(), r.PathValue("target_id"), nil); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "target not found", http.StatusNotFound)
+			return
+		}
+		h.internalError(w, r, "unsnooze target error", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
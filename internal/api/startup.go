@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// startingRetryAfter is the Retry-After, in seconds, sent while the process is starting.
+const startingRetryAfter = "5"
+
+// Startup tracks the phase of a starting process (see models.StartupMigrating and the
+// phases after it), so /readyz only reports ready once every phase has completed.
+type Startup struct {
+	mu    sync.Mutex
+	phase string
+	since time.Time
+}
+
+// NewStartup returns a Startup in the first phase.
+func NewStartup() *Startup {
+	return &Startup{phase: models.StartupMigrating, since: time.Now()}
+}
+
+// Set moves to phase, logging how long the previous one took.
+func (s *Startup) Set(phase string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	logging.API.Infof("startup: %s done in %s, now %s", s.phase, time.Since(s.since).Round(time.Millisecond), phase)
+	s.phase, s.since = phase, time.Now()
+}
+
+// Phase returns the current phase.
+func (s *Startup) Phase() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.phase
+}
+
+// Ready reports whether every phase has completed.
+func (s *Startup) Ready() bool { return s.Phase() == models.StartupReady }
+
+// writeStarting answers /readyz while the process is starting.
+func writeStarting(w http.ResponseWriter, phase string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", startingRetryAfter)
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(struct {
+		Status    string                  `json:"status"`
+		Phase     string                  `json:"phase"`
+		Databases []models.DatabaseHealth `json:"databases"`
+	}{Status: "starting", Phase: phase, Databases: []models.DatabaseHealth{}})
+}
+
+// handler serves requests until the router is ready: /healthz reports that the process is
+// up and /readyz the phase, and everything else is refused with 503.
+func (s *Startup) handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz":
+			w.WriteHeader(http.StatusOK)
+		case "/readyz":
+			writeStarting(w, s.Phase())
+		default:
+			w.Header().Set("Retry-After", startingRetryAfter)
+			http.Error(w, "service is starting", http.StatusServiceUnavailable)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// invalidQueryCode is the error code of a request rejected by strict query validation.
+const invalidQueryCode = "invalid_query"
+
+// WithStrictQueryParams rejects requests with unknown query parameters, or with a limit,
+// page_token, since, or until that would otherwise be ignored or fall back to a default,
+// with a 400 listing every offending parameter. Off by default, since clients may rely on
+// the lenient behaviour.
+func WithStrictQueryParams() Option {
+	return func(h *Handlers) { h.strictQuery = true }
+}
+
+// querySpec lists the query parameters a route accepts.
+type querySpec struct {
+	params   []string
+	metadata bool // metadata.<key> filters are accepted
+	maxLimit int  // Largest accepted limit, when limit is one of params
+}
+
+// routeQueries is the querySpec of every route taking query parameters, keyed by method and
+// pattern. Routes missing here accept none.
+var routeQueries = map[string]querySpec{
+	"GET /targets":                              {params: []string{"limit", "page_token", "host", "fields", "include_total", "order_by"}, metadata: true, maxLimit: 500},
+	"DELETE /targets":                           {params: []string{"host", "confirm", "dry_run"}, metadata: true},
+	"GET /targets/{target_id}/results":          {params: []string{"limit", "since", "until", "fields", "header"}, maxLimit: 1000},
+	"GET /targets/{target_id}/results/compare":  {params: []string{"from", "to"}},
+	"GET /targets/{target_id}/timeseries":       {params: []string{"bucket", "window", "since", "until", "fill"}},
+	"GET /targets/{target_id}/transitions":      {params: []string{"limit", "since", "until"}, maxLimit: 1000},
+	"GET /targets/{target_id}/downtime":         {params: []string{"window", "since", "until"}},
+	"GET /targets/{target_id}/status-breakdown": {params: []string{"window", "since", "until"}},
+	"GET /results":                              {params: []string{"target_ids", "limit", "since", "until", "fields"}, maxLimit: 1000},
+	"GET /hosts":                                {params: []string{"window", "since", "until", "order_by"}},
+	"GET /reports/top":                          {params: []string{"metric", "window", "since", "until", "limit"}, maxLimit: 500},
+	"GET /reports/bandwidth":                    {params: []string{"window", "since", "until", "group_by", "host", "target_id"}},
+	"POST /reports/send":                        {params: []string{"period"}},
+	"GET /admin/errors":                         {params: []string{"request_id"}},
+	"GET /events":                               {params: []string{"type", "limit", "since", "until"}, maxLimit: 1000},
+	"GET /snapshot":                             {params: []string{"at", "limit", "page_token", "host", "status"}, metadata: true, maxLimit: 500},
+}
+
+// fieldError describes why one query parameter was rejected.
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// withStrictQuery validates the query of requests to the route key (see routeQueries) before
+// they reach the handler. Parameters the handler validates itself, such as order_by, are left to it.
+func (h *Handlers) withStrictQuery(key string) Middleware {
+	spec := routeQueries[key]
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if errs := h.checkQuery(spec, r); len(errs) > 0 {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(struct {
+					Error  string       `json:"error"`
+					Fields []fieldError `json:"fields"`
+				}{invalidQueryCode, errs})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// checkQuery returns an error for every parameter of r's query that spec doesn't accept or
+// that is malformed, ordered by parameter name.
+func (h *Handlers) checkQuery(spec querySpec, r *http.Request) []fieldError {
+	q := r.URL.Query()
+	var errs []fieldError
+	for _, name := range slices.Sorted(maps.Keys(q)) {
+		if !slices.Contains(spec.params, name) && !(spec.metadata && strings.HasPrefix(name, "metadata.")) {
+			errs = append(errs, fieldError{name, "unknown query parameter"})
+			continue
+		}
+		if len(q[name]) > 1 {
+			errs = append(errs, fieldError{name, "must be given at most once"})
+			continue
+		}
+		var msg string
+		switch value := q.Get(name); {
+		case value == "":
+			// An empty value is the same as leaving the parameter out.
+		case name == "limit":
+			if v, err := strconv.Atoi(value); err != nil || v <= 0 || v > spec.maxLimit {
+				msg = fmt.Sprintf("limit must be an integer between 1 and %d", spec.maxLimit)
+			}
+		case name == "page_token":
+			if _, err := h.pageTokens.decode(value); err != nil {
+				msg = errInvalidPageToken.Error()
+			}
+		case name == "since" || name == "until":
+			if _, err := parseTimestamp(q, name); err != nil {
+				msg = err.Error()
+			}
+		}
+		if msg != "" {
+			errs = append(errs, fieldError{name, msg})
+		}
+	}
+	return errs
+}
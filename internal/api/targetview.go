@@ -0,0 +1,47 @@
+package api
+
+import (
+	"context"
+	"slices"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// targetV2 is the v2 shape of a target. It adds the canonical URL that duplicate detection
+// compares and the host that checks are grouped and limited by, both hidden in v1.
+type targetV2 struct {
+	models.Target
+	CanonicalURL string `json:"canonical_url"`
+	Host         string `json:"host,omitempty"` // Empty for heartbeat targets
+}
+
+// targetFieldsV2 are the fields a v2 target list can project.
+var targetFieldsV2 = append(slices.Clone(targetFields), "canonical_url", "host")
+
+// versionedTarget returns t in the response shape of the request's API version.
+func versionedTarget(ctx context.Context, t *models.Target) interface{} {
+	if apiVersion(ctx) == V1 {
+		return t
+	}
+	return targetV2{Target: *t, CanonicalURL: t.CanonicalURL, Host: t.Host}
+}
+
+// versionedTargets returns targets in the response shape of the request's API version.
+func versionedTargets(ctx context.Context, targets []models.Target) []interface{} {
+	if targets == nil {
+		return nil // Keeps the null that v1 sends for an empty list
+	}
+	out := make([]interface{}, len(targets))
+	for i := range targets {
+		out[i] = versionedTarget(ctx, &targets[i])
+	}
+	return out
+}
+
+// versionedTargetFields returns the fields a target list can project in the request's version.
+func versionedTargetFields(ctx context.Context) []string {
+	if apiVersion(ctx) == V1 {
+		return targetFields
+	}
+	return targetFieldsV2
+}
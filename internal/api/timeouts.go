@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// timeoutCode is the error code of a request that ran past its deadline.
+const timeoutCode = "timeout"
+
+// errRequestTimeout is the cause of a request context cancelled by withDeadline, telling it
+// apart from a deadline the client's own context carried.
+var errRequestTimeout = errors.New("request deadline exceeded")
+
+// slowRouteTimeouts are the deadlines of routes that do much more work than a single read or
+// write, such as fetching a sitemap or deleting many targets, unless RequestTimeouts sets them.
+var slowRouteTimeouts = map[string]time.Duration{
+	"POST /targets/batch": 30 * time.Second,
+	"DELETE /targets":     30 * time.Second,
+	"POST /discover":      30 * time.Second,
+	"POST /reports/send":  30 * time.Second,
+}
+
+// RequestTimeouts sets how long a request may run before its context is cancelled, so that a
+// slow database makes requests fail quickly instead of piling up.
+type RequestTimeouts struct {
+	Read  time.Duration // GET and HEAD requests
+	Write time.Duration // Requests with other methods
+	// Routes overrides the deadline of single routes, keyed by method and pattern as in
+	// "GET /targets". Zero, here or above, leaves requests without a deadline.
+	Routes map[string]time.Duration
+}
+
+// WithRequestTimeouts gives every API request a deadline. A request whose handler fails
+// after its deadline passed is answered with a 503 and a "timeout" error instead of a 500.
+func WithRequestTimeouts(t RequestTimeouts) Option {
+	return func(h *Handlers) { h.timeouts = &t }
+}
+
+// ParseRouteTimeouts parses per-route deadlines given as "METHOD /pattern=duration", e.g.
+// "GET /targets=10s", into RequestTimeouts.Routes.
+func ParseRouteTimeouts(raw []string) (map[string]time.Duration, error) {
+	routes := make(map[string]time.Duration, len(raw))
+	for _, r := range raw {
+		key, value, ok := strings.Cut(r, "=")
+		method, pattern, hasPattern := strings.Cut(key, " ")
+		if !ok || !hasPattern || method == "" || !strings.HasPrefix(pattern, "/") {
+			return nil, fmt.Errorf("invalid route timeout %q, expected METHOD /pattern=duration", r)
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid duration in route timeout %q", r)
+		}
+		routes[strings.ToUpper(method)+" "+pattern] = d
+	}
+	return routes, nil
+}
+
+// routeTimeout returns the deadline of a route, or zero for none.
+func (t *RequestTimeouts) routeTimeout(rt route) time.Duration {
+	key := rt.method + " " + rt.pattern
+	if d, ok := t.Routes[key]; ok {
+		return d
+	}
+	if d, ok := slowRouteTimeouts[key]; ok {
+		return d
+	}
+	if rt.method == http.MethodGet || rt.method == http.MethodHead {
+		return t.Read
+	}
+	return t.Write
+}
+
+// withDeadline cancels the request context after timeout.
+func withDeadline(timeout time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeoutCause(r.Context(), timeout, errRequestTimeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// deadlineExceeded reports whether r ran past the deadline set by withDeadline.
+func deadlineExceeded(r *http.Request) bool {
+	return errors.Is(context.Cause(r.Context()), errRequestTimeout)
+}
+
+// timeoutError responds to a request that ran past its deadline.
+func timeoutError(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(struct {
+		Error     string `json:"error"`
+		Message   string `json:"message"`
+		RequestID string `json:"request_id,omitempty"`
+	}{timeoutCode, "the request did not complete in time", requestID(r.Context())})
+}
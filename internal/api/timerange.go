@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// parseTimestamp parses the RFC 3339 query parameter name, which may carry any UTC offset,
+// as a UTC time. It returns nil when the parameter isn't set.
+func parseTimestamp(q url.Values, name string) (*time.Time, error) {
+	raw := q.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	t, err := parseRFC3339(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 timestamp with a UTC offset, e.g. 2024-01-02T15:04:05Z or 2024-01-02T10:04:05-05:00", name)
+	}
+	return &t, nil
+}
+
+// parseRFC3339 parses an RFC 3339 timestamp from a query as a UTC time.
+func parseRFC3339(raw string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil && strings.Contains(raw, " ") {
+		// An unescaped "+05:00" offset arrives as " 05:00".
+		t, err = time.Parse(time.RFC3339, strings.ReplaceAll(raw, " ", "+"))
+	}
+	return t.UTC(), err
+}
+
+// parseInterval parses the required query parameter name as an ISO 8601 interval of two
+// RFC 3339 timestamps, <start>/<end>, with start before end.
+func parseInterval(q url.Values, name string) (since, until time.Time, err error) {
+	raw := q.Get(name)
+	if raw == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("%s is required", name)
+	}
+	start, end, ok := strings.Cut(raw, "/")
+	if ok {
+		if since, err = parseRFC3339(start); err == nil {
+			until, err = parseRFC3339(end)
+		}
+	}
+	if !ok || err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%s must be two RFC 3339 timestamps separated by a slash, e.g. 2024-01-01T00:00:00Z/2024-01-02T00:00:00Z", name)
+	}
+	if !since.Before(until) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%s must start before it ends", name)
+	}
+	return since, until, nil
+}
+
+// parseTimeRange parses the optional since and until query parameters, rejecting a range
+// that ends before it starts.
+func parseTimeRange(q url.Values) (since, until *time.Time, err error) {
+	if since, err = parseTimestamp(q, "since"); err != nil {
+		return nil, nil, err
+	}
+	if until, err = parseTimestamp(q, "until"); err != nil {
+		return nil, nil, err
+	}
+	if since != nil && until != nil && !since.Before(*until) {
+		return nil, nil, errors.New("since must be before until")
+	}
+	return since, until, nil
+}
+
+// parseWindow returns the time range a statistics endpoint covers: the window (a duration,
+// defaultWindow when unset) up to until, or now. since can be given instead of window.
+func parseWindow(q url.Values, defaultWindow time.Duration, now time.Time) (since, until time.Time, err error) {
+	sincePtr, untilPtr, err := parseTimeRange(q)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	until = now.UTC()
+	if untilPtr != nil {
+		until = *untilPtr
+	}
+	window := defaultWindow
+	if wnd := q.Get("window"); wnd != "" {
+		if sincePtr != nil {
+			return time.Time{}, time.Time{}, errors.New("since and window can't be combined")
+		}
+		v, err := parseDuration(wnd)
+		if err != nil || v <= 0 {
+			return time.Time{}, time.Time{}, errors.New("window must be a positive duration")
+		}
+		window = v
+	}
+	if sincePtr != nil {
+		if !sincePtr.Before(until) {
+			return time.Time{}, time.Time{}, errors.New("since must be before until")
+		}
+		return *sincePtr, until, nil
+	}
+	return until.Add(-window), until, nil
+}
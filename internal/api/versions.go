@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// API versions served by the router.
+const (
+	V1 = "v1"
+	V2 = "v2"
+)
+
+// Deprecation describes when an API version was deprecated and when it stops being served.
+// Responses from a deprecated version carry Deprecation (RFC 9745), Sunset (RFC 8594), and
+// Link headers so clients can find out before it goes away.
+type Deprecation struct {
+	At     time.Time // When the version was deprecated; zero omits the Deprecation header
+	Sunset time.Time // When the version will be removed; zero omits the Sunset header
+	Link   string    // Optional URL of migration docs, sent as rel="deprecation"
+}
+
+// route is a versioned endpoint. Patterns are relative to the version prefix.
+type route struct {
+	method  string
+	pattern string
+	handler http.HandlerFunc
+}
+
+// version is a set of routes served under /<name>.
+type version struct {
+	name   string
+	routes []route
+}
+
+// WithDeprecation marks an API version as deprecated.
+func WithDeprecation(name string, d Deprecation) Option {
+	return func(h *Handlers) {
+		if h.deprecations == nil {
+			h.deprecations = make(map[string]Deprecation)
+		}
+		h.deprecations[name] = d
+	}
+}
+
+// v1Routes is the frozen v1 API. Existing routes must keep their request and response shapes;
+// breaking changes belong in v2.
+func (h *Handlers) v1Routes() []route {
+	return []route{
+		{"POST", "/targets", h.CreateTarget},
+		{"POST", "/targets/batch", h.CreateTargetsBatch},
+		{"PATCH", "/targets/batch", h.UpdateTargetsBatch},
+		{"GET", "/targets", h.ListTargets},
+		{"DELETE", "/targets", h.DeleteTargets},
+		{"PATCH", "/targets/{target_id}", h.UpdateTarget},
+		{"POST", "/targets/{target_id}/snooze", h.SnoozeTarget},
+		{"DELETE", "/targets/{target_id}/snooze", h.UnsnoozeTarget},
+		{"GET", "/targets/{target_id}/results", h.ListCheckResults},
+		{"GET", "/targets/{target_id}/results/compare", h.CompareResults},
+		{"GET", "/targets/{target_id}/timeseries", h.GetTimeseries},
+		{"GET", "/targets/{target_id}/aliases", h.ListTargetAliases},
+		{"GET", "/targets/{target_id}/transitions", h.ListTransitions},
+		{"GET", "/targets/{target_id}/downtime", h.GetDowntime},
+		{"GET", "/targets/{target_id}/status-breakdown", h.GetStatusBreakdown},
+		{"GET", "/targets/{target_id}/security", h.GetSecurity},
+		{"GET", "/results", h.ListRecentResults},
+		{"GET", "/hosts", h.ListHosts},
+		{"POST", "/hosts/{host}/pause", h.PauseHost},
+		{"POST", "/hosts/{host}/resume", h.ResumeHost},
+		{"PUT", "/hosts/{host}/client-certificate", h.PutClientCertificate},
+		{"GET", "/hosts/{host}/client-certificate", h.GetClientCertificate},
+		{"DELETE", "/hosts/{host}/client-certificate", h.DeleteClientCertificate},
+		{"GET", "/domains", h.ListDomains},
+		{"GET", "/reports/top", h.TopTargets},
+		{"GET", "/reports/bandwidth", h.BandwidthReport},
+		{"POST", "/reports/send", h.SendReport},
+		{"POST", "/heartbeats/{token}", h.Heartbeat},
+		{"POST", "/discover", h.Discover},
+		{"POST", "/crawl", h.StartCrawl},
+		{"GET", "/crawl/{job_id}", h.GetCrawl},
+		{"GET", "/jobs/{job_id}", h.GetJob},
+		{"POST", "/jobs/{job_id}/cancel", h.CancelJob},
+		{"GET", "/admin/queue", h.GetQueue},
+		{"GET", "/admin/workers", h.GetWorkers},
+		{"PUT", "/admin/workers", h.ResizeWorkers},
+		{"GET", "/admin/errors", h.ListErrors},
+		{"POST", "/admin/database/maintenance", h.MaintainDatabase},
+		{"GET", "/admin/client-certificates", h.ListClientCertificates},
+		{"GET", "/admin/log-levels", h.GetLogLevels},
+		{"PUT", "/admin/log-levels", h.SetLogLevels},
+		{"GET", "/events", h.ListSystemEvents},
+		{"GET", "/snapshot", h.Snapshot},
+	}
+}
+
+// v2Routes is the v2 API. It starts out serving the v1 handlers; handlers that need to
+// change shape branch on apiVersion, or are replaced here, without touching v1.
+func (h *Handlers) v2Routes() []route {
+	return h.v1Routes()
+}
+
+// versions lists every API version served, oldest first.
+func (h *Handlers) versions() []version {
+	return []version{
+		{name: V1, routes: h.v1Routes()},
+		{name: V2, routes: h.v2Routes()},
+	}
+}
+
+// register adds every route of every version to the mux, wrapped in its routeMiddleware.
+func (h *Handlers) register(mux *http.ServeMux) {
+	for _, v := range h.versions() {
+		for _, rt := range v.routes {
+			mux.Handle(rt.method+" /"+v.name+rt.pattern, Chain(h.routeMiddleware(v.name, rt)...)(rt.handler))
+		}
+	}
+}
+
+type versionKey struct{}
+
+// withVersion records the API version in the request context.
+func withVersion(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), versionKey{}, name)))
+		})
+	}
+}
+
+// apiVersion returns the API version a request was routed to, defaulting to v1.
+func apiVersion(ctx context.Context) string {
+	if v, ok := ctx.Value(versionKey{}).(string); ok {
+		return v
+	}
+	return V1
+}
+
+// withDeprecation sets the deprecation headers before the handler writes its response.
+func withDeprecation(d Deprecation) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !d.At.IsZero() {
+				w.Header().Set("Deprecation", "@"+strconv.FormatInt(d.At.Unix(), 10))
+			}
+			if !d.Sunset.IsZero() {
+				w.Header().Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if d.Link != "" {
+				w.Header().Add("Link", "<"+d.Link+">; rel=\"deprecation\"")
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// Wasm check limits, so a target's config stays a handful of settings.
+const (
+	maxWasmConfig    = 32
+	maxWasmConfigLen = 1024
+)
+
+// WithWasmChecks allows wasm targets, whose modules must be .wasm files in dir, the plugins
+// directory the checker loads them from. Without it creating a wasm target is refused.
+func WithWasmChecks(dir string) Option {
+	return func(h *Handlers) { h.wasmDir = dir }
+}
+
+// normalizeWasmCheck validates a wasm target's module: a plain name of a .wasm file in the
+// plugins directory, with a bounded config.
+func (h *Handlers) normalizeWasmCheck(w *models.WasmCheck) (*models.WasmCheck, error) {
+	if h.wasmDir == "" {
+		return nil, errors.New("wasm targets are disabled")
+	}
+	if w == nil || w.Module == "" {
+		return nil, errors.New("wasm.module is required for wasm targets")
+	}
+	if w.Module != filepath.Base(w.Module) || filepath.Ext(w.Module) != ".wasm" {
+		return nil, fmt.Errorf("invalid wasm.module %q, expected the name of a .wasm file in the plugins directory", w.Module)
+	}
+	info, err := os.Stat(filepath.Join(h.wasmDir, w.Module))
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("wasm.module %q is not a module in the plugins directory", w.Module)
+	}
+	if len(w.Config) > maxWasmConfig {
+		return nil, fmt.Errorf("wasm.config must not contain more than %d entries", maxWasmConfig)
+	}
+	for key, value := range w.Config {
+		if key == "" || strings.Contains(key, "=") {
+			return nil, fmt.Errorf("invalid wasm.config key %q, expected a non-empty name without =", key)
+		}
+		if len(key) > maxWasmConfigLen || len(value) > maxWasmConfigLen {
+			return nil, fmt.Errorf("wasm.config keys and values must not be longer than %d bytes each", maxWasmConfigLen)
+		}
+	}
+	var config map[string]string
+	if len(w.Config) > 0 {
+		config = w.Config
+	}
+	return &models.WasmCheck{Module: w.Module, Config: config}, nil
+}
@@ -0,0 +1,218 @@
+// Package archive moves aged check results out of the database into compressed NDJSON
+// objects in S3-compatible storage, and restores them when statistics reach back that far.
+//
+// Results are archived a whole UTC day at a time, to one object per day: one JSON check
+// result per line, with its target_id, gzipped. The store records every archived day, so a
+// day is archived once, and a restore knows which objects to read without listing the bucket.
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/clock"
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+const (
+	// maxDaysPerRun bounds how many days one archive run exports, so a first run against a
+	// long history is spread over several intervals.
+	maxDaysPerRun = 7
+	// restoreBatch is how many results are inserted per transaction when restoring.
+	restoreBatch = 500
+	// defaultRestoreTTL is how long restored results are kept when Config leaves it unset.
+	defaultRestoreTTL = 24 * time.Hour
+)
+
+// Config configures an Archiver.
+type Config struct {
+	After      time.Duration // Results are archived once they are older than this
+	RestoreTTL time.Duration // How long restored results are kept locally; defaults to 24h
+	Prefix     string        // Prepended to every object key
+	S3         S3Config
+	Clock      clock.Clock // Times restores; defaults to clock.Real
+}
+
+// record is a check result as written to an archive. The API's JSON form leaves out the
+// target ID, so it is carried alongside.
+type record struct {
+	TargetID string `json:"target_id"`
+	models.CheckResult
+}
+
+// Archiver exports aged results from a store and restores them on demand.
+type Archiver struct {
+	store      storage.ResultArchiveStore
+	s3         *s3Client
+	after      time.Duration
+	restoreTTL time.Duration
+	prefix     string
+	clock      clock.Clock
+
+	restoreMu sync.Mutex // Serializes restores, so concurrent queries read each day once
+}
+
+// New returns an Archiver for store, sending requests to object storage with client.
+func New(store storage.ResultArchiveStore, cfg Config, client *http.Client) (*Archiver, error) {
+	if cfg.After <= 0 {
+		return nil, errors.New("the archive age must be positive")
+	}
+	s3, err := newS3Client(cfg.S3, client)
+	if err != nil {
+		return nil, err
+	}
+	a := &Archiver{store: store, s3: s3, after: cfg.After, restoreTTL: cfg.RestoreTTL, prefix: cfg.Prefix, clock: cfg.Clock}
+	if a.restoreTTL <= 0 {
+		a.restoreTTL = defaultRestoreTTL
+	}
+	if a.clock == nil {
+		a.clock = clock.Real
+	}
+	return a, nil
+}
+
+// objectKey returns where day's results are archived.
+func (a *Archiver) objectKey(day time.Time) string {
+	key := "check_results/" + day.Format("2006/01/02") + ".ndjson.gz"
+	if a.prefix != "" {
+		key = a.prefix + "/" + key
+	}
+	return key
+}
+
+// ArchiveCheckResults prunes results restored more than the restore TTL ago, then exports
+// each day that ended more than After before now and prunes it locally. Results that changed
+// a target's status are kept locally, so transitions and downtime are unaffected. It returns
+// how many results were deleted.
+func (a *Archiver) ArchiveCheckResults(ctx context.Context, now time.Time) (int, error) {
+	deleted, err := a.store.PruneRestoredResults(ctx, now.Add(-a.restoreTTL))
+	if err != nil {
+		return 0, err
+	}
+	cutoff := now.UTC().Add(-a.after).Truncate(24 * time.Hour)
+	days, err := a.store.UnarchivedResultDays(ctx, cutoff, maxDaysPerRun)
+	if err != nil {
+		return deleted, err
+	}
+	for _, day := range days {
+		n, err := a.archiveDay(ctx, day, now)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to archive %s: %w", day.Format("2006-01-02"), err)
+		}
+		deleted += n
+	}
+	return deleted, nil
+}
+
+// archiveDay uploads the results of day and prunes them once the upload has succeeded. A
+// run interrupted in between uploads the same object again next time.
+func (a *Archiver) archiveDay(ctx context.Context, day, now time.Time) (int, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	count := 0
+	err := a.store.ExportCheckResults(ctx, day, day.AddDate(0, 0, 1), func(r models.CheckResult) error {
+		count++
+		return enc.Encode(record{TargetID: r.TargetID, CheckResult: r})
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := zw.Close(); err != nil {
+		return 0, fmt.Errorf("failed to compress results: %w", err)
+	}
+	key := a.objectKey(day)
+	if err := a.s3.put(ctx, key, "application/gzip", buf.Bytes()); err != nil {
+		return 0, fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	n, err := a.store.MarkResultsArchived(ctx, storage.ArchivedDay{Day: day, Object: key, Results: count, ArchivedAt: now.UTC()})
+	if err != nil {
+		return 0, err
+	}
+	logging.Storage.Infof("archived %d check results from %s to %s", count, day.Format("2006-01-02"), key)
+	return n, nil
+}
+
+// RestoreCheckResults makes the archived results checked in [since, until) available in the
+// store again, reading each archived day that isn't restored already. Restored results are
+// pruned again after the restore TTL. It returns how many results were restored.
+func (a *Archiver) RestoreCheckResults(ctx context.Context, since, until time.Time) (int, error) {
+	a.restoreMu.Lock()
+	defer a.restoreMu.Unlock()
+	days, err := a.store.ListArchivedDays(ctx, since, until)
+	if err != nil {
+		return 0, err
+	}
+	restored := 0
+	for _, d := range days {
+		if d.RestoredAt != nil {
+			continue
+		}
+		n, err := a.restoreDay(ctx, d)
+		if err != nil {
+			return restored, fmt.Errorf("failed to restore %s: %w", d.Day.Format("2006-01-02"), err)
+		}
+		restored += n
+	}
+	return restored, nil
+}
+
+// restoreDay reads an archived day back into the store.
+func (a *Archiver) restoreDay(ctx context.Context, d storage.ArchivedDay) (int, error) {
+	body, err := a.s3.get(ctx, d.Object)
+	if errors.Is(err, errObjectNotFound) {
+		// Removed from the bucket, e.g. by a lifecycle rule; there's nothing to restore.
+		logging.Storage.Warnf("archived check results %s are missing from object storage", d.Object)
+		return 0, a.store.MarkDayRestored(ctx, d.Day, a.clock.Now())
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	zr, err := gzip.NewReader(bufio.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to decompress %s: %w", d.Object, err)
+	}
+	dec := json.NewDecoder(zr)
+	restored := 0
+	batch := make([]models.CheckResult, 0, restoreBatch)
+	flush := func() error {
+		n, err := a.store.RestoreCheckResults(ctx, batch)
+		restored += n
+		batch = batch[:0]
+		return err
+	}
+	for {
+		var rec record
+		if err := dec.Decode(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			return restored, fmt.Errorf("failed to decode %s: %w", d.Object, err)
+		}
+		rec.CheckResult.TargetID = rec.TargetID
+		if batch = append(batch, rec.CheckResult); len(batch) == restoreBatch {
+			if err := flush(); err != nil {
+				return restored, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return restored, err
+	}
+	if err := a.store.MarkDayRestored(ctx, d.Day, a.clock.Now()); err != nil {
+		return restored, err
+	}
+	logging.Storage.Infof("restored %d archived check results from %s", restored, d.Object)
+	return restored, nil
+}
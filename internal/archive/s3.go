@@ -0,0 +1,119 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/internal/awssig"
+)
+
+// errObjectNotFound is returned by get for an object the bucket doesn't have.
+var errObjectNotFound = errors.New("object not found")
+
+// S3Config locates the bucket archives are written to.
+type S3Config struct {
+	Bucket   string
+	Region   string
+	Endpoint string // Defaults to the regional AWS endpoint, e.g. https://s3.eu-west-1.amazonaws.com
+	// PathStyle addresses objects as <endpoint>/<bucket>/<key> rather than on a
+	// <bucket>.<endpoint host> subdomain, as MinIO and most other S3-compatible stores expect.
+	PathStyle   bool
+	Credentials awssig.Credentials
+}
+
+// s3Client reads and writes objects with the S3 REST API, signed with Signature Version 4.
+type s3Client struct {
+	cfg    S3Config
+	base   *url.URL
+	client *http.Client
+}
+
+func newS3Client(cfg S3Config, client *http.Client) (*s3Client, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("a bucket is required")
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	base, err := url.Parse(endpoint)
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q", endpoint)
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/")
+	if cfg.PathStyle {
+		base.Path += "/" + cfg.Bucket
+	} else {
+		base.Host = cfg.Bucket + "." + base.Host
+	}
+	return &s3Client{cfg: cfg, base: base, client: client}, nil
+}
+
+// objectURL returns the URL of key, with each path segment escaped as SigV4 expects.
+func (c *s3Client) objectURL(key string) *url.URL {
+	u := *c.base
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = awssig.Escape(s)
+	}
+	u.RawPath = u.EscapedPath() + "/" + strings.Join(segments, "/")
+	u.Path = u.Path + "/" + key
+	return &u
+}
+
+// do sends a signed request for key and returns the response, which is closed unless the
+// status is 2xx.
+func (c *s3Client) do(ctx context.Context, method, key string, header http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.objectURL(key).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	sum := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum[:]))
+	awssig.Sign(req, body, c.cfg.Credentials, c.cfg.Region, "s3", time.Now())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, errObjectNotFound
+		}
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("s3 returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// put writes body to key, replacing any object already there.
+func (c *s3Client) put(ctx context.Context, key, contentType string, body []byte) error {
+	resp, err := c.do(ctx, http.MethodPut, key, http.Header{"Content-Type": {contentType}}, body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// get opens the object at key, or returns errObjectNotFound.
+func (c *s3Client) get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,212 @@
+package cloudwatch
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/internal/awssig"
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+const (
+	// maxDatumsPerRequest and maxRequestBytes are the PutMetricData limits per call.
+	maxDatumsPerRequest = 1000
+	maxRequestBytes     = 1 << 20
+	// bufferSize bounds how many results may wait for the next flush before new ones are dropped.
+	bufferSize = 10000
+)
+
+// Dimension is a CloudWatch metric dimension.
+type Dimension struct {
+	Name  string
+	Value string
+}
+
+// ParseDimensions parses "Name=Value" strings (e.g. from CLOUDWATCH_DIMENSIONS).
+func ParseDimensions(raw []string) ([]Dimension, error) {
+	dims := make([]Dimension, 0, len(raw))
+	for _, r := range raw {
+		name, value, ok := strings.Cut(r, "=")
+		if !ok || name == "" || value == "" {
+			return nil, fmt.Errorf("invalid dimension %q, expected Name=Value", r)
+		}
+		dims = append(dims, Dimension{Name: name, Value: value})
+	}
+	return dims, nil
+}
+
+// Config configures a Publisher.
+type Config struct {
+	Namespace     string
+	Region        string
+	Endpoint      string      // Defaults to the regional monitoring endpoint
+	Dimensions    []Dimension // Added to every metric alongside TargetId
+	FlushInterval time.Duration
+	Credentials   awssig.Credentials
+}
+
+// datum is a single metric value.
+type datum struct {
+	name      string
+	unit      string
+	value     float64
+	timestamp time.Time
+	targetID  string
+}
+
+// Publisher pushes per-target Availability (percent) and Latency (milliseconds) metrics to
+// CloudWatch. Results are buffered and sent with PutMetricData on every flush interval, split
+// into as many requests as the API limits require.
+type Publisher struct {
+	cfg      Config
+	endpoint string
+	client   *http.Client
+	results  chan models.CheckResult
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// New creates a Publisher.
+func New(cfg Config, client *http.Client) *Publisher {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://monitoring." + cfg.Region + ".amazonaws.com/"
+	}
+	return &Publisher{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   client,
+		results:  make(chan models.CheckResult, bufferSize),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Publish queues a result. It never blocks; results are dropped when the buffer is full.
+func (p *Publisher) Publish(result models.CheckResult) {
+	select {
+	case p.results <- result:
+	default:
+		logging.Metrics.Warnf("cloudwatch buffer full, dropping result for target %s", result.TargetID)
+	}
+}
+
+// Start begins the background flush loop.
+func (p *Publisher) Start() {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(p.cfg.FlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.flush()
+			case <-p.stopChan:
+				p.flush()
+				return
+			}
+		}
+	}()
+}
+
+// Stop flushes buffered results and stops the flush loop.
+func (p *Publisher) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stopChan)
+		p.wg.Wait()
+	})
+}
+
+// flush drains the buffer and sends its metrics.
+func (p *Publisher) flush() {
+	var data []datum
+drain:
+	for {
+		select {
+		case r := <-p.results:
+			availability := 0.0
+			if r.Succeeded() {
+				availability = 100
+			}
+			data = append(data,
+				datum{name: "Availability", unit: "Percent", value: availability, timestamp: r.CheckedAt, targetID: r.TargetID},
+				datum{name: "Latency", unit: "Milliseconds", value: float64(r.LatencyMS), timestamp: r.CheckedAt, targetID: r.TargetID},
+			)
+		default:
+			break drain
+		}
+	}
+
+	for len(data) > 0 {
+		body, n := p.encode(data)
+		if err := p.put(body); err != nil {
+			logging.Metrics.Errorf("error publishing %d metrics to cloudwatch: %v", n, err)
+		}
+		data = data[n:]
+	}
+}
+
+// encode builds a PutMetricData form body from as many leading data as fit in one request,
+// returning the body and how many data it contains.
+func (p *Publisher) encode(data []datum) (string, int) {
+	var body strings.Builder
+	body.WriteString("Action=PutMetricData&Version=2010-08-01&Namespace=" + awssig.Escape(p.cfg.Namespace))
+
+	n := 0
+	for _, d := range data {
+		if n == maxDatumsPerRequest {
+			break
+		}
+		member := p.encodeDatum(n+1, d)
+		if n > 0 && body.Len()+len(member) > maxRequestBytes {
+			break
+		}
+		body.WriteString(member)
+		n++
+	}
+	return body.String(), n
+}
+
+func (p *Publisher) encodeDatum(index int, d datum) string {
+	prefix := "&MetricData.member." + strconv.Itoa(index) + "."
+	var b strings.Builder
+	b.WriteString(prefix + "MetricName=" + awssig.Escape(d.name))
+	b.WriteString(prefix + "Unit=" + d.unit)
+	b.WriteString(prefix + "Value=" + strconv.FormatFloat(d.value, 'f', -1, 64))
+	b.WriteString(prefix + "Timestamp=" + awssig.Escape(d.timestamp.UTC().Format(time.RFC3339)))
+	dims := append([]Dimension{{Name: "TargetId", Value: d.targetID}}, p.cfg.Dimensions...)
+	for i, dim := range dims {
+		dimPrefix := prefix + "Dimensions.member." + strconv.Itoa(i+1) + "."
+		b.WriteString(dimPrefix + "Name=" + awssig.Escape(dim.Name))
+		b.WriteString(dimPrefix + "Value=" + awssig.Escape(dim.Value))
+	}
+	return b.String()
+}
+
+func (p *Publisher) put(body string) error {
+	req, err := http.NewRequest(http.MethodPost, p.endpoint, bytes.NewBufferString(body))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	awssig.Sign(req, []byte(body), p.cfg.Credentials, p.cfg.Region, "monitoring", time.Now())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("cloudwatch returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
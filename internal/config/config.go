@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -14,6 +15,136 @@ type Config struct {
 	HTTPTimeout    time.Duration
 	ShutdownGrace  time.Duration
 	HTTPPort       string
+	AdminToken     string
+	PageTokenKey   string
+	StrictQuery    bool
+
+	RequestReadTimeout  time.Duration
+	RequestWriteTimeout time.Duration
+	RequestTimeouts     []string // "METHOD /pattern=duration" overrides
+
+	HTTPListen            string // Overrides HTTPPort, e.g. "127.0.0.1:8080" or "unix:///run/linkwatch.sock"
+	HTTPSocketMode        string // Octal permissions of a Unix socket
+	HTTPRedirectAddr      string
+	HTTPReadHeaderTimeout time.Duration
+	HTTPIdleTimeout       time.Duration
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSSelfSigned         bool
+	ACMEDomains           []string
+	ACMECacheDir          string
+	ACMEEmail             string
+	ACMEDirectoryURL      string
+
+	SchedulerBatchSize         int
+	SchedulerTargetCache       time.Duration // Longest the scheduler reuses its target list while unchanged; zero disables
+	DatabaseReadURL            string
+	DatabaseContractMigrations bool
+	DatabaseCompressAbove      int           // Gzip large result columns of at least this many bytes; zero disables
+	DatabaseSlowQuery          time.Duration // Log statements slower than this; zero disables
+	DatabaseMaxOpenConns       int           // Zero leaves connections unlimited
+	DatabaseMaxIdleConns       int
+	DatabaseConnMaxLifetime    time.Duration
+	DatabaseConnMaxIdleTime    time.Duration
+	DatabaseConnectRetry       time.Duration // How long to keep retrying an unreachable database at startup
+	DatabaseIntegrityCheck     string        // off, quick, or full
+	DatabaseAutoVacuum         string        // none, full, or incremental; empty keeps the database's
+	DatabasePageSize           int           // Zero keeps the database's
+
+	ReportSchedule   string
+	ReportPeriod     string
+	ReportFrom       string
+	ReportRecipients []string
+	SMTPAddr         string
+	SMTPUsername     string
+	SMTPPassword     string
+
+	AlertWebhookURL    string
+	AlertWebhookSecret string
+	AlertMode          string
+	AlertDigestWindow  time.Duration
+	AlertRecipients    []string
+
+	ResultWebhookURL       string
+	ResultWebhookSecret    string
+	ResultWebhookBatchSize int
+	ResultWebhookInterval  time.Duration
+
+	DiscoveryMaxURLs int
+	CrawlMaxLinks    int
+
+	TargetStateCacheTTL time.Duration
+	DegradedLatency     time.Duration
+
+	ResultStorageMode string
+	ResultKeepalive   time.Duration
+	ResultSampleEvery time.Duration
+	QueueBackend      string
+	RedisURL          string
+	CheckerRole       string
+	LeaderElection    bool
+	LeaderLeaseTTL    time.Duration
+	ShardIndex        int
+	ShardTotal        int
+
+	CheckMaxBodyBytes     int64
+	CheckBodyContentTypes []string
+	CheckMaxRedirects     int
+	CheckQueueSize        int
+	QueueMonitorInterval  time.Duration // Zero disables the queue metrics and threshold events
+	SchedulerLagThreshold time.Duration // Zero disables the event
+	QueueAgeThreshold     time.Duration // Zero disables the event
+	QueueSaturation       int           // Percent of the queue's capacity; zero disables the event
+	CheckDNSFailureTTL    time.Duration
+	CheckTimeoutBudget    time.Duration
+	CheckWarmup           time.Duration
+	RedactQueryParams     []string
+	ExecChecksDir         string // Directory of exec target commands; empty disables exec targets
+	ExecCheckTimeout      time.Duration
+	WasmPluginsDir        string // Directory of wasm target modules; empty disables wasm targets
+	WasmCheckTimeout      time.Duration
+	CheckMinTLSVersion    string // Lowest TLS version targets may negotiate without an alert; empty disables the alerts
+	ClientCertSecret      string // Encrypts stored client certificate keys; empty disables client certificates
+
+	URLMaxLength      int
+	URLMaxPathLength  int
+	URLMaxQueryLength int
+	URLRequireTLD     bool
+
+	APIV1DeprecatedAt    string // RFC3339
+	APIV1Sunset          string // RFC3339
+	APIV1DeprecationLink string
+
+	SelfMonitor    bool   // Registers and checks the service's own /readyz as a target
+	SelfMonitorURL string // Base URL the service reaches itself at; derived from HTTPListen when empty
+
+	LogLevel  string   // Level of every component without its own in LogLevels
+	LogLevels []string // "component=level" overrides
+
+	StatsDAddr   string
+	StatsDPrefix string
+	StatsDTags   []string
+
+	CloudWatchEnabled    bool
+	CloudWatchNamespace  string
+	CloudWatchDimensions []string
+	CloudWatchInterval   time.Duration
+	CloudWatchEndpoint   string
+
+	ArchiveAfter       time.Duration // Zero disables archiving
+	ArchiveInterval    time.Duration
+	ArchiveRestoreTTL  time.Duration
+	ArchiveBucket      string
+	ArchivePrefix      string
+	ArchiveEndpoint    string
+	ArchiveRegion      string
+	ArchivePathStyle   bool
+	ArchiveAccessKeyID string // Override AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+	ArchiveSecretKey   string
+
+	DomainCheckInterval time.Duration // How often each target domain is looked up over RDAP; zero disables
+	DomainExpiryWarning time.Duration // Alert when a domain expires within this
+	RDAPURL             string
 }
 
 // Load loads configuration from environment variables with sane defaults.
@@ -25,6 +156,136 @@ func Load() *Config {
 		HTTPTimeout:    getEnvDuration("HTTP_TIMEOUT", 5*time.Second),
 		ShutdownGrace:  getEnvDuration("SHUTDOWN_GRACE", 10*time.Second),
 		HTTPPort:       getEnv("HTTP_PORT", "8080"),
+		AdminToken:     getEnv("ADMIN_TOKEN", ""),
+		PageTokenKey:   getEnv("PAGE_TOKEN_SECRET", ""),
+		StrictQuery:    getEnvBool("STRICT_QUERY_PARAMS", false),
+
+		RequestReadTimeout:  getEnvDuration("REQUEST_TIMEOUT_READ", 5*time.Second),
+		RequestWriteTimeout: getEnvDuration("REQUEST_TIMEOUT_WRITE", 3*time.Second),
+		RequestTimeouts:     getEnvList("REQUEST_TIMEOUTS"),
+
+		HTTPListen:            getEnv("HTTP_LISTEN", ""),
+		HTTPSocketMode:        getEnv("HTTP_SOCKET_MODE", "0660"),
+		HTTPRedirectAddr:      getEnv("HTTP_REDIRECT_ADDR", ""),
+		HTTPReadHeaderTimeout: getEnvDuration("HTTP_READ_HEADER_TIMEOUT", 10*time.Second),
+		HTTPIdleTimeout:       getEnvDuration("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+		TLSCertFile:           getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:            getEnv("TLS_KEY_FILE", ""),
+		TLSSelfSigned:         getEnvBool("TLS_SELF_SIGNED", false),
+		ACMEDomains:           getEnvList("ACME_DOMAINS"),
+		ACMECacheDir:          getEnv("ACME_CACHE_DIR", "acme-cache"),
+		ACMEEmail:             getEnv("ACME_EMAIL", ""),
+		ACMEDirectoryURL:      getEnv("ACME_DIRECTORY_URL", ""),
+
+		SchedulerBatchSize:         getEnvInt("SCHEDULER_BATCH_SIZE", 1000),
+		SchedulerTargetCache:       getEnvDuration("SCHEDULER_TARGET_CACHE", 0),
+		DatabaseReadURL:            getEnv("DATABASE_READ_URL", ""),
+		DatabaseContractMigrations: getEnvBool("DATABASE_CONTRACT_MIGRATIONS", false),
+		DatabaseCompressAbove:      getEnvInt("DATABASE_COMPRESS_ABOVE", 0),
+		DatabaseSlowQuery:          getEnvDuration("DATABASE_SLOW_QUERY", 0),
+		DatabaseMaxOpenConns:       getEnvInt("DATABASE_MAX_OPEN_CONNS", 0),
+		DatabaseMaxIdleConns:       getEnvInt("DATABASE_MAX_IDLE_CONNS", 0),
+		DatabaseConnMaxLifetime:    getEnvDuration("DATABASE_CONN_MAX_LIFETIME", 0),
+		DatabaseConnMaxIdleTime:    getEnvDuration("DATABASE_CONN_MAX_IDLE_TIME", 0),
+		DatabaseConnectRetry:       getEnvDuration("DATABASE_CONNECT_RETRY", 0),
+		DatabaseIntegrityCheck:     getEnv("DATABASE_INTEGRITY_CHECK", "off"),
+		DatabaseAutoVacuum:         getEnv("DATABASE_AUTO_VACUUM", ""),
+		DatabasePageSize:           getEnvInt("DATABASE_PAGE_SIZE", 0),
+
+		ReportSchedule:   getEnv("REPORT_SCHEDULE", ""),
+		ReportPeriod:     getEnv("REPORT_PERIOD", "daily"),
+		ReportFrom:       getEnv("REPORT_FROM", "linkwatch@localhost"),
+		ReportRecipients: getEnvList("REPORT_RECIPIENTS"),
+		SMTPAddr:         getEnv("SMTP_ADDR", ""),
+		SMTPUsername:     getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:     getEnv("SMTP_PASSWORD", ""),
+
+		AlertWebhookURL:    getEnv("ALERT_WEBHOOK_URL", ""),
+		AlertWebhookSecret: getEnv("ALERT_WEBHOOK_SECRET", ""),
+		AlertMode:          getEnv("ALERT_MODE", "immediate"),
+		AlertDigestWindow:  getEnvDuration("ALERT_DIGEST_WINDOW", 5*time.Minute),
+		AlertRecipients:    getEnvList("ALERT_RECIPIENTS"),
+
+		ResultWebhookURL:       getEnv("RESULT_WEBHOOK_URL", ""),
+		ResultWebhookSecret:    getEnv("RESULT_WEBHOOK_SECRET", ""),
+		ResultWebhookBatchSize: getEnvInt("RESULT_WEBHOOK_BATCH_SIZE", 100),
+		ResultWebhookInterval:  getEnvDuration("RESULT_WEBHOOK_INTERVAL", 10*time.Second),
+
+		DiscoveryMaxURLs: getEnvInt("DISCOVERY_MAX_URLS", 500),
+		CrawlMaxLinks:    getEnvInt("CRAWL_MAX_LINKS", 500),
+
+		TargetStateCacheTTL: getEnvDuration("TARGET_STATE_CACHE_TTL", 10*time.Second),
+		DegradedLatency:     getEnvDuration("DEGRADED_LATENCY", time.Second),
+
+		ResultStorageMode: getEnv("RESULT_STORAGE_MODE", "all"),
+		ResultKeepalive:   getEnvDuration("RESULT_KEEPALIVE", 5*time.Minute),
+		ResultSampleEvery: getEnvDuration("RESULT_SAMPLING_INTERVAL", time.Hour),
+		QueueBackend:      getEnv("QUEUE_BACKEND", "memory"),
+		RedisURL:          getEnv("REDIS_URL", ""),
+		CheckerRole:       getEnv("CHECKER_ROLE", "all"),
+		LeaderElection:    getEnvBool("LEADER_ELECTION", false),
+		LeaderLeaseTTL:    getEnvDuration("LEADER_LEASE_TTL", 15*time.Second),
+		ShardIndex:        getEnvInt("SHARD_INDEX", 0),
+		ShardTotal:        getEnvInt("SHARD_TOTAL", 1),
+
+		CheckMaxBodyBytes:     int64(getEnvInt("CHECK_MAX_BODY_BYTES", 1<<20)),
+		CheckBodyContentTypes: getEnvList("CHECK_BODY_CONTENT_TYPES"),
+		CheckMaxRedirects:     getEnvInt("CHECK_MAX_REDIRECTS", 5),
+		CheckQueueSize:        getEnvInt("CHECK_QUEUE_SIZE", 0),
+		QueueMonitorInterval:  getEnvDuration("QUEUE_MONITOR_INTERVAL", 15*time.Second),
+		SchedulerLagThreshold: getEnvDuration("SCHEDULER_LAG_THRESHOLD", 0),
+		QueueAgeThreshold:     getEnvDuration("QUEUE_AGE_THRESHOLD", 0),
+		QueueSaturation:       getEnvInt("QUEUE_SATURATION_THRESHOLD", 0),
+		CheckDNSFailureTTL:    getEnvDuration("CHECK_DNS_FAILURE_TTL", 30*time.Second),
+		CheckTimeoutBudget:    getEnvDuration("CHECK_TIMEOUT_BUDGET", 0),
+		CheckWarmup:           getEnvDuration("CHECK_WARMUP", 0),
+		RedactQueryParams:     getEnvList("REDACT_QUERY_PARAMS"),
+		ExecChecksDir:         getEnv("EXEC_CHECKS_DIR", ""),
+		ExecCheckTimeout:      getEnvDuration("EXEC_CHECK_TIMEOUT", 10*time.Second),
+		WasmPluginsDir:        getEnv("WASM_PLUGINS_DIR", ""),
+		WasmCheckTimeout:      getEnvDuration("WASM_CHECK_TIMEOUT", 10*time.Second),
+		CheckMinTLSVersion:    getEnv("CHECK_MIN_TLS_VERSION", ""),
+		ClientCertSecret:      getEnv("CLIENT_CERT_SECRET", ""),
+
+		URLMaxLength:      getEnvInt("URL_MAX_LENGTH", 2048),
+		URLMaxPathLength:  getEnvInt("URL_MAX_PATH_LENGTH", 0),
+		URLMaxQueryLength: getEnvInt("URL_MAX_QUERY_LENGTH", 0),
+		URLRequireTLD:     getEnvBool("URL_REQUIRE_TLD", false),
+
+		APIV1DeprecatedAt:    getEnv("API_V1_DEPRECATED_AT", ""),
+		APIV1Sunset:          getEnv("API_V1_SUNSET", ""),
+		APIV1DeprecationLink: getEnv("API_V1_DEPRECATION_LINK", ""),
+
+		SelfMonitor:    getEnvBool("SELF_MONITOR", false),
+		SelfMonitorURL: getEnv("SELF_MONITOR_URL", ""),
+
+		LogLevel:  getEnv("LOG_LEVEL", "info"),
+		LogLevels: getEnvList("LOG_LEVELS"),
+
+		StatsDAddr:   getEnv("STATSD_ADDR", ""),
+		StatsDPrefix: getEnv("STATSD_PREFIX", "linkwatch."),
+		StatsDTags:   getEnvList("STATSD_TAGS"),
+
+		CloudWatchEnabled:    getEnvBool("CLOUDWATCH_ENABLED", false),
+		CloudWatchNamespace:  getEnv("CLOUDWATCH_NAMESPACE", "Linkwatch"),
+		CloudWatchDimensions: getEnvList("CLOUDWATCH_DIMENSIONS"),
+		CloudWatchInterval:   getEnvDuration("CLOUDWATCH_INTERVAL", time.Minute),
+		CloudWatchEndpoint:   getEnv("CLOUDWATCH_ENDPOINT", ""),
+
+		ArchiveAfter:       getEnvDuration("ARCHIVE_AFTER", 0),
+		ArchiveInterval:    getEnvDuration("ARCHIVE_INTERVAL", time.Hour),
+		ArchiveRestoreTTL:  getEnvDuration("ARCHIVE_RESTORE_TTL", 24*time.Hour),
+		ArchiveBucket:      getEnv("ARCHIVE_S3_BUCKET", ""),
+		ArchivePrefix:      getEnv("ARCHIVE_S3_PREFIX", "linkwatch"),
+		ArchiveEndpoint:    getEnv("ARCHIVE_S3_ENDPOINT", ""),
+		ArchiveRegion:      getEnv("ARCHIVE_S3_REGION", ""),
+		ArchivePathStyle:   getEnvBool("ARCHIVE_S3_PATH_STYLE", false),
+		ArchiveAccessKeyID: getEnv("ARCHIVE_S3_ACCESS_KEY_ID", ""),
+		ArchiveSecretKey:   getEnv("ARCHIVE_S3_SECRET_ACCESS_KEY", ""),
+
+		DomainCheckInterval: getEnvDuration("DOMAIN_CHECK_INTERVAL", 0),
+		DomainExpiryWarning: getEnvDuration("DOMAIN_EXPIRY_WARNING", 30*24*time.Hour),
+		RDAPURL:             getEnv("RDAP_URL", "https://rdap.org/"),
 	}
 }
 
@@ -46,6 +307,16 @@ func getEnvInt(key string, fallback int) int {
 	return fallback
 }
 
+// Helper function to get an environment variable as a boolean.
+func getEnvBool(key string, fallback bool) bool {
+	if valueStr, exists := os.LookupEnv(key); exists {
+		if value, err := strconv.ParseBool(valueStr); err == nil {
+			return value
+		}
+	}
+	return fallback
+}
+
 // Helper function to get an environment variable as a time.Duration.
 func getEnvDuration(key string, fallback time.Duration) time.Duration {
 	if valueStr, exists := os.LookupEnv(key); exists {
@@ -55,3 +326,14 @@ func getEnvDuration(key string, fallback time.Duration) time.Duration {
 	}
 	return fallback
 }
+
+// Helper function to get an environment variable as a comma-separated list.
+func getEnvList(key string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,210 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// Link scopes supported by a crawl.
+const (
+	ScopeSameHost = "same_host"
+	ScopeAll      = "all"
+)
+
+const (
+	// maxPageBytes caps how much of the crawled page is parsed.
+	maxPageBytes = 5 << 20
+	// linkConcurrency is the number of links checked in parallel for a single crawl.
+	linkConcurrency = 4
+)
+
+// BrokenLink describes a link that failed its check.
+type BrokenLink struct {
+	URL        string  `json:"url"`
+	StatusCode *int    `json:"status_code"`
+	Error      *string `json:"error"`
+}
+
+// Report is the outcome of crawling a single page.
+type Report struct {
+	PageURL      string       `json:"page_url"`
+	Scope        string       `json:"scope"`
+	LinksFound   int          `json:"links_found"`
+	LinksChecked int          `json:"links_checked"`
+	Broken       []BrokenLink `json:"broken"`
+}
+
+// Crawler fetches a page and checks every link on it once.
+type Crawler struct {
+	client   *http.Client
+	maxLinks int
+}
+
+// New creates a Crawler that checks at most maxLinks links per page.
+func New(client *http.Client, maxLinks int) *Crawler {
+	return &Crawler{client: client, maxLinks: maxLinks}
+}
+
+// Crawl fetches pageURL, extracts its <a href> links within scope, and checks each one.
+// The progress callback, if non-nil, receives the number of links checked so far and the total.
+func (c *Crawler) Crawl(ctx context.Context, pageURL, scope string, progress func(done, total int)) (*Report, error) {
+	page, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page url: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build page request: %w", err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch page: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("page returned status %d", resp.StatusCode)
+	}
+
+	links, err := ExtractLinks(io.LimitReader(resp.Body, maxPageBytes), resp.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse page: %w", err)
+	}
+
+	var toCheck []string
+	for _, link := range links {
+		if scope == ScopeSameHost && !strings.EqualFold(link.Hostname(), page.Hostname()) {
+			continue
+		}
+		toCheck = append(toCheck, link.String())
+	}
+	report := &Report{PageURL: pageURL, Scope: scope, LinksFound: len(links), Broken: []BrokenLink{}}
+	if c.maxLinks > 0 && len(toCheck) > c.maxLinks {
+		toCheck = toCheck[:c.maxLinks]
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, linkConcurrency)
+		done int
+	)
+	for _, link := range toCheck {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(link string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			broken := c.checkLink(ctx, link)
+
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			report.LinksChecked++
+			if broken != nil {
+				report.Broken = append(report.Broken, *broken)
+			}
+			if progress != nil {
+				progress(done, len(toCheck))
+			}
+		}(link)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// checkLink returns a BrokenLink when the link errors or responds with a 4xx/5xx status.
+// HEAD is tried first, falling back to GET for servers that don't support it.
+func (c *Crawler) checkLink(ctx context.Context, link string) *BrokenLink {
+	status, err := c.request(ctx, http.MethodHead, link)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = c.request(ctx, http.MethodGet, link)
+	}
+	if err != nil {
+		msg := err.Error()
+		return &BrokenLink{URL: link, Error: &msg}
+	}
+	if status >= 400 {
+		return &BrokenLink{URL: link, StatusCode: &status}
+	}
+	return nil
+}
+
+func (c *Crawler) request(ctx context.Context, method, link string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, link, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// ExtractLinks parses an HTML document and returns the unique absolute http(s) URLs of its
+// <a href> links, resolved against base (or the document's <base href>). Fragments are dropped.
+func ExtractLinks(r io.Reader, base *url.URL) ([]*url.URL, error) {
+	seen := make(map[string]bool)
+	var links []*url.URL
+	tokenizer := html.NewTokenizer(r)
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if tokenizer.Err() == io.EOF {
+				return links, nil
+			}
+			return nil, tokenizer.Err()
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			if !hasAttr || (string(name) != "a" && string(name) != "base") {
+				continue
+			}
+			href := attr(tokenizer, "href")
+			if href == "" {
+				continue
+			}
+			ref, err := base.Parse(strings.TrimSpace(href))
+			if err != nil {
+				continue
+			}
+			if string(name) == "base" {
+				base = ref
+				continue
+			}
+			if ref.Scheme != "http" && ref.Scheme != "https" {
+				continue
+			}
+			ref.Fragment = ""
+			if key := ref.String(); !seen[key] {
+				seen[key] = true
+				links = append(links, ref)
+			}
+		}
+	}
+}
+
+// attr returns the value of the named attribute of the current tag.
+func attr(z *html.Tokenizer, name string) string {
+	for {
+		key, val, more := z.TagAttr()
+		if string(key) == name {
+			return string(val)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
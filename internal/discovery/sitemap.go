@@ -0,0 +1,179 @@
+package discovery
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// maxSitemaps caps how many sitemap documents a single discovery run fetches.
+	maxSitemaps = 50
+	// maxDocumentBytes caps how much of a robots.txt or sitemap document is read.
+	maxDocumentBytes = 10 << 20
+)
+
+// Discoverer extracts URLs for a site from its robots.txt and sitemap.xml files.
+type Discoverer struct {
+	client  *http.Client
+	maxURLs int
+}
+
+// New creates a Discoverer that never returns more than maxURLs URLs.
+func New(client *http.Client, maxURLs int) *Discoverer {
+	return &Discoverer{client: client, maxURLs: maxURLs}
+}
+
+// MaxURLs returns the configured upper bound on discovered URLs.
+func (d *Discoverer) MaxURLs() int {
+	return d.maxURLs
+}
+
+// Result is the outcome of a discovery run.
+type Result struct {
+	URLs      []string
+	Sitemaps  []string
+	Truncated bool // True when more URLs were available than the limit allowed
+}
+
+// sitemapDocument covers both <urlset> and <sitemapindex> documents.
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// Discover fetches robots.txt Sitemap: entries and /sitemap.xml for the site that rootURL
+// belongs to and returns up to limit page URLs (bounded by the configured maximum).
+func (d *Discoverer) Discover(ctx context.Context, rootURL string, limit int) (*Result, error) {
+	root, err := url.Parse(rootURL)
+	if err != nil || root.Host == "" {
+		return nil, fmt.Errorf("invalid root url %q", rootURL)
+	}
+	if limit <= 0 || limit > d.maxURLs {
+		limit = d.maxURLs
+	}
+	base := root.Scheme + "://" + root.Host
+
+	queue, err := d.robotsSitemaps(ctx, base+"/robots.txt")
+	if err != nil {
+		return nil, err
+	}
+	queue = append(queue, base+"/sitemap.xml")
+
+	res := &Result{}
+	seenSitemaps := make(map[string]bool)
+	seenURLs := make(map[string]bool)
+	var lastErr error
+	for len(queue) > 0 && len(seenSitemaps) < maxSitemaps {
+		sitemapURL := queue[0]
+		queue = queue[1:]
+		if seenSitemaps[sitemapURL] {
+			continue
+		}
+		seenSitemaps[sitemapURL] = true
+
+		doc, err := d.fetchSitemap(ctx, sitemapURL)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		res.Sitemaps = append(res.Sitemaps, sitemapURL)
+		for _, s := range doc.Sitemaps {
+			if loc := strings.TrimSpace(s.Loc); loc != "" {
+				queue = append(queue, loc)
+			}
+		}
+		for _, u := range doc.URLs {
+			loc := strings.TrimSpace(u.Loc)
+			if loc == "" || seenURLs[loc] {
+				continue
+			}
+			if len(res.URLs) >= limit {
+				res.Truncated = true
+				return res, nil
+			}
+			seenURLs[loc] = true
+			res.URLs = append(res.URLs, loc)
+		}
+	}
+
+	if len(res.Sitemaps) == 0 && lastErr != nil {
+		return nil, fmt.Errorf("no sitemap could be fetched: %w", lastErr)
+	}
+	return res, nil
+}
+
+// robotsSitemaps returns the Sitemap: entries listed in robots.txt. A missing robots.txt is not an error.
+func (d *Discoverer) robotsSitemaps(ctx context.Context, robotsURL string) ([]string, error) {
+	body, status, err := d.get(ctx, robotsURL)
+	if err != nil {
+		return nil, nil
+	}
+	defer body.Close()
+	if status != http.StatusOK {
+		return nil, nil
+	}
+
+	var sitemaps []string
+	scanner := bufio.NewScanner(io.LimitReader(body, maxDocumentBytes))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) > len("sitemap:") && strings.EqualFold(line[:len("sitemap:")], "sitemap:") {
+			if loc := strings.TrimSpace(line[len("sitemap:"):]); loc != "" {
+				sitemaps = append(sitemaps, loc)
+			}
+		}
+	}
+	return sitemaps, nil
+}
+
+// fetchSitemap downloads and parses a sitemap document, transparently decompressing .gz files.
+func (d *Discoverer) fetchSitemap(ctx context.Context, sitemapURL string) (*sitemapDocument, error) {
+	body, status, err := d.get(ctx, sitemapURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sitemap %s: %w", sitemapURL, err)
+	}
+	defer body.Close()
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("sitemap %s returned status %d", sitemapURL, status)
+	}
+
+	var r io.Reader = io.LimitReader(body, maxDocumentBytes)
+	if strings.HasSuffix(strings.ToLower(sitemapURL), ".gz") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress sitemap %s: %w", sitemapURL, err)
+		}
+		defer gz.Close()
+		r = io.LimitReader(gz, maxDocumentBytes)
+	}
+
+	var doc sitemapDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse sitemap %s: %w", sitemapURL, err)
+	}
+	return &doc, nil
+}
+
+func (d *Discoverer) get(ctx context.Context, target string) (io.ReadCloser, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Body, resp.StatusCode, nil
+}
@@ -0,0 +1,334 @@
+// Package domains looks up the registrations of the domains targets are on over RDAP, the
+// structured successor of WHOIS, and warns before they expire.
+//
+// Targets are grouped by registrable domain, the name under a public suffix that is
+// registered as a whole, so www.example.com and api.example.com share example.com. Each
+// domain is looked up once per refresh interval; its expiry date and registrar are kept in
+// the store, and the targets on a domain are alerted once per expiry date that falls within
+// the warning threshold.
+package domains
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/zeng-yichen/linkwatch/pkg/clock"
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/notify"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+const (
+	// DefaultRDAPURL is the RDAP bootstrap service domains are looked up through when Config
+	// leaves the URL unset. It redirects each query to the registry of the domain's TLD.
+	DefaultRDAPURL = "https://rdap.org/"
+	// defaultWarning is how long before expiry a domain is warned about when Config leaves it unset.
+	defaultWarning = 30 * 24 * time.Hour
+	// maxLookupsPerRun bounds how many domains one refresh looks up, so a first run against
+	// many domains is spread over several runs and stays within registries' rate limits.
+	maxLookupsPerRun = 20
+	// maxResponseSize caps how much of an RDAP response is read.
+	maxResponseSize = 1 << 20
+)
+
+// Store is what a Monitor needs of the store: the targets, and somewhere to keep domains.
+type Store interface {
+	storage.DomainStore
+	GetAllTargets(ctx context.Context) ([]models.Target, error)
+}
+
+// Config configures a Monitor.
+type Config struct {
+	RDAPURL string        // Base URL of the RDAP service; defaults to DefaultRDAPURL
+	Refresh time.Duration // How long a lookup is reused before the domain is looked up again
+	Warning time.Duration // Domains expiring within this are warned about; defaults to 30 days
+	Clock   clock.Clock   // Defaults to clock.Real
+}
+
+// Monitor keeps the registrations of target domains up to date.
+type Monitor struct {
+	store    Store
+	notifier notify.Notifier
+	client   *http.Client
+	rdapURL  string
+	refresh  time.Duration
+	warning  time.Duration
+	clock    clock.Clock
+}
+
+// New creates a Monitor that stores registrations in store and alerts through notifier,
+// which may be nil. Lookups are made with client.
+func New(store Store, notifier notify.Notifier, cfg Config, client *http.Client) (*Monitor, error) {
+	if cfg.Refresh <= 0 {
+		return nil, errors.New("the domain refresh interval must be positive")
+	}
+	m := &Monitor{store: store, notifier: notifier, client: client, rdapURL: cfg.RDAPURL, refresh: cfg.Refresh, warning: cfg.Warning, clock: cfg.Clock}
+	if m.rdapURL == "" {
+		m.rdapURL = DefaultRDAPURL
+	}
+	if !strings.HasSuffix(m.rdapURL, "/") {
+		m.rdapURL += "/"
+	}
+	if m.warning <= 0 {
+		m.warning = defaultWarning
+	}
+	if m.clock == nil {
+		m.clock = clock.Real
+	}
+	return m, nil
+}
+
+// Registrable returns the registrable domain of host, or false for IP addresses and hosts
+// outside the ICANN namespace, such as localhost or names under .internal, which have no
+// registration to look up. Under a privately run suffix, such as github.io, the registration
+// is that of the suffix itself.
+func Registrable(host string) (string, bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" || net.ParseIP(strings.Trim(host, "[]")) != nil {
+		return "", false
+	}
+	suffix, icann := publicsuffix.PublicSuffix(host)
+	if !icann {
+		if !strings.Contains(suffix, ".") {
+			return "", false
+		}
+		return suffix, true
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return "", false
+	}
+	return domain, true
+}
+
+// RefreshDomains looks up the domains of every HTTP target whose registration is missing or
+// older than the refresh interval, oldest first, forgets those no target is on any more, and
+// alerts about domains that newly expire within the warning threshold. It returns how many
+// domains were looked up.
+func (m *Monitor) RefreshDomains(ctx context.Context, now time.Time) (int, error) {
+	targets, err := m.store.GetAllTargets(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list targets: %w", err)
+	}
+	onDomain := make(map[string][]models.Target)
+	for _, t := range targets {
+		if t.Type != "" && t.Type != models.TargetTypeHTTP {
+			continue
+		}
+		if name, ok := Registrable(t.Host); ok {
+			onDomain[name] = append(onDomain[name], t)
+		}
+	}
+	stored, err := m.store.ListDomains(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list domains: %w", err)
+	}
+	known := make(map[string]models.Domain, len(stored))
+	for _, d := range stored {
+		if _, ok := onDomain[d.Name]; !ok {
+			if err := m.store.DeleteDomain(ctx, d.Name); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		known[d.Name] = d
+	}
+
+	// Domains never looked up come first, then those looked up longest ago.
+	names := make([]string, 0, len(onDomain))
+	for name := range onDomain {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, aok := known[names[i]]
+		b, bok := known[names[j]]
+		if aok != bok {
+			return !aok
+		}
+		if !a.CheckedAt.Equal(b.CheckedAt) {
+			return a.CheckedAt.Before(b.CheckedAt)
+		}
+		return names[i] < names[j]
+	})
+
+	looked := 0
+	for _, name := range names {
+		d, ok := known[name]
+		changed := !ok || d.Targets != len(onDomain[name])
+		d.Name, d.Targets = name, len(onDomain[name])
+		if (!ok || now.Sub(d.CheckedAt) >= m.refresh) && looked < maxLookupsPerRun {
+			if err := ctx.Err(); err != nil {
+				return looked, err
+			}
+			m.lookup(ctx, &d, now)
+			looked++
+			changed = true
+		}
+		if m.expiring(d, now) && (d.AlertedExpiry == nil || !d.AlertedExpiry.Equal(*d.ExpiresAt)) {
+			m.alert(ctx, d, onDomain[name], now)
+			d.AlertedExpiry = d.ExpiresAt
+			changed = true
+		}
+		if changed {
+			if err := m.store.UpsertDomain(ctx, d); err != nil {
+				return looked, err
+			}
+		}
+	}
+	return looked, nil
+}
+
+// ListDomains returns every stored domain, marking those that expire within the warning
+// threshold.
+func (m *Monitor) ListDomains(ctx context.Context) ([]models.Domain, error) {
+	domains, err := m.store.ListDomains(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := m.clock.Now()
+	for i := range domains {
+		domains[i].Expiring = m.expiring(domains[i], now)
+	}
+	return domains, nil
+}
+
+// expiring reports whether d expires within the warning threshold of now, or has expired.
+func (m *Monitor) expiring(d models.Domain, now time.Time) bool {
+	return d.ExpiresAt != nil && d.ExpiresAt.Sub(now) <= m.warning
+}
+
+// alert notifies about d expiring, once for each target on it.
+func (m *Monitor) alert(ctx context.Context, d models.Domain, targets []models.Target, now time.Time) {
+	if m.notifier == nil {
+		return
+	}
+	days := int(d.ExpiresAt.Sub(now).Hours() / 24)
+	msg := fmt.Sprintf("domain %s expires on %s, in %d days", d.Name, d.ExpiresAt.UTC().Format(time.DateOnly), days)
+	if days < 0 {
+		msg = fmt.Sprintf("domain %s expired on %s", d.Name, d.ExpiresAt.UTC().Format(time.DateOnly))
+	}
+	if d.Registrar != "" {
+		msg += "; registrar " + d.Registrar
+	}
+	for _, t := range targets {
+		event := notify.Event{Type: notify.EventTargetDomainExpiring, TargetID: t.ID, URL: t.URL, Message: msg, At: now}
+		if err := m.notifier.Notify(ctx, event); err != nil {
+			logging.Checker.Errorf("error sending domain expiry alert for target %s: %v", t.ID, err)
+		}
+	}
+}
+
+// lookup fetches d's registration over RDAP. A failed lookup records its error and keeps the
+// registration found before.
+func (m *Monitor) lookup(ctx context.Context, d *models.Domain, now time.Time) {
+	d.CheckedAt = now
+	reg, err := m.fetch(ctx, d.Name)
+	if err != nil {
+		msg := err.Error()
+		d.Error = &msg
+		return
+	}
+	d.Registrar, d.ExpiresAt, d.Error = reg.registrar(), reg.expiry(), nil
+}
+
+// rdapDomain is the part of an RDAP domain object (RFC 9083) the monitor reads.
+type rdapDomain struct {
+	Events []struct {
+		Action string `json:"eventAction"`
+		Date   string `json:"eventDate"`
+	} `json:"events"`
+	Entities []rdapEntity `json:"entities"`
+}
+
+// rdapEntity is a contact of an RDAP object. Its vCard is a jCard (RFC 7095): ["vcard",
+// [[name, params, type, value], ...]].
+type rdapEntity struct {
+	Roles []string          `json:"roles"`
+	VCard []json.RawMessage `json:"vcardArray"`
+}
+
+// fetch looks up a domain.
+func (m *Monitor) fetch(ctx context.Context, name string) (*rdapDomain, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.rdapURL+"domain/"+name, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("RDAP lookup failed: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, errors.New("domain not found over RDAP")
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("RDAP lookup returned status %d", resp.StatusCode)
+	}
+	var reg rdapDomain
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&reg); err != nil {
+		return nil, fmt.Errorf("invalid RDAP response: %w", err)
+	}
+	return &reg, nil
+}
+
+// expiry returns the domain's expiration event date, or nil when it has none.
+func (r *rdapDomain) expiry() *time.Time {
+	for _, e := range r.Events {
+		if e.Action != "expiration" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, e.Date); err == nil {
+			t = t.UTC()
+			return &t
+		}
+	}
+	return nil
+}
+
+// registrar returns the formatted name of the domain's registrar entity, or "".
+func (r *rdapDomain) registrar() string {
+	for _, e := range r.Entities {
+		for _, role := range e.Roles {
+			if role == "registrar" {
+				return e.formattedName()
+			}
+		}
+	}
+	return ""
+}
+
+// formattedName returns the "fn" property of the entity's vCard, or "".
+func (e *rdapEntity) formattedName() string {
+	if len(e.VCard) < 2 {
+		return ""
+	}
+	var props [][]json.RawMessage
+	if err := json.Unmarshal(e.VCard[1], &props); err != nil {
+		return ""
+	}
+	for _, p := range props {
+		var name, value string
+		if len(p) < 4 || json.Unmarshal(p[0], &name) != nil || name != "fn" {
+			continue
+		}
+		if json.Unmarshal(p[3], &value) == nil {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,184 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Supported import formats.
+const (
+	// FormatURLs is a plain text file with one URL per line; blank lines and # comments are ignored.
+	FormatURLs = "urls"
+	// FormatUptimeRobot is an Uptime Robot monitor export (CSV with a "URL" or "URL/IP" column).
+	FormatUptimeRobot = "uptimerobot"
+	// FormatBlackbox is a Prometheus scrape config probing through the blackbox exporter, or a
+	// bare list of static_configs (the file_sd format).
+	FormatBlackbox = "blackbox"
+)
+
+// Formats lists every supported format.
+var Formats = []string{FormatURLs, FormatUptimeRobot, FormatBlackbox}
+
+// Result is the outcome of parsing an export.
+type Result struct {
+	URLs    []string // Unique URLs in the order they were found
+	Skipped []string // Entries that are not HTTP(S) monitors, e.g. ping or TCP checks
+}
+
+// Parse reads an export in the given format. Plain URL lists are passed through as-is, leaving
+// validation to the server; the other formats skip entries without an http(s) scheme.
+func Parse(format string, r io.Reader) (*Result, error) {
+	var (
+		entries []string
+		err     error
+	)
+	switch format {
+	case FormatURLs:
+		entries, err = parseURLList(r)
+	case FormatUptimeRobot:
+		entries, err = parseUptimeRobot(r)
+	case FormatBlackbox:
+		entries, err = parseBlackbox(r)
+	default:
+		return nil, fmt.Errorf("unknown format %q, expected one of: %s", format, strings.Join(Formats, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{}
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		if format != FormatURLs && !isHTTP(e) {
+			res.Skipped = append(res.Skipped, e)
+			continue
+		}
+		if !seen[e] {
+			seen[e] = true
+			res.URLs = append(res.URLs, e)
+		}
+	}
+	return res, nil
+}
+
+func isHTTP(s string) bool {
+	lower := strings.ToLower(s)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+func parseURLList(r io.Reader) ([]string, error) {
+	var urls []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read url list: %w", err)
+	}
+	return urls, nil
+}
+
+func parseUptimeRobot(r io.Reader) ([]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+	col := -1
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if name == "url" || name == "url/ip" {
+			col = i
+			break
+		}
+	}
+	if col < 0 {
+		return nil, errors.New(`csv has no "URL" or "URL/IP" column`)
+	}
+
+	var urls []string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return urls, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv: %w", err)
+		}
+		if col < len(record) {
+			if u := strings.TrimSpace(record[col]); u != "" {
+				urls = append(urls, u)
+			}
+		}
+	}
+}
+
+// staticConfig is a Prometheus static_configs (or file_sd) entry.
+type staticConfig struct {
+	Targets []string `yaml:"targets"`
+}
+
+type scrapeConfig struct {
+	MetricsPath   string         `yaml:"metrics_path"`
+	StaticConfigs []staticConfig `yaml:"static_configs"`
+}
+
+// parseBlackbox accepts a prometheus.yml, taking the static targets of every job that scrapes
+// the blackbox exporter's /probe endpoint, or a bare list of static configs.
+func parseBlackbox(r io.Reader) ([]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid yaml: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	var statics []staticConfig
+	switch doc.Content[0].Kind {
+	case yaml.SequenceNode:
+		if err := doc.Content[0].Decode(&statics); err != nil {
+			return nil, fmt.Errorf("invalid static configs: %w", err)
+		}
+	case yaml.MappingNode:
+		var cfg struct {
+			ScrapeConfigs []scrapeConfig `yaml:"scrape_configs"`
+		}
+		if err := doc.Content[0].Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("invalid prometheus config: %w", err)
+		}
+		for _, sc := range cfg.ScrapeConfigs {
+			if sc.MetricsPath == "/probe" {
+				statics = append(statics, sc.StaticConfigs...)
+			}
+		}
+	default:
+		return nil, errors.New("expected a prometheus config or a list of static configs")
+	}
+
+	var urls []string
+	for _, sc := range statics {
+		for _, t := range sc.Targets {
+			if t = strings.TrimSpace(t); t != "" {
+				urls = append(urls, t)
+			}
+		}
+	}
+	return urls, nil
+}
@@ -0,0 +1,193 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// maxConcurrentJobs bounds how many jobs run at once; further jobs wait in the queued state.
+const maxConcurrentJobs = 4
+
+// interruptedReason is recorded on jobs left unfinished by a previous process.
+const interruptedReason = "interrupted by restart"
+
+// ErrFinished is returned when cancelling a job that has already reached a terminal state.
+var ErrFinished = errors.New("job already finished")
+
+// RunFunc performs the work of a job. It should report progress as a percentage and stop
+// promptly when ctx is cancelled. The returned result is stored as the job's JSON payload.
+type RunFunc func(ctx context.Context, progress func(percent int)) (interface{}, error)
+
+// Manager runs background jobs and persists their state through the store.
+type Manager struct {
+	store   storage.JobStore
+	sem     chan struct{}
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewManager creates a Manager that stores jobs in the given store.
+func NewManager(store storage.JobStore) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		store:   store,
+		sem:     make(chan struct{}, maxConcurrentJobs),
+		cancels: make(map[string]context.CancelFunc),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Recover marks jobs orphaned by a previous process as failed. Call it once at startup.
+func (m *Manager) Recover(ctx context.Context) error {
+	n, err := m.store.FailUnfinishedJobs(ctx, interruptedReason, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		logging.API.Warnf("marked %d interrupted jobs as failed", n)
+	}
+	return nil
+}
+
+// Submit records a new queued job of the given type and runs it in the background.
+func (m *Manager) Submit(ctx context.Context, jobType string, run RunFunc) (*models.Job, error) {
+	job := &models.Job{Type: jobType, Status: models.JobStatusQueued, CreatedAt: time.Now().UTC()}
+	if err := m.store.CreateJob(ctx, job); err != nil {
+		return nil, err
+	}
+
+	jobCtx, cancel := context.WithCancel(m.ctx)
+	m.mu.Lock()
+	m.cancels[job.ID] = cancel
+	m.mu.Unlock()
+
+	snapshot := *job
+	m.wg.Add(1)
+	go m.run(jobCtx, job, run)
+	return &snapshot, nil
+}
+
+// Get returns the current state of a job.
+func (m *Manager) Get(ctx context.Context, id string) (*models.Job, error) {
+	return m.store.GetJob(ctx, id)
+}
+
+// Cancel stops a queued or running job. It returns ErrFinished if the job has already finished.
+func (m *Manager) Cancel(ctx context.Context, id string) (*models.Job, error) {
+	job, err := m.store.GetJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Finished() {
+		return job, ErrFinished
+	}
+
+	m.mu.Lock()
+	cancel, ok := m.cancels[id]
+	m.mu.Unlock()
+	if ok {
+		cancel()
+		job.Status = models.JobStatusCancelled
+		return job, nil
+	}
+
+	// Not owned by this process (e.g. left behind by a crash); settle it directly.
+	now := time.Now().UTC()
+	job.Status, job.FinishedAt = models.JobStatusCancelled, &now
+	if err := m.store.UpdateJob(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Stop cancels all running jobs and waits for them to finish.
+func (m *Manager) Stop() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+func (m *Manager) run(ctx context.Context, job *models.Job, run RunFunc) {
+	defer m.wg.Done()
+	defer func() {
+		m.mu.Lock()
+		if cancel, ok := m.cancels[job.ID]; ok {
+			cancel()
+			delete(m.cancels, job.ID)
+		}
+		m.mu.Unlock()
+	}()
+
+	select {
+	case m.sem <- struct{}{}:
+		defer func() { <-m.sem }()
+	case <-ctx.Done():
+		m.finish(job, nil, ctx.Err())
+		return
+	}
+
+	started := time.Now().UTC()
+	job.Status, job.StartedAt = models.JobStatusRunning, &started
+	m.save(job)
+
+	var mu sync.Mutex
+	result, err := run(ctx, func(percent int) {
+		mu.Lock()
+		defer mu.Unlock()
+		if percent < 0 || percent > 100 || percent == job.Progress || ctx.Err() != nil {
+			return
+		}
+		job.Progress = percent
+		m.save(job)
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	m.finish(job, result, err)
+}
+
+// finish records the terminal state of a job. Cancellation, whether requested or caused by
+// shutdown, is recorded as cancelled rather than failed.
+func (m *Manager) finish(job *models.Job, result interface{}, err error) {
+	now := time.Now().UTC()
+	job.FinishedAt = &now
+	switch {
+	case errors.Is(err, context.Canceled):
+		job.Status = models.JobStatusCancelled
+	case err != nil:
+		msg := err.Error()
+		job.Status, job.Error = models.JobStatusFailed, &msg
+	default:
+		payload, merr := json.Marshal(result)
+		if merr != nil {
+			msg := fmt.Sprintf("failed to encode job result: %v", merr)
+			job.Status, job.Error = models.JobStatusFailed, &msg
+			break
+		}
+		job.Status, job.Progress, job.Result = models.JobStatusDone, 100, payload
+	}
+	m.save(job)
+}
+
+// save persists the job outside of any request context, so updates survive cancellation.
+func (m *Manager) save(job *models.Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.store.UpdateJob(ctx, job); err != nil {
+		logging.API.Errorf("error updating job %s: %v", job.ID, err)
+	}
+}
@@ -0,0 +1,250 @@
+// Package redisqueue implements checker.Queue on Redis, so one scheduler process can feed
+// checks to workers in any number of other processes.
+//
+// Queued target IDs are members of a sorted set scored by a per-host sequence number, and
+// workers pop the lowest score with BZPOPMIN. Each host's checks get consecutive numbers
+// starting no lower than the front of the queue, so hosts are served in turn however many
+// targets each has. While a check runs, its claim time is kept in a hash; claims older than
+// the visibility timeout belong to a worker that died and are queued again.
+package redisqueue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/checker"
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+const (
+	// defaultVisibility is how long a check may stay claimed before it is queued again. It
+	// must exceed the longest check, retries included.
+	defaultVisibility = 5 * time.Minute
+	// popTimeout is how long a worker blocks waiting for a check before looking at quit.
+	popTimeout = time.Second
+	// ioTimeout bounds each command on top of any blocking time.
+	ioTimeout = 5 * time.Second
+)
+
+// Config configures a Queue.
+type Config struct {
+	URL        string        // redis://[user:password@]host[:port][/db], or rediss:// for TLS
+	Prefix     string        // Prepended to every key; defaults to "linkwatch"
+	Visibility time.Duration // Defaults to 5 minutes
+}
+
+// Queue is a checker.Queue stored in Redis.
+type Queue struct {
+	client     *client
+	prefix     string
+	visibility time.Duration
+
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	mu       sync.Mutex
+	reapedAt time.Time
+}
+
+// New connects to Redis and returns a Queue.
+func New(cfg Config) (*Queue, error) {
+	c, err := newClient(cfg.URL, ioTimeout)
+	if err != nil {
+		return nil, err
+	}
+	q := &Queue{client: c, prefix: cfg.Prefix, visibility: cfg.Visibility, closed: make(chan struct{})}
+	if q.prefix == "" {
+		q.prefix = "linkwatch"
+	}
+	if q.visibility <= 0 {
+		q.visibility = defaultVisibility
+	}
+	if _, err := c.do(0, "PING"); err != nil {
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	return q, nil
+}
+
+// queuedTarget is how a target is stored while queued. The API's JSON form leaves out the
+// fields only the checker uses, so they are carried alongside.
+type queuedTarget struct {
+	models.Target
+	CanonicalURL string `json:"canonical_url"`
+	Host         string `json:"host"`
+}
+
+func (q *Queue) key(name string) string { return q.prefix + ":" + name }
+
+// pushScript queues a target in one step, so a crash or a dropped connection can't leave the
+// marker set without the target queued, refusing it until the marker expires. Its score is
+// one more than the host's last, but no lower than the front of the queue, so a host that had
+// nothing queued joins the current round.
+//
+// KEYS: the target's marker, the targets hash, the seq hash, and the queue.
+// ARGV: the marker's expiry in milliseconds, the target ID, its payload, and its host.
+const pushScript = `
+if not redis.call('SET', KEYS[1], '1', 'NX', 'PX', ARGV[1]) then
+	return 0
+end
+redis.call('HSET', KEYS[2], ARGV[2], ARGV[3])
+local seq = redis.call('HINCRBY', KEYS[3], ARGV[4], 1)
+local front = redis.call('ZRANGE', KEYS[4], 0, 0, 'WITHSCORES')
+if #front == 2 and tonumber(front[2]) > seq then
+	seq = math.floor(tonumber(front[2]))
+	redis.call('HSET', KEYS[3], ARGV[4], seq)
+end
+return redis.call('ZADD', KEYS[4], 'NX', seq, ARGV[2])
+`
+
+// Push queues target. A target that is queued, or claimed by a worker, isn't queued again.
+// The marker covers the check until Done; it expires in case Done never comes.
+func (q *Queue) Push(target models.Target) error {
+	payload, err := json.Marshal(queuedTarget{Target: target, CanonicalURL: target.CanonicalURL, Host: target.Host})
+	if err != nil {
+		return err
+	}
+	added, err := q.client.do(0, "EVAL", pushScript, "4",
+		q.key("queued:"+target.ID), q.key("targets"), q.key("seq"), q.key("queue"),
+		strconv.FormatInt(q.visibility.Milliseconds(), 10), target.ID, string(payload), target.Host)
+	if err != nil {
+		return err
+	}
+	if added == int64(0) {
+		return checker.ErrAlreadyQueued
+	}
+	return nil
+}
+
+// Take pops the next check, blocking up to a second at a time until one is queued.
+func (q *Queue) Take(quit <-chan struct{}) (models.Target, bool) {
+	for {
+		select {
+		case <-quit:
+			return models.Target{}, false
+		case <-q.closed:
+			return models.Target{}, false
+		default:
+		}
+		q.reapIfDue()
+
+		reply, err := q.client.do(popTimeout, "BZPOPMIN", q.key("queue"), strconv.Itoa(int(popTimeout.Seconds())))
+		if err != nil {
+			logging.Checker.Errorf("error taking check from redis: %v", err)
+			select {
+			case <-quit:
+			case <-q.closed:
+			case <-time.After(popTimeout):
+			}
+			continue
+		}
+		items, _ := reply.([]interface{})
+		if len(items) != 3 {
+			continue // Timed out
+		}
+		id, _ := items[1].(string)
+		if _, err := q.client.do(0, "HSET", q.key("claims"), id, strconv.FormatInt(time.Now().UnixMilli(), 10)); err != nil {
+			logging.Checker.Errorf("error claiming check %s in redis: %v", id, err)
+		}
+		target, err := q.target(id)
+		if err != nil {
+			logging.Checker.Errorf("error loading queued check %s from redis: %v", id, err)
+			q.Done(models.Target{ID: id})
+			continue
+		}
+		return target, true
+	}
+}
+
+func (q *Queue) target(id string) (models.Target, error) {
+	reply, err := q.client.do(0, "HGET", q.key("targets"), id)
+	if err != nil {
+		return models.Target{}, err
+	}
+	payload, ok := reply.(string)
+	if !ok {
+		return models.Target{}, errors.New("target missing")
+	}
+	var qt queuedTarget
+	if err := json.Unmarshal([]byte(payload), &qt); err != nil {
+		return models.Target{}, err
+	}
+	qt.Target.CanonicalURL, qt.Target.Host = qt.CanonicalURL, qt.Host
+	return qt.Target, nil
+}
+
+// Done removes the claim and queue marker of a finished check.
+func (q *Queue) Done(target models.Target) {
+	for _, args := range [][]string{
+		{"HDEL", q.key("claims"), target.ID},
+		{"HDEL", q.key("targets"), target.ID},
+		{"DEL", q.key("queued:" + target.ID)},
+	} {
+		if _, err := q.client.do(0, args...); err != nil {
+			logging.Checker.Errorf("error completing check %s in redis: %v", target.ID, err)
+		}
+	}
+}
+
+// reapIfDue queues claims older than the visibility timeout again, at most every quarter of
+// the timeout per process. Removing the claim first makes sure only one process requeues it.
+func (q *Queue) reapIfDue() {
+	q.mu.Lock()
+	if time.Since(q.reapedAt) < q.visibility/4 {
+		q.mu.Unlock()
+		return
+	}
+	q.reapedAt = time.Now()
+	q.mu.Unlock()
+
+	reply, err := q.client.do(0, "HGETALL", q.key("claims"))
+	if err != nil {
+		logging.Checker.Errorf("error listing claimed checks in redis: %v", err)
+		return
+	}
+	items, _ := reply.([]interface{})
+	cutoff := time.Now().Add(-q.visibility).UnixMilli()
+	for i := 0; i+1 < len(items); i += 2 {
+		id, _ := items[i].(string)
+		at, _ := items[i+1].(string)
+		if ms, err := strconv.ParseInt(at, 10, 64); err != nil || ms >= cutoff {
+			continue
+		}
+		if n, err := q.client.do(0, "HDEL", q.key("claims"), id); err != nil || n != int64(1) {
+			continue
+		}
+		q.client.do(0, "SET", q.key("queued:"+id), "1", "PX", strconv.FormatInt(q.visibility.Milliseconds(), 10))
+		if _, err := q.client.do(0, "ZADD", q.key("queue"), "NX", "0", id); err != nil {
+			logging.Checker.Errorf("error requeueing check %s in redis: %v", id, err)
+			continue
+		}
+		logging.Checker.Infof("requeued check %s, claimed by a worker that didn't finish it", id)
+	}
+}
+
+// Depth returns how many checks are queued and not yet taken.
+func (q *Queue) Depth() int {
+	reply, err := q.client.do(0, "ZCARD", q.key("queue"))
+	if err != nil {
+		logging.Checker.Errorf("error counting queued checks in redis: %v", err)
+		return 0
+	}
+	n, _ := reply.(int64)
+	return int(n)
+}
+
+// Capacity is zero: the queue is bounded only by the number of targets.
+func (q *Queue) Capacity() int { return 0 }
+
+// Close stops workers from taking checks and closes idle connections. Queued checks stay in
+// Redis for other workers.
+func (q *Queue) Close() {
+	q.closeOnce.Do(func() {
+		close(q.closed)
+		q.client.close()
+	})
+}
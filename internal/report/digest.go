@@ -0,0 +1,50 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+
+	"github.com/zeng-yichen/linkwatch/pkg/notify"
+)
+
+// DigestMailer emails alert digests (see notify.DigestNotifier).
+type DigestMailer struct {
+	mailer     Mailer
+	from       string
+	recipients []string
+}
+
+// NewDigestMailer creates a digest sender emailing the recipients through mailer.
+func NewDigestMailer(mailer Mailer, from string, recipients []string) *DigestMailer {
+	return &DigestMailer{mailer: mailer, from: from, recipients: recipients}
+}
+
+var digestTemplate = template.Must(template.New("digest").Parse(`Linkwatch alerts
+{{.Since.Format "2006-01-02 15:04:05 MST"}} - {{.Until.Format "2006-01-02 15:04:05 MST"}}
+
+Down ({{len .Down}}):
+{{range .Down}}  {{.URL}}: {{.Message}}
+{{else}}  none
+{{end}}
+Up ({{len .Up}}):
+{{range .Up}}  {{.URL}}: {{.Message}}
+{{else}}  none
+{{end}}
+All alerts ({{len .Events}}):
+{{range .Events}}  {{.At.Format "15:04:05"}}  {{.Type}}  {{.URL}}
+{{end}}`))
+
+// SendDigest renders the digest as plain text and emails it.
+func (m *DigestMailer) SendDigest(ctx context.Context, digest notify.Digest) error {
+	var buf bytes.Buffer
+	if err := digestTemplate.Execute(&buf, digest); err != nil {
+		return fmt.Errorf("failed to render digest: %w", err)
+	}
+	subject := fmt.Sprintf("Linkwatch alerts: %d down, %d up", len(digest.Down), len(digest.Up))
+	if err := m.mailer.Send(m.from, m.recipients, subject, buf.String()); err != nil {
+		return fmt.Errorf("failed to send digest: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/internal/cron"
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// Supported report periods.
+const (
+	PeriodDaily  = "daily"
+	PeriodWeekly = "weekly"
+)
+
+// slowestCount is the number of slowest endpoints listed in a summary.
+const slowestCount = 5
+
+// PeriodWindow returns the lookback window covered by a report period.
+func PeriodWindow(period string) (time.Duration, error) {
+	switch period {
+	case PeriodDaily:
+		return 24 * time.Hour, nil
+	case PeriodWeekly:
+		return 7 * 24 * time.Hour, nil
+	default:
+		return 0, fmt.Errorf("unsupported report period %q", period)
+	}
+}
+
+// TargetUptime describes a single target's availability within the report window.
+type TargetUptime struct {
+	models.TargetStats
+	UptimePercent float64 `json:"uptime_percent"`
+}
+
+// Summary is a generated report covering all checked targets within a window.
+type Summary struct {
+	Period         string               `json:"period"`
+	Since          time.Time            `json:"since"`
+	Until          time.Time            `json:"until"`
+	Targets        []TargetUptime       `json:"targets"`
+	TotalIncidents int64                `json:"total_incidents"`
+	Slowest        []models.TargetStats `json:"slowest"`
+}
+
+// Mailer delivers a rendered report to its recipients.
+type Mailer interface {
+	Send(from string, to []string, subject, body string) error
+}
+
+// Reporter generates summaries from storage and optionally emails them on a schedule.
+type Reporter struct {
+	store      storage.ResultReader
+	mailer     Mailer
+	from       string
+	recipients []string
+	stopChan   chan struct{}
+	wg         sync.WaitGroup
+}
+
+// New creates a new Reporter. The mailer may be nil, in which case reports can be
+// generated but not sent.
+func New(store storage.ResultReader, mailer Mailer, from string, recipients []string) *Reporter {
+	return &Reporter{
+		store:      store,
+		mailer:     mailer,
+		from:       from,
+		recipients: recipients,
+		stopChan:   make(chan struct{}),
+	}
+}
+
+// CanSend reports whether the reporter has a mailer and at least one recipient.
+func (r *Reporter) CanSend() bool {
+	return r.mailer != nil && len(r.recipients) > 0
+}
+
+// Generate builds a summary for the period ending at now.
+func (r *Reporter) Generate(ctx context.Context, period string) (*Summary, error) {
+	window, err := PeriodWindow(period)
+	if err != nil {
+		return nil, err
+	}
+	until := time.Now().UTC()
+	since := until.Add(-window)
+
+	stats, err := r.store.ListTargetStats(ctx, storage.TargetStatsParams{
+		Since:   since,
+		Until:   until,
+		OrderBy: storage.StatsOrderByFailures,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate target stats: %w", err)
+	}
+
+	summary := &Summary{Period: period, Since: since, Until: until, Targets: []TargetUptime{}}
+	for _, st := range stats {
+		uptime := 0.0
+		if st.CheckCount > 0 {
+			uptime = float64(st.SuccessCount) / float64(st.CheckCount) * 100
+		}
+		summary.Targets = append(summary.Targets, TargetUptime{TargetStats: st, UptimePercent: uptime})
+		summary.TotalIncidents += st.IncidentCount
+	}
+
+	slowest := append([]models.TargetStats(nil), stats...)
+	sort.SliceStable(slowest, func(i, j int) bool { return slowest[i].AvgLatencyMS > slowest[j].AvgLatencyMS })
+	if len(slowest) > slowestCount {
+		slowest = slowest[:slowestCount]
+	}
+	summary.Slowest = slowest
+	return summary, nil
+}
+
+// Send generates a summary for the period and emails it to the configured recipients.
+func (r *Reporter) Send(ctx context.Context, period string) (*Summary, error) {
+	if !r.CanSend() {
+		return nil, fmt.Errorf("reporting is not configured with a mailer and recipients")
+	}
+	summary, err := r.Generate(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+	body, err := Render(summary)
+	if err != nil {
+		return nil, err
+	}
+	subject := fmt.Sprintf("Linkwatch %s report: %d targets, %d incidents", summary.Period, len(summary.Targets), summary.TotalIncidents)
+	if err := r.mailer.Send(r.from, r.recipients, subject, body); err != nil {
+		return nil, fmt.Errorf("failed to send report: %w", err)
+	}
+	return summary, nil
+}
+
+// Start sends a report for the given period every time the cron schedule fires.
+func (r *Reporter) Start(schedule *cron.Schedule, period string) {
+	logging.Notify.Infof("starting %s report scheduler", period)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		for {
+			next := schedule.Next(time.Now())
+			if next.IsZero() {
+				logging.Notify.Warnf("report schedule never fires, stopping report scheduler")
+				return
+			}
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-timer.C:
+				if _, err := r.Send(context.Background(), period); err != nil {
+					logging.Notify.Errorf("error sending scheduled report: %v", err)
+				} else {
+					logging.Notify.Infof("sent %s report to %d recipients", period, len(r.recipients))
+				}
+			case <-r.stopChan:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the report scheduler.
+func (r *Reporter) Stop() {
+	close(r.stopChan)
+	r.wg.Wait()
+}
+
+var summaryTemplate = template.Must(template.New("summary").Parse(`Linkwatch {{.Period}} report
+{{.Since.Format "2006-01-02 15:04 MST"}} - {{.Until.Format "2006-01-02 15:04 MST"}}
+
+Targets checked: {{len .Targets}}
+Incidents: {{.TotalIncidents}}
+
+Uptime per target:
+{{range .Targets}}  {{printf "%6.2f" .UptimePercent}}%  {{.URL}} ({{.FailureCount}} failed of {{.CheckCount}}, {{.IncidentCount}} incidents)
+{{else}}  no checks recorded
+{{end}}
+Slowest endpoints:
+{{range .Slowest}}  {{printf "%8.1f" .AvgLatencyMS}} ms avg  {{.URL}} (max {{.MaxLatencyMS}} ms)
+{{else}}  no checks recorded
+{{end}}`))
+
+// Render formats a summary as a plain-text email body.
+func Render(summary *Summary) (string, error) {
+	var buf bytes.Buffer
+	if err := summaryTemplate.Execute(&buf, summary); err != nil {
+		return "", fmt.Errorf("failed to render report: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,45 @@
+package report
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPMailer sends reports through an SMTP relay.
+type SMTPMailer struct {
+	addr     string
+	username string
+	password string
+}
+
+// NewSMTPMailer creates a mailer for the given "host:port" relay. Authentication is
+// only attempted when a username is provided.
+func NewSMTPMailer(addr, username, password string) *SMTPMailer {
+	return &SMTPMailer{addr: addr, username: username, password: password}
+}
+
+// Send delivers a plain-text message to the recipients.
+func (m *SMTPMailer) Send(from string, to []string, subject, body string) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		host, _, err := net.SplitHostPort(m.addr)
+		if err != nil {
+			return fmt.Errorf("invalid smtp address: %w", err)
+		}
+		auth = smtp.PlainAuth("", m.username, m.password, host)
+	}
+
+	var msg strings.Builder
+	msg.WriteString("From: " + from + "\r\n")
+	msg.WriteString("To: " + strings.Join(to, ", ") + "\r\n")
+	msg.WriteString("Subject: " + subject + "\r\n")
+	msg.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return smtp.SendMail(m.addr, auth, from, to, []byte(msg.String()))
+}
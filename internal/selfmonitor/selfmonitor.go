@@ -0,0 +1,71 @@
+// Package selfmonitor registers the service's own readiness probe as a target, so linkwatch
+// checks itself with the same scheduler, history, and alerts as everything else.
+package selfmonitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// Label is the metadata entry marking the target linkwatch checks itself with.
+var Label = map[string]string{"linkwatch": "self"}
+
+// probe is the readiness probe the target checks; it fails while the service is degraded.
+const probe = "/readyz?fail_degraded=true"
+
+// Config is how the service is listening, from SELF_MONITOR, SELF_MONITOR_URL, and the HTTP
+// listener settings.
+type Config struct {
+	Enabled bool
+	URL     string // Base URL the service reaches itself at; derived from Addr when empty
+	Addr    string // host:port or unix://path the API listens on
+	TLS     bool   // The listener serves HTTPS
+}
+
+// ProbeURL returns the URL of the service's own readiness probe, at URL or else at the
+// loopback address of the listener on Addr, or "" when self-monitoring is disabled. Unix
+// sockets and HTTPS listeners aren't reachable that way, so they need URL.
+func (c Config) ProbeURL() (string, error) {
+	if !c.Enabled {
+		return "", nil
+	}
+	if c.URL != "" {
+		return strings.TrimSuffix(c.URL, "/") + probe, nil
+	}
+	if strings.HasPrefix(c.Addr, "unix://") || c.TLS {
+		return "", errors.New("SELF_MONITOR needs SELF_MONITOR_URL when serving HTTPS or on a Unix socket")
+	}
+	host, port, err := net.SplitHostPort(c.Addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid listen address %q: %w", c.Addr, err)
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port) + probe, nil
+}
+
+// Register creates the self-monitoring target for url, or returns the existing one, so a
+// restart checks the same target. It returns nil when url is empty. A self-monitoring target
+// left from an earlier SELF_MONITOR_URL is kept, with its history, until it is deleted.
+func Register(ctx context.Context, store storage.TargetWriter, url string) (*models.Target, error) {
+	if url == "" {
+		return nil, nil
+	}
+	target, err := models.NewHTTPTarget(url)
+	if err != nil {
+		return nil, err
+	}
+	target.Metadata = Label
+	created, err := store.CreateTarget(ctx, target, nil)
+	if errors.Is(err, storage.ErrDuplicateKey) {
+		return created, nil
+	}
+	return created, err
+}
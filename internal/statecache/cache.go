@@ -0,0 +1,144 @@
+package statecache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/metrics"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// Cache is a read-through, in-memory cache of target state derived from each target's
+// latest check result. Entries expire after the TTL and are invalidated as soon as a new
+// result is published for the target. A zero TTL disables caching.
+type Cache struct {
+	store   storage.ResultReader
+	ttl     time.Duration
+	metrics metrics.Recorder
+
+	mu      sync.Mutex
+	entries map[string]entry
+	// gen increases on every invalidation; dirty records the generation at which each target
+	// was last invalidated, so a load that raced with an invalidation is not cached. loads
+	// counts the in-flight loads by the generation they started at. An invalidation only
+	// matters to loads started before it, so dirty is pruned as they finish.
+	gen   uint64
+	dirty map[string]uint64
+	loads map[uint64]int
+}
+
+type entry struct {
+	state   models.TargetState
+	expires time.Time
+}
+
+// New creates a Cache backed by the store. Hits and misses are counted as cache.hits and
+// cache.misses on the recorder.
+func New(store storage.ResultReader, ttl time.Duration, recorder metrics.Recorder) *Cache {
+	return &Cache{
+		store:   store,
+		ttl:     ttl,
+		metrics: recorder,
+		entries: make(map[string]entry),
+		dirty:   make(map[string]uint64),
+		loads:   make(map[uint64]int),
+	}
+}
+
+// States returns the state of each target, loading any missing or expired entries from
+// the store in a single query.
+func (c *Cache) States(ctx context.Context, targetIDs []string) (map[string]models.TargetState, error) {
+	states := make(map[string]models.TargetState, len(targetIDs))
+	var missing []string
+
+	now := time.Now()
+	c.mu.Lock()
+	for _, id := range targetIDs {
+		if e, ok := c.entries[id]; ok && now.Before(e.expires) {
+			states[id] = e.state
+			continue
+		}
+		delete(c.entries, id)
+		missing = append(missing, id)
+	}
+	startGen := c.gen
+	if len(missing) > 0 {
+		c.loads[startGen]++
+	}
+	c.mu.Unlock()
+
+	c.metrics.Count("cache.hits", int64(len(targetIDs)-len(missing)), metrics.T("cache", "target_state"))
+	c.metrics.Count("cache.misses", int64(len(missing)), metrics.T("cache", "target_state"))
+	if len(missing) == 0 {
+		return states, nil
+	}
+
+	latest, err := c.store.GetLatestResults(ctx, missing)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.finishLoad(startGen)
+	if err != nil {
+		return nil, err
+	}
+	expires := time.Now().Add(c.ttl)
+	for _, id := range missing {
+		var state models.TargetState
+		if r, ok := latest[id]; ok {
+			state = models.StateFromResult(&r)
+		} else {
+			state = models.StateFromResult(nil)
+		}
+		states[id] = state
+		if c.ttl > 0 && c.dirty[id] <= startGen {
+			c.entries[id] = entry{state: state, expires: expires}
+		}
+	}
+	return states, nil
+}
+
+// finishLoad forgets a load started at gen, along with the invalidations that no load still
+// in flight started before. c.mu must be held.
+func (c *Cache) finishLoad(gen uint64) {
+	if c.loads[gen]--; c.loads[gen] == 0 {
+		delete(c.loads, gen)
+	}
+	if len(c.loads) == 0 {
+		clear(c.dirty)
+		return
+	}
+	oldest := ^uint64(0)
+	for g := range c.loads {
+		oldest = min(oldest, g)
+	}
+	for id, g := range c.dirty {
+		if g <= oldest {
+			delete(c.dirty, id)
+		}
+	}
+}
+
+// Invalidate drops the cached state of a target.
+func (c *Cache) Invalidate(targetID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	if len(c.loads) > 0 {
+		c.dirty[targetID] = c.gen
+	}
+	delete(c.entries, targetID)
+}
+
+// Pending returns how many invalidations are kept to stop in-flight loads from caching
+// the state they replaced.
+func (c *Cache) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.dirty)
+}
+
+// Publish invalidates the target's state when a new check result is stored.
+func (c *Cache) Publish(result models.CheckResult) {
+	c.Invalidate(result.TargetID)
+}
@@ -0,0 +1,52 @@
+package checker
+
+import (
+	"context"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+)
+
+// ResultArchiver moves aged check results out of the store, e.g. into object storage.
+type ResultArchiver interface {
+	// ArchiveCheckResults archives and deletes the results due for archiving at now,
+	// returning how many were deleted locally.
+	ArchiveCheckResults(ctx context.Context, now time.Time) (int, error)
+}
+
+// WithResultArchiving runs archiver every interval. Like sampling, it only runs on the
+// instance that leads.
+func WithResultArchiving(archiver ResultArchiver, interval time.Duration) Option {
+	return func(c *Checker) {
+		if interval > 0 {
+			c.archiver = archiver
+			c.archiveEvery = interval
+		}
+	}
+}
+
+// archiveResults runs the archiver every archiveEvery until the checker stops.
+func (c *Checker) archiveResults() {
+	ticker := c.clock.NewTicker(c.archiveEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			if !c.leading() {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), c.archiveEvery)
+			n, err := c.archiver.ArchiveCheckResults(ctx, c.clock.Now().UTC())
+			cancel()
+			if err != nil {
+				logging.Checker.Errorf("error archiving check results: %v", err)
+			}
+			if n > 0 {
+				logging.Checker.Infof("archived check results, deleted %d locally", n)
+				c.metrics.Count("results.archived", int64(n))
+			}
+		case <-c.stopChan:
+			return
+		}
+	}
+}
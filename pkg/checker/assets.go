@@ -0,0 +1,191 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+
+	"github.com/zeng-yichen/linkwatch/pkg/metrics"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+const (
+	// defaultMaxAssets is how many assets an asset check requests when the target leaves
+	// MaxAssets unset.
+	defaultMaxAssets = 50
+	// assetConcurrency is the number of assets requested in parallel for a single check.
+	assetConcurrency = 4
+)
+
+// asset is a script, stylesheet, or image referenced by a page.
+type asset struct {
+	url *url.URL
+	typ string
+}
+
+// isHTML reports whether a Content-Type header names an HTML document.
+func isHTML(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml")
+}
+
+// checkAssets checks the assets of an HTML page: each must load over HTTPS if the page
+// did, and the first MaxAssets must respond without an error or a 4xx/5xx status.
+func (p *WorkerPool) checkAssets(ctx context.Context, target models.Target, page *url.URL, body []byte) *models.AssetReport {
+	assets := extractAssets(bytes.NewReader(body), page)
+	report := &models.AssetReport{Found: len(assets)}
+	for _, a := range assets {
+		if page.Scheme == "https" && a.url.Scheme == "http" {
+			report.Findings = append(report.Findings, models.AssetFinding{URL: p.redactor.URL(a.url.String()), Type: a.typ, Problem: models.AssetProblemMixedContent})
+		}
+	}
+	limit := target.AssetCheck.MaxAssets
+	if limit <= 0 {
+		limit = defaultMaxAssets
+	}
+	if len(assets) > limit {
+		assets = assets[:limit]
+	}
+	report.Checked = len(assets)
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, assetConcurrency)
+	)
+	for _, a := range assets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			finding := p.checkAsset(ctx, a)
+			if finding == nil {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			report.Findings = append(report.Findings, *finding)
+		}()
+	}
+	wg.Wait()
+
+	slices.SortStableFunc(report.Findings, func(a, b models.AssetFinding) int {
+		if c := strings.Compare(a.Problem, b.Problem); c != 0 {
+			return c
+		}
+		return strings.Compare(a.URL, b.URL)
+	})
+	for _, f := range report.Findings {
+		p.metrics.Count("checks.asset_findings", 1, metrics.T("host", target.Host), metrics.T("problem", f.Problem))
+	}
+	return report
+}
+
+// checkAsset returns a broken finding when the asset errors or responds with a 4xx/5xx
+// status. HEAD is tried first, falling back to GET for servers that don't support it.
+func (p *WorkerPool) checkAsset(ctx context.Context, a asset) *models.AssetFinding {
+	status, err := p.requestAsset(ctx, http.MethodHead, a.url)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = p.requestAsset(ctx, http.MethodGet, a.url)
+	}
+	finding := &models.AssetFinding{URL: p.redactor.URL(a.url.String()), Type: a.typ, Problem: models.AssetProblemBroken}
+	switch {
+	case err != nil:
+		msg := p.redactor.Text(err.Error())
+		finding.Error = &msg
+	case status >= 400:
+		finding.StatusCode = &status
+	default:
+		return nil
+	}
+	return finding
+}
+
+func (p *WorkerPool) requestAsset(ctx context.Context, method string, u *url.URL) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// extractAssets parses an HTML document and returns the unique absolute http(s) URLs of
+// its <script src>, <link rel="stylesheet" href>, and <img src> assets, resolved against
+// base (or the document's <base href>), in document order.
+func extractAssets(r io.Reader, base *url.URL) []asset {
+	seen := make(map[string]bool)
+	var assets []asset
+	tokenizer := html.NewTokenizer(r)
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			// A page cut off by the body limit still yields the assets before the cut.
+			return assets
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			if !hasAttr {
+				continue
+			}
+			attrs := tagAttrs(tokenizer)
+			var ref, typ string
+			switch string(name) {
+			case "base":
+				if u, err := base.Parse(strings.TrimSpace(attrs["href"])); err == nil && attrs["href"] != "" {
+					base = u
+				}
+				continue
+			case "script":
+				ref, typ = attrs["src"], models.AssetTypeScript
+			case "link":
+				if !slices.Contains(strings.Fields(strings.ToLower(attrs["rel"])), "stylesheet") {
+					continue
+				}
+				ref, typ = attrs["href"], models.AssetTypeStylesheet
+			case "img":
+				ref, typ = attrs["src"], models.AssetTypeImage
+			default:
+				continue
+			}
+			if ref = strings.TrimSpace(ref); ref == "" {
+				continue
+			}
+			u, err := base.Parse(ref)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				continue
+			}
+			u.Fragment = ""
+			if key := u.String(); !seen[key] {
+				seen[key] = true
+				assets = append(assets, asset{url: u, typ: typ})
+			}
+		}
+	}
+}
+
+// tagAttrs returns the attributes of the current tag, keyed by lowercase name.
+func tagAttrs(z *html.Tokenizer) map[string]string {
+	attrs := make(map[string]string)
+	for {
+		key, val, more := z.TagAttr()
+		if _, ok := attrs[string(key)]; !ok {
+			attrs[string(key)] = string(val)
+		}
+		if !more {
+			return attrs
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package checker
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"strings"
+)
+
+// defaultMaxBodyBytes is how much of a response body is read when no limit is configured.
+const defaultMaxBodyBytes = 1 << 20
+
+// bodyPolicy guards how much of a response body the checker reads, and for which content types.
+type bodyPolicy struct {
+	maxBytes     int64
+	contentTypes []string // Extra media types (or "*") read in addition to text types
+}
+
+// isText reports whether a media type is textual: text/*, JSON, XML, or JavaScript.
+func isText(mediaType string) bool {
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == "application/json", mediaType == "application/xml", mediaType == "application/javascript",
+		strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	return false
+}
+
+// shouldRead reports whether a body with the given Content-Type header is read. Responses
+// without a Content-Type are treated as text.
+func (b bodyPolicy) shouldRead(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if isText(mediaType) {
+		return true
+	}
+	for _, t := range b.contentTypes {
+		if t == "*" || strings.EqualFold(t, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// read consumes up to maxBytes of the body and reports how many bytes it read, whether there
+// was more, and whether the body ended before it was complete: a read error such as a
+// connection reset or a missing final chunk, or fewer bytes than contentLength (-1 when
+// unknown). When keep is non-nil, what was read is kept in it.
+//
+// Bodies whose content type shouldRead excludes are not read at all, so that skipping them
+// saves the download: they count as 0 bytes and are never found partial, however short.
+func (b bodyPolicy) read(body io.Reader, contentType string, contentLength int64, keep *bytes.Buffer) (n int64, truncated, partial bool) {
+	if !b.shouldRead(contentType) {
+		return 0, false, false
+	}
+	var dst io.Writer = io.Discard
+	if keep != nil {
+		dst = keep
+	}
+	n, err := io.Copy(dst, io.LimitReader(body, b.maxBytes+1))
+	if n > b.maxBytes {
+		return n, true, false
+	}
+	return n, false, err != nil || n < contentLength
+}
@@ -0,0 +1,529 @@
+// Package checker schedules and performs target checks. A Checker walks every stored target
+// once per interval and hands HTTP targets to a pool of workers, which retry failures, store
+// each result, and publish it. Behavior is configured with Options, and Hooks run around
+// every check.
+//
+// Embedding the checker takes a store and a call to Start:
+//
+//	store, err := sqlite.New(ctx, "linkwatch.db")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	c := checker.New(store, 30*time.Second, 8, 5*time.Second, checker.WithResultSinks(sink))
+//	c.Start()
+//	defer c.Stop()
+//
+// See examples/embedded for a complete program.
+package checker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/clock"
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/metrics"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/notify"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+	"github.com/zeng-yichen/linkwatch/pkg/urlutil"
+)
+
+// QueueStats reports the worker pool's queue capacity, current depth, how long its oldest
+// target has waited, and how many targets have been dropped since startup.
+func (c *Checker) QueueStats() models.QueueStats {
+	stats := models.QueueStats{
+		Capacity: c.pool.QueueCapacity(),
+		Depth:    c.pool.QueueDepth(),
+		Dropped:  c.pool.QueueDropped(),
+	}
+	if q, ok := c.pool.jobs.(OldestQueuer); ok {
+		if oldest := q.OldestQueued(); !oldest.IsZero() {
+			stats.OldestAgeMS = max(c.clock.Since(oldest).Milliseconds(), 0)
+		}
+	}
+	return stats
+}
+
+// WorkerStats reports the configured worker count and how many workers are still running.
+func (c *Checker) WorkerStats() models.WorkerStats {
+	return models.WorkerStats{Size: c.pool.Workers(), Active: c.pool.ActiveWorkers()}
+}
+
+// HostLoad reports the checks running and queued on every host with either.
+func (c *Checker) HostLoad() map[string]models.HostLoad {
+	return c.pool.HostLoad()
+}
+
+// ResizeWorkers changes how many checks run concurrently. See WorkerPool.Resize.
+func (c *Checker) ResizeWorkers(n int) error {
+	if err := c.pool.Resize(n); err != nil {
+		return err
+	}
+	logging.Checker.Infof("resized worker pool to %d workers", n)
+	return nil
+}
+
+// defaultBatchSize is the number of targets loaded per scheduling page.
+const defaultBatchSize = 1000
+
+// Store is what a Checker persists through: it pages through targets, reads and stores
+// their results, and records system events.
+type Store interface {
+	storage.TargetReader
+	storage.ResultStore
+	RecordSystemEvent(ctx context.Context, event *models.SystemEvent) error
+}
+
+// Checker is responsible for periodically scheduling URL checks.
+type Checker struct {
+	store         Store
+	pool          *WorkerPool
+	notifier      notify.Notifier
+	sinks         *notify.Sinks
+	metrics       metrics.Recorder
+	batchSize     int
+	filter        *changeFilter
+	dnsFailures   *dnsFailureCache
+	budget        time.Duration
+	warmup        time.Duration
+	body          bodyPolicy
+	maxRedirects  int
+	queueSize     int
+	storeQueue    storage.CheckQueue
+	queue         Queue
+	role          string
+	leader        *leaderElection
+	shard         shard
+	sampler       storage.ResultSampler
+	sampleEvery   time.Duration
+	archiver      ResultArchiver
+	archiveEvery  time.Duration
+	domains       DomainMonitor
+	domainEvery   time.Duration
+	clientCerts   ClientCertSource
+	targets       *targetCache // Nil reads the targets from the store every pass
+	redactor      *urlutil.Redactor
+	hooks         []Hook
+	clock         clock.Clock
+	poolOpts      []PoolOption
+	checkInterval time.Duration
+	health        schedulerHealth
+	monitorEvery  time.Duration // Zero disables the queue monitor
+	thresholds    QueueThresholds
+	overflowing   bool // The last pass dropped checks, see scheduleChecks
+	stopChan      chan struct{}
+	wg            sync.WaitGroup
+}
+
+// Option configures optional Checker dependencies.
+type Option func(*Checker)
+
+// WithNotifier sets the notifier used to fire alerts. Alerts are logged by default.
+func WithNotifier(n notify.Notifier) Option {
+	return func(c *Checker) { c.notifier = n }
+}
+
+// WithResultSinks registers sinks that receive every check result after it is stored.
+func WithResultSinks(sinks ...notify.ResultSink) Option {
+	return func(c *Checker) {
+		for _, s := range sinks {
+			c.sinks.Register(s)
+		}
+	}
+}
+
+// WithResultPublisher registers a single result sink. It is equivalent to WithResultSinks(p).
+func WithResultPublisher(p notify.ResultPublisher) Option {
+	return WithResultSinks(p)
+}
+
+// WithMetrics sets the recorder for check, queue, and heartbeat metrics. Metrics are discarded by default.
+func WithMetrics(m metrics.Recorder) Option {
+	return func(c *Checker) { c.metrics = m }
+}
+
+// WithBatchSize sets how many targets the scheduler loads from storage at a time.
+func WithBatchSize(n int) Option {
+	return func(c *Checker) {
+		if n > 0 {
+			c.batchSize = n
+		}
+	}
+}
+
+// WithChangeOnlyStorage stores a result only when its status, error, or latency bucket changed
+// since the last stored result, or when keepalive has elapsed since then.
+func WithChangeOnlyStorage(keepalive time.Duration) Option {
+	return func(c *Checker) { c.filter = newChangeFilter(keepalive) }
+}
+
+// WithWarmup makes Start catch up on checks that came due while the checker wasn't running by
+// spreading them over the window, instead of checking every target at once. Targets checked
+// within the last interval wait for the first regular cycle, which starts after the window.
+func WithWarmup(window time.Duration) Option {
+	return func(c *Checker) {
+		if window > 0 {
+			c.warmup = window
+		}
+	}
+}
+
+// WithTimeoutBudget caps the total time a check may take, including retries and backoff, so a
+// target that keeps timing out can't hold a worker for every attempt's full HTTP timeout. Each
+// attempt gets an even share of what is left of the budget (never more than the HTTP timeout),
+// and no retry is made once the backoff would exhaust it. Targets can override the budget with
+// TimeoutBudgetMS. It is unbounded by default.
+func WithTimeoutBudget(d time.Duration) Option {
+	return func(c *Checker) {
+		if d > 0 {
+			c.budget = d
+		}
+	}
+}
+
+// WithDNSFailureCache fails checks of a host without a lookup for ttl after its name failed to
+// resolve, recording them with CachedDNSFailure set. It is disabled by default.
+func WithDNSFailureCache(ttl time.Duration) Option {
+	return func(c *Checker) {
+		if ttl > 0 {
+			c.dnsFailures = newDNSFailureCache(ttl)
+		}
+	}
+}
+
+// WithBodyLimits caps how many bytes of each response body are read (1MB by default). Only
+// text bodies (text/*, JSON, XML, JavaScript) are read, plus the given extra media types;
+// "*" reads every content type.
+func WithBodyLimits(maxBytes int64, contentTypes []string) Option {
+	return func(c *Checker) {
+		if maxBytes > 0 {
+			c.body.maxBytes = maxBytes
+		}
+		c.body.contentTypes = contentTypes
+	}
+}
+
+// WithMaxRedirects sets how many redirects a check follows (5 by default). A longer chain, or
+// one that revisits a URL, fails the check; with 0 the first response is recorded as is.
+func WithMaxRedirects(n int) Option {
+	return func(c *Checker) {
+		if n >= 0 {
+			c.maxRedirects = n
+		}
+	}
+}
+
+// WithQueueSize sets how many targets may wait for a worker (twice the concurrency by default).
+// Targets submitted while the queue is full are dropped until the next cycle.
+func WithQueueSize(n int) Option {
+	return func(c *Checker) {
+		if n > 0 {
+			c.queueSize = n
+		}
+	}
+}
+
+// WithQueue keeps scheduled checks in q instead of the default in-memory queue.
+// WithQueueSize and WithStoreQueue are ignored.
+func WithQueue(q Queue) Option {
+	return func(c *Checker) { c.queue = q }
+}
+
+// Checker roles. A scheduler process and any number of worker processes can share a Queue
+// so that checking scales separately from scheduling.
+const (
+	RoleAll       = "all"       // Schedule checks and run workers
+	RoleScheduler = "scheduler" // Only schedule checks (and evaluate heartbeats); run no workers
+	RoleWorker    = "worker"    // Only run workers, taking checks from the queue
+)
+
+// WithRole limits the checker to scheduling or to checking; see RoleScheduler and RoleWorker.
+// Both only make sense with a Queue shared between processes.
+func WithRole(role string) Option {
+	return func(c *Checker) { c.role = role }
+}
+
+// WithRedactor sets how credentials are stripped from target URLs in logs and stored check
+// errors. By default userinfo and the urlutil.DefaultSensitiveParams query parameters are.
+func WithRedactor(r *urlutil.Redactor) Option {
+	return func(c *Checker) { c.redactor = r }
+}
+
+// WithHooks adds hooks that run around every HTTP check. See Hook.
+func WithHooks(hooks ...Hook) Option {
+	return func(c *Checker) { c.hooks = append(c.hooks, hooks...) }
+}
+
+// WithClock sets the time source for scheduling, retry backoff, and latency measurement. It
+// defaults to the system clock; tests pass a clock.Fake to drive checks without sleeping.
+func WithClock(clk clock.Clock) Option {
+	return func(c *Checker) { c.clock = clk }
+}
+
+// WithHTTPClient sends checks with client instead of the default one, e.g. to add
+// authentication or a proxy. A zero Timeout uses the checker's HTTP timeout and a nil
+// Transport the default one, and the checker's redirect policy replaces CheckRedirect; the
+// client passed in is not modified.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Checker) { c.poolOpts = append(c.poolOpts, PoolHTTPClient(client)) }
+}
+
+// WithTransport sends checks through rt, e.g. to serve them from a test double or to add
+// tracing. Certificates are not verified by the default transport; rt decides for itself.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Checker) { c.poolOpts = append(c.poolOpts, PoolTransport(rt)) }
+}
+
+// New creates a new Checker.
+func New(store Store, interval time.Duration, maxConcurrency int, httpTimeout time.Duration, opts ...Option) *Checker {
+	c := &Checker{
+		store:         store,
+		notifier:      notify.LogNotifier{},
+		sinks:         &notify.Sinks{},
+		metrics:       metrics.Nop{},
+		batchSize:     defaultBatchSize,
+		body:          bodyPolicy{maxBytes: defaultMaxBodyBytes},
+		maxRedirects:  defaultMaxRedirects,
+		queueSize:     maxConcurrency * 2,
+		clock:         clock.Real,
+		role:          RoleAll,
+		checkInterval: interval,
+		stopChan:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	jobs := c.queue
+	switch {
+	case jobs != nil:
+	case c.storeQueue != nil:
+		jobs = newDBQueue(c.storeQueue, c.clock)
+	default:
+		jobs = newFairQueue(c.queueSize, c.clock)
+	}
+	c.pool = newWorkerPool(store, jobs, httpTimeout, c.poolOpts...)
+	c.pool.sinks = c.sinks
+	c.pool.notifier = c.notifier
+	c.pool.metrics = c.metrics
+	c.pool.filter = c.filter
+	c.pool.dnsFailures = c.dnsFailures
+	c.pool.budget = c.budget
+	c.pool.body = c.body
+	c.pool.hooks = c.hooks
+	c.pool.clock = c.clock
+	if c.clientCerts != nil {
+		c.pool.presentClientCerts(c.clientCerts)
+	}
+	if c.redactor != nil {
+		c.pool.redactor = c.redactor
+	}
+	c.pool.httpClient.CheckRedirect = redirectPolicy(c.maxRedirects)
+	if c.leader != nil {
+		c.leader.name = c.shard.leaseName()
+		c.leader.clock = c.clock
+		c.leader.metrics = c.metrics
+	}
+	if c.role != RoleScheduler {
+		c.pool.startWorkers(maxConcurrency)
+	}
+	return c
+}
+
+// Start begins the periodic checking process.
+func (c *Checker) Start() {
+	if c.monitorEvery > 0 {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.monitorQueue()
+		}()
+	}
+	if c.role == RoleWorker {
+		// Workers already run; another process schedules their checks.
+		logging.Checker.Infof("starting checker as a worker with %d workers", c.pool.Workers())
+		c.health.set(models.CheckerOK)
+		c.recordEvent(models.SystemEventCheckerStarted, "checker started as a worker", map[string]string{"role": c.role})
+		return
+	}
+	logging.Checker.Infof("starting background checker with interval: %s", c.checkInterval)
+	c.recordEvent(models.SystemEventCheckerStarted, "checker started", map[string]string{"role": c.role, "interval": c.checkInterval.String()})
+	if c.leader != nil {
+		c.leader.renew()
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.leader.keepRenewing(c.stopChan)
+		}()
+	}
+	// Sampling, archiving, and domain lookups cover every target, so of several shards only
+	// the first runs them.
+	if c.sampler != nil && c.shard.index == 0 {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.sampleResults()
+		}()
+	}
+	if c.archiver != nil && c.shard.index == 0 {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.archiveResults()
+		}()
+	}
+	if c.domains != nil && c.shard.index == 0 {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.monitorDomains()
+		}()
+	}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.supervise()
+	}()
+}
+
+// runScheduler schedules checks every interval until the checker stops.
+func (c *Checker) runScheduler() {
+	if c.warmup > 0 && c.leading() {
+		if !c.catchUp() {
+			logging.Checker.Infof("stopping background checker...")
+			c.pool.Stop()
+			return
+		}
+	}
+	ticker := c.clock.NewTicker(c.checkInterval)
+	defer ticker.Stop()
+
+	// Perform an initial check on startup
+	if c.warmup == 0 {
+		c.scheduleChecks()
+	}
+
+	for {
+		select {
+		case <-ticker.C():
+			c.scheduleChecks()
+		case <-c.stopChan:
+			logging.Checker.Infof("stopping background checker...")
+			c.pool.Stop() // Stop the worker pool
+			return
+		}
+	}
+}
+
+// Stop gracefully shuts down the checker and its worker pool.
+func (c *Checker) Stop() {
+	close(c.stopChan)
+	c.wg.Wait()
+	c.health.set(models.CheckerStopped)
+	// Workers run from New, taking checks a store queue kept from a previous run, so the
+	// pool is stopped even if Start never was.
+	c.pool.Stop()
+	logging.Checker.Infof("background checker stopped")
+	c.recordEvent(models.SystemEventCheckerStopped, "checker stopped", map[string]string{"role": c.role})
+}
+
+// scheduleChecks walks all targets, in pages of batchSize or from the target cache, and
+// dispatches them to the worker pool.
+func (c *Checker) scheduleChecks() {
+	if !c.leading() {
+		// Standing by is all a follower has to do, so it never lags.
+		c.health.scheduled(c.clock.Now().UTC())
+		return
+	}
+	logging.Checker.Debugf("scheduling checks for all targets...")
+	ctx := context.Background()
+	now := c.clock.Now().UTC()
+	owned, submitted, dropped, skipped, snoozed, offSchedule := 0, 0, 0, 0, 0, 0
+	paused := c.pausedHosts(ctx)
+	dispatch := func(t models.Target) {
+		if t.Type == models.TargetTypeHeartbeat {
+			c.checkHeartbeat(t, now)
+			return
+		}
+		if c.pool.Submit(t) {
+			submitted++
+		} else {
+			dropped++
+		}
+	}
+	// Targets with dependencies are held back until the pass is over, then dispatched after
+	// their dependencies, so they are judged against results as recent as possible.
+	var dependent []models.Target
+	total, err := c.eachTarget(ctx, now, func(t models.Target) {
+		if !c.shard.owns(t.ID) {
+			return
+		}
+		owned++
+		if paused[t.Host] {
+			skipped++
+			return
+		}
+		if t.Snoozed(now) {
+			snoozed++
+			return
+		}
+		if !t.OnSchedule(now) {
+			offSchedule++
+			return
+		}
+		if t.Dependencies != nil {
+			dependent = append(dependent, t)
+			return
+		}
+		dispatch(t)
+	})
+	if err != nil {
+		logging.Checker.Errorf("error fetching targets for checking: %v", err)
+		return
+	}
+	for _, t := range orderByDependency(dependent) {
+		dispatch(t)
+	}
+	c.health.scheduled(c.clock.Now().UTC())
+
+	if owned == 0 {
+		logging.Checker.Debugf("no targets to check")
+		return
+	}
+	logging.Checker.Debugf("submitted %d targets for checking", submitted)
+	if skipped > 0 {
+		logging.Checker.Debugf("skipped %d targets on paused hosts", skipped)
+		c.metrics.Count("checks.skipped", int64(skipped), metrics.T("reason", "host_paused"))
+	}
+	if snoozed > 0 {
+		logging.Checker.Debugf("skipped %d snoozed targets", snoozed)
+		c.metrics.Count("checks.skipped", int64(snoozed), metrics.T("reason", "snoozed"))
+	}
+	if offSchedule > 0 {
+		logging.Checker.Debugf("skipped %d targets outside their schedule", offSchedule)
+		c.metrics.Count("checks.skipped", int64(offSchedule), metrics.T("reason", "off_schedule"))
+	}
+	if dropped > 0 {
+		logging.Checker.Warnf("job queue full, dropped %d targets until the next cycle; consider raising CHECK_QUEUE_SIZE (%d)", dropped, c.pool.QueueCapacity())
+		// Only the first pass of an overflow is recorded, so a queue that stays full doesn't
+		// add an event every interval.
+		if !c.overflowing {
+			c.recordEvent(models.SystemEventQueueOverflow, fmt.Sprintf("check queue full, dropped %d checks", dropped),
+				map[string]string{"dropped": strconv.Itoa(dropped), "capacity": strconv.Itoa(c.pool.QueueCapacity())})
+		}
+	}
+	c.overflowing = dropped > 0
+	c.metrics.Count("checks.submitted", int64(submitted))
+	c.metrics.Gauge("queue.depth", float64(c.pool.QueueDepth()))
+	c.metrics.Gauge("queue.capacity", float64(c.pool.QueueCapacity()))
+	c.metrics.Gauge("workers.size", float64(c.pool.Workers()))
+	c.metrics.Gauge("workers.active", float64(c.pool.ActiveWorkers()))
+	c.metrics.Gauge("targets.total", float64(total))
+	if c.shard.total > 1 {
+		c.metrics.Gauge("targets.shard", float64(owned))
+	}
+}
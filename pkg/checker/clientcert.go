@@ -0,0 +1,124 @@
+package checker
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/clock"
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// clientCertTTL is how long a host's client certificate, or that it has none, is reused
+// before it is looked up again, so saved and deleted certificates apply within it.
+const clientCertTTL = time.Minute
+
+// ClientCertSource looks up the client certificates presented to hosts for mutual TLS.
+type ClientCertSource interface {
+	// GetClientCertificate returns host's certificate with its key, or storage.ErrNotFound.
+	GetClientCertificate(ctx context.Context, host string) (*models.ClientCertificate, error)
+}
+
+// WithClientCertificates presents the certificate src has for a host to that host, and no
+// other, when it asks for one. Requests to hosts without a certificate, including redirects
+// away from one with, go through the default transport unchanged. It only applies when checks
+// go through an *http.Transport, as they do unless WithTransport replaces it.
+func WithClientCertificates(src ClientCertSource) Option {
+	return func(c *Checker) {
+		c.clientCerts = src
+	}
+}
+
+// presentClientCerts wraps the pool's transport in a certTransport presenting src's certificates.
+func (p *WorkerPool) presentClientCerts(src ClientCertSource) {
+	base, ok := p.httpClient.Transport.(*http.Transport)
+	if !ok {
+		logging.Checker.Warnf("client certificates are not presented: checks go through a custom transport")
+		return
+	}
+	p.httpClient.Transport = newCertTransport(base, src, p.clock)
+}
+
+// certTransport sends each request to a host with a client certificate through a clone of
+// base presenting it, and every other request through base.
+type certTransport struct {
+	base  *http.Transport
+	src   ClientCertSource
+	clock clock.Clock
+
+	mu    sync.Mutex
+	hosts map[string]*hostTransport
+}
+
+// hostTransport is the transport for one host, nil when it has no certificate.
+type hostTransport struct {
+	rt      *http.Transport
+	expires time.Time
+}
+
+func newCertTransport(base *http.Transport, src ClientCertSource, clk clock.Clock) *certTransport {
+	return &certTransport{base: base, src: src, clock: clk, hosts: make(map[string]*hostTransport)}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *certTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return t.base.RoundTrip(req)
+	}
+	if rt := t.transport(req.Context(), req.URL.Hostname()); rt != nil {
+		return rt.RoundTrip(req)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// transport returns the transport presenting host's certificate, or nil when it has none. A
+// certificate that fails to load is logged and the host checked without one, which its server
+// will then refuse with an error the check records.
+func (t *certTransport) transport(ctx context.Context, host string) *http.Transport {
+	now := t.clock.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if h, ok := t.hosts[host]; ok && now.Before(h.expires) {
+		return h.rt
+	}
+	if old := t.hosts[host]; old != nil && old.rt != nil {
+		old.rt.CloseIdleConnections() // Connections keep the certificate they were opened with.
+	}
+	h := &hostTransport{expires: now.Add(clientCertTTL)}
+	t.hosts[host] = h
+	c, err := t.src.GetClientCertificate(ctx, host)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			logging.Checker.Errorf("error loading client certificate for host %s: %v", host, err)
+		}
+		return nil
+	}
+	cert, err := tls.X509KeyPair([]byte(c.CertPEM), []byte(c.KeyPEM))
+	if err != nil {
+		logging.Checker.Errorf("error loading client certificate for host %s: %v", host, err)
+		return nil
+	}
+	h.rt = t.base.Clone()
+	if h.rt.TLSClientConfig == nil {
+		h.rt.TLSClientConfig = &tls.Config{}
+	}
+	h.rt.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	return h.rt
+}
+
+// CloseIdleConnections closes the idle connections of every transport.
+func (t *certTransport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, h := range t.hosts {
+		if h.rt != nil {
+			h.rt.CloseIdleConnections()
+		}
+	}
+}
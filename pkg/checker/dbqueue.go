@@ -0,0 +1,143 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/clock"
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// Queue backends, as selected by QUEUE_BACKEND.
+const (
+	QueueMemory = "memory"
+	QueueDB     = "db"
+)
+
+// dbQueuePollInterval is how often an idle worker looks for checks queued by another
+// process. Checks submitted by this process wake a worker straight away.
+const dbQueuePollInterval = time.Second
+
+// WithStoreQueue keeps scheduled checks in q instead of in memory, so checks that were
+// scheduled but not yet done when the process stopped are picked up after a restart. The
+// queue isn't bounded by WithQueueSize: each target is queued at most once.
+func WithStoreQueue(q storage.CheckQueue) Option {
+	return func(c *Checker) { c.storeQueue = q }
+}
+
+// dbQueue is a Queue backed by a storage.CheckQueue.
+type dbQueue struct {
+	store  storage.CheckQueue
+	clock  clock.Clock
+	wake   chan struct{} // Signalled on push, so a waiting worker claims without polling
+	closed chan struct{}
+	once   sync.Once
+}
+
+// newDBQueue returns a dbQueue, releasing checks left claimed by a previous process.
+func newDBQueue(store storage.CheckQueue, clk clock.Clock) *dbQueue {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if n, err := store.ReleaseClaimedChecks(ctx); err != nil {
+		logging.Checker.Errorf("error releasing claimed checks: %v", err)
+	} else if n > 0 {
+		logging.Checker.Infof("requeued %d checks left unfinished by a previous run", n)
+	}
+	return &dbQueue{store: store, clock: clk, wake: make(chan struct{}, 1), closed: make(chan struct{})}
+}
+
+func (q *dbQueue) Push(target models.Target) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	added, err := q.store.EnqueueCheck(ctx, target, q.clock.Now())
+	if err != nil {
+		return err
+	}
+	if !added {
+		return ErrAlreadyQueued
+	}
+	q.signal()
+	return nil
+}
+
+// signal wakes one waiting worker, if none has a wakeup pending already.
+func (q *dbQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Take claims the next check. Closing the queue stops workers without draining it: what is
+// left stays queued for the next run.
+func (q *dbQueue) Take(quit <-chan struct{}) (models.Target, bool) {
+	for {
+		select {
+		case <-quit:
+			return models.Target{}, false
+		case <-q.closed:
+			return models.Target{}, false
+		default:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		t, err := q.store.ClaimCheck(ctx, q.clock.Now())
+		cancel()
+		if err == nil {
+			// There may be more: pass the wakeup on to another waiting worker.
+			q.signal()
+			return *t, true
+		}
+		if !errors.Is(err, storage.ErrNotFound) {
+			logging.Checker.Errorf("error claiming check: %v", err)
+		}
+		select {
+		case <-quit:
+			return models.Target{}, false
+		case <-q.closed:
+			return models.Target{}, false
+		case <-q.wake:
+		case <-q.clock.After(dbQueuePollInterval):
+		}
+	}
+}
+
+func (q *dbQueue) Done(target models.Target) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := q.store.CompleteCheck(ctx, target.ID); err != nil {
+		logging.Checker.Errorf("error completing check for %s: %v", target.ID, err)
+	}
+}
+
+func (q *dbQueue) Depth() int {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	n, err := q.store.CountQueuedChecks(ctx)
+	if err != nil {
+		logging.Checker.Errorf("error counting queued checks: %v", err)
+	}
+	return n
+}
+
+func (q *dbQueue) OldestQueued() time.Time {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	oldest, err := q.store.OldestQueuedCheck(ctx)
+	if err != nil {
+		logging.Checker.Errorf("error finding the oldest queued check: %v", err)
+	}
+	return oldest
+}
+
+// Capacity is zero: the queue is bounded only by the number of targets.
+func (q *dbQueue) Capacity() int {
+	return 0
+}
+
+func (q *dbQueue) Close() {
+	q.once.Do(func() { close(q.closed) })
+}
@@ -0,0 +1,89 @@
+package checker
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// Result storage modes.
+const (
+	// StoreAll stores every check result.
+	StoreAll = "all"
+	// StoreOnChange stores a result only when it differs from the last stored one, plus a
+	// keepalive result at least every keepalive interval.
+	StoreOnChange = "on_change"
+)
+
+// latencyBucketBoundsMS are the upper bounds of the latency buckets compared in on-change
+// mode; latency moving between buckets counts as a change.
+var latencyBucketBoundsMS = []int64{100, 250, 500, 1000, 2500, 5000}
+
+func latencyBucket(ms int64) int {
+	for i, bound := range latencyBucketBoundsMS {
+		if ms < bound {
+			return i
+		}
+	}
+	return len(latencyBucketBoundsMS)
+}
+
+// storedResult is the part of the last stored result that on-change mode compares against.
+type storedResult struct {
+	statusCode    int // 0 when no response was received
+	errMsg        string
+	latencyBucket int
+	missing       string // Required security headers missing, comma-separated
+	tlsVersion    string
+	at            time.Time
+}
+
+// changeFilter decides which results are stored in on-change mode.
+type changeFilter struct {
+	keepalive time.Duration
+	mu        sync.Mutex
+	last      map[string]storedResult
+}
+
+func newChangeFilter(keepalive time.Duration) *changeFilter {
+	return &changeFilter{keepalive: keepalive, last: make(map[string]storedResult)}
+}
+
+// shouldStore reports whether the result must be stored: it is the first result seen for the
+// target, its status, error, latency bucket, missing security headers, or TLS version
+// changed, or the keepalive interval elapsed.
+// A result that should be stored becomes the new comparison point.
+func (f *changeFilter) shouldStore(r models.CheckResult) bool {
+	cur := storedResult{latencyBucket: latencyBucket(r.LatencyMS), at: r.CheckedAt}
+	if r.StatusCode != nil {
+		cur.statusCode = *r.StatusCode
+	}
+	if r.Error != nil {
+		cur.errMsg = *r.Error
+	}
+	if r.Security != nil {
+		cur.missing = strings.Join(r.Security.Missing, ",")
+	}
+	if r.TLS != nil {
+		cur.tlsVersion = r.TLS.Version
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	prev, ok := f.last[r.TargetID]
+	if ok && prev.statusCode == cur.statusCode && prev.errMsg == cur.errMsg &&
+		prev.latencyBucket == cur.latencyBucket && prev.missing == cur.missing && prev.tlsVersion == cur.tlsVersion && r.CheckedAt.Sub(prev.at) < f.keepalive {
+		return false
+	}
+	f.last[r.TargetID] = cur
+	return true
+}
+
+// forget drops the comparison point for a target, e.g. after its result failed to save.
+func (f *changeFilter) forget(targetID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.last, targetID)
+}
@@ -0,0 +1,106 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/metrics"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// downDependency returns the first of a target's dependencies whose latest result failed,
+// or an empty string when all of them are up. Dependencies without results yet count as up,
+// and so do all of them when their results can't be read, so an alert is never lost to a
+// failed lookup.
+func downDependency(ctx context.Context, store storage.ResultReader, t models.Target) string {
+	if t.Dependencies == nil || len(t.Dependencies.TargetIDs) == 0 {
+		return ""
+	}
+	latest, err := store.GetLatestResults(ctx, t.Dependencies.TargetIDs)
+	if err != nil {
+		logging.Checker.Errorf("error fetching dependency results for target %s: %v", t.ID, err)
+		return ""
+	}
+	for _, id := range t.Dependencies.TargetIDs {
+		if r, ok := latest[id]; ok && !r.Succeeded() {
+			return id
+		}
+	}
+	return ""
+}
+
+// orderByDependency returns targets ordered so that each comes after those of its
+// dependencies that are among them, keeping the given order otherwise. Targets caught in a
+// cycle, which the API refuses but concurrent updates could still create, come last.
+func orderByDependency(targets []models.Target) []models.Target {
+	index := make(map[string]int, len(targets))
+	for i, t := range targets {
+		index[t.ID] = i
+	}
+	pending := make([]int, len(targets)) // Unordered dependencies of each target
+	dependents := make(map[int][]int)
+	for i, t := range targets {
+		for _, id := range t.Dependencies.TargetIDs {
+			if j, ok := index[id]; ok {
+				pending[i]++
+				dependents[j] = append(dependents[j], i)
+			}
+		}
+	}
+
+	ordered := make([]models.Target, 0, len(targets))
+	done := make([]bool, len(targets))
+	var ready []int
+	for i := range targets {
+		if pending[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, targets[i])
+		done[i] = true
+		for _, j := range dependents[i] {
+			if pending[j]--; pending[j] == 0 {
+				ready = append(ready, j)
+			}
+		}
+	}
+	for i, t := range targets {
+		if !done[i] {
+			ordered = append(ordered, t)
+		}
+	}
+	return ordered
+}
+
+// recordSuppressed stores the result of a target left unchecked because dep is down.
+func (p *WorkerPool) recordSuppressed(ctx context.Context, target models.Target, dep string) {
+	msg := fmt.Sprintf("dependency %s is down", dep)
+	result := models.CheckResult{
+		TargetID:  target.ID,
+		CheckedAt: p.clock.Now(),
+		Error:     &msg,
+
+		ErrorCategory: models.ErrorCategoryDependency,
+		Outcome:       models.OutcomeSuppressed,
+	}
+	p.metrics.Count("checks.suppressed", 1, metrics.T("host", target.Host), metrics.T("mode", "skip"))
+	if p.filter != nil && !p.filter.shouldStore(result) {
+		p.metrics.Count("checks.unchanged", 1)
+		return
+	}
+	if err := p.store.CreateCheckResult(ctx, &result); err != nil {
+		logging.Checker.Errorf("error saving check result for target %s: %v", target.ID, err)
+		if p.filter != nil {
+			p.filter.forget(target.ID)
+		}
+		return
+	}
+	if p.sinks != nil {
+		p.sinks.Publish(result)
+	}
+}
@@ -0,0 +1,52 @@
+package checker
+
+import (
+	"sync"
+	"time"
+)
+
+// dnsFailureCache remembers hosts whose names recently failed to resolve, so that during an
+// outage the other targets on those hosts fail fast instead of querying the resolver again.
+type dnsFailureCache struct {
+	ttl   time.Duration
+	mu    sync.Mutex
+	hosts map[string]dnsFailure
+}
+
+type dnsFailure struct {
+	errMsg  string
+	expires time.Time
+}
+
+func newDNSFailureCache(ttl time.Duration) *dnsFailureCache {
+	return &dnsFailureCache{ttl: ttl, hosts: make(map[string]dnsFailure)}
+}
+
+// lookup returns the error of a cached resolution failure for host, if one hasn't expired.
+func (c *dnsFailureCache) lookup(host string, now time.Time) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, ok := c.hosts[host]
+	if !ok {
+		return "", false
+	}
+	if !now.Before(f.expires) {
+		delete(c.hosts, host)
+		return "", false
+	}
+	return f.errMsg, true
+}
+
+// record caches a resolution failure for host until the TTL has passed.
+func (c *dnsFailureCache) record(host, errMsg string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hosts[host] = dnsFailure{errMsg: errMsg, expires: now.Add(c.ttl)}
+}
+
+// forget drops a cached failure once the host has been reached.
+func (c *dnsFailureCache) forget(host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.hosts, host)
+}
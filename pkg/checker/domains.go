@@ -0,0 +1,55 @@
+package checker
+
+import (
+	"context"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+)
+
+// maxDomainTick is the longest the checker waits between domain refreshes, so the domains of
+// new targets are looked up within it even when registrations are reused for much longer.
+const maxDomainTick = time.Hour
+
+// DomainMonitor looks up the registrations of the domains targets are on.
+type DomainMonitor interface {
+	// RefreshDomains looks up the domains due at now, returning how many were looked up.
+	RefreshDomains(ctx context.Context, now time.Time) (int, error)
+}
+
+// WithDomainMonitoring runs monitor at least hourly, or every interval when that is shorter;
+// the monitor decides which domains are due. Like archiving, it only runs on the instance that
+// leads.
+func WithDomainMonitoring(monitor DomainMonitor, interval time.Duration) Option {
+	return func(c *Checker) {
+		if interval > 0 {
+			c.domains = monitor
+			c.domainEvery = min(interval, maxDomainTick)
+		}
+	}
+}
+
+// monitorDomains runs the domain monitor every domainEvery until the checker stops.
+func (c *Checker) monitorDomains() {
+	ticker := c.clock.NewTicker(c.domainEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			if !c.leading() {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), c.domainEvery)
+			n, err := c.domains.RefreshDomains(ctx, c.clock.Now().UTC())
+			cancel()
+			if err != nil {
+				logging.Checker.Errorf("error refreshing domains: %v", err)
+			}
+			if n > 0 {
+				c.metrics.Count("domains.looked_up", int64(n))
+			}
+		case <-c.stopChan:
+			return
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package checker
+
+import (
+	"context"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// recordEvent stores a system event. Failing to store one is only logged; the checker keeps
+// going either way.
+func (c *Checker) recordEvent(eventType, message string, details map[string]string) {
+	event := &models.SystemEvent{Type: eventType, At: c.clock.Now().UTC(), Message: message, Details: details}
+	if err := c.store.RecordSystemEvent(context.Background(), event); err != nil {
+		logging.Checker.Errorf("error recording %s event: %v", eventType, err)
+	}
+}
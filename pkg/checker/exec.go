@@ -0,0 +1,182 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/metrics"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+const (
+	// defaultExecTimeout is how long an exec check may run when WithExecChecks leaves it unset.
+	defaultExecTimeout = 10 * time.Second
+	// maxExecStdout and maxExecStderr cap how much of a command's output is kept.
+	maxExecStdout = 64 << 10
+	maxExecStderr = 4 << 10
+	// execPath is the PATH commands run with, whatever the checker's own is.
+	execPath = "/usr/local/bin:/usr/bin:/bin"
+)
+
+// execRunner runs the commands of exec targets.
+type execRunner struct {
+	dir     string
+	timeout time.Duration
+}
+
+// WithExecChecks enables exec targets, whose commands are the executables in dir. Each
+// command runs with a timeout (10s by default; a target's TimeoutBudgetMS overrides it), in an
+// empty temporary directory, with nothing on stdin and an environment of only PATH, HOME,
+// TMPDIR, and LINKWATCH_TARGET_ID, so it sees none of the checker's configuration or secrets.
+//
+// Exit code 0 passes the check and any other fails it. A command may also print a JSON object
+// on stdout: "outcome" (success, warning, or failure) refines the exit code, "status_code" and
+// "latency_ms" are recorded in place of the measured run time, and "error" describes a failure.
+// Without one, a failure is described by the first line of stderr. Without WithExecChecks,
+// exec targets fail with the exec error category.
+func WithExecChecks(dir string, timeout time.Duration) Option {
+	return func(c *Checker) {
+		if timeout <= 0 {
+			timeout = defaultExecTimeout
+		}
+		c.poolOpts = append(c.poolOpts, func(p *WorkerPool) { p.exec = &execRunner{dir: dir, timeout: timeout} })
+	}
+}
+
+// execOutput is the JSON object an exec check, or a wasm check, may print on stdout.
+type execOutput struct {
+	Outcome    string `json:"outcome"`
+	StatusCode *int   `json:"status_code"`
+	LatencyMS  *int64 `json:"latency_ms"`
+	Error      string `json:"error"`
+}
+
+// readOutput returns the JSON object on stdout, or a zero one when there is none.
+func readOutput(stdout []byte) execOutput {
+	var out execOutput
+	if trimmed := bytes.TrimSpace(stdout); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &out); err != nil {
+			return execOutput{}
+		}
+	}
+	return out
+}
+
+// limitedBuffer keeps the first max bytes written to it and discards the rest, so a chatty
+// command never blocks on a full pipe.
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+// performExecCheck runs the command of an exec target and records its result.
+func (p *WorkerPool) performExecCheck(ctx context.Context, target models.Target) {
+	startTime := p.clock.Now()
+	result := models.CheckResult{TargetID: target.ID, CheckedAt: startTime}
+	fail := func(category, msg string) {
+		msg = p.redactor.Text(msg)
+		result.Error, result.ErrorCategory = &msg, category
+	}
+
+	var latency time.Duration
+	switch {
+	case p.exec == nil:
+		fail(models.ErrorCategoryExec, "exec checks are disabled")
+	case target.Exec == nil:
+		fail(models.ErrorCategoryExec, "target has no command")
+	default:
+		latency = p.runExec(ctx, target, &result, fail)
+	}
+	result.LatencyMS, result.LatencyUS = latency.Milliseconds(), latency.Microseconds()
+	if result.Error != nil {
+		p.metrics.Count("checks.exec_failed", 1, metrics.T("command", commandName(target)), metrics.T("category", result.ErrorCategory))
+	}
+	p.finishCheck(ctx, target, result, latency)
+}
+
+// commandName returns the command of an exec target, for metric tags.
+func commandName(target models.Target) string {
+	if target.Exec == nil {
+		return ""
+	}
+	return target.Exec.Command
+}
+
+// runExec runs target's command, filling in result, and returns how long the check took.
+func (p *WorkerPool) runExec(ctx context.Context, target models.Target, result *models.CheckResult, fail func(category, msg string)) time.Duration {
+	name := target.Exec.Command
+	if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+		fail(models.ErrorCategoryExec, fmt.Sprintf("invalid command %q", name))
+		return 0
+	}
+	timeout := p.exec.timeout
+	if target.TimeoutBudgetMS > 0 {
+		timeout = time.Duration(target.TimeoutBudgetMS) * time.Millisecond
+	}
+	workDir, err := os.MkdirTemp("", "linkwatch-exec-")
+	if err != nil {
+		fail(models.ErrorCategoryExec, fmt.Sprintf("failed to create working directory: %v", err))
+		return 0
+	}
+	defer os.RemoveAll(workDir)
+
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(runCtx, filepath.Join(p.exec.dir, name), target.Exec.Args...)
+	cmd.Dir = workDir
+	cmd.Env = []string{"PATH=" + execPath, "HOME=" + workDir, "TMPDIR=" + workDir, "LINKWATCH_TARGET_ID=" + target.ID}
+	// Children that outlive the command could keep its pipes open; stop waiting for them.
+	cmd.WaitDelay = time.Second
+	stdout, stderr := &limitedBuffer{max: maxExecStdout}, &limitedBuffer{max: maxExecStderr}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	err = cmd.Run()
+	latency := p.clock.Since(result.CheckedAt)
+
+	out := readOutput(stdout.Bytes())
+	if out.LatencyMS != nil && *out.LatencyMS >= 0 {
+		latency = time.Duration(*out.LatencyMS) * time.Millisecond
+	}
+	result.StatusCode = out.StatusCode
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		fail(models.ErrorCategoryTimeout, fmt.Sprintf("command timed out after %s", timeout))
+	case errors.As(err, &exitErr):
+		msg := out.Error
+		if msg == "" {
+			msg, _, _ = strings.Cut(strings.TrimSpace(stderr.String()), "\n")
+		}
+		if msg == "" {
+			msg = exitErr.Error()
+		}
+		fail(models.ErrorCategoryExec, msg)
+	case err != nil:
+		fail(models.ErrorCategoryExec, fmt.Sprintf("failed to run command: %v", err))
+	case out.Outcome == models.OutcomeFailure:
+		msg := out.Error
+		if msg == "" {
+			msg = "command reported a failure"
+		}
+		fail(models.ErrorCategoryExec, msg)
+	case out.Outcome == models.OutcomeWarning:
+		result.Outcome = models.OutcomeWarning
+	}
+	return latency
+}
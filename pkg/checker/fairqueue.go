@@ -0,0 +1,169 @@
+package checker
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/clock"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+var (
+	// ErrQueueFull is returned by Queue.Push when the queue has no room.
+	ErrQueueFull = errors.New("queue full")
+	// ErrAlreadyQueued is returned by Queue.Push when the target is already waiting.
+	ErrAlreadyQueued = errors.New("already queued")
+)
+
+// Queue holds scheduled checks until a worker takes them. The checker keeps them in memory
+// by default; WithQueue plugs in another implementation, e.g. one shared by several
+// processes so the checks one scheduler produces are spread over many workers.
+type Queue interface {
+	// Push queues target, returning ErrQueueFull or ErrAlreadyQueued if it wasn't added.
+	Push(target models.Target) error
+	// Take waits for the next target. It reports false once quit is closed, or once the
+	// queue is closed and nothing is left for the worker to take.
+	Take(quit <-chan struct{}) (models.Target, bool)
+	// Done is called when the check of a taken target has finished.
+	Done(target models.Target)
+	// Depth returns how many targets are waiting.
+	Depth() int
+	// Capacity returns how many targets may wait, or 0 when the queue isn't bounded.
+	Capacity() int
+	// Close is called when the checker stops, after which workers stop taking targets.
+	Close()
+}
+
+// fairQueue is the default, in-memory Queue. Targets wait in a FIFO per host, and workers take from
+// the hosts in turn, so a host with thousands of targets gets the same share of workers as
+// a host with one instead of starving it.
+type fairQueue struct {
+	mu     sync.Mutex
+	clock  clock.Clock
+	queues map[string][]fairEntry
+	queued map[string]bool // IDs of the queued targets, so a target waits at most once
+	ring   []string        // Hosts with queued targets, in turn order
+	next   int             // Index in ring of the host whose turn is next
+	size   int
+	max    int
+	closed bool
+
+	// ready holds one token per queued target, so workers can wait for work in a select.
+	// It is closed by close; workers drain the remaining targets before seeing it closed.
+	ready chan struct{}
+}
+
+// fairEntry is a queued target and when it was queued.
+type fairEntry struct {
+	target   models.Target
+	queuedAt time.Time
+}
+
+func newFairQueue(capacity int, clk clock.Clock) *fairQueue {
+	return &fairQueue{
+		clock:  clk,
+		queues: make(map[string][]fairEntry),
+		queued: make(map[string]bool),
+		max:    capacity,
+		ready:  make(chan struct{}, capacity),
+	}
+}
+
+// Push queues target behind the others on its host, unless it is already waiting.
+func (q *fairQueue) Push(target models.Target) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrQueueFull
+	}
+	if q.queued[target.ID] {
+		return ErrAlreadyQueued
+	}
+	if q.size == q.max {
+		return ErrQueueFull
+	}
+	pending, ok := q.queues[target.Host]
+	if !ok || len(pending) == 0 {
+		// A new host waits for a full round, taking the turn just before the current one.
+		q.ring = append(q.ring, "")
+		copy(q.ring[q.next+1:], q.ring[q.next:])
+		q.ring[q.next] = target.Host
+		q.next = (q.next + 1) % len(q.ring)
+	}
+	q.queues[target.Host] = append(pending, fairEntry{target: target, queuedAt: q.clock.Now()})
+	q.queued[target.ID] = true
+	q.size++
+	q.ready <- struct{}{}
+	return nil
+}
+
+func (q *fairQueue) Take(quit <-chan struct{}) (models.Target, bool) {
+	select {
+	case <-quit:
+		return models.Target{}, false
+	case _, ok := <-q.ready:
+		if !ok {
+			return models.Target{}, false
+		}
+		return q.pop(), true
+	}
+}
+
+// pop takes the oldest target of the host whose turn it is. Callers receive from ready
+// first, which guarantees there is a target to take.
+func (q *fairQueue) pop() models.Target {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	host := q.ring[q.next]
+	pending := q.queues[host]
+	target := pending[0].target
+	delete(q.queued, target.ID)
+	q.size--
+	if len(pending) == 1 {
+		delete(q.queues, host)
+		q.ring = append(q.ring[:q.next], q.ring[q.next+1:]...)
+		if q.next == len(q.ring) {
+			q.next = 0
+		}
+	} else {
+		q.queues[host] = pending[1:]
+		q.next = (q.next + 1) % len(q.ring)
+	}
+	return target
+}
+
+func (q *fairQueue) Done(models.Target) {}
+
+// Depth returns how many targets are queued.
+func (q *fairQueue) Depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+// OldestQueued returns when the longest-waiting target was queued: the earliest of the
+// hosts' first targets.
+func (q *fairQueue) OldestQueued() time.Time {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var oldest time.Time
+	for _, pending := range q.queues {
+		if at := pending[0].queuedAt; oldest.IsZero() || at.Before(oldest) {
+			oldest = at
+		}
+	}
+	return oldest
+}
+
+func (q *fairQueue) Capacity() int {
+	return q.max
+}
+
+// Close stops further pushes and lets workers exit once the queue is drained.
+func (q *fairQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	close(q.ready)
+}
@@ -0,0 +1,78 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/metrics"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/notify"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// HeartbeatMissedError is the error recorded when a heartbeat target misses its deadline.
+const HeartbeatMissedError = "heartbeat missed"
+
+// HeartbeatDeadline returns the time by which the next ping for a heartbeat target is due.
+func HeartbeatDeadline(t models.Target) time.Time {
+	last := t.CreatedAt
+	if t.LastPingAt != nil {
+		last = *t.LastPingAt
+	}
+	return last.Add(time.Duration(t.GracePeriodSeconds) * time.Second)
+}
+
+// checkHeartbeat marks a heartbeat target down, once per missed deadline, when no ping
+// arrived within its grace period. A miss while one of its dependencies is down is
+// suppressed and raises no alert.
+func (c *Checker) checkHeartbeat(t models.Target, now time.Time) {
+	deadline := HeartbeatDeadline(t)
+	if !now.After(deadline) {
+		return
+	}
+
+	ctx := context.Background()
+	latest, err := c.store.ListCheckResultsByTargetID(ctx, storage.ListCheckResultsParams{TargetID: t.ID, Limit: 1})
+	if err != nil {
+		logging.Checker.Errorf("error fetching latest result for heartbeat target %s: %v", t.ID, err)
+		return
+	}
+	if len(latest) > 0 && latest[0].Error != nil && !latest[0].CheckedAt.Before(deadline) {
+		return // Already marked down for this deadline.
+	}
+
+	errMsg := HeartbeatMissedError
+	result := models.CheckResult{
+		TargetID:  t.ID,
+		CheckedAt: now,
+		Error:     &errMsg,
+
+		ErrorCategory: models.ErrorCategoryHeartbeatMissed,
+	}
+	if downDependency(ctx, c.store, t) != "" {
+		result.Outcome = models.OutcomeSuppressed
+	}
+	if err := c.store.CreateCheckResult(ctx, &result); err != nil {
+		logging.Checker.Errorf("error saving missed heartbeat for target %s: %v", t.ID, err)
+		return
+	}
+	c.sinks.Publish(result)
+	c.metrics.Count("heartbeats.missed", 1)
+	if result.Outcome == models.OutcomeSuppressed {
+		c.metrics.Count("checks.suppressed", 1, metrics.T("mode", "suppress"))
+		return
+	}
+
+	event := notify.Event{
+		Type:     notify.EventTargetDown,
+		TargetID: t.ID,
+		URL:      t.URL,
+		Message:  fmt.Sprintf("no heartbeat received since %s", deadline.Add(-time.Duration(t.GracePeriodSeconds)*time.Second).Format(time.RFC3339)),
+		At:       now,
+	}
+	if err := c.notifier.Notify(ctx, event); err != nil {
+		logging.Checker.Errorf("error sending alert for target %s: %v", t.ID, err)
+	}
+}
@@ -0,0 +1,40 @@
+package checker
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// Hook lets code embedding the checker observe and adjust checks without changing the worker
+// pool. Hooks run in the order they were added, on the worker goroutine performing the check.
+type Hook interface {
+	// BeforeCheck runs before every HTTP attempt, including retries, and may modify the request
+	// (e.g. to add headers). Returning an error skips the check: nothing is stored or published.
+	BeforeCheck(ctx context.Context, target models.Target, req *http.Request) error
+	// AfterCheck runs once the final attempt has completed and may enrich the result before it
+	// is recorded in metrics, stored, and published.
+	AfterCheck(ctx context.Context, target models.Target, result *models.CheckResult)
+}
+
+// HookFuncs adapts a pair of functions to a Hook. Either may be nil.
+type HookFuncs struct {
+	Before func(ctx context.Context, target models.Target, req *http.Request) error
+	After  func(ctx context.Context, target models.Target, result *models.CheckResult)
+}
+
+// BeforeCheck calls f.Before if it is set.
+func (f HookFuncs) BeforeCheck(ctx context.Context, target models.Target, req *http.Request) error {
+	if f.Before == nil {
+		return nil
+	}
+	return f.Before(ctx, target, req)
+}
+
+// AfterCheck calls f.After if it is set.
+func (f HookFuncs) AfterCheck(ctx context.Context, target models.Target, result *models.CheckResult) {
+	if f.After != nil {
+		f.After(ctx, target, result)
+	}
+}
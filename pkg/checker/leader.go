@@ -0,0 +1,110 @@
+package checker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/clock"
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/metrics"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// schedulerLease names the lease held by the instance that schedules checks.
+const schedulerLease = "scheduler"
+
+// WithLeaderElection makes the checker schedule checks only while it holds the scheduler lease
+// in leases, so that of several instances sharing a store exactly one schedules. The lease is
+// renewed every third of ttl; if its holder dies, another instance takes it over once ttl has
+// passed, and Stop releases it for an immediate handover. holder must be unique per instance.
+// Workers run whether or not the instance leads.
+func WithLeaderElection(leases storage.Leases, holder string, ttl time.Duration) Option {
+	return func(c *Checker) {
+		if ttl > 0 {
+			c.leader = &leaderElection{leases: leases, holder: holder, ttl: ttl}
+		}
+	}
+}
+
+// leaderElection tracks whether this instance holds the scheduler lease.
+type leaderElection struct {
+	leases  storage.Leases
+	name    string // schedulerLease, or its shard's lease
+	holder  string
+	ttl     time.Duration
+	clock   clock.Clock
+	metrics metrics.Recorder
+
+	mu    sync.Mutex
+	until time.Time // When the lease this instance holds runs out; zero when it holds none
+}
+
+// leading reports whether the lease is held. A lease that couldn't be renewed is given up when
+// it would expire, since by then another instance may have taken it.
+func (l *leaderElection) leading() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.clock.Now().Before(l.until)
+}
+
+// renew takes or renews the lease, logging when leadership changes hands.
+func (l *leaderElection) renew() {
+	ctx, cancel := context.WithTimeout(context.Background(), l.ttl/3)
+	defer cancel()
+	was := l.leading()
+	now := l.clock.Now()
+	held, err := l.leases.AcquireLease(ctx, l.name, l.holder, now, l.ttl)
+	if err != nil {
+		// Keep what is held; it runs out on its own if the store stays unreachable.
+		logging.Checker.Errorf("error renewing the scheduler lease: %v", err)
+	} else {
+		l.mu.Lock()
+		if held {
+			l.until = now.Add(l.ttl)
+		} else {
+			l.until = time.Time{}
+		}
+		l.mu.Unlock()
+	}
+	is := l.leading()
+	switch {
+	case is && !was:
+		logging.Checker.Infof("acquired the scheduler lease as %s, scheduling checks", l.holder)
+	case was && !is:
+		logging.Checker.Warnf("lost the scheduler lease, another instance schedules checks")
+	}
+	leader := 0.0
+	if is {
+		leader = 1
+	}
+	l.metrics.Gauge("scheduler.leader", leader)
+}
+
+// keepRenewing renews the lease until stop is closed, then releases it.
+func (l *leaderElection) keepRenewing(stop <-chan struct{}) {
+	ticker := l.clock.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			l.renew()
+		case <-stop:
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := l.leases.ReleaseLease(ctx, l.name, l.holder); err != nil {
+				logging.Checker.Errorf("error releasing the scheduler lease: %v", err)
+			}
+			l.mu.Lock()
+			l.until = time.Time{}
+			l.mu.Unlock()
+			return
+		}
+	}
+}
+
+// leading reports whether this instance should schedule checks: always, without leader
+// election.
+func (c *Checker) leading() bool {
+	return c.leader == nil || c.leader.leading()
+}
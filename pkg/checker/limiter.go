@@ -0,0 +1,80 @@
+package checker
+
+import (
+	"sync"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// HostLimiter ensures that only one check per host is running at any given time. It also
+// counts targets queued per host, so the load on each host can be inspected.
+type HostLimiter struct {
+	mu     sync.Mutex
+	hosts  map[string]struct{}
+	queued map[string]int
+}
+
+// NewHostLimiter creates a new HostLimiter.
+func NewHostLimiter() *HostLimiter {
+	return &HostLimiter{
+		hosts:  make(map[string]struct{}),
+		queued: make(map[string]int),
+	}
+}
+
+// Acquire attempts to acquire a lock for a given host.
+// It returns true if the lock was acquired, and false otherwise.
+func (hl *HostLimiter) Acquire(host string) bool {
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+
+	if _, exists := hl.hosts[host]; exists {
+		return false // Another check for this host is already in progress.
+	}
+
+	hl.hosts[host] = struct{}{}
+	return true
+}
+
+// Release releases the lock for a given host.
+func (hl *HostLimiter) Release(host string) {
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+	delete(hl.hosts, host)
+}
+
+// Enqueued records that a target on host is waiting for a worker.
+func (hl *HostLimiter) Enqueued(host string) {
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+	hl.queued[host]++
+}
+
+// Dequeued records that a worker picked up a target on host.
+func (hl *HostLimiter) Dequeued(host string) {
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+	if hl.queued[host] <= 1 {
+		delete(hl.queued, host)
+		return
+	}
+	hl.queued[host]--
+}
+
+// Load returns the in-flight and queued counts of every host with either.
+func (hl *HostLimiter) Load() map[string]models.HostLoad {
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+	load := make(map[string]models.HostLoad, len(hl.hosts)+len(hl.queued))
+	for host := range hl.hosts {
+		l := load[host]
+		l.InFlight = 1
+		load[host] = l
+	}
+	for host, n := range hl.queued {
+		l := load[host]
+		l.Queued = n
+		load[host] = l
+	}
+	return load
+}
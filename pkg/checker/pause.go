@@ -0,0 +1,22 @@
+package checker
+
+import (
+	"context"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+)
+
+// pausedHosts loads the hosts whose checks are suspended, once per scheduling pass. If they
+// can't be loaded, the pass checks every host rather than none.
+func (c *Checker) pausedHosts(ctx context.Context) map[string]bool {
+	pauses, err := c.store.ListPausedHosts(ctx)
+	if err != nil {
+		logging.Checker.Errorf("error loading paused hosts, checking all hosts: %v", err)
+		return nil
+	}
+	paused := make(map[string]bool, len(pauses))
+	for _, p := range pauses {
+		paused[p.Host] = true
+	}
+	return paused
+}
@@ -0,0 +1,609 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/clock"
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/metrics"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/notify"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+	"github.com/zeng-yichen/linkwatch/pkg/urlutil"
+)
+
+// WorkerPool manages a pool of goroutines to perform HTTP checks concurrently.
+type WorkerPool struct {
+	store       storage.ResultStore
+	jobs        Queue
+	httpClient  *http.Client
+	hostLimiter *HostLimiter
+	sinks       notify.ResultSink // Optional; receives each stored result
+	notifier    notify.Notifier   // Optional; alerted when a target turns slow, loses a security header, or negotiates weak TLS
+	metrics     metrics.Recorder
+	filter      *changeFilter    // Set in on-change storage mode; nil stores every result
+	dnsFailures *dnsFailureCache // Optional; nil resolves every check
+	budget      time.Duration    // Total time a check may spend across attempts; zero is unbounded
+	body        bodyPolicy
+	minTLS      string      // Lowest TLS version negotiated without an alert; see WithMinTLSVersion
+	exec        *execRunner // Set by WithExecChecks; nil fails exec targets
+	wasm        *wasmRunner // Set by WithWasmChecks; nil fails wasm targets
+	hooks       []Hook
+	clock       clock.Clock
+	redactor    *urlutil.Redactor
+	dropped     atomic.Int64 // Targets skipped because the queue was full
+	running     atomic.Int64 // Worker goroutines that haven't exited, including draining ones
+	wg          sync.WaitGroup
+	stopOnce    sync.Once
+
+	mu      sync.Mutex
+	quits   []chan struct{} // One per worker; closing it retires the worker after its current check
+	stopped bool
+}
+
+// ErrPoolStopped is returned when resizing a pool that has been stopped.
+var ErrPoolStopped = errors.New("worker pool stopped")
+
+// PoolOption configures a WorkerPool created with NewWorkerPool.
+type PoolOption func(*WorkerPool)
+
+// PoolHTTPClient makes the pool send checks with client. See WithHTTPClient.
+func PoolHTTPClient(client *http.Client) PoolOption {
+	return func(p *WorkerPool) { p.httpClient = copyClient(client, p.httpClient) }
+}
+
+// PoolTransport makes the pool send checks through rt. See WithTransport.
+func PoolTransport(rt http.RoundTripper) PoolOption {
+	return func(p *WorkerPool) { p.httpClient.Transport = rt }
+}
+
+// NewWorkerPool creates a new worker pool whose queue holds twice as many targets as there are workers.
+func NewWorkerPool(store storage.ResultStore, maxConcurrency int, httpTimeout time.Duration, opts ...PoolOption) *WorkerPool {
+	pool := newWorkerPool(store, newFairQueue(maxConcurrency*2, clock.Real), httpTimeout, opts...)
+	pool.startWorkers(maxConcurrency)
+	return pool
+}
+
+// newWorkerPool creates a pool without workers; the caller finishes configuring it and then
+// starts them, so no worker sees a half-configured pool.
+func newWorkerPool(store storage.ResultStore, jobs Queue, httpTimeout time.Duration, opts ...PoolOption) *WorkerPool {
+	pool := &WorkerPool{
+		store:       store,
+		jobs:        jobs,
+		hostLimiter: NewHostLimiter(),
+		metrics:     metrics.Nop{},
+		body:        bodyPolicy{maxBytes: defaultMaxBodyBytes},
+		clock:       clock.Real,
+		redactor:    urlutil.NewRedactor(nil),
+		httpClient: &http.Client{
+			Timeout: httpTimeout,
+			Transport: &http.Transport{
+				// TLS 1.0 and 1.1 are allowed so targets still on them are reported, not failed.
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true, MinVersion: tls.VersionTLS10},
+			},
+			CheckRedirect: redirectPolicy(defaultMaxRedirects),
+		},
+	}
+	for _, opt := range opts {
+		opt(pool)
+	}
+	return pool
+}
+
+// copyClient returns a copy of client, so the pool's redirect policy isn't set on the caller's
+// client. A zero Timeout or nil Transport is taken from def.
+func copyClient(client, def *http.Client) *http.Client {
+	c := *client
+	if c.Timeout == 0 {
+		c.Timeout = def.Timeout
+	}
+	if c.Transport == nil {
+		c.Transport = def.Transport
+	}
+	c.CheckRedirect = def.CheckRedirect
+	return &c
+}
+
+// startWorkers launches count more worker goroutines. The caller must hold p.mu or own the
+// pool exclusively.
+func (p *WorkerPool) startWorkers(count int) {
+	p.wg.Add(count)
+	for i := 0; i < count; i++ {
+		quit := make(chan struct{})
+		p.quits = append(p.quits, quit)
+		p.running.Add(1)
+		go func() {
+			defer p.wg.Done()
+			defer p.running.Add(-1)
+			for {
+				target, ok := p.jobs.Take(quit)
+				if !ok {
+					return
+				}
+				p.hostLimiter.Dequeued(target.Host)
+				p.checkTarget(target)
+				p.jobs.Done(target)
+			}
+		}()
+	}
+}
+
+// Resize changes the number of workers. Added workers start immediately; removed workers
+// finish the check they are running and then exit, leaving queued targets to the others.
+func (p *WorkerPool) Resize(count int) error {
+	if count < 1 {
+		return fmt.Errorf("worker count must be at least 1, got %d", count)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return ErrPoolStopped
+	}
+	if n := len(p.quits); count > n {
+		p.startWorkers(count - n)
+	} else {
+		for _, quit := range p.quits[count:] {
+			close(quit)
+		}
+		p.quits = p.quits[:count]
+	}
+	p.metrics.Gauge("workers.size", float64(count))
+	return nil
+}
+
+// Workers returns the configured number of workers.
+func (p *WorkerPool) Workers() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.quits)
+}
+
+// ActiveWorkers returns how many workers are running, including removed workers that are
+// still finishing a check.
+func (p *WorkerPool) ActiveWorkers() int {
+	return int(p.running.Load())
+}
+
+// Submit adds a target to the job queue for checking. It reports false, and counts the
+// target as dropped, when the queue is full. Submitting a target that is already queued
+// reports true without queueing it again, even when the queue is full.
+func (p *WorkerPool) Submit(target models.Target) bool {
+	p.hostLimiter.Enqueued(target.Host)
+	switch err := p.jobs.Push(target); {
+	case err == nil:
+		return true
+	case errors.Is(err, ErrAlreadyQueued):
+		p.hostLimiter.Dequeued(target.Host)
+		return true
+	default:
+		p.hostLimiter.Dequeued(target.Host)
+		if !errors.Is(err, ErrQueueFull) {
+			logging.Checker.Errorf("error queueing check for %s: %v", p.redactor.URL(target.URL), err)
+		}
+		p.dropped.Add(1)
+		p.metrics.Count("queue.dropped", 1)
+		return false
+	}
+}
+
+// HostLoad returns the in-flight and queued checks of every host with either.
+func (p *WorkerPool) HostLoad() map[string]models.HostLoad {
+	return p.hostLimiter.Load()
+}
+
+// QueueDepth returns the number of targets waiting for a worker.
+func (p *WorkerPool) QueueDepth() int {
+	return p.jobs.Depth()
+}
+
+// QueueCapacity returns the size of the job queue.
+func (p *WorkerPool) QueueCapacity() int {
+	return p.jobs.Capacity()
+}
+
+// QueueDropped returns how many targets have been dropped because the queue was full.
+func (p *WorkerPool) QueueDropped() int64 {
+	return p.dropped.Load()
+}
+
+// Stop gracefully stops all workers.
+func (p *WorkerPool) Stop() {
+	p.stopOnce.Do(func() {
+		p.mu.Lock()
+		p.stopped = true
+		p.mu.Unlock()
+		p.jobs.Close()
+		p.wg.Wait()
+	})
+}
+
+// checkTarget runs performCheck, turning a panic into a failed result with the
+// internal_panic category, so a bug hit by one check takes down neither the worker nor the
+// process, and the target doesn't silently go unchecked.
+func (p *WorkerPool) checkTarget(target models.Target) {
+	startTime := p.clock.Now()
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		msg := p.redactor.Text(fmt.Sprintf("internal error: %v", r))
+		logging.Checker.Errorf("check of target %s panicked: %s\n%s", target.ID, msg, debug.Stack())
+		p.metrics.Count("checks.panics", 1, metrics.T("host", target.Host))
+		latency := p.clock.Since(startTime)
+		result := models.CheckResult{
+			TargetID:  target.ID,
+			CheckedAt: startTime,
+			LatencyMS: latency.Milliseconds(),
+			LatencyUS: latency.Microseconds(),
+			Error:     &msg,
+
+			ErrorCategory: models.ErrorCategoryInternalPanic,
+		}
+		if p.filter != nil {
+			p.filter.forget(target.ID)
+		}
+		if err := p.store.CreateCheckResult(context.Background(), &result); err != nil {
+			logging.Checker.Errorf("error saving check result for target %s: %v", target.ID, err)
+			return
+		}
+		if p.sinks != nil {
+			p.sinks.Publish(result)
+		}
+	}()
+	ctx := context.Background()
+	if target.Dependencies != nil && target.Dependencies.Skip {
+		if dep := downDependency(ctx, p.store, target); dep != "" {
+			p.recordSuppressed(ctx, target, dep)
+			return
+		}
+	}
+	switch target.Type {
+	case models.TargetTypeExec:
+		p.performExecCheck(ctx, target)
+	case models.TargetTypeWasm:
+		p.performWasmCheck(ctx, target)
+	default:
+		p.performCheck(ctx, target)
+	}
+}
+
+// performCheck executes the HTTP check for a single target.
+func (p *WorkerPool) performCheck(ctx context.Context, target models.Target) {
+	if !p.hostLimiter.Acquire(target.Host) {
+		logging.Checker.Debugf("skipping check for %s, host %s is already being checked", p.redactor.URL(target.URL), target.Host)
+		p.metrics.Count("checks.skipped", 1, metrics.T("reason", "host_busy"))
+		return
+	}
+	defer p.hostLimiter.Release(target.Host)
+
+	attempts := 0
+	maxAttempts := 3
+	backoff := 200 * time.Millisecond
+
+	budget := p.budget
+	if target.TimeoutBudgetMS > 0 {
+		budget = time.Duration(target.TimeoutBudgetMS) * time.Millisecond
+	}
+	var deadline time.Time
+	if budget > 0 {
+		deadline = p.clock.Now().Add(budget)
+	}
+
+	var statusCode *int
+	var errMsg *string
+	var category string
+	var headers map[string]string
+	var security *models.SecurityReport
+	var tlsState *models.TLSInfo
+	var page *bytes.Buffer // The final response's body, kept for the asset check
+	var pageURL *url.URL
+	var truncated, partial bool
+	var downloaded int64 // Body bytes read, summed over every attempt
+	var startTime time.Time
+	var latency time.Duration
+	var timings *models.Timings
+	var history []models.CheckAttempt
+	var cachedDNS bool
+
+	retry := func(code int, err error) bool {
+		if err != nil {
+			// Following the same redirects again would fail the same way.
+			return !errors.Is(err, errTooManyRedirects) && !errors.Is(err, errRedirectLoop)
+		}
+		return code >= 500 && code <= 599
+	}
+
+	for {
+		attempts++
+		// The result reflects the final attempt; earlier ones are kept in history.
+		statusCode, errMsg, category, headers, security, tlsState, truncated, partial, timings = nil, nil, "", nil, nil, nil, false, false, nil
+		startTime = p.clock.Now()
+		if p.dnsFailures != nil && attempts == 1 {
+			if m, ok := p.dnsFailures.lookup(target.Host, startTime); ok {
+				errMsg, category, cachedDNS = &m, models.ErrorCategoryDNS, true
+				p.metrics.Count("checks.dns_cached", 1, metrics.T("host", target.Host))
+				break
+			}
+		}
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if !deadline.IsZero() {
+			// Split what is left of the budget evenly across the remaining attempts.
+			attemptCtx, cancel = context.WithTimeout(ctx, deadline.Sub(startTime)/time.Duration(maxAttempts-attempts+1))
+		}
+		req, err := http.NewRequestWithContext(attemptCtx, http.MethodGet, target.CanonicalURL, nil)
+		if err != nil {
+			cancel()
+			m := p.redactor.Text(err.Error())
+			errMsg = &m
+			break
+		}
+		for _, h := range p.hooks {
+			if err := h.BeforeCheck(ctx, target, req); err != nil {
+				cancel()
+				logging.Checker.Warnf("skipping check for target %s: %v", target.ID, p.redactor.Text(err.Error()))
+				p.metrics.Count("checks.skipped", 1, metrics.T("reason", "hook"))
+				return
+			}
+		}
+		if len(p.hooks) > 0 {
+			startTime = p.clock.Now() // Hooks aren't part of the measured latency.
+		}
+		trace := newPhaseTrace(p.clock, startTime)
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace.clientTrace()))
+
+		resp, err := p.httpClient.Do(req)
+		latency = p.clock.Since(startTime)
+		timings = trace.result()
+		if err != nil {
+			m := p.redactor.Text(err.Error())
+			errMsg = &m
+			category = categorizeError(err)
+		} else {
+			status := resp.StatusCode
+			statusCode = &status
+			headers = captureHeaders(resp.Header, target.CaptureHeaders)
+			security = auditSecurityHeaders(resp.Header, target.SecurityAudit)
+			tlsState = tlsInfo(resp.TLS)
+			page, pageURL = nil, nil
+			if target.AssetCheck != nil && isHTML(resp.Header.Get("Content-Type")) {
+				page, pageURL = &bytes.Buffer{}, req.URL
+				if resp.Request != nil {
+					pageURL = resp.Request.URL // After redirects
+				}
+			}
+			var n int64
+			n, truncated, partial = p.body.read(resp.Body, resp.Header.Get("Content-Type"), resp.ContentLength, page)
+			resp.Body.Close()
+			downloaded += n
+		}
+		cancel()
+
+		history = append(history, models.CheckAttempt{
+			Attempt:    attempts,
+			StartedAt:  startTime,
+			StatusCode: statusCode,
+			LatencyMS:  latency.Milliseconds(),
+			LatencyUS:  latency.Microseconds(),
+			Error:      errMsg,
+
+			ErrorCategory: category,
+			Timings:       timings,
+		})
+
+		code := 0
+		if statusCode != nil {
+			code = *statusCode
+		}
+		if attempts < maxAttempts && retry(code, err) {
+			if !deadline.IsZero() && !p.clock.Now().Add(backoff).Before(deadline) {
+				p.metrics.Count("checks.budget_exhausted", 1, metrics.T("host", target.Host))
+				break
+			}
+			p.metrics.Count("checks.retries", 1, metrics.T("host", target.Host))
+			p.clock.Sleep(backoff)
+			backoff *= 2
+			continue
+		}
+		break
+	}
+
+	if p.dnsFailures != nil && !cachedDNS {
+		switch {
+		case category == models.ErrorCategoryDNS:
+			p.dnsFailures.record(target.Host, *errMsg, p.clock.Now())
+		case statusCode != nil:
+			p.dnsFailures.forget(target.Host)
+		}
+	}
+
+	// The result's latency is the final attempt's; its total duration runs from the first
+	// attempt's start to the final one's end, through every retry and backoff.
+	firstStart := startTime
+	if len(history) > 0 {
+		firstStart = history[0].StartedAt
+	}
+	result := models.CheckResult{
+		ID:         "", // DB/storage layer may set ID; not required in interface
+		TargetID:   target.ID,
+		CheckedAt:  startTime,
+		StartedAt:  &firstStart,
+		LatencyMS:  latency.Milliseconds(),
+		LatencyUS:  latency.Microseconds(),
+		StatusCode: statusCode,
+		Error:      errMsg,
+		Headers:    headers,
+		Security:   security,
+		Timings:    timings,
+		TLS:        tlsState,
+
+		ErrorCategory:    category,
+		BodyTruncated:    truncated,
+		Partial:          partial,
+		CachedDNSFailure: cachedDNS,
+		TotalDurationMS:  (startTime.Sub(firstStart) + latency).Milliseconds(),
+		BytesDownloaded:  downloaded,
+	}
+	if len(history) > 1 {
+		result.Attempts = history
+	}
+	if target.StatusPolicy != nil && statusCode != nil {
+		result.Outcome = target.StatusPolicy.Classify(*statusCode)
+	}
+	if page != nil && errMsg == nil && result.Succeeded() {
+		result.Assets = p.checkAssets(ctx, target, pageURL, page.Bytes())
+		if len(result.Assets.Findings) > 0 && (result.Outcome == "" || result.Outcome == models.OutcomeSuccess) {
+			result.Outcome = models.OutcomeWarning
+		}
+	}
+	p.finishCheck(ctx, target, result, latency)
+}
+
+// finishCheck marks the result of a check partial, slow, or suppressed where that applies,
+// runs the hooks, and then stores, publishes, and alerts on it.
+func (p *WorkerPool) finishCheck(ctx context.Context, target models.Target, result models.CheckResult, latency time.Duration) {
+	completed := p.clock.Now()
+	result.CompletedAt = &completed
+	if result.Partial && result.Succeeded() {
+		// A response cut off mid-body would otherwise pass as a success.
+		result.Outcome = models.OutcomePartial
+		p.metrics.Count("checks.partial", 1, metrics.T("host", target.Host))
+	}
+	threshold := time.Duration(target.LatencyThresholdMS) * time.Millisecond
+	if threshold > 0 && latency >= threshold && result.Succeeded() && (result.Outcome == "" || result.Outcome == models.OutcomeSuccess) {
+		result.Outcome = models.OutcomeSlow
+		p.metrics.Count("checks.slow", 1, metrics.T("host", target.Host))
+	}
+	if !result.Succeeded() && target.Dependencies != nil {
+		if dep := downDependency(ctx, p.store, target); dep != "" {
+			result.Outcome = models.OutcomeSuppressed
+			p.metrics.Count("checks.suppressed", 1, metrics.T("host", target.Host), metrics.T("mode", "suppress"))
+		}
+	}
+	for _, h := range p.hooks {
+		h.AfterCheck(ctx, target, &result)
+	}
+
+	outcome := "success"
+	if !result.Succeeded() {
+		outcome = "failure"
+	}
+	tags := []metrics.Tag{metrics.T("host", target.Host), metrics.T("status_class", metrics.StatusClass(result.StatusCode))}
+	p.metrics.Timing("checks.latency", latency, tags...)
+	p.metrics.Timing("checks.total_duration", max(time.Duration(result.TotalDurationMS)*time.Millisecond, latency), tags...)
+	p.metrics.Count("checks.completed", 1, append(tags, metrics.T("outcome", outcome))...)
+	if result.BytesDownloaded > 0 {
+		p.metrics.Count("checks.bytes_downloaded", result.BytesDownloaded, metrics.T("host", target.Host))
+	}
+
+	if p.filter != nil && !p.filter.shouldStore(result) {
+		p.metrics.Count("checks.unchanged", 1)
+		return
+	}
+	// Only the first slow check in a row alerts, so the previous result decides.
+	alertSlow := result.Outcome == models.OutcomeSlow && p.notifier != nil
+	if alertSlow {
+		latest, err := p.store.ListCheckResultsByTargetID(ctx, storage.ListCheckResultsParams{TargetID: target.ID, Limit: 1, Fields: []string{"outcome"}})
+		alertSlow = err == nil && (len(latest) == 0 || latest[0].Outcome != models.OutcomeSlow)
+	}
+	minTLS := p.minTLSVersion(target)
+	weakTLS := belowMinimum(result.TLS, minTLS)
+	if weakTLS {
+		p.metrics.Count("checks.weak_tls", 1, metrics.T("host", target.Host), metrics.T("version", result.TLS.Version))
+	}
+	// Like slow checks, only the first weak check in a row alerts.
+	alertWeak := weakTLS && p.notifier != nil
+	if alertWeak {
+		latest, err := p.store.ListCheckResultsByTargetID(ctx, storage.ListCheckResultsParams{TargetID: target.ID, Limit: 1, Fields: []string{"tls"}})
+		alertWeak = err == nil && (len(latest) == 0 || !belowMinimum(latest[0].TLS, minTLS))
+	}
+	var missing []string
+	if result.Security != nil && len(result.Security.Missing) > 0 {
+		p.metrics.Count("checks.security_missing", 1, metrics.T("host", target.Host))
+		if p.notifier != nil {
+			missing = p.newlyMissing(ctx, target.ID, result.Security.Missing)
+		}
+	}
+	if dbErr := p.store.CreateCheckResult(ctx, &result); dbErr != nil {
+		logging.Checker.Errorf("error saving check result for target %s: %v", target.ID, dbErr)
+		if p.filter != nil {
+			p.filter.forget(target.ID)
+		}
+		return
+	}
+	if p.sinks != nil {
+		p.sinks.Publish(result)
+	}
+	if alertSlow {
+		event := notify.Event{
+			Type:     notify.EventTargetSlow,
+			TargetID: target.ID,
+			URL:      target.URL,
+			Message:  fmt.Sprintf("latency %s reached the threshold of %s", latency.Round(time.Millisecond), threshold),
+			At:       result.CheckedAt,
+		}
+		if err := p.notifier.Notify(ctx, event); err != nil {
+			logging.Checker.Errorf("error sending alert for target %s: %v", target.ID, err)
+		}
+	}
+	if alertWeak {
+		event := notify.Event{
+			Type:     notify.EventTargetWeakTLS,
+			TargetID: target.ID,
+			URL:      target.URL,
+			Message:  fmt.Sprintf("negotiated %s with %s, below the minimum of TLS %s", result.TLS.Version, result.TLS.CipherSuite, minTLS),
+			At:       result.CheckedAt,
+		}
+		if err := p.notifier.Notify(ctx, event); err != nil {
+			logging.Checker.Errorf("error sending alert for target %s: %v", target.ID, err)
+		}
+	}
+	if len(missing) > 0 {
+		event := notify.Event{
+			Type:     notify.EventTargetSecurityHeaderMissing,
+			TargetID: target.ID,
+			URL:      target.URL,
+			Message:  "required security headers missing: " + strings.Join(missing, ", "),
+			At:       result.CheckedAt,
+		}
+		if err := p.notifier.Notify(ctx, event); err != nil {
+			logging.Checker.Errorf("error sending alert for target %s: %v", target.ID, err)
+		}
+	}
+}
+
+// maxCapturedHeaderBytes caps the length of each captured header value.
+const maxCapturedHeaderBytes = 256
+
+// captureHeaders returns the values of the named response headers that are present, with
+// repeated headers joined by ", ". It returns nil when nothing was captured.
+func captureHeaders(header http.Header, names []string) map[string]string {
+	var captured map[string]string
+	for _, name := range names {
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		value := strings.Join(values, ", ")
+		if len(value) > maxCapturedHeaderBytes {
+			value = value[:maxCapturedHeaderBytes]
+		}
+		if captured == nil {
+			captured = make(map[string]string, len(names))
+		}
+		captured[name] = value
+	}
+	return captured
+}
@@ -0,0 +1,126 @@
+package checker
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// QueueThresholds are the levels past which the queue monitor warns that checks can't keep
+// up. Zero fields aren't alerted on.
+type QueueThresholds struct {
+	SchedulerLag time.Duration // Since the scheduler last went through the targets; must exceed the check interval
+	OldestQueued time.Duration // How long the longest-waiting target has been queued
+	Saturation   float64       // Share of a bounded queue's capacity in use, from 0 to 1
+}
+
+// OldestQueuer is implemented by Queues that know when their longest-waiting target was
+// queued. The queue monitor only reports the age of the oldest target for those.
+type OldestQueuer interface {
+	// OldestQueued returns when the longest-waiting target was queued, or the zero time when
+	// the queue is empty.
+	OldestQueued() time.Time
+}
+
+// Queue monitor measurements, as named in metrics and in threshold events.
+const (
+	queueMetricLag        = "scheduler.lag"
+	queueMetricOldest     = "queue.oldest_age"
+	queueMetricSaturation = "queue.saturation"
+)
+
+// WithQueueMonitor measures every interval how far behind the checker is: the scheduler's
+// lag, the age of the oldest queued target, and the queue's saturation, reported as the
+// scheduler.lag, queue.oldest_age, and queue.saturation metrics. A measurement past its
+// threshold records a queue.threshold_exceeded event, and one back under it a
+// queue.threshold_cleared event.
+func WithQueueMonitor(interval time.Duration, thresholds QueueThresholds) Option {
+	return func(c *Checker) {
+		if interval > 0 {
+			c.monitorEvery = interval
+			c.thresholds = thresholds
+		}
+	}
+}
+
+// queueMeasurement is one of the queue monitor's measurements with its threshold.
+type queueMeasurement struct {
+	metric    string
+	value     float64 // Seconds for durations
+	threshold float64 // Zero isn't alerted on
+	display   string
+}
+
+// monitorQueue measures the queue every monitorEvery until the checker stops.
+func (c *Checker) monitorQueue() {
+	ticker := c.clock.NewTicker(c.monitorEvery)
+	defer ticker.Stop()
+	exceeded := make(map[string]bool)
+	for {
+		select {
+		case <-ticker.C():
+			for _, m := range c.measureQueue() {
+				c.checkThreshold(m, exceeded)
+			}
+		case <-c.stopChan:
+			return
+		}
+	}
+}
+
+// measureQueue takes the measurements that apply to this checker: workers don't schedule, so
+// they have no lag, and only bounded queues saturate.
+func (c *Checker) measureQueue() []queueMeasurement {
+	now := c.clock.Now()
+	var ms []queueMeasurement
+	if c.role != RoleWorker {
+		if health := c.Health(); health.Status != models.CheckerStopped && health.LastPassAt != nil {
+			lag := now.Sub(*health.LastPassAt)
+			ms = append(ms, queueMeasurement{queueMetricLag, lag.Seconds(), c.thresholds.SchedulerLag.Seconds(), lag.Round(time.Second).String()})
+		}
+	}
+	if q, ok := c.pool.jobs.(OldestQueuer); ok {
+		var age time.Duration
+		if oldest := q.OldestQueued(); !oldest.IsZero() {
+			age = max(now.Sub(oldest), 0)
+		}
+		ms = append(ms, queueMeasurement{queueMetricOldest, age.Seconds(), c.thresholds.OldestQueued.Seconds(), age.Round(time.Second).String()})
+	}
+	if capacity := c.pool.QueueCapacity(); capacity > 0 {
+		saturation := float64(c.pool.QueueDepth()) / float64(capacity)
+		ms = append(ms, queueMeasurement{queueMetricSaturation, saturation, c.thresholds.Saturation, fmt.Sprintf("%.0f%%", saturation*100)})
+	}
+	return ms
+}
+
+// checkThreshold reports m and records an event when it crosses its threshold either way.
+// exceeded holds the measurements past their thresholds, so a queue that stays behind
+// doesn't add an event every interval.
+func (c *Checker) checkThreshold(m queueMeasurement, exceeded map[string]bool) {
+	c.metrics.Gauge(m.metric, m.value)
+	if m.threshold <= 0 {
+		return
+	}
+	over := m.value >= m.threshold
+	if over == exceeded[m.metric] {
+		return
+	}
+	exceeded[m.metric] = over
+	details := map[string]string{
+		"metric":      m.metric,
+		"value":       strconv.FormatFloat(m.value, 'f', -1, 64),
+		"threshold":   strconv.FormatFloat(m.threshold, 'f', -1, 64),
+		"workers":     strconv.Itoa(c.pool.Workers()),
+		"queue_depth": strconv.Itoa(c.pool.QueueDepth()),
+	}
+	if over {
+		logging.Checker.Warnf("%s is %s, past its threshold; checks aren't keeping up, consider raising MAX_CONCURRENCY (%d workers)", m.metric, m.display, c.pool.Workers())
+		c.recordEvent(models.SystemEventQueueThresholdExceeded, fmt.Sprintf("%s is %s, past its threshold", m.metric, m.display), details)
+		return
+	}
+	logging.Checker.Infof("%s is %s, back under its threshold", m.metric, m.display)
+	c.recordEvent(models.SystemEventQueueThresholdCleared, fmt.Sprintf("%s is %s, back under its threshold", m.metric, m.display), details)
+}
@@ -0,0 +1,66 @@
+package checker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// defaultMaxRedirects is how many redirects a check follows when no limit is configured.
+const defaultMaxRedirects = 5
+
+var (
+	errTooManyRedirects = errors.New("too many redirects")
+	errRedirectLoop     = errors.New("redirect loop")
+)
+
+// redirectPolicy returns a CheckRedirect function that follows at most maxRedirects hops and
+// fails on a URL seen earlier in the chain. With maxRedirects 0, the first redirect response
+// is recorded as the result.
+func redirectPolicy(maxRedirects int) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if maxRedirects == 0 {
+			return http.ErrUseLastResponse
+		}
+		for _, prev := range via {
+			if prev.URL.String() == req.URL.String() {
+				return fmt.Errorf("%w: %s was already visited", errRedirectLoop, req.URL)
+			}
+		}
+		if len(via) > maxRedirects {
+			return fmt.Errorf("%w: stopped after %d", errTooManyRedirects, maxRedirects)
+		}
+		return nil
+	}
+}
+
+// categorizeError classifies a request error into one of the models.ErrorCategory values.
+func categorizeError(err error) string {
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthErr x509.UnknownAuthorityError
+	var recordErr tls.RecordHeaderError
+	switch {
+	case errors.Is(err, errTooManyRedirects):
+		return models.ErrorCategoryTooManyRedirects
+	case errors.Is(err, errRedirectLoop):
+		return models.ErrorCategoryRedirectLoop
+	case errors.As(err, &dnsErr):
+		return models.ErrorCategoryDNS
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return models.ErrorCategoryTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return models.ErrorCategoryConnectionRefused
+	case errors.As(err, &certErr), errors.As(err, &unknownAuthErr), errors.As(err, &recordErr):
+		return models.ErrorCategoryTLS
+	default:
+		return models.ErrorCategoryNetwork
+	}
+}
@@ -0,0 +1,46 @@
+package checker
+
+import (
+	"context"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// WithResultSampling thins the stored results of targets with a ResultSampling policy every
+// interval, through sampler. Like scheduling, it only runs on the instance that leads.
+func WithResultSampling(sampler storage.ResultSampler, interval time.Duration) Option {
+	return func(c *Checker) {
+		if interval > 0 {
+			c.sampler = sampler
+			c.sampleEvery = interval
+		}
+	}
+}
+
+// sampleResults runs the sampler every sampleInterval until the checker stops.
+func (c *Checker) sampleResults() {
+	ticker := c.clock.NewTicker(c.sampleEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			if !c.leading() {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), c.sampleEvery)
+			n, err := c.sampler.SampleCheckResults(ctx, c.clock.Now().UTC())
+			cancel()
+			if err != nil {
+				logging.Checker.Errorf("error sampling check results: %v", err)
+			}
+			if n > 0 {
+				logging.Checker.Infof("sampled check results, deleted %d", n)
+				c.metrics.Count("results.sampled", int64(n))
+			}
+		case <-c.stopChan:
+			return
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package checker
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// auditSecurityHeaders records the security headers of a response for a target's audit, or
+// returns nil when the target has none. Values are capped like captured headers.
+func auditSecurityHeaders(header http.Header, audit *models.SecurityAudit) *models.SecurityReport {
+	if audit == nil {
+		return nil
+	}
+	report := &models.SecurityReport{Headers: captureHeaders(header, audit.Headers())}
+	if report.Headers == nil {
+		report.Headers = map[string]string{}
+	}
+	for _, name := range audit.Required {
+		if _, ok := report.Headers[name]; !ok {
+			report.Missing = append(report.Missing, name)
+		}
+	}
+	return report
+}
+
+// newlyMissing returns those of the missing required headers that the target's previous
+// audited check still had, or that its first audited check lacks, so a header that goes
+// missing alerts once rather than on every check.
+func (p *WorkerPool) newlyMissing(ctx context.Context, targetID string, missing []string) []string {
+	latest, err := p.store.ListCheckResultsByTargetID(ctx, storage.ListCheckResultsParams{TargetID: targetID, Limit: 1, Audited: true, Fields: []string{"security"}})
+	if err != nil {
+		return nil
+	}
+	var before []string
+	if len(latest) > 0 && latest[0].Security != nil {
+		before = latest[0].Security.Missing
+	}
+	var added []string
+	for _, name := range missing {
+		if !slices.Contains(before, name) {
+			added = append(added, name)
+		}
+	}
+	return added
+}
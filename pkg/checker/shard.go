@@ -0,0 +1,54 @@
+package checker
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// WithShard makes the checker schedule only the targets in shard index of total, so total
+// instances, each with its own index, split the checks between them without a shared queue.
+// Heartbeat deadlines and start-up catch-up are split the same way; result sampling and
+// archiving only run on shard 0. With leader election, each shard has its own lease, so
+// standby instances can be run per shard. Workers run whatever checks reach them.
+func WithShard(index, total int) Option {
+	return func(c *Checker) {
+		if total > 1 {
+			c.shard = shard{index: index, total: total}
+		}
+	}
+}
+
+// shard is the part of the targets an instance schedules. The zero value owns every target.
+type shard struct {
+	index, total int
+}
+
+// owns reports whether the target with id belongs to the shard.
+func (s shard) owns(id string) bool {
+	return s.total <= 1 || ShardOf(id, s.total) == s.index
+}
+
+// leaseName returns the scheduler lease of the shard. Unsharded instances keep the lease
+// name they always had.
+func (s shard) leaseName() string {
+	if s.total <= 1 {
+		return schedulerLease
+	}
+	return fmt.Sprintf("%s/%d", schedulerLease, s.index)
+}
+
+// ShardOf returns which of total shards the target with id belongs to. It uses jump
+// consistent hashing (Lamping and Veach), so going from n to n+1 shards moves only the
+// 1/(n+1) of targets that land in the new shard, and none between existing shards.
+func ShardOf(id string, total int) int {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	key := h.Sum64()
+	b, j := int64(-1), int64(0)
+	for j < int64(total) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
@@ -0,0 +1,125 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// schedulerRestartDelay is how long a crashed scheduler waits before it is started again, so
+// a panic on every pass doesn't spin.
+const schedulerRestartDelay = 5 * time.Second
+
+// schedulerHealth tracks the scheduling loop for Health.
+type schedulerHealth struct {
+	mu          sync.Mutex
+	status      string
+	restarts    int
+	lastPanic   string
+	lastPanicAt time.Time
+	lastPass    time.Time // When the scheduler last went through the targets, or started
+}
+
+func (h *schedulerHealth) set(status string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status = status
+}
+
+// scheduled records that the scheduler went through the targets, or is standing by for a
+// leader that does, at at.
+func (h *schedulerHealth) scheduled(at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastPass = at
+}
+
+// crashed records a panic of the scheduling loop.
+func (h *schedulerHealth) crashed(p any, at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status = models.CheckerRestarting
+	h.restarts++
+	h.lastPanic, h.lastPanicAt = fmt.Sprint(p), at
+}
+
+// Health reports whether the checker's scheduling loop is running and how often it has
+// crashed and been restarted.
+func (c *Checker) Health() models.CheckerHealth {
+	h := &c.health
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	health := models.CheckerHealth{Status: h.status, Role: c.role, Restarts: h.restarts, LastPanic: h.lastPanic}
+	if health.Status == "" {
+		health.Status = models.CheckerStopped
+	}
+	if !h.lastPanicAt.IsZero() {
+		at := h.lastPanicAt
+		health.LastPanicAt = &at
+	}
+	if !h.lastPass.IsZero() {
+		at := h.lastPass
+		health.LastPassAt = &at
+	}
+	return health
+}
+
+// supervise runs the scheduling loop until the checker stops, starting it again
+// schedulerRestartDelay after it panics.
+func (c *Checker) supervise() {
+	for {
+		c.health.set(models.CheckerOK)
+		c.health.scheduled(c.clock.Now().UTC())
+		if !c.runSchedulerRecovered() {
+			return
+		}
+		select {
+		case <-c.clock.After(schedulerRestartDelay):
+			logging.Checker.Infof("restarting background checker")
+		case <-c.stopChan:
+			return
+		}
+	}
+}
+
+// runSchedulerRecovered runs the scheduling loop and reports whether it panicked.
+func (c *Checker) runSchedulerRecovered() (crashed bool) {
+	defer func() {
+		if p := recover(); p != nil {
+			logging.Checker.Errorf("background checker crashed, restarting in %s: %v\n%s", schedulerRestartDelay, p, debug.Stack())
+			c.health.crashed(p, c.clock.Now().UTC())
+			c.metrics.Count("scheduler.restarts", 1)
+			c.recordEvent(models.SystemEventCheckerRestarted, fmt.Sprintf("background checker crashed, restarting in %s", schedulerRestartDelay),
+				map[string]string{"panic": fmt.Sprint(p)})
+			crashed = true
+		}
+	}()
+	c.runScheduler()
+	return false
+}
+
+// CheckNow queues a check of target outside the schedule, e.g. the first check of a target
+// that was just created, so it doesn't wait for the next pass or for a scheduler that isn't
+// running. Heartbeat targets, snoozed targets, targets outside their schedule, and targets
+// on paused hosts aren't checked.
+// It reports whether a check was queued.
+func (c *Checker) CheckNow(target models.Target) bool {
+	now := c.clock.Now()
+	if target.Type == models.TargetTypeHeartbeat || target.Snoozed(now) || !target.OnSchedule(now) {
+		return false
+	}
+	select {
+	case <-c.stopChan:
+		return false
+	default:
+	}
+	if c.pausedHosts(context.Background())[target.Host] {
+		return false
+	}
+	return c.pool.Submit(target)
+}
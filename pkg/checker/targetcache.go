@@ -0,0 +1,98 @@
+package checker
+
+import (
+	"context"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/metrics"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// TargetVersioner reports a counter that changes whenever a target is created, changed, or
+// deleted, by any process sharing the store.
+type TargetVersioner interface {
+	TargetVersion(ctx context.Context) (int64, error)
+}
+
+// targetCache is the target list as of the last scheduling pass that read it.
+type targetCache struct {
+	versions TargetVersioner
+	refresh  time.Duration
+	targets  []models.Target
+	version  int64
+	loadedAt time.Time
+	loaded   bool
+}
+
+// WithTargetCache keeps the target list in memory between scheduling passes. Each pass reads
+// the version from versions and only lists the targets again when it changed, or when refresh
+// has passed since they were listed, as a backstop for changes made behind the store's back.
+// The whole list is then held in memory, instead of one page at a time. A failed version read
+// lists the targets again, as without the cache.
+func WithTargetCache(versions TargetVersioner, refresh time.Duration) Option {
+	return func(c *Checker) {
+		if refresh > 0 {
+			c.targets = &targetCache{versions: versions, refresh: refresh}
+		}
+	}
+}
+
+// eachTarget calls fn with every target in ID order, from the target cache when it is enabled
+// and current, and returns how many there were.
+func (c *Checker) eachTarget(ctx context.Context, now time.Time, fn func(models.Target)) (int, error) {
+	if c.targets == nil {
+		return c.pageTargets(ctx, fn)
+	}
+	tc := c.targets
+	version, err := tc.versions.TargetVersion(ctx)
+	reason := ""
+	switch {
+	case err != nil:
+		logging.Checker.Errorf("error reading target version, reloading targets: %v", err)
+		reason = "error"
+	case !tc.loaded:
+		reason = "cold"
+	case version != tc.version:
+		reason = "changed"
+	case now.Sub(tc.loadedAt) >= tc.refresh:
+		reason = "refresh"
+	}
+	if reason != "" {
+		// The version is read before the targets, so a change made while they are listed
+		// is picked up by the next pass.
+		var targets []models.Target
+		if _, err := c.pageTargets(ctx, func(t models.Target) { targets = append(targets, t) }); err != nil {
+			tc.loaded = false
+			return 0, err
+		}
+		tc.targets, tc.version, tc.loadedAt = targets, version, now
+		// Without a version to compare against, the next pass lists them again.
+		tc.loaded = err == nil
+		c.metrics.Count("scheduler.target_reloads", 1, metrics.T("reason", reason))
+	}
+	for _, t := range tc.targets {
+		fn(t)
+	}
+	return len(tc.targets), nil
+}
+
+// pageTargets calls fn with every target in the store, reading them in pages of batchSize
+// so memory use stays bounded regardless of how many targets exist.
+func (c *Checker) pageTargets(ctx context.Context, fn func(models.Target)) (int, error) {
+	total, afterID := 0, ""
+	for {
+		targets, err := c.store.ListTargetsPage(ctx, afterID, c.batchSize)
+		if err != nil {
+			return total, err
+		}
+		for _, t := range targets {
+			fn(t)
+		}
+		total += len(targets)
+		if len(targets) < c.batchSize {
+			return total, nil
+		}
+		afterID = targets[len(targets)-1].ID
+	}
+}
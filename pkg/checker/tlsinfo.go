@@ -0,0 +1,59 @@
+package checker
+
+import (
+	"crypto/tls"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// tlsVersionIDs maps the versions of models.TLSVersions to their crypto/tls constants.
+var tlsVersionIDs = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// WithMinTLSVersion alerts, with a target.weak_tls event, when a target negotiates a TLS
+// version older than version (one of models.TLSVersions), unless the target sets its own
+// minimum. The checker still connects down to TLS 1.0, so old versions are reported rather
+// than failing the handshake.
+func WithMinTLSVersion(version string) Option {
+	return func(c *Checker) {
+		c.poolOpts = append(c.poolOpts, func(p *WorkerPool) { p.minTLS = version })
+	}
+}
+
+// tlsInfo describes the TLS connection of a response, or returns nil for plain HTTP.
+func tlsInfo(state *tls.ConnectionState) *models.TLSInfo {
+	if state == nil {
+		return nil
+	}
+	return &models.TLSInfo{Version: tls.VersionName(state.Version), CipherSuite: tls.CipherSuiteName(state.CipherSuite)}
+}
+
+// belowMinimum reports whether info's version is older than the minimum, one of
+// models.TLSVersions. Unknown versions and minimums never are.
+func belowMinimum(info *models.TLSInfo, minimum string) bool {
+	if info == nil {
+		return false
+	}
+	min, ok := tlsVersionIDs[minimum]
+	if !ok {
+		return false
+	}
+	for _, id := range tlsVersionIDs {
+		if tls.VersionName(id) == info.Version {
+			return id < min
+		}
+	}
+	return false
+}
+
+// minTLSVersion returns the minimum TLS version that applies to target.
+func (p *WorkerPool) minTLSVersion(target models.Target) string {
+	if target.MinTLSVersion != "" {
+		return target.MinTLSVersion
+	}
+	return p.minTLS
+}
@@ -0,0 +1,75 @@
+package checker
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/clock"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// phaseTrace times the phases of one HTTP attempt. Phases repeated across redirects are
+// summed, and the time to first byte is that of the final response.
+type phaseTrace struct {
+	clock clock.Clock
+	start time.Time
+
+	mu                               sync.Mutex // Trace hooks may run on transport goroutines
+	dnsStart, connectStart, tlsStart time.Time
+	timings                          models.Timings
+}
+
+func newPhaseTrace(c clock.Clock, start time.Time) *phaseTrace {
+	return &phaseTrace{clock: c, start: start}
+}
+
+func (t *phaseTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.add(&t.timings.DNSUS, t.dnsStart) },
+		ConnectStart: func(network, addr string) {
+			t.mark(&t.connectStart)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err == nil {
+				t.add(&t.timings.ConnectUS, t.connectStart)
+			}
+		},
+		TLSHandshakeStart: func() { t.mark(&t.tlsStart) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.add(&t.timings.TLSUS, t.tlsStart)
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.timings.FirstByteUS = t.clock.Since(t.start).Microseconds()
+		},
+	}
+}
+
+func (t *phaseTrace) mark(at *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*at = t.clock.Now()
+}
+
+func (t *phaseTrace) add(total *int64, since time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !since.IsZero() {
+		*total += t.clock.Since(since).Microseconds()
+	}
+}
+
+// result returns the recorded timings, or nil if the attempt never reached the network.
+func (t *phaseTrace) result() *models.Timings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timings == (models.Timings{}) {
+		return nil
+	}
+	timings := t.timings
+	return &timings
+}
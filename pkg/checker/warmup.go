@@ -0,0 +1,98 @@
+package checker
+
+import (
+	"context"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// overdueTargets returns the HTTP targets whose next check came due while the checker wasn't
+// running: those never checked, or last checked at least an interval before now. Heartbeat
+// deadlines are evaluated on the way, since that needs no requests. Targets on paused hosts
+// are left out.
+func (c *Checker) overdueTargets(now time.Time) ([]models.Target, error) {
+	ctx := context.Background()
+	paused := c.pausedHosts(ctx)
+	var overdue []models.Target
+	afterID := ""
+	for {
+		targets, err := c.store.ListTargetsPage(ctx, afterID, c.batchSize)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]string, 0, len(targets))
+		for _, t := range targets {
+			if paused[t.Host] || !c.shard.owns(t.ID) {
+				continue
+			}
+			if t.Type == models.TargetTypeHeartbeat {
+				c.checkHeartbeat(t, now)
+				continue
+			}
+			ids = append(ids, t.ID)
+		}
+		latest, err := c.store.GetLatestResults(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range targets {
+			if t.Type == models.TargetTypeHeartbeat || paused[t.Host] || !c.shard.owns(t.ID) {
+				continue
+			}
+			if r, ok := latest[t.ID]; !ok || !r.CheckedAt.Add(c.checkInterval).After(now) {
+				overdue = append(overdue, t)
+			}
+		}
+		if len(targets) < c.batchSize {
+			return overdue, nil
+		}
+		afterID = targets[len(targets)-1].ID
+	}
+}
+
+// catchUp spreads the checks that came due during downtime evenly over the warm-up window, so
+// a restart doesn't send every overdue target to the pool at once. It reports false if the
+// checker was stopped before it finished.
+func (c *Checker) catchUp() bool {
+	overdue, err := c.overdueTargets(c.clock.Now().UTC())
+	if err != nil {
+		logging.Checker.Errorf("error finding overdue targets, skipping catch-up: %v", err)
+		return true
+	}
+	total := len(overdue)
+	c.metrics.Gauge("scheduler.backlog", float64(total))
+	if total == 0 {
+		return true
+	}
+	logging.Checker.Infof("catching up on %d overdue targets over %s", total, c.warmup)
+
+	spacing := c.warmup / time.Duration(total)
+	dropped, nextReport := 0, total/10
+	for i, t := range overdue {
+		if i > 0 {
+			select {
+			case <-c.clock.After(spacing):
+			case <-c.stopChan:
+				return false
+			}
+		}
+		if !c.pool.Submit(t) {
+			dropped++
+		}
+		c.health.scheduled(c.clock.Now().UTC())
+		remaining := total - i - 1
+		c.metrics.Gauge("scheduler.backlog", float64(remaining))
+		if i+1 >= nextReport && remaining > 0 {
+			logging.Checker.Debugf("catch-up: submitted %d of %d overdue targets", i+1, total)
+			nextReport += max(total/10, 1)
+		}
+	}
+	if dropped > 0 {
+		logging.Checker.Warnf("catch-up finished, %d targets dropped because the job queue was full", dropped)
+	} else {
+		logging.Checker.Infof("catch-up finished, submitted %d overdue targets", total)
+	}
+	return true
+}
@@ -0,0 +1,215 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+
+	"github.com/zeng-yichen/linkwatch/pkg/metrics"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+const (
+	// defaultWasmTimeout is how long a wasm check may run when WithWasmChecks leaves it unset.
+	defaultWasmTimeout = 10 * time.Second
+	// maxWasmMemoryPages caps each run's linear memory at 64 MiB.
+	maxWasmMemoryPages = 1024
+)
+
+// WasmPlugins are the WebAssembly modules of a plugins directory, compiled once at startup
+// for wasm targets to run.
+type WasmPlugins struct {
+	runtime wazero.Runtime
+	modules map[string]wazero.CompiledModule
+}
+
+// LoadWasmPlugins compiles every .wasm file in dir. A module that doesn't compile fails the
+// load, so a broken plugin stops startup instead of failing each of its checks.
+//
+// Modules are WASI (preview 1) programs, as built by GOOS=wasip1 or Rust's wasm32-wasip1,
+// and get no filesystem, network, or host environment: only what is passed to each run.
+func LoadWasmPlugins(ctx context.Context, dir string) (*WasmPlugins, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	config := wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(maxWasmMemoryPages)
+	p := &WasmPlugins{
+		runtime: wazero.NewRuntimeWithConfig(ctx, config),
+		modules: make(map[string]wazero.CompiledModule),
+	}
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, p.runtime); err != nil {
+		p.Close(ctx)
+		return nil, fmt.Errorf("failed to instantiate WASI: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".wasm" {
+			continue
+		}
+		code, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			p.modules[name], err = p.runtime.CompileModule(ctx, code)
+		}
+		if err != nil {
+			p.Close(ctx)
+			return nil, fmt.Errorf("failed to load plugin %s: %w", name, err)
+		}
+	}
+	return p, nil
+}
+
+// Names returns the loaded modules' file names, in order.
+func (p *WasmPlugins) Names() []string {
+	names := make([]string, 0, len(p.modules))
+	for name := range p.modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close releases the compiled modules, ending any run still in progress.
+func (p *WasmPlugins) Close(ctx context.Context) error {
+	return p.runtime.Close(ctx)
+}
+
+// wasmRunner runs the modules of wasm targets.
+type wasmRunner struct {
+	plugins *WasmPlugins
+	timeout time.Duration
+}
+
+// WithWasmChecks enables wasm targets, whose modules are plugins. Each run is a fresh
+// instance of the module with a timeout (10s by default; a target's TimeoutBudgetMS overrides
+// it), memory capped at 64 MiB, the target's config as a JSON object on stdin, and an
+// environment of only LINKWATCH_TARGET_ID. Its exit code and stdout are read as for exec
+// checks (see WithExecChecks). Without WithWasmChecks, wasm targets fail with the wasm error
+// category.
+func WithWasmChecks(plugins *WasmPlugins, timeout time.Duration) Option {
+	return func(c *Checker) {
+		if timeout <= 0 {
+			timeout = defaultWasmTimeout
+		}
+		c.poolOpts = append(c.poolOpts, func(p *WorkerPool) { p.wasm = &wasmRunner{plugins: plugins, timeout: timeout} })
+	}
+}
+
+// performWasmCheck runs the module of a wasm target and records its result.
+func (p *WorkerPool) performWasmCheck(ctx context.Context, target models.Target) {
+	startTime := p.clock.Now()
+	result := models.CheckResult{TargetID: target.ID, CheckedAt: startTime}
+	fail := func(category, msg string) {
+		msg = p.redactor.Text(msg)
+		result.Error, result.ErrorCategory = &msg, category
+	}
+
+	var latency time.Duration
+	switch {
+	case p.wasm == nil:
+		fail(models.ErrorCategoryWasm, "wasm checks are disabled")
+	case target.Wasm == nil:
+		fail(models.ErrorCategoryWasm, "target has no module")
+	default:
+		latency = p.runWasm(ctx, target, &result, fail)
+	}
+	result.LatencyMS, result.LatencyUS = latency.Milliseconds(), latency.Microseconds()
+	if result.Error != nil {
+		p.metrics.Count("checks.wasm_failed", 1, metrics.T("module", moduleName(target)), metrics.T("category", result.ErrorCategory))
+	}
+	p.finishCheck(ctx, target, result, latency)
+}
+
+// moduleName returns the module of a wasm target, for metric tags.
+func moduleName(target models.Target) string {
+	if target.Wasm == nil {
+		return ""
+	}
+	return target.Wasm.Module
+}
+
+// runWasm runs target's module, filling in result, and returns how long the check took.
+func (p *WorkerPool) runWasm(ctx context.Context, target models.Target, result *models.CheckResult, fail func(category, msg string)) time.Duration {
+	name := target.Wasm.Module
+	compiled, ok := p.wasm.plugins.modules[name]
+	if !ok {
+		// Plugins are only loaded at startup, so a module added since needs a restart.
+		fail(models.ErrorCategoryWasm, fmt.Sprintf("module %q is not loaded", name))
+		return 0
+	}
+	timeout := p.wasm.timeout
+	if target.TimeoutBudgetMS > 0 {
+		timeout = time.Duration(target.TimeoutBudgetMS) * time.Millisecond
+	}
+	config := target.Wasm.Config
+	if config == nil {
+		config = map[string]string{}
+	}
+	input, _ := json.Marshal(config)
+
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	stdout, stderr := &limitedBuffer{max: maxExecStdout}, &limitedBuffer{max: maxExecStderr}
+	moduleConfig := wazero.NewModuleConfig().
+		WithName(""). // Anonymous, so any number of runs of a module can be in progress
+		WithArgs(name).
+		WithEnv("LINKWATCH_TARGET_ID", target.ID).
+		WithStdin(bytes.NewReader(input)).
+		WithStdout(stdout).
+		WithStderr(stderr).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithSysNanosleep().
+		WithRandSource(rand.Reader)
+	mod, err := p.wasm.plugins.runtime.InstantiateModule(runCtx, compiled, moduleConfig)
+	if mod != nil {
+		mod.Close(ctx)
+	}
+	latency := p.clock.Since(result.CheckedAt)
+
+	out := readOutput(stdout.Bytes())
+	if out.LatencyMS != nil && *out.LatencyMS >= 0 {
+		latency = time.Duration(*out.LatencyMS) * time.Millisecond
+	}
+	result.StatusCode = out.StatusCode
+
+	var exitErr *sys.ExitError
+	switch {
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		fail(models.ErrorCategoryTimeout, fmt.Sprintf("module timed out after %s", timeout))
+	case errors.As(err, &exitErr):
+		msg := out.Error
+		if msg == "" {
+			msg, _, _ = strings.Cut(strings.TrimSpace(stderr.String()), "\n")
+		}
+		if msg == "" {
+			msg = fmt.Sprintf("exit status %d", exitErr.ExitCode())
+		}
+		fail(models.ErrorCategoryWasm, msg)
+	case err != nil:
+		fail(models.ErrorCategoryWasm, fmt.Sprintf("failed to run module: %v", err))
+	case out.Outcome == models.OutcomeFailure:
+		msg := out.Error
+		if msg == "" {
+			msg = "module reported a failure"
+		}
+		fail(models.ErrorCategoryWasm, msg)
+	case out.Outcome == models.OutcomeWarning:
+		result.Outcome = models.OutcomeWarning
+	}
+	return latency
+}
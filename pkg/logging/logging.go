@@ -0,0 +1,144 @@
+// Package logging adds levels to the standard logger, set per component and changeable at
+// runtime, so routine messages can be kept out of the logs without losing them for debugging.
+package logging
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// Level is the severity of a log message. A logger writes messages at or above its level.
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{LevelDebug: "debug", LevelInfo: "info", LevelWarn: "warn", LevelError: "error"}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int32(l))
+}
+
+// ParseLevel parses a level name, ignoring case.
+func ParseLevel(s string) (Level, error) {
+	for l, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q, must be one of: debug, info, warn, error", s)
+}
+
+// Logger writes a component's messages to the standard logger, prefixed with their level
+// and the component's name.
+type Logger struct {
+	name  string
+	level atomic.Int32
+}
+
+// The components with their own level.
+var (
+	API     = register("api")
+	Checker = register("checker")
+	Metrics = register("metrics")
+	Notify  = register("notify")
+	Storage = register("storage")
+)
+
+var components = map[string]*Logger{}
+
+// register adds a component logging at info. It is only called while initializing the
+// package, so components is never written concurrently.
+func register(name string) *Logger {
+	l := &Logger{name: name}
+	l.level.Store(int32(LevelInfo))
+	components[name] = l
+	return l
+}
+
+// Component returns the named component's logger.
+func Component(name string) (*Logger, bool) {
+	l, ok := components[name]
+	return l, ok
+}
+
+// Names returns every component's name, sorted.
+func Names() []string {
+	return slices.Sorted(maps.Keys(components))
+}
+
+// Levels returns every component's current level, by name.
+func Levels() map[string]Level {
+	levels := make(map[string]Level, len(components))
+	for name, l := range components {
+		levels[name] = l.Level()
+	}
+	return levels
+}
+
+// SetAll sets every component's level.
+func SetAll(level Level) {
+	for _, l := range components {
+		l.SetLevel(level)
+	}
+}
+
+// ParseLevels parses "component=level" pairs, e.g. from LOG_LEVELS, checking that every
+// component and level exists.
+func ParseLevels(pairs []string) (map[string]Level, error) {
+	levels := make(map[string]Level, len(pairs))
+	for _, p := range pairs {
+		name, value, ok := strings.Cut(p, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid log level %q, must be component=level", p)
+		}
+		name = strings.TrimSpace(name)
+		if _, ok := components[name]; !ok {
+			return nil, fmt.Errorf("unknown log component %q, must be one of: %s", name, strings.Join(Names(), ", "))
+		}
+		level, err := ParseLevel(strings.TrimSpace(value))
+		if err != nil {
+			return nil, err
+		}
+		levels[name] = level
+	}
+	return levels, nil
+}
+
+// Name returns the component's name.
+func (l *Logger) Name() string { return l.name }
+
+// Level returns the lowest level the logger writes.
+func (l *Logger) Level() Level { return Level(l.level.Load()) }
+
+// SetLevel changes the lowest level the logger writes. It is safe to call while logging.
+func (l *Logger) SetLevel(level Level) { l.level.Store(int32(level)) }
+
+// Enabled reports whether messages at level are written, for callers that would otherwise
+// do work to build a message that is dropped.
+func (l *Logger) Enabled(level Level) bool { return level >= l.Level() }
+
+func (l *Logger) Debugf(format string, args ...any) { l.output(LevelDebug, format, args...) }
+func (l *Logger) Infof(format string, args ...any)  { l.output(LevelInfo, format, args...) }
+func (l *Logger) Warnf(format string, args ...any)  { l.output(LevelWarn, format, args...) }
+func (l *Logger) Errorf(format string, args ...any) { l.output(LevelError, format, args...) }
+
+func (l *Logger) output(level Level, format string, args ...any) {
+	if !l.Enabled(level) {
+		return
+	}
+	// Calldepth 3 attributes the message to whoever called Debugf and friends when the
+	// standard logger is set to print file names.
+	log.Default().Output(3, strings.ToUpper(level.String())+" "+l.name+": "+fmt.Sprintf(format, args...))
+}
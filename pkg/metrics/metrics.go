@@ -0,0 +1,69 @@
+// Package metrics defines the Recorder the checker reports to, with a StatsD implementation.
+package metrics
+
+import (
+	"strconv"
+	"time"
+)
+
+// Tag is a key/value dimension attached to a metric.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// T is shorthand for constructing a Tag.
+func T(key, value string) Tag {
+	return Tag{Key: key, Value: value}
+}
+
+// Recorder is the metrics abstraction used throughout the service. Exporters (e.g. StatsD)
+// implement it; components record against it without knowing where metrics end up.
+type Recorder interface {
+	// Count adds value to a counter.
+	Count(name string, value int64, tags ...Tag)
+	// Gauge sets a gauge to value.
+	Gauge(name string, value float64, tags ...Tag)
+	// Timing records a duration sample.
+	Timing(name string, d time.Duration, tags ...Tag)
+}
+
+// Nop discards all metrics. It is the default when no exporter is configured.
+type Nop struct{}
+
+func (Nop) Count(string, int64, ...Tag)          {}
+func (Nop) Gauge(string, float64, ...Tag)        {}
+func (Nop) Timing(string, time.Duration, ...Tag) {}
+
+// Multi fans metrics out to several recorders.
+type Multi []Recorder
+
+func (m Multi) Count(name string, value int64, tags ...Tag) {
+	for _, r := range m {
+		r.Count(name, value, tags...)
+	}
+}
+
+func (m Multi) Gauge(name string, value float64, tags ...Tag) {
+	for _, r := range m {
+		r.Gauge(name, value, tags...)
+	}
+}
+
+func (m Multi) Timing(name string, d time.Duration, tags ...Tag) {
+	for _, r := range m {
+		r.Timing(name, d, tags...)
+	}
+}
+
+// StatusClass buckets an HTTP status code for use as a low-cardinality tag ("2xx", "5xx", ...).
+// A nil status (no response) is reported as "error".
+func StatusClass(status *int) string {
+	if status == nil {
+		return "error"
+	}
+	if *status < 100 || *status > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(*status/100) + "xx"
+}
@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+)
+
+const (
+	// maxPacketSize keeps datagrams under the common 1500-byte MTU.
+	maxPacketSize = 1432
+	// flushInterval bounds how long a metric may sit in the buffer.
+	flushInterval = time.Second
+)
+
+// StatsD exports metrics over UDP in the DogStatsD line format
+// ("name:value|type|#tag:value,..."), which plain StatsD servers accept minus the tags.
+// Lines are buffered and sent in packets of up to maxPacketSize bytes.
+type StatsD struct {
+	conn     net.Conn
+	prefix   string
+	tags     []Tag
+	mu       sync.Mutex
+	buf      []byte
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// NewStatsD creates an exporter sending to addr ("host:port"). Every metric name is prefixed
+// with prefix and carries the given constant tags.
+func NewStatsD(addr, prefix string, tags []Tag) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial statsd: %w", err)
+	}
+	s := &StatsD{conn: conn, prefix: prefix, tags: tags, stopChan: make(chan struct{})}
+	s.wg.Add(1)
+	go s.flushLoop()
+	return s, nil
+}
+
+// ParseTags parses "key:value" strings (e.g. from STATSD_TAGS) into tags. Entries without a
+// colon become tags with an empty value.
+func ParseTags(raw []string) []Tag {
+	tags := make([]Tag, 0, len(raw))
+	for _, r := range raw {
+		key, value, _ := strings.Cut(r, ":")
+		tags = append(tags, Tag{Key: key, Value: value})
+	}
+	return tags
+}
+
+// Count adds value to a counter.
+func (s *StatsD) Count(name string, value int64, tags ...Tag) {
+	s.write(name, strconv.FormatInt(value, 10), "c", tags)
+}
+
+// Gauge sets a gauge to value.
+func (s *StatsD) Gauge(name string, value float64, tags ...Tag) {
+	s.write(name, strconv.FormatFloat(value, 'f', -1, 64), "g", tags)
+}
+
+// Timing records a duration sample in milliseconds.
+func (s *StatsD) Timing(name string, d time.Duration, tags ...Tag) {
+	s.write(name, strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64), "ms", tags)
+}
+
+// Close flushes buffered metrics and closes the connection.
+func (s *StatsD) Close() error {
+	s.stopOnce.Do(func() {
+		close(s.stopChan)
+		s.wg.Wait()
+	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushLocked()
+	return s.conn.Close()
+}
+
+func (s *StatsD) write(name, value, kind string, tags []Tag) {
+	var line strings.Builder
+	line.WriteString(sanitize(s.prefix + name))
+	line.WriteByte(':')
+	line.WriteString(value)
+	line.WriteByte('|')
+	line.WriteString(kind)
+	if len(s.tags)+len(tags) > 0 {
+		line.WriteString("|#")
+		for i, t := range append(append([]Tag(nil), s.tags...), tags...) {
+			if i > 0 {
+				line.WriteByte(',')
+			}
+			line.WriteString(sanitize(t.Key))
+			if t.Value != "" {
+				line.WriteByte(':')
+				line.WriteString(sanitize(t.Value))
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.buf) > 0 && len(s.buf)+1+line.Len() > maxPacketSize {
+		s.flushLocked()
+	}
+	if len(s.buf) > 0 {
+		s.buf = append(s.buf, '\n')
+	}
+	s.buf = append(s.buf, line.String()...)
+}
+
+func (s *StatsD) flushLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			s.flushLocked()
+			s.mu.Unlock()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+func (s *StatsD) flushLocked() {
+	if len(s.buf) == 0 {
+		return
+	}
+	if _, err := s.conn.Write(s.buf); err != nil {
+		logging.Metrics.Errorf("error sending metrics to statsd: %v", err)
+	}
+	s.buf = s.buf[:0]
+}
+
+// sanitize replaces characters that are reserved by the StatsD line format.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', ',', '#', '\n', ' ':
+			return '_'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,799 @@
+// Package models defines the targets, check results, and aggregates shared by the checker,
+// storage, and API.
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Target types supported by the checker.
+const (
+	TargetTypeHTTP      = "http"
+	TargetTypeHeartbeat = "heartbeat"
+	TargetTypeExec      = "exec" // Runs a command from the checker's exec directory
+	TargetTypeWasm      = "wasm" // Runs a WebAssembly module from the checker's plugins directory
+)
+
+// Target represents a URL to be monitored.
+// It contains both the original URL and its canonical form.
+type Target struct {
+	ID           string    `json:"id"`
+	URL          string    `json:"url"`
+	CanonicalURL string    `json:"-"` // Internal field, not exposed in API responses
+	Host         string    `json:"-"` // Internal field for the checker's per-host limiter
+	CreatedAt    time.Time `json:"created_at"`
+	Type         string    `json:"type"`
+
+	// Heartbeat targets are pinged by external systems instead of being checked over HTTP.
+	HeartbeatToken     string     `json:"heartbeat_token,omitempty"`
+	GracePeriodSeconds int64      `json:"grace_period_seconds,omitempty"`
+	LastPingAt         *time.Time `json:"last_ping_at,omitempty"`
+
+	// CaptureHeaders lists response headers recorded with each check result (canonical names).
+	CaptureHeaders []string `json:"capture_headers,omitempty"`
+
+	// StatusPolicy overrides which response status codes count as success, warning, or failure.
+	StatusPolicy *StatusPolicy `json:"status_policy,omitempty"`
+
+	// TimeoutBudgetMS caps the total time a check may take across all of its attempts,
+	// overriding the checker's budget. Zero uses the checker's budget.
+	TimeoutBudgetMS int64 `json:"timeout_budget_ms,omitempty"`
+
+	// LatencyThresholdMS is the latency at or above which a passing check is slow. Zero
+	// doesn't classify checks by latency.
+	LatencyThresholdMS int64 `json:"latency_threshold_ms,omitempty"`
+
+	// MinTLSVersion is the lowest TLS version (one of TLSVersions) the target may negotiate
+	// without an alert, overriding the checker's minimum. Empty uses the checker's minimum.
+	MinTLSVersion string `json:"min_tls_version,omitempty"`
+
+	// Metadata holds free-form labels, such as the owning team or a runbook URL.
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// ResultSampling thins the target's stored results once they are old; all are kept when nil.
+	ResultSampling *ResultSampling `json:"result_sampling,omitempty"`
+
+	// SnoozedUntil suspends the target's checks and alerts until then.
+	SnoozedUntil *time.Time `json:"snoozed_until,omitempty"`
+
+	// Exec is the command an exec target runs in place of a request.
+	Exec *ExecCheck `json:"exec,omitempty"`
+
+	// Wasm is the module a wasm target runs in place of a request.
+	Wasm *WasmCheck `json:"wasm,omitempty"`
+
+	// Dependencies are targets that must be up for this one's failures to count as its own.
+	Dependencies *Dependencies `json:"dependencies,omitempty"`
+
+	// Schedule limits checks to the hours the target is expected to be up; always when nil.
+	Schedule *CheckSchedule `json:"schedule,omitempty"`
+
+	// SecurityAudit records the target's security headers with each check; off when nil.
+	SecurityAudit *SecurityAudit `json:"security_audit,omitempty"`
+
+	// AssetCheck verifies the scripts, stylesheets, and images of HTML pages; off when nil.
+	AssetCheck *AssetCheck `json:"asset_check,omitempty"`
+
+	State *TargetState `json:"state,omitempty"` // Populated by the API from the latest check result
+}
+
+// Snoozed reports whether the target's checks and alerts are suspended at now.
+func (t *Target) Snoozed(now time.Time) bool {
+	return t.SnoozedUntil != nil && now.Before(*t.SnoozedUntil)
+}
+
+// ExecCheck is the command of an exec target. The command names an executable in the
+// checker's exec directory, so the API can't run arbitrary programs.
+type ExecCheck struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+}
+
+// WasmCheck is the module of a wasm target. The module names a file in the checker's plugins
+// directory, and Config is passed to it on each run.
+type WasmCheck struct {
+	Module string            `json:"module"`
+	Config map[string]string `json:"config,omitempty"`
+}
+
+// SecurityHeaders are the response headers every security audit records.
+var SecurityHeaders = []string{
+	"Strict-Transport-Security",
+	"Content-Security-Policy",
+	"X-Content-Type-Options",
+	"X-Frame-Options",
+	"Referrer-Policy",
+	"Permissions-Policy",
+}
+
+// SecurityAudit configures a target's security header audit. Required headers, canonical
+// names that may go beyond SecurityHeaders, raise an alert when a check finds them missing.
+type SecurityAudit struct {
+	Required []string `json:"required,omitempty"`
+}
+
+// Headers returns the headers the audit records: SecurityHeaders followed by any other
+// required ones.
+func (a *SecurityAudit) Headers() []string {
+	headers := slices.Clone(SecurityHeaders)
+	for _, name := range a.Required {
+		if !slices.Contains(headers, name) {
+			headers = append(headers, name)
+		}
+	}
+	return headers
+}
+
+// SecurityReport is what a security audit found on a check's response.
+type SecurityReport struct {
+	Headers map[string]string `json:"headers"`           // Audited headers that were present, with their values
+	Missing []string          `json:"missing,omitempty"` // Required headers that weren't
+}
+
+// TLSVersions are the TLS versions a minimum can be set to, oldest first.
+var TLSVersions = []string{"1.0", "1.1", "1.2", "1.3"}
+
+// TLSInfo is the TLS connection a check's final response was received over.
+type TLSInfo struct {
+	Version     string `json:"version"`      // e.g. "TLS 1.3"
+	CipherSuite string `json:"cipher_suite"` // e.g. "TLS_AES_128_GCM_SHA256"
+}
+
+// AssetCheck configures the asset check of an HTML target. MaxAssets caps how many assets
+// are requested per check, 50 when zero; every asset is still checked for mixed content.
+type AssetCheck struct {
+	MaxAssets int `json:"max_assets,omitempty"`
+}
+
+// Asset types found on a page, and the problems found with them.
+const (
+	AssetTypeScript     = "script"
+	AssetTypeStylesheet = "stylesheet"
+	AssetTypeImage      = "image"
+
+	AssetProblemMixedContent = "mixed_content" // Loaded over plain HTTP by an HTTPS page
+	AssetProblemBroken       = "broken"        // Errored or responded with a 4xx/5xx status
+)
+
+// AssetReport is what an asset check found on a page.
+type AssetReport struct {
+	Found    int            `json:"found"`   // Distinct assets referenced by the page
+	Checked  int            `json:"checked"` // Assets requested, up to the target's MaxAssets
+	Findings []AssetFinding `json:"findings,omitempty"`
+}
+
+// AssetFinding is a problem with one of a page's assets.
+type AssetFinding struct {
+	URL        string  `json:"url"`
+	Type       string  `json:"type"`
+	Problem    string  `json:"problem"`
+	StatusCode *int    `json:"status_code,omitempty"`
+	Error      *string `json:"error,omitempty"`
+}
+
+// OnSchedule reports whether the target is expected to be up, and so checked, at now.
+func (t *Target) OnSchedule(now time.Time) bool {
+	return t.Schedule == nil || t.Schedule.Active(now)
+}
+
+// CheckSchedule limits a target to the hours it is expected to be up, e.g. an internal tool
+// used 9 to 5 on weekdays. Outside its windows the target isn't checked and its heartbeat
+// deadlines don't fire, so failures only count while the target is meant to be up.
+type CheckSchedule struct {
+	Timezone string           `json:"timezone,omitempty"` // IANA zone of the windows; UTC when empty
+	Windows  []ScheduleWindow `json:"windows"`
+}
+
+// ScheduleWindow is a daily time range on some days of the week. A window whose end is not
+// after its start runs past midnight into the next day, and belongs to the day it starts on.
+type ScheduleWindow struct {
+	Days  []string `json:"days,omitempty"` // ScheduleDays; every day when empty
+	Start string   `json:"start"`          // "HH:MM", inclusive
+	End   string   `json:"end"`            // "HH:MM", exclusive; "24:00" is midnight at the end of the day
+}
+
+// ScheduleDays are the day names of schedule windows, indexed by time.Weekday.
+var ScheduleDays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// locations caches the time zones of schedules, which are evaluated every scheduling pass.
+var locations sync.Map
+
+// Active reports whether t falls within one of the schedule's windows. A schedule whose time
+// zone can't be loaded is always active, so a bad zone never silently stops checks.
+func (s *CheckSchedule) Active(t time.Time) bool {
+	loc := time.UTC
+	if s.Timezone != "" {
+		if cached, ok := locations.Load(s.Timezone); ok {
+			loc = cached.(*time.Location)
+		} else if l, err := time.LoadLocation(s.Timezone); err == nil {
+			locations.Store(s.Timezone, l)
+			loc = l
+		} else {
+			return true
+		}
+	}
+	t = t.In(loc)
+	minute := t.Hour()*60 + t.Minute()
+	today, yesterday := t.Weekday(), (t.Weekday()+6)%7
+	for _, w := range s.Windows {
+		start, okStart := clockMinutes(w.Start)
+		end, okEnd := clockMinutes(w.End)
+		if !okStart || !okEnd {
+			continue
+		}
+		if start < end {
+			if w.onDay(today) && minute >= start && minute < end {
+				return true
+			}
+			continue
+		}
+		if (w.onDay(today) && minute >= start) || (w.onDay(yesterday) && minute < end) {
+			return true
+		}
+	}
+	return false
+}
+
+// onDay reports whether the window starts on day.
+func (w ScheduleWindow) onDay(day time.Weekday) bool {
+	return len(w.Days) == 0 || slices.Contains(w.Days, ScheduleDays[day])
+}
+
+// clockMinutes parses an "HH:MM" time of day, from "00:00" to "24:00", into minutes since midnight.
+func clockMinutes(s string) (int, bool) {
+	if s == "24:00" {
+		return 24 * 60, true
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// Dependencies ties a target to others it can't be up without, e.g. an app endpoint to the
+// load balancer in front of it. While the latest result of any of them failed, the target's
+// failures are marked OutcomeSuppressed and raise no alerts, so an outage is reported once,
+// for the target that caused it.
+type Dependencies struct {
+	TargetIDs []string `json:"target_ids"`
+	// Skip leaves the target unchecked while a dependency is down, recording a suppressed
+	// result instead of sending a check that can only fail.
+	Skip bool `json:"skip,omitempty"`
+}
+
+// Target statuses derived from the latest check result.
+const (
+	TargetStatusUp      = "up"
+	TargetStatusDown    = "down"
+	TargetStatusWarning = "warning" // Up, but degraded: the latest result is a warning, partial, or slow
+	TargetStatusUnknown = "unknown"
+)
+
+// Check outcomes assigned by a StatusPolicy, or by the checker to a partial or slow response.
+const (
+	OutcomeSuccess = "success"
+	OutcomeWarning = "warning"
+	OutcomeFailure = "failure"
+	OutcomePartial = "partial" // The body ended early; degraded, but still up
+	OutcomeSlow    = "slow"    // Passed, but at or above the target's latency threshold
+
+	// OutcomeSuppressed marks a failure while one of the target's dependencies was down. It
+	// still counts as a failure, but raises no alert.
+	OutcomeSuppressed = "suppressed_by_dependency"
+)
+
+// StatusPolicy classifies response status codes for a target. Each list holds exact codes
+// ("401") or classes ("4xx"); an exact code takes precedence over a class, and codes matching
+// neither keep the default classification (2xx and 3xx succeed). A warning still counts as up.
+type StatusPolicy struct {
+	Success []string `json:"success,omitempty"`
+	Warning []string `json:"warning,omitempty"`
+	Failure []string `json:"failure,omitempty"`
+}
+
+// Classify returns the outcome of a response with the given status code.
+func (p *StatusPolicy) Classify(code int) string {
+	exact := strconv.Itoa(code)
+	for _, key := range []string{exact, exact[:1] + "xx"} {
+		switch {
+		case slices.Contains(p.Success, key):
+			return OutcomeSuccess
+		case slices.Contains(p.Warning, key):
+			return OutcomeWarning
+		case slices.Contains(p.Failure, key):
+			return OutcomeFailure
+		}
+	}
+	if code >= 200 && code < 400 {
+		return OutcomeSuccess
+	}
+	return OutcomeFailure
+}
+
+// DefaultSamplingWindow is how long results are all kept when a ResultSampling doesn't say.
+const DefaultSamplingWindow = 24 * time.Hour
+
+// ResultSampling keeps every check result of a target for KeepAllSeconds (24 hours when
+// zero), then one in every KeepOneIn, so targets checked every few seconds don't fill the
+// database. Results that changed the target's status are always kept.
+type ResultSampling struct {
+	KeepOneIn      int   `json:"keep_one_in"`
+	KeepAllSeconds int64 `json:"keep_all_seconds,omitempty"`
+}
+
+// Window returns how long results are all kept.
+func (p *ResultSampling) Window() time.Duration {
+	if p.KeepAllSeconds == 0 {
+		return DefaultSamplingWindow
+	}
+	return time.Duration(p.KeepAllSeconds) * time.Second
+}
+
+// TargetState summarizes a target's latest check result.
+type TargetState struct {
+	Status         string     `json:"status"`
+	LastCheckedAt  *time.Time `json:"last_checked_at"`
+	LastStatusCode *int       `json:"last_status_code"`
+	LastLatencyMS  *int64     `json:"last_latency_ms"`
+	LastError      *string    `json:"last_error"`
+}
+
+// StateFromResult derives a target's state from its latest result, or unknown when it has none.
+func StateFromResult(latest *CheckResult) TargetState {
+	if latest == nil {
+		return TargetState{Status: TargetStatusUnknown}
+	}
+	state := TargetState{
+		Status:         TargetStatusDown,
+		LastCheckedAt:  &latest.CheckedAt,
+		LastStatusCode: latest.StatusCode,
+		LastLatencyMS:  &latest.LatencyMS,
+		LastError:      latest.Error,
+	}
+	switch {
+	case latest.Succeeded() && (latest.Outcome == OutcomeWarning || latest.Outcome == OutcomePartial || latest.Outcome == OutcomeSlow):
+		state.Status = TargetStatusWarning
+	case latest.Succeeded():
+		state.Status = TargetStatusUp
+	}
+	return state
+}
+
+// TargetAlias is a URL submitted for a target. Different forms of a URL canonicalize to the
+// same target, whose URL is the first one submitted; aliases keep the others.
+type TargetAlias struct {
+	URL         string    `json:"url"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+}
+
+// StateTransition records a target's status changing, together with the check result that
+// changed it. A target starts out unknown, so its first result always records a transition.
+type StateTransition struct {
+	TargetID   string    `json:"-"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	At         time.Time `json:"at"`
+	ResultID   string    `json:"result_id"`
+	Cause      string    `json:"cause,omitempty"` // The result's FailureCause; set on transitions to down
+}
+
+// Outage is a period a target spent down.
+type Outage struct {
+	Start           time.Time  `json:"start"`
+	End             *time.Time `json:"end"` // Nil while the outage is ongoing
+	DurationSeconds int64      `json:"duration_seconds"`
+	Cause           string     `json:"cause"`
+}
+
+// Outages returns the periods within [since, until) that a target spent down, oldest first,
+// from its transitions in that range (oldest first) and the state it was in at since. Outages
+// that started before since or are still ongoing at until are clipped to the range; an ongoing
+// outage has no End.
+func Outages(initial StateTransition, transitions []StateTransition, since, until time.Time) []Outage {
+	outages := []Outage{}
+	var open *Outage
+	if initial.ToStatus == TargetStatusDown {
+		open = &Outage{Start: since, Cause: initial.Cause}
+	}
+	for _, t := range transitions {
+		switch {
+		case t.ToStatus == TargetStatusDown && open == nil:
+			open = &Outage{Start: t.At, Cause: t.Cause}
+		case t.ToStatus != TargetStatusDown && open != nil:
+			end := t.At
+			open.End = &end
+			open.DurationSeconds = int64(end.Sub(open.Start).Seconds())
+			outages = append(outages, *open)
+			open = nil
+		}
+	}
+	if open != nil {
+		open.DurationSeconds = int64(until.Sub(open.Start).Seconds())
+		outages = append(outages, *open)
+	}
+	return outages
+}
+
+// CheckResult stores the outcome of a single HTTP check for a Target.
+type CheckResult struct {
+	ID         string    `json:"id"`
+	TargetID   string    `json:"-"` // Not exposed in the results list API
+	CheckedAt  time.Time `json:"checked_at"`
+	StatusCode *int      `json:"status_code"` // Pointer to allow for null on network errors
+	LatencyMS  int64     `json:"latency_ms"`
+	LatencyUS  int64     `json:"latency_us"` // LatencyMS in microseconds, for endpoints faster than a millisecond
+	Error      *string   `json:"error"`      // Pointer to allow for null on success
+
+	TotalDurationMS int64 `json:"total_duration_ms"` // From the first attempt's start to the final attempt's end, retries and backoff included
+	BytesDownloaded int64 `json:"bytes_downloaded"`  // Response body bytes read across every attempt
+
+	Timings *Timings `json:"timings,omitempty"` // Phase durations of the final attempt; unset for heartbeat pings
+	TLS     *TLSInfo `json:"tls,omitempty"`     // Set when the final response came over TLS
+
+	ErrorCategory string `json:"error_category,omitempty"` // One of the ErrorCategory values; set with Error
+	Outcome       string `json:"outcome,omitempty"`        // Set when the target has a StatusPolicy or latency threshold, or the response was partial
+
+	Headers map[string]string `json:"headers,omitempty"` // Captured response headers, keyed by canonical name
+
+	Security *SecurityReport `json:"security,omitempty"` // Set when the target has a security audit and a response was received
+	Assets   *AssetReport    `json:"assets,omitempty"`   // Set when the target has an asset check and returned an HTML page
+
+	BodyTruncated bool `json:"body_truncated,omitempty"` // The body exceeded the checker's read limit
+	Partial       bool `json:"partial,omitempty"`        // The body ended before it was complete, e.g. a connection reset mid-body
+
+	CachedDNSFailure bool `json:"cached_dns_failure,omitempty"` // Failed from the checker's DNS failure cache without a lookup
+
+	Attempts []CheckAttempt `json:"attempts,omitempty"` // Every attempt, oldest first; only set when the check was retried
+
+	StartedAt   *time.Time `json:"started_at,omitempty"`   // When the first attempt started; CheckedAt is the final one's
+	CompletedAt *time.Time `json:"completed_at,omitempty"` // When the check finished, after any retries
+	Seq         int64      `json:"seq,omitempty"`          // Order the target's results were stored in, assigned by the store
+}
+
+// Error categories recorded on failed checks, so failures can be grouped without parsing messages.
+const (
+	ErrorCategoryTimeout           = "timeout"
+	ErrorCategoryDNS               = "dns"
+	ErrorCategoryConnectionRefused = "connection_refused"
+	ErrorCategoryTLS               = "tls"
+	ErrorCategoryTooManyRedirects  = "too_many_redirects"
+	ErrorCategoryRedirectLoop      = "redirect_loop"
+	ErrorCategoryHeartbeatMissed   = "heartbeat_missed"
+	ErrorCategoryInternalPanic     = "internal_panic"
+	ErrorCategoryDependency        = "dependency" // Not checked because a dependency was down
+	ErrorCategoryExec              = "exec"       // An exec check's command failed or couldn't run
+	ErrorCategoryWasm              = "wasm"       // A wasm check's module failed or couldn't run
+	ErrorCategoryNetwork           = "network"    // Any other transport error
+)
+
+// ResultID derives a check result's ID from its target, check time, and attempt count, so a
+// result written twice (e.g. replayed or retried by an external agent) gets the same ID and
+// is stored once.
+func ResultID(targetID string, checkedAt time.Time, attempts int) string {
+	sum := sha256.Sum256([]byte(targetID + "|" + checkedAt.UTC().Format(time.RFC3339Nano) + "|" + strconv.Itoa(attempts)))
+	return "cr_" + hex.EncodeToString(sum[:12])
+}
+
+// CheckAttempt records a single HTTP attempt within a check, including retries.
+type CheckAttempt struct {
+	Attempt    int       `json:"attempt"` // 1-based
+	StartedAt  time.Time `json:"started_at"`
+	StatusCode *int      `json:"status_code"`
+	LatencyMS  int64     `json:"latency_ms"`
+	LatencyUS  int64     `json:"latency_us"`
+	Error      *string   `json:"error"`
+
+	ErrorCategory string   `json:"error_category,omitempty"`
+	Timings       *Timings `json:"timings,omitempty"`
+}
+
+// Timings holds the duration of each phase of an HTTP check, in microseconds. Phases the
+// check didn't go through (e.g. DNS and connect on a reused connection, TLS over plain HTTP)
+// are zero, and phases repeated across redirects are summed.
+type Timings struct {
+	DNSUS       int64 `json:"dns_us,omitempty"`
+	ConnectUS   int64 `json:"connect_us,omitempty"`
+	TLSUS       int64 `json:"tls_us,omitempty"`
+	FirstByteUS int64 `json:"first_byte_us,omitempty"` // From sending the request to the final response's first byte
+}
+
+// FailureCause summarizes why a failed check failed: OutcomeSuppressed when a dependency was
+// down, else its error category or, when a response was received, its status code (e.g.
+// "status_503").
+func (r CheckResult) FailureCause() string {
+	switch {
+	case r.Outcome == OutcomeSuppressed:
+		return OutcomeSuppressed
+	case r.ErrorCategory != "":
+		return r.ErrorCategory
+	case r.Error != nil || r.StatusCode == nil:
+		return ErrorCategoryNetwork
+	default:
+		return "status_" + strconv.Itoa(*r.StatusCode)
+	}
+}
+
+// Succeeded reports whether the check passed: no error and, when a response was received,
+// a status its target's policy doesn't classify as a failure (by default, 2xx or 3xx). It
+// mirrors the storage layer's success classification.
+func (r CheckResult) Succeeded() bool {
+	if r.Error != nil {
+		return false
+	}
+	if r.Outcome != "" {
+		return r.Outcome != OutcomeFailure && r.Outcome != OutcomeSuppressed
+	}
+	return r.StatusCode == nil || (*r.StatusCode >= 200 && *r.StatusCode < 400)
+}
+
+// TimeseriesBucket holds aggregated check results for a single time bucket.
+type TimeseriesBucket struct {
+	BucketStart  time.Time `json:"bucket_start"`
+	AvgLatencyMS float64   `json:"avg_latency_ms"`
+	MaxLatencyMS int64     `json:"max_latency_ms"`
+	SuccessCount int64     `json:"success_count"`
+	FailureCount int64     `json:"failure_count"`
+	Synthetic    bool      `json:"synthetic,omitempty"` // Carried forward over a gap in on-change storage mode
+}
+
+// StatusBreakdown counts a target's check results over a time window by what they returned.
+type StatusBreakdown struct {
+	Total           int64            `json:"total"`
+	StatusClasses   map[string]int64 `json:"status_classes"`   // "1xx" to "5xx", for checks that got a response
+	ErrorCategories map[string]int64 `json:"error_categories"` // For checks that failed with an error; "uncategorized" for results that predate categories
+}
+
+// LatencyPercentiles are nearest-rank percentiles of check latency, in milliseconds.
+type LatencyPercentiles struct {
+	P50 int64 `json:"p50"`
+	P90 int64 `json:"p90"`
+	P95 int64 `json:"p95"`
+	P99 int64 `json:"p99"`
+}
+
+// ResultSummary summarizes a target's check results over a time window.
+type ResultSummary struct {
+	CheckCount      int64               `json:"check_count"`
+	SuccessCount    int64               `json:"success_count"`
+	SuccessRate     *float64            `json:"success_rate"` // Fraction of checks that succeeded; null without checks
+	AvgLatencyMS    float64             `json:"avg_latency_ms"`
+	LatencyMS       *LatencyPercentiles `json:"latency_ms"`       // Null without checks
+	ErrorCategories map[string]int64    `json:"error_categories"` // As in StatusBreakdown
+}
+
+// TargetStats holds aggregated check statistics for a single target over a time window.
+type TargetStats struct {
+	TargetID      string  `json:"target_id"`
+	URL           string  `json:"url"`
+	CheckCount    int64   `json:"check_count"`
+	SuccessCount  int64   `json:"success_count"`
+	FailureCount  int64   `json:"failure_count"`
+	IncidentCount int64   `json:"incident_count"` // Runs of consecutive failed checks
+	AvgLatencyMS  float64 `json:"avg_latency_ms"`
+	MaxLatencyMS  int64   `json:"max_latency_ms"`
+}
+
+// BandwidthUsage totals the response body bytes downloaded by stored check results on one UTC
+// day, for a single target or for every target on a host.
+type BandwidthUsage struct {
+	Day             string  `json:"day"` // YYYY-MM-DD
+	Host            string  `json:"host"`
+	TargetID        string  `json:"target_id,omitempty"` // Set when grouped by target
+	URL             string  `json:"url,omitempty"`       // Set when grouped by target
+	CheckCount      int64   `json:"check_count"`
+	BytesDownloaded int64   `json:"bytes_downloaded"`
+	AvgBytes        float64 `json:"avg_bytes"` // Per check
+}
+
+// TargetSnapshot is a target's state at a past instant, as reconstructed from its state
+// transitions and the results stored up to then.
+type TargetSnapshot struct {
+	TargetID    string      `json:"target_id"`
+	URL         string      `json:"url"`
+	Host        string      `json:"host"`
+	State       TargetState `json:"state"`
+	StatusSince *time.Time  `json:"status_since"`    // When the target entered the status; nil while unknown
+	Cause       string      `json:"cause,omitempty"` // What caused a down status
+}
+
+// HostStats describes the targets on one host: their check statistics over a time window and
+// the checker's current load on the host.
+type HostStats struct {
+	Host         string   `json:"host"`
+	TargetCount  int64    `json:"target_count"`
+	CheckCount   int64    `json:"check_count"`
+	SuccessRate  *float64 `json:"success_rate"` // Fraction of checks that succeeded; null without checks
+	AvgLatencyMS float64  `json:"avg_latency_ms"`
+	InFlight     int      `json:"in_flight"` // Checks running now
+	Queued       int      `json:"queued"`    // Targets waiting for a worker
+	Paused       bool     `json:"paused"`
+}
+
+// HostPause records that checks for every target on a host are suspended.
+type HostPause struct {
+	Host     string    `json:"host"`
+	PausedAt time.Time `json:"paused_at"`
+}
+
+// ClientCertificate is the certificate checks of a host present when the server asks for one,
+// for mutual TLS. The key is never returned by the API, and is stored encrypted.
+type ClientCertificate struct {
+	Host      string    `json:"host"`
+	CertPEM   string    `json:"cert_pem"` // The certificate chain, leaf first
+	KeyPEM    string    `json:"-"`
+	Subject   string    `json:"subject"`   // The leaf's subject
+	NotAfter  time.Time `json:"not_after"` // When the leaf expires
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Domain is the registration of a registrable domain with targets, as last looked up over
+// RDAP. A failed lookup keeps the registration found before and records the error.
+type Domain struct {
+	Name      string     `json:"domain"`
+	Registrar string     `json:"registrar,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Targets   int        `json:"targets"` // Targets on the domain or its subdomains
+	CheckedAt time.Time  `json:"checked_at"`
+	Error     *string    `json:"error"`
+	Expiring  bool       `json:"expiring"` // Expires within the warning threshold; set when listed
+
+	AlertedExpiry *time.Time `json:"-"` // The expiry last alerted on, so each is alerted once
+}
+
+// HostLoad is the checker's current load on a host.
+type HostLoad struct {
+	InFlight int
+	Queued   int
+}
+
+// QueueStats describes the checker's job queue.
+type QueueStats struct {
+	Capacity int   `json:"capacity"`
+	Depth    int   `json:"depth"`
+	Dropped  int64 `json:"dropped"` // Targets skipped because the queue was full, since startup
+	// OldestAgeMS is how long the longest-waiting target has been queued; 0 when the queue is
+	// empty or, like the Redis queue, can't tell.
+	OldestAgeMS int64 `json:"oldest_age_ms"`
+}
+
+// WorkerStats describes the checker's worker pool.
+type WorkerStats struct {
+	Size   int `json:"size"`   // Configured number of workers
+	Active int `json:"active"` // Running workers, including removed ones finishing a check
+}
+
+// RequestError is an internal error returned to an API client, looked up by request ID.
+type RequestError struct {
+	RequestID string    `json:"request_id"`
+	At        time.Time `json:"at"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Message   string    `json:"message"` // What the handler was doing, e.g. "list results error"
+	Error     string    `json:"error"`   // The underlying error
+}
+
+// Database health states.
+const (
+	DatabaseOK          = "ok"
+	DatabaseReadOnly    = "read_only"   // Reachable, but writes fail
+	DatabaseUnavailable = "unavailable" // Queries fail
+)
+
+// DatabaseHealth describes one database connection used by the store.
+type DatabaseHealth struct {
+	Name   string        `json:"name"` // e.g. "primary", "replica"
+	Status string        `json:"status"`
+	Error  string        `json:"error,omitempty"`
+	Pool   *DatabasePool `json:"pool,omitempty"`
+}
+
+// DatabasePool describes a database's connection pool.
+type DatabasePool struct {
+	MaxOpen int   `json:"max_open"` // Zero when unlimited
+	Open    int   `json:"open"`
+	InUse   int   `json:"in_use"`
+	Idle    int   `json:"idle"`
+	Waits   int64 `json:"waits"`   // Queries that waited for a free connection, since startup
+	WaitMS  int64 `json:"wait_ms"` // Total time they waited
+}
+
+// Database maintenance operations.
+const (
+	MaintenanceVacuum            = "vacuum"             // Rebuild the database file, returning free pages to the filesystem
+	MaintenanceIncrementalVacuum = "incremental_vacuum" // Return free pages without a rebuild; needs auto_vacuum=incremental
+	MaintenanceAnalyze           = "analyze"            // Refresh the statistics the query planner uses
+)
+
+// DatabaseMaintenance is the result of a database maintenance operation. Sizes are in bytes.
+type DatabaseMaintenance struct {
+	Operation  string `json:"operation"`
+	SizeBefore int64  `json:"size_before"`
+	SizeAfter  int64  `json:"size_after"`
+	FreeBefore int64  `json:"free_before"` // Free pages, reclaimable by a vacuum
+	FreeAfter  int64  `json:"free_after"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Checker health states.
+const (
+	CheckerOK         = "ok"
+	CheckerStopped    = "stopped"    // Not started yet, or shut down
+	CheckerRestarting = "restarting" // The scheduling loop panicked and is about to start again
+)
+
+// CheckerHealth describes the background checker's scheduling loop.
+type CheckerHealth struct {
+	Status      string     `json:"status"`
+	Role        string     `json:"role"`
+	Restarts    int        `json:"restarts"`             // Times the scheduling loop was restarted after a panic
+	LastPanic   string     `json:"last_panic,omitempty"` // The value of the latest panic
+	LastPanicAt *time.Time `json:"last_panic_at,omitempty"`
+	LastPassAt  *time.Time `json:"last_pass_at,omitempty"` // When the scheduler last went through the targets
+}
+
+// System event types, recorded for GET /v1/events.
+const (
+	SystemEventServiceStarted         = "service.started"
+	SystemEventServiceStopping        = "service.stopping"
+	SystemEventCheckerStarted         = "checker.started"
+	SystemEventCheckerStopped         = "checker.stopped"
+	SystemEventCheckerRestarted       = "checker.restarted" // The scheduling loop panicked and was restarted
+	SystemEventMigrationApplied       = "migration.applied"
+	SystemEventQueueOverflow          = "queue.overflow"           // Scheduled checks were dropped because the queue was full
+	SystemEventQueueThresholdExceeded = "queue.threshold_exceeded" // Scheduler lag, queue age, or saturation passed its threshold
+	SystemEventQueueThresholdCleared  = "queue.threshold_cleared"
+	SystemEventDatabaseDegraded       = "database.degraded"
+	SystemEventDatabaseRestored       = "database.restored"
+)
+
+// SystemEvent records something the service itself did or ran into, as opposed to a
+// target's checks.
+type SystemEvent struct {
+	ID      string            `json:"id"`
+	Type    string            `json:"type"`
+	At      time.Time         `json:"at"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// Startup phases, in the order a starting process goes through them.
+const (
+	StartupMigrating       = "migrating"        // Opening the database and applying migrations
+	StartupWarmingCache    = "warming_cache"    // Loading the first targets and their states
+	StartupStartingChecker = "starting_checker" // Recovering jobs and starting the checker
+	StartupReady           = "ready"
+)
+
+// Background job states. Done, failed, and cancelled are terminal.
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusDone      = "done"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+// Job tracks a long-running background operation such as a crawl or discovery run.
+type Job struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Status     string          `json:"status"`
+	Progress   int             `json:"progress"` // Percent complete, 0-100
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      *string         `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// Finished reports whether the job has reached a terminal state.
+func (j *Job) Finished() bool {
+	return j.Status == JobStatusDone || j.Status == JobStatusFailed || j.Status == JobStatusCancelled
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/urlutil"
+)
+
+// NewHTTPTarget canonicalizes a raw URL and builds a new HTTP target for it, ready to be
+// passed to a store's CreateTarget.
+func NewHTTPTarget(rawURL string) (*Target, error) {
+	canonicalURL, err := urlutil.Canonicalize(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	parsedURL, _ := url.Parse(canonicalURL)
+	return &Target{
+		ID:           newID("t_"),
+		URL:          rawURL,
+		CanonicalURL: canonicalURL,
+		Host:         parsedURL.Hostname(),
+		CreatedAt:    time.Now().UTC(),
+		Type:         TargetTypeHTTP,
+	}, nil
+}
+
+func newID(prefix string) string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return prefix + time.Now().UTC().Format("20060102150405")
+	}
+	return prefix + hex.EncodeToString(b)
+}
+
+// NewExecTarget builds a new exec target running check, ready to be passed to a store's
+// CreateTarget. Its URL is the command line, which also identifies duplicates.
+func NewExecTarget(check ExecCheck) *Target {
+	line := "exec:" + check.Command
+	for _, arg := range check.Args {
+		line += " " + quoteArg(arg)
+	}
+	return &Target{
+		ID:           newID("t_"),
+		URL:          line,
+		CanonicalURL: line,
+		CreatedAt:    time.Now().UTC(),
+		Type:         TargetTypeExec,
+		Exec:         &ExecCheck{Command: check.Command, Args: check.Args},
+	}
+}
+
+// NewWasmTarget builds a new wasm target running check, ready to be passed to a store's
+// CreateTarget. Its URL is the module followed by its config in key order, which also
+// identifies duplicates.
+func NewWasmTarget(check WasmCheck) *Target {
+	line := "wasm:" + check.Module
+	for _, key := range slices.Sorted(maps.Keys(check.Config)) {
+		line += " " + quoteArg(key) + "=" + quoteArg(check.Config[key])
+	}
+	return &Target{
+		ID:           newID("t_"),
+		URL:          line,
+		CanonicalURL: line,
+		CreatedAt:    time.Now().UTC(),
+		Type:         TargetTypeWasm,
+		Wasm:         &WasmCheck{Module: check.Module, Config: check.Config},
+	}
+}
+
+// quoteArg quotes arg for a target's command line when it is empty or would be ambiguous.
+func quoteArg(arg string) string {
+	if arg == "" || strings.ContainsAny(arg, " \t\n\"'\\") {
+		return strconv.Quote(arg)
+	}
+	return arg
+}
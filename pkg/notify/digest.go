@@ -0,0 +1,144 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+)
+
+// Alert delivery modes, as selected by ALERT_MODE.
+const (
+	AlertImmediate = "immediate" // Every event is delivered as it is raised
+	AlertDigest    = "digest"    // Events are batched into one Digest per window
+)
+
+// EventDigest is the Type of a Digest delivery.
+const EventDigest = "digest"
+
+// Digest summarizes the alert events raised within a window. Down and Up hold the last event
+// of each target whose last event was target.down or target.up, and Events every event,
+// oldest first.
+type Digest struct {
+	Type   string    `json:"type"`
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"`
+	Down   []Event   `json:"down"`
+	Up     []Event   `json:"up"`
+	Events []Event   `json:"events"`
+}
+
+// DigestSender delivers digests.
+type DigestSender interface {
+	SendDigest(ctx context.Context, digest Digest) error
+}
+
+// SendDigest posts the digest to the webhook URL.
+func (n *WebhookNotifier) SendDigest(ctx context.Context, digest Digest) error {
+	body, err := json.Marshal(digest)
+	if err != nil {
+		return fmt.Errorf("failed to encode digest: %w", err)
+	}
+	return postJSON(ctx, n.client, n.url, n.secret, body)
+}
+
+// DigestNotifier collects alert events and delivers those raised within each window as one
+// Digest, so a large outage produces one notification instead of one per target.
+type DigestNotifier struct {
+	window   time.Duration
+	senders  []DigestSender
+	timeout  time.Duration
+	stopChan chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	pending []Event
+	since   time.Time // Start of the current window
+}
+
+// NewDigestNotifier creates a notifier delivering a digest to every sender once per window
+// in which events were raised. Each delivery may take up to timeout.
+func NewDigestNotifier(window, timeout time.Duration, senders ...DigestSender) *DigestNotifier {
+	return &DigestNotifier{
+		window:   window,
+		senders:  senders,
+		timeout:  timeout,
+		stopChan: make(chan struct{}),
+		since:    time.Now().UTC(),
+	}
+}
+
+// Notify adds the event to the current window's digest.
+func (n *DigestNotifier) Notify(ctx context.Context, event Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.pending = append(n.pending, event)
+	return nil
+}
+
+// Start begins delivering a digest at the end of every window.
+func (n *DigestNotifier) Start() {
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		ticker := time.NewTicker(n.window)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				n.flush()
+			case <-n.stopChan:
+				n.flush()
+				return
+			}
+		}
+	}()
+}
+
+// Stop delivers what has been collected so far and stops the delivery loop.
+func (n *DigestNotifier) Stop() {
+	n.stopOnce.Do(func() { close(n.stopChan) })
+	n.wg.Wait()
+}
+
+// flush delivers the pending events, if any, and starts the next window.
+func (n *DigestNotifier) flush() {
+	now := time.Now().UTC()
+	n.mu.Lock()
+	events, since := n.pending, n.since
+	n.pending, n.since = nil, now
+	n.mu.Unlock()
+	if len(events) == 0 {
+		return
+	}
+
+	digest := Digest{Type: EventDigest, Since: since, Until: now, Down: []Event{}, Up: []Event{}, Events: events}
+	last := make(map[string]Event)
+	var order []string
+	for _, e := range events {
+		if _, ok := last[e.TargetID]; !ok {
+			order = append(order, e.TargetID)
+		}
+		last[e.TargetID] = e
+	}
+	for _, id := range order {
+		switch e := last[id]; e.Type {
+		case EventTargetDown:
+			digest.Down = append(digest.Down, e)
+		case EventTargetUp:
+			digest.Up = append(digest.Up, e)
+		}
+	}
+
+	for _, s := range n.senders {
+		ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+		if err := s.SendDigest(ctx, digest); err != nil {
+			logging.Notify.Errorf("error delivering digest of %d alerts: %v", len(events), err)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,85 @@
+// Package notify delivers target up/down alerts and check results to webhooks and other
+// ResultPublishers.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+)
+
+// Event types emitted when a target changes availability, starts breaching its latency
+// threshold, stops sending a required security header, negotiates a weak TLS version, or is on
+// a domain about to expire.
+const (
+	EventTargetDown = "target.down"
+	EventTargetUp   = "target.up"
+	EventTargetSlow = "target.slow"
+
+	EventTargetSecurityHeaderMissing = "target.security_header_missing"
+	EventTargetWeakTLS               = "target.weak_tls"
+	EventTargetDomainExpiring        = "target.domain_expiring"
+)
+
+// Event describes an alert about a single target.
+type Event struct {
+	Type     string    `json:"type"`
+	TargetID string    `json:"target_id"`
+	URL      string    `json:"url"`
+	Message  string    `json:"message"`
+	At       time.Time `json:"at"`
+}
+
+// Notifier delivers alert events.
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// LogNotifier writes alert events to the log, at warn through the notify component.
+type LogNotifier struct{}
+
+// Notify logs the event.
+func (LogNotifier) Notify(ctx context.Context, event Event) error {
+	logging.Notify.Warnf("alert %s for target %s: %s", event.Type, event.TargetID, event.Message)
+	return nil
+}
+
+// WebhookNotifier posts alert events as JSON to a URL.
+type WebhookNotifier struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a notifier that posts events to the given URL. When secret is
+// non-empty, each delivery is signed (see Sign).
+func NewWebhookNotifier(url, secret string, timeout time.Duration) *WebhookNotifier {
+	return &WebhookNotifier{url: url, secret: secret, client: &http.Client{Timeout: timeout}}
+}
+
+// Notify posts the event to the webhook URL.
+func (n *WebhookNotifier) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	return postJSON(ctx, n.client, n.url, n.secret, body)
+}
+
+// Multi fans an event out to several notifiers, returning the first error encountered.
+type Multi []Notifier
+
+// Notify delivers the event to every notifier.
+func (m Multi) Notify(ctx context.Context, event Event) error {
+	var firstErr error
+	for _, n := range m {
+		if err := n.Notify(ctx, event); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
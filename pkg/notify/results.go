@@ -0,0 +1,164 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// resultBufferBatches is how many full batches may be buffered before new results are dropped.
+const resultBufferBatches = 10
+
+// resultDelivery is the webhook representation of a check result.
+type resultDelivery struct {
+	ID         string            `json:"id"`
+	TargetID   string            `json:"target_id"`
+	CheckedAt  time.Time         `json:"checked_at"`
+	StatusCode *int              `json:"status_code"`
+	LatencyMS  int64             `json:"latency_ms"`
+	LatencyUS  int64             `json:"latency_us"`
+	Error      *string           `json:"error"`
+	Headers    map[string]string `json:"headers,omitempty"`
+
+	Security *models.SecurityReport `json:"security,omitempty"`
+	Assets   *models.AssetReport    `json:"assets,omitempty"`
+
+	ErrorCategory string                `json:"error_category,omitempty"`
+	BodyTruncated bool                  `json:"body_truncated,omitempty"`
+	Partial       bool                  `json:"partial,omitempty"`
+	Attempts      []models.CheckAttempt `json:"attempts,omitempty"`
+	Timings       *models.Timings       `json:"timings,omitempty"`
+	TLS           *models.TLSInfo       `json:"tls,omitempty"`
+}
+
+// ResultWebhook delivers check results to a webhook in batches. A batch is sent when it
+// reaches the configured size or when the flush interval elapses, whichever comes first.
+type ResultWebhook struct {
+	url       string
+	secret    string
+	client    *http.Client
+	batchSize int
+	interval  time.Duration
+	results   chan models.CheckResult
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+	stopOnce  sync.Once
+}
+
+// NewResultWebhook creates a batching result webhook. When secret is non-empty, each batch is
+// signed (see Sign).
+func NewResultWebhook(url, secret string, batchSize int, interval, timeout time.Duration) *ResultWebhook {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return &ResultWebhook{
+		url:       url,
+		secret:    secret,
+		client:    &http.Client{Timeout: timeout},
+		batchSize: batchSize,
+		interval:  interval,
+		results:   make(chan models.CheckResult, batchSize*resultBufferBatches),
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// Publish queues a result for delivery. It never blocks; results are dropped when the buffer is full.
+func (w *ResultWebhook) Publish(result models.CheckResult) {
+	select {
+	case w.results <- result:
+	default:
+		logging.Notify.Warnf("result webhook buffer full, dropping result for target %s", result.TargetID)
+	}
+}
+
+// Start begins the background delivery loop.
+func (w *ResultWebhook) Start() {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		batch := make([]resultDelivery, 0, w.batchSize)
+		flush := func() {
+			if len(batch) == 0 {
+				return
+			}
+			if err := w.deliver(batch); err != nil {
+				logging.Notify.Errorf("error delivering %d results to webhook: %v", len(batch), err)
+			}
+			batch = batch[:0]
+		}
+
+		for {
+			select {
+			case r := <-w.results:
+				batch = append(batch, toDelivery(r))
+				if len(batch) >= w.batchSize {
+					flush()
+				}
+			case <-ticker.C:
+				flush()
+			case <-w.stopChan:
+				// Drain whatever is still buffered before exiting.
+				for {
+					select {
+					case r := <-w.results:
+						batch = append(batch, toDelivery(r))
+						if len(batch) >= w.batchSize {
+							flush()
+						}
+					default:
+						flush()
+						return
+					}
+				}
+			}
+		}
+	}()
+}
+
+// Stop flushes buffered results and stops the delivery loop.
+func (w *ResultWebhook) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopChan)
+		w.wg.Wait()
+	})
+}
+
+func (w *ResultWebhook) deliver(batch []resultDelivery) error {
+	body, err := json.Marshal(struct {
+		Results []resultDelivery `json:"results"`
+	}{batch})
+	if err != nil {
+		return err
+	}
+	return postJSON(context.Background(), w.client, w.url, w.secret, body)
+}
+
+func toDelivery(r models.CheckResult) resultDelivery {
+	return resultDelivery{
+		ID:         r.ID,
+		TargetID:   r.TargetID,
+		CheckedAt:  r.CheckedAt,
+		StatusCode: r.StatusCode,
+		LatencyMS:  r.LatencyMS,
+		LatencyUS:  r.LatencyUS,
+		Error:      r.Error,
+		Headers:    r.Headers,
+		Security:   r.Security,
+		Assets:     r.Assets,
+
+		ErrorCategory: r.ErrorCategory,
+		BodyTruncated: r.BodyTruncated,
+		Partial:       r.Partial,
+		Attempts:      r.Attempts,
+		Timings:       r.Timings,
+		TLS:           r.TLS,
+	}
+}
@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"sync"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// ResultSink receives every check result after it has been stored: from the checker's
+// workers, missed heartbeats, and heartbeat pings. Publish is called on the goroutine that
+// stored the result, so sinks that do I/O should buffer and return quickly.
+type ResultSink interface {
+	Publish(result models.CheckResult)
+}
+
+// ResultPublisher is the previous name of ResultSink.
+type ResultPublisher = ResultSink
+
+// Lifecycle is implemented by sinks with a background loop to start and stop, such as
+// ResultWebhook.
+type Lifecycle interface {
+	Start()
+	Stop()
+}
+
+// Sinks is a registry that fans each result out to every registered sink, in registration
+// order. A sink that panics is logged and skipped so it can't take down a check worker.
+// The zero value is ready to use.
+type Sinks struct {
+	mu    sync.RWMutex
+	sinks []ResultSink
+}
+
+// Register adds a sink. Sinks are usually registered at startup, before results flow.
+func (s *Sinks) Register(sink ResultSink) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sinks = append(s.sinks, sink)
+}
+
+// Publish hands the result to every registered sink.
+func (s *Sinks) Publish(result models.CheckResult) {
+	s.mu.RLock()
+	sinks := s.sinks
+	s.mu.RUnlock()
+	for _, sink := range sinks {
+		publishTo(sink, result)
+	}
+}
+
+// Start starts every registered sink that implements Lifecycle.
+func (s *Sinks) Start() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, sink := range s.sinks {
+		if l, ok := sink.(Lifecycle); ok {
+			l.Start()
+		}
+	}
+}
+
+// Stop stops every registered sink that implements Lifecycle, in reverse order, letting
+// each flush what it has buffered.
+func (s *Sinks) Stop() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := len(s.sinks) - 1; i >= 0; i-- {
+		if l, ok := s.sinks[i].(Lifecycle); ok {
+			l.Stop()
+		}
+	}
+}
+
+func publishTo(sink ResultSink, result models.CheckResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			logging.Notify.Errorf("result sink %T panicked on result for target %s: %v", sink, result.TargetID, r)
+		}
+	}()
+	sink.Publish(result)
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// ErrMetadataLimit is returned when a batch update would leave a target with more metadata
+// entries than its mutation allows.
+var ErrMetadataLimit = errors.New("metadata limit exceeded")
+
+// TargetFilter selects the targets of a batch update: those on Host with every Metadata
+// pair and, when IDs is set, one of IDs. Empty fields don't filter.
+type TargetFilter struct {
+	Host     string
+	Metadata map[string]string
+	IDs      []string
+}
+
+// Matches reports whether the filter selects t.
+func (f TargetFilter) Matches(t models.Target) bool {
+	if f.Host != "" && t.Host != f.Host {
+		return false
+	}
+	if len(f.IDs) > 0 && !slices.Contains(f.IDs, t.ID) {
+		return false
+	}
+	for k, v := range f.Metadata {
+		if got, ok := t.Metadata[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// TargetMutation is a change made to every target of a batch update. Fields left zero keep
+// the targets' settings.
+type TargetMutation struct {
+	SetMetadata    map[string]string // Entries added, replacing those with the same key
+	RemoveMetadata []string          // Keys removed after SetMetadata is applied
+	// MaxMetadataEntries, when positive, fails the update with ErrMetadataLimit if a target
+	// would be left with more entries.
+	MaxMetadataEntries int
+
+	SnoozedUntil *time.Time // Snoozes the targets until then
+	Unsnooze     bool       // Ends the targets' snoozes
+}
+
+// Apply makes the mutation to t and reports whether anything changed.
+func (m TargetMutation) Apply(t *models.Target) (bool, error) {
+	metadata := maps.Clone(t.Metadata)
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	maps.Copy(metadata, m.SetMetadata)
+	for _, k := range m.RemoveMetadata {
+		delete(metadata, k)
+	}
+	if m.MaxMetadataEntries > 0 && len(metadata) > m.MaxMetadataEntries {
+		return false, fmt.Errorf("%w: target %s would have %d entries", ErrMetadataLimit, t.ID, len(metadata))
+	}
+	if len(metadata) == 0 {
+		metadata = nil
+	}
+
+	snoozed := t.SnoozedUntil
+	switch {
+	case m.SnoozedUntil != nil:
+		until := *m.SnoozedUntil
+		snoozed = &until
+	case m.Unsnooze:
+		snoozed = nil
+	}
+
+	changed := !maps.Equal(metadata, t.Metadata) || (snoozed == nil) != (t.SnoozedUntil == nil) ||
+		(snoozed != nil && !snoozed.Equal(*t.SnoozedUntil))
+	t.Metadata, t.SnoozedUntil = metadata, snoozed
+	return changed, nil
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// Health ranks, in the order ListTargetsByHealth lists targets: most in need of attention first.
+const (
+	HealthRankDown      = iota // The latest check failed
+	HealthRankDegraded         // The latest check passed with a warning, or at or above the degraded latency
+	HealthRankUp               // The latest check passed
+	HealthRankUnchecked        // The target hasn't been checked
+)
+
+// HealthRank returns the rank of a target whose latest result is latest, nil when it has none.
+// A passing result at least degraded slow ranks as degraded.
+func HealthRank(latest *models.CheckResult, degraded time.Duration) int {
+	state := models.StateFromResult(latest)
+	switch {
+	case state.Status == models.TargetStatusDown:
+		return HealthRankDown
+	case state.Status == models.TargetStatusWarning:
+		return HealthRankDegraded
+	case state.Status == models.TargetStatusUp && time.Duration(latest.LatencyMS)*time.Millisecond >= degraded:
+		return HealthRankDegraded
+	case state.Status == models.TargetStatusUp:
+		return HealthRankUp
+	default:
+		return HealthRankUnchecked
+	}
+}
+
+// ListTargetsByHealthParams selects a page of targets ordered by HealthRank and then by
+// (created_at, id). The After fields are the previous page's last target, as keyset cursor;
+// an empty AfterID starts at the first target.
+type ListTargetsByHealthParams struct {
+	Host            string
+	Metadata        map[string]string // Keeps only targets with every one of these pairs
+	DegradedLatency time.Duration
+	AfterRank       int
+	AfterTime       time.Time
+	AfterID         string
+	Limit           int
+}
+
+// RankedTarget is a target listed by ListTargetsByHealth, with the rank it was ordered by.
+type RankedTarget struct {
+	models.Target
+	Rank int
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// Change is a new value for one setting of a TargetPatch. The setting is kept unless Set.
+type Change[T any] struct {
+	Value T
+	Set   bool
+}
+
+// To returns a Change of a setting to v.
+func To[T any](v T) Change[T] {
+	return Change[T]{Value: v, Set: true}
+}
+
+// TargetPatch changes some of one target's settings. A nil or zero value clears a setting,
+// as described on the corresponding models.Target field.
+type TargetPatch struct {
+	CaptureHeaders   Change[[]string]
+	StatusPolicy     Change[*models.StatusPolicy]
+	TimeoutBudget    Change[time.Duration] // Zero uses the checker's
+	LatencyThreshold Change[time.Duration]
+	Metadata         Change[map[string]string]
+	ResultSampling   Change[*models.ResultSampling]
+	Dependencies     Change[*models.Dependencies]
+	Schedule         Change[*models.CheckSchedule]
+	SecurityAudit    Change[*models.SecurityAudit]
+	AssetCheck       Change[*models.AssetCheck]
+	MinTLSVersion    Change[string] // Empty uses the checker's
+}
+
+// Apply makes the patch to t.
+func (p TargetPatch) Apply(t *models.Target) {
+	if p.CaptureHeaders.Set {
+		t.CaptureHeaders = p.CaptureHeaders.Value
+	}
+	if p.StatusPolicy.Set {
+		t.StatusPolicy = p.StatusPolicy.Value
+	}
+	if p.TimeoutBudget.Set {
+		t.TimeoutBudgetMS = p.TimeoutBudget.Value.Milliseconds()
+	}
+	if p.LatencyThreshold.Set {
+		t.LatencyThresholdMS = p.LatencyThreshold.Value.Milliseconds()
+	}
+	if p.Metadata.Set {
+		t.Metadata = p.Metadata.Value
+	}
+	if p.ResultSampling.Set {
+		t.ResultSampling = p.ResultSampling.Value
+	}
+	if p.Dependencies.Set {
+		t.Dependencies = p.Dependencies.Value
+	}
+	if p.Schedule.Set {
+		t.Schedule = p.Schedule.Value
+	}
+	if p.SecurityAudit.Set {
+		t.SecurityAudit = p.SecurityAudit.Value
+	}
+	if p.AssetCheck.Set {
+		t.AssetCheck = p.AssetCheck.Value
+	}
+	if p.MinTLSVersion.Set {
+		t.MinTLSVersion = p.MinTLSVersion.Value
+	}
+}
@@ -0,0 +1,224 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// dayLayout is how archived_days keys a day. It is the date prefix of a formatted time, so
+// a result's day is substr(checked_at, 1, 10).
+const dayLayout = "2006-01-02"
+
+// UnarchivedResultDays returns the days before before with results that weren't archived.
+func (s *Store) UnarchivedResultDays(ctx context.Context, before time.Time, limit int) ([]time.Time, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT substr(checked_at, 1, 10) AS day FROM check_results
+		WHERE checked_at < ? AND substr(checked_at, 1, 10) NOT IN (SELECT day FROM archived_days)
+		ORDER BY day LIMIT ?`, formatTime(before), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unarchived days: %w", err)
+	}
+	defer rows.Close()
+	var days []time.Time
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, fmt.Errorf("failed to scan unarchived day: %w", err)
+		}
+		day, err := time.Parse(dayLayout, raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse day: %w", err)
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+// ExportCheckResults streams the results checked in [since, until) to fn.
+func (s *Store) ExportCheckResults(ctx context.Context, since, until time.Time, fn func(models.CheckResult) error) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+resultColumns+` FROM check_results WHERE checked_at >= ? AND checked_at < ? ORDER BY checked_at, id`,
+		formatTime(since), formatTime(until))
+	if err != nil {
+		return fmt.Errorf("failed to export check results: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r, err := scanResult(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan check result row: %w", err)
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// MarkResultsArchived records day as archived and prunes its results.
+func (s *Store) MarkResultsArchived(ctx context.Context, day storage.ArchivedDay) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO archived_days (day, object, results, archived_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(day) DO UPDATE SET object = excluded.object, results = excluded.results, archived_at = excluded.archived_at, restored_at = NULL`,
+		day.Day.UTC().Format(dayLayout), day.Object, day.Results, formatTime(day.ArchivedAt))
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark day archived: %w", err)
+	}
+	n, err := pruneDayTx(ctx, tx, day.Day)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return n, nil
+}
+
+// pruneDayTx deletes the results checked on day, keeping those a state transition refers
+// to, as result sampling does.
+func pruneDayTx(ctx context.Context, tx *sql.Tx, day time.Time) (int, error) {
+	since, until := formatTime(day), formatTime(day.AddDate(0, 0, 1))
+	res, err := tx.ExecContext(ctx, `
+		DELETE FROM check_results WHERE checked_at >= ? AND checked_at < ?
+		AND id NOT IN (SELECT result_id FROM target_state_transitions WHERE at >= ? AND at < ?)`,
+		since, until, since, until)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune archived check results: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// ListArchivedDays returns the archived days overlapping [since, until).
+func (s *Store) ListArchivedDays(ctx context.Context, since, until time.Time) ([]storage.ArchivedDay, error) {
+	// Days are compared by date: the range covers the day of since to that of its last instant.
+	rows, err := s.db.QueryContext(ctx, `SELECT day, object, results, archived_at, restored_at FROM archived_days WHERE day >= ? AND day <= ? ORDER BY day`,
+		since.UTC().Format(dayLayout), until.Add(-time.Nanosecond).UTC().Format(dayLayout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archived days: %w", err)
+	}
+	defer rows.Close()
+	var days []storage.ArchivedDay
+	for rows.Next() {
+		var d storage.ArchivedDay
+		var day, archivedAt string
+		var restoredAt sql.NullString
+		if err := rows.Scan(&day, &d.Object, &d.Results, &archivedAt, &restoredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan archived day: %w", err)
+		}
+		if d.Day, err = time.Parse(dayLayout, day); err != nil {
+			return nil, fmt.Errorf("failed to parse day: %w", err)
+		}
+		if d.ArchivedAt, err = time.Parse(time.RFC3339Nano, archivedAt); err != nil {
+			return nil, fmt.Errorf("failed to parse archive time: %w", err)
+		}
+		if restoredAt.Valid {
+			t, err := time.Parse(time.RFC3339Nano, restoredAt.String)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse restore time: %w", err)
+			}
+			d.RestoredAt = &t
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
+
+// RestoreCheckResults inserts archived results as they were stored, skipping those already
+// present and those whose target was deleted since.
+func (s *Store) RestoreCheckResults(ctx context.Context, results []models.CheckResult) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO check_results (id, target_id, checked_at, status_code, latency_ms, latency_us, error, error_category, outcome, headers, body_truncated, partial, cached_dns_failure, attempts, timings, security, assets, tls, started_at, completed_at, seq, total_duration_ms, bytes_downloaded)
+		SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ? WHERE EXISTS (SELECT 1 FROM targets WHERE id = ?)
+		ON CONFLICT(id) DO NOTHING`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare restore: %w", err)
+	}
+	defer stmt.Close()
+	restored := 0
+	for _, r := range results {
+		res, err := stmt.ExecContext(ctx, r.ID, r.TargetID, formatTime(r.CheckedAt), r.StatusCode, r.LatencyMS, r.LatencyUS, s.packString(r.Error),
+			nullString(r.ErrorCategory), nullString(r.Outcome), nullJSON(r.Headers), r.BodyTruncated, r.Partial, r.CachedDNSFailure,
+			s.pack(nullJSON(r.Attempts)), s.pack(nullJSON(r.Timings)), s.pack(nullJSON(r.Security)), s.pack(nullJSON(r.Assets)), nullJSON(r.TLS),
+			formatNullTime(r.StartedAt), formatNullTime(r.CompletedAt), sql.NullInt64{Int64: r.Seq, Valid: r.Seq != 0}, max(r.TotalDurationMS, r.LatencyMS), r.BytesDownloaded, r.TargetID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to restore check result: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		restored += int(n)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return restored, nil
+}
+
+// MarkDayRestored sets the restored mark of an archived day.
+func (s *Store) MarkDayRestored(ctx context.Context, day, at time.Time) error {
+	if _, err := s.db.ExecContext(ctx, `UPDATE archived_days SET restored_at = ? WHERE day = ?`, formatTime(at), day.UTC().Format(dayLayout)); err != nil {
+		return fmt.Errorf("failed to mark day restored: %w", err)
+	}
+	return nil
+}
+
+// PruneRestoredResults prunes the days restored before before again.
+func (s *Store) PruneRestoredResults(ctx context.Context, before time.Time) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT day FROM archived_days WHERE restored_at IS NOT NULL AND restored_at < ?`, formatTime(before))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list restored days: %w", err)
+	}
+	var days []time.Time
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan restored day: %w", err)
+		}
+		day, err := time.Parse(dayLayout, raw)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to parse day: %w", err)
+		}
+		days = append(days, day)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to list restored days: %w", err)
+	}
+
+	pruned := 0
+	for _, day := range days {
+		n, err := pruneDayTx(ctx, tx, day)
+		if err != nil {
+			return 0, err
+		}
+		pruned += n
+		if _, err := tx.ExecContext(ctx, `UPDATE archived_days SET restored_at = NULL WHERE day = ?`, day.Format(dayLayout)); err != nil {
+			return 0, fmt.Errorf("failed to clear restored mark: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return pruned, nil
+}
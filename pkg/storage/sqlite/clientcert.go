@@ -0,0 +1,144 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// ErrNoClientCertSecret is returned when saving or reading a client certificate key without
+// WithClientCertSecret.
+var ErrNoClientCertSecret = errors.New("client certificate secret is not configured")
+
+// WithClientCertSecret encrypts the private keys of client certificates with AES-256-GCM,
+// keyed by the SHA-256 of secret. Certificates themselves are stored in the clear. Keys saved
+// under one secret can't be read under another, so changing it means saving them again.
+func WithClientCertSecret(secret string) Option {
+	return func(s *Store) {
+		key := sha256.Sum256([]byte(secret))
+		block, _ := aes.NewCipher(key[:]) // Only fails for a key of the wrong size
+		s.certKeys, _ = cipher.NewGCM(block)
+	}
+}
+
+// sealKey encrypts a private key as a random nonce followed by the ciphertext.
+func (s *Store) sealKey(keyPEM string) ([]byte, error) {
+	if s.certKeys == nil {
+		return nil, ErrNoClientCertSecret
+	}
+	nonce := make([]byte, s.certKeys.NonceSize(), s.certKeys.NonceSize()+len(keyPEM)+s.certKeys.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return s.certKeys.Seal(nonce, nonce, []byte(keyPEM), nil), nil
+}
+
+// openKey reverses sealKey.
+func (s *Store) openKey(sealed []byte) (string, error) {
+	if s.certKeys == nil {
+		return "", ErrNoClientCertSecret
+	}
+	n := s.certKeys.NonceSize()
+	if len(sealed) < n {
+		return "", errors.New("failed to decrypt client certificate key: too short")
+	}
+	key, err := s.certKeys.Open(nil, sealed[:n], sealed[n:], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt client certificate key (was the secret changed?): %w", err)
+	}
+	return string(key), nil
+}
+
+// PutClientCertificate saves a host's certificate, encrypting its key.
+func (s *Store) PutClientCertificate(ctx context.Context, cert models.ClientCertificate) error {
+	sealed, err := s.sealKey(cert.KeyPEM)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO client_certificates (host, cert_pem, key_sealed, subject, not_after, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(host) DO UPDATE SET cert_pem = excluded.cert_pem, key_sealed = excluded.key_sealed, subject = excluded.subject,
+			not_after = excluded.not_after, updated_at = excluded.updated_at`,
+		cert.Host, cert.CertPEM, sealed, cert.Subject, formatTime(cert.NotAfter), formatTime(cert.UpdatedAt))
+	if err != nil {
+		return fmt.Errorf("failed to save client certificate: %w", err)
+	}
+	return nil
+}
+
+// GetClientCertificate returns a host's certificate with its decrypted key.
+func (s *Store) GetClientCertificate(ctx context.Context, host string) (*models.ClientCertificate, error) {
+	var c models.ClientCertificate
+	var sealed []byte
+	var notAfter, updatedAt string
+	err := s.db.QueryRowContext(ctx, `SELECT host, cert_pem, key_sealed, subject, not_after, updated_at FROM client_certificates WHERE host = ?`, host).
+		Scan(&c.Host, &c.CertPEM, &sealed, &c.Subject, &notAfter, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client certificate: %w", err)
+	}
+	if err := parseCertTimes(&c, notAfter, updatedAt); err != nil {
+		return nil, err
+	}
+	if c.KeyPEM, err = s.openKey(sealed); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ListClientCertificates returns every stored certificate without its key, ordered by host.
+func (s *Store) ListClientCertificates(ctx context.Context) ([]models.ClientCertificate, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT host, cert_pem, subject, not_after, updated_at FROM client_certificates ORDER BY host`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list client certificates: %w", err)
+	}
+	defer rows.Close()
+	var certs []models.ClientCertificate
+	for rows.Next() {
+		var c models.ClientCertificate
+		var notAfter, updatedAt string
+		if err := rows.Scan(&c.Host, &c.CertPEM, &c.Subject, &notAfter, &updatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan client certificate: %w", err)
+		}
+		if err := parseCertTimes(&c, notAfter, updatedAt); err != nil {
+			return nil, err
+		}
+		certs = append(certs, c)
+	}
+	return certs, rows.Err()
+}
+
+// DeleteClientCertificate forgets a host's certificate.
+func (s *Store) DeleteClientCertificate(ctx context.Context, host string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM client_certificates WHERE host = ?`, host)
+	if err != nil {
+		return fmt.Errorf("failed to delete client certificate: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+// parseCertTimes parses the stored times of a client certificate into c.
+func parseCertTimes(c *models.ClientCertificate, notAfter, updatedAt string) error {
+	var err error
+	if c.NotAfter, err = time.Parse(time.RFC3339Nano, notAfter); err != nil {
+		return fmt.Errorf("failed to parse client certificate expiry: %w", err)
+	}
+	if c.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAt); err != nil {
+		return fmt.Errorf("failed to parse client certificate update time: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package sqlite
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"io"
+	"strings"
+)
+
+// gzipMagic starts every gzip stream. Stored text never does: errors are printable and JSON
+// starts with a bracket, so a value beginning with it is known to be compressed.
+const gzipMagic = "\x1f\x8b"
+
+// WithCompression gzips the large text columns of check results (error, attempts, timings,
+// security, and assets) at least threshold bytes long when they are written, if that makes
+// them smaller. Compressed values are stored as BLOBs and decompressed when read, so results
+// come back unchanged. Reads always decompress, whatever the option, so instances with and
+// without it can share a database once every instance is on a release that reads them.
+// Captured headers stay uncompressed, since result filters match on them in SQL.
+func WithCompression(threshold int) Option {
+	return func(s *Store) { s.compressAbove = threshold }
+}
+
+// pack returns v as it should be stored: gzipped when compression is on and v is at least the
+// threshold and shrinks, as is otherwise.
+func (s *Store) pack(v sql.NullString) any {
+	if s.compressAbove <= 0 || !v.Valid || len(v.String) < s.compressAbove {
+		return v
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(zw, v.String); err != nil {
+		return v
+	}
+	if err := zw.Close(); err != nil || buf.Len() >= len(v.String) {
+		return v
+	}
+	return buf.Bytes()
+}
+
+// packString is pack for an optional string.
+func (s *Store) packString(v *string) any {
+	if v == nil {
+		return nil
+	}
+	return s.pack(sql.NullString{String: *v, Valid: true})
+}
+
+// unpack reverses pack on a scanned column. A value that fails to decompress is returned as
+// stored rather than failing the whole read.
+func unpack(v sql.NullString) sql.NullString {
+	if !v.Valid || !strings.HasPrefix(v.String, gzipMagic) {
+		return v
+	}
+	zr, err := gzip.NewReader(strings.NewReader(v.String))
+	if err != nil {
+		return v
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		return v
+	}
+	return sql.NullString{String: string(b), Valid: true}
+}
@@ -0,0 +1,55 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// UpsertDomain saves a domain's registration.
+func (s *Store) UpsertDomain(ctx context.Context, d models.Domain) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO domains (name, registrar, expires_at, targets, checked_at, error, alerted_expiry) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET registrar = excluded.registrar, expires_at = excluded.expires_at, targets = excluded.targets,
+			checked_at = excluded.checked_at, error = excluded.error, alerted_expiry = excluded.alerted_expiry`,
+		d.Name, d.Registrar, formatNullTime(d.ExpiresAt), d.Targets, formatTime(d.CheckedAt), d.Error, formatNullTime(d.AlertedExpiry))
+	if err != nil {
+		return fmt.Errorf("failed to save domain: %w", err)
+	}
+	return nil
+}
+
+// ListDomains returns every stored domain, ordered by name.
+func (s *Store) ListDomains(ctx context.Context) ([]models.Domain, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT name, registrar, expires_at, targets, checked_at, error, alerted_expiry FROM domains ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list domains: %w", err)
+	}
+	defer rows.Close()
+	var domains []models.Domain
+	for rows.Next() {
+		var d models.Domain
+		var checkedAt string
+		var expiresAt, alertedExpiry sql.NullString
+		if err := rows.Scan(&d.Name, &d.Registrar, &expiresAt, &d.Targets, &checkedAt, &d.Error, &alertedExpiry); err != nil {
+			return nil, fmt.Errorf("failed to scan domain: %w", err)
+		}
+		if d.CheckedAt, err = time.Parse(time.RFC3339Nano, checkedAt); err != nil {
+			return nil, fmt.Errorf("failed to parse domain check time: %w", err)
+		}
+		d.ExpiresAt, d.AlertedExpiry = parseNullTime(expiresAt), parseNullTime(alertedExpiry)
+		domains = append(domains, d)
+	}
+	return domains, rows.Err()
+}
+
+// DeleteDomain forgets a domain.
+func (s *Store) DeleteDomain(ctx context.Context, name string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM domains WHERE name = ?`, name); err != nil {
+		return fmt.Errorf("failed to delete domain: %w", err)
+	}
+	return nil
+}
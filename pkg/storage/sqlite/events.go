@@ -0,0 +1,79 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// RecordSystemEvent stores a system event, assigning its ID when empty.
+func (s *Store) RecordSystemEvent(ctx context.Context, event *models.SystemEvent) error {
+	if event.ID == "" {
+		event.ID = randomID("evt_")
+	}
+	var details sql.NullString
+	if len(event.Details) > 0 {
+		b, err := json.Marshal(event.Details)
+		if err != nil {
+			return fmt.Errorf("failed to encode event details: %w", err)
+		}
+		details = sql.NullString{String: string(b), Valid: true}
+	}
+	_, err := s.db.ExecContext(ctx, `INSERT INTO system_events (id, type, at, message, details) VALUES (?, ?, ?, ?, ?)`,
+		event.ID, event.Type, formatTime(event.At), event.Message, details)
+	if err != nil {
+		return fmt.Errorf("failed to record system event: %w", err)
+	}
+	return nil
+}
+
+// ListSystemEvents returns system events, newest first.
+func (s *Store) ListSystemEvents(ctx context.Context, params storage.ListSystemEventsParams) ([]models.SystemEvent, error) {
+	query := `SELECT id, type, at, message, details FROM system_events WHERE 1 = 1`
+	var args []interface{}
+	if params.Type != "" {
+		query += ` AND type = ?`
+		args = append(args, params.Type)
+	}
+	if params.Since != nil {
+		query += ` AND at >= ?`
+		args = append(args, formatTime(*params.Since))
+	}
+	if params.Until != nil {
+		query += ` AND at < ?`
+		args = append(args, formatTime(*params.Until))
+	}
+	query += ` ORDER BY at DESC, rowid DESC LIMIT ?`
+	args = append(args, params.Limit)
+
+	rows, err := s.queryRead(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list system events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.SystemEvent{}
+	for rows.Next() {
+		var e models.SystemEvent
+		var at string
+		var details sql.NullString
+		if err := rows.Scan(&e.ID, &e.Type, &at, &e.Message, &details); err != nil {
+			return nil, fmt.Errorf("failed to scan system event: %w", err)
+		}
+		if e.At, err = time.Parse(time.RFC3339Nano, at); err != nil {
+			return nil, fmt.Errorf("failed to parse event time: %w", err)
+		}
+		if details.Valid {
+			if err := json.Unmarshal([]byte(details.String), &e.Details); err != nil {
+				return nil, fmt.Errorf("failed to decode event details: %w", err)
+			}
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,58 @@
+package sqlite
+
+import (
+	"context"
+	"strings"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// DatabaseHealth probes the primary and, when configured, the read replica. The primary is
+// checked for writability with a write that is rolled back, so a database on a read-only
+// volume reports read_only rather than ok. Each reports its connection pool.
+func (s *Store) DatabaseHealth(ctx context.Context) []models.DatabaseHealth {
+	primary := s.primaryHealth(ctx)
+	primary.Pool = poolStats(s.db)
+	health := []models.DatabaseHealth{primary}
+	if s.replica != nil {
+		h := models.DatabaseHealth{Name: "replica", Status: models.DatabaseOK, Pool: poolStats(s.replica.db)}
+		if err := s.replica.db.PingContext(ctx); err != nil {
+			h.Status, h.Error = models.DatabaseUnavailable, err.Error()
+		} else if !s.replica.available() {
+			h.Status, h.Error = models.DatabaseUnavailable, "recent queries failed; reading from primary"
+		}
+		health = append(health, h)
+	}
+	return health
+}
+
+func (s *Store) primaryHealth(ctx context.Context) models.DatabaseHealth {
+	h := models.DatabaseHealth{Name: "primary", Status: models.DatabaseOK}
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		h.Status, h.Error = models.DatabaseUnavailable, err.Error()
+		return h
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `SELECT 1 FROM targets LIMIT 1`); err != nil {
+		h.Status, h.Error = models.DatabaseUnavailable, err.Error()
+		return h
+	}
+	if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		return writeProbeHealth(h, err)
+	}
+	defer conn.ExecContext(context.Background(), `ROLLBACK`)
+	if _, err := conn.ExecContext(ctx, `UPDATE schema_migrations SET applied_at = applied_at WHERE version = (SELECT MAX(version) FROM schema_migrations)`); err != nil {
+		return writeProbeHealth(h, err)
+	}
+	return h
+}
+
+// writeProbeHealth records a failed write probe. A lock held by another writer means writes
+// work and are merely busy.
+func writeProbeHealth(h models.DatabaseHealth, err error) models.DatabaseHealth {
+	if msg := err.Error(); !strings.Contains(msg, "locked") && !strings.Contains(msg, "busy") {
+		h.Status, h.Error = models.DatabaseReadOnly, msg
+	}
+	return h
+}
@@ -0,0 +1,193 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	moderncsqlite "modernc.org/sqlite"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/metrics"
+)
+
+// maxLoggedQuery caps how much of a slow statement is logged.
+const maxLoggedQuery = 1000
+
+// WithMetrics records how long every statement takes as the db.query timing, tagged with the
+// statement's op (select, insert, update, delete, or other) and its db (primary or replica).
+// A query runs until its rows are closed, so reading them is included.
+func WithMetrics(recorder metrics.Recorder) Option {
+	return func(s *Store) { s.metrics = recorder }
+}
+
+// WithSlowQueryLog logs every statement that takes at least threshold, with its SQL and
+// duration but never its arguments, which hold target URLs, tokens, and result data.
+func WithSlowQueryLog(threshold time.Duration) Option {
+	return func(s *Store) { s.slowQuery = threshold }
+}
+
+// open opens a connection pool to dsn, sized by WithPool, through an instrumented driver when
+// metrics or the slow query log are enabled. role tags what the connection is for.
+func (s *Store) open(dsn, role string) (*sql.DB, error) {
+	var db *sql.DB
+	if s.metrics == nil && s.slowQuery <= 0 {
+		var err error
+		if db, err = sql.Open("sqlite", dsn); err != nil {
+			return nil, err
+		}
+	} else {
+		db = sql.OpenDB(&instrumentedConnector{dsn: dsn, drv: &moderncsqlite.Driver{}, observer: &queryObserver{role: role, metrics: s.metrics, slow: s.slowQuery}})
+	}
+	s.configurePool(db)
+	return db, nil
+}
+
+// queryObserver records the statements run on one connection pool.
+type queryObserver struct {
+	role    string
+	metrics metrics.Recorder // Nil when only slow queries are logged
+	slow    time.Duration    // Zero disables the slow query log
+}
+
+// observe records that query, with args arguments, took since start.
+func (o *queryObserver) observe(query string, args int, start time.Time) {
+	d := time.Since(start)
+	if o.metrics != nil {
+		o.metrics.Timing("db.query", d, metrics.T("op", queryOp(query)), metrics.T("db", o.role))
+	}
+	if o.slow > 0 && d >= o.slow {
+		if o.metrics != nil {
+			o.metrics.Count("db.slow_queries", 1, metrics.T("op", queryOp(query)), metrics.T("db", o.role))
+		}
+		logging.Storage.Warnf("slow query on %s database took %s: %s%s", o.role, d.Round(time.Millisecond), loggedQuery(query), redactedArgs(args))
+	}
+}
+
+// queryOp returns the kind of statement query is, for metric tags. Statements starting with
+// a WITH clause count as selects, which is all the store runs them for.
+func queryOp(query string) string {
+	q := strings.TrimSpace(query)
+	if end := strings.IndexFunc(q, unicode.IsSpace); end >= 0 {
+		q = q[:end]
+	}
+	switch op := strings.ToLower(q); op {
+	case "select", "insert", "update", "delete":
+		return op
+	case "with":
+		return "select"
+	}
+	return "other"
+}
+
+// loggedQuery collapses query's whitespace and truncates it for the log.
+func loggedQuery(query string) string {
+	q := strings.Join(strings.Fields(query), " ")
+	if len(q) > maxLoggedQuery {
+		q = q[:maxLoggedQuery] + "..."
+	}
+	return q
+}
+
+// redactedArgs notes how many arguments a logged statement had without showing them.
+func redactedArgs(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return " [" + strconv.Itoa(n) + " args redacted]"
+}
+
+// instrumentedConnector opens SQLite connections whose statements are observed.
+type instrumentedConnector struct {
+	dsn      string
+	drv      driver.Driver
+	observer *queryObserver
+}
+
+func (c *instrumentedConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.drv.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConn{Conn: conn, observer: c.observer}, nil
+}
+
+func (c *instrumentedConnector) Driver() driver.Driver { return c.drv }
+
+// instrumentedConn times the statements run on a driver connection. The SQLite driver
+// implements every context-aware interface forwarded here.
+type instrumentedConn struct {
+	driver.Conn
+	observer *queryObserver
+}
+
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c *instrumentedConn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
+
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedStmt{Stmt: stmt, query: query, observer: c.observer}, nil
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	defer c.observer.observe(query, len(args), time.Now())
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+	if err != nil {
+		c.observer.observe(query, len(args), start)
+		return nil, err
+	}
+	return &instrumentedRows{Rows: rows, done: func() { c.observer.observe(query, len(args), start) }}, nil
+}
+
+// instrumentedStmt times each run of a prepared statement.
+type instrumentedStmt struct {
+	driver.Stmt
+	query    string
+	observer *queryObserver
+}
+
+func (s *instrumentedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	defer s.observer.observe(s.query, len(args), time.Now())
+	return s.Stmt.(driver.StmtExecContext).ExecContext(ctx, args)
+}
+
+func (s *instrumentedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := s.Stmt.(driver.StmtQueryContext).QueryContext(ctx, args)
+	if err != nil {
+		s.observer.observe(s.query, len(args), start)
+		return nil, err
+	}
+	return &instrumentedRows{Rows: rows, done: func() { s.observer.observe(s.query, len(args), start) }}, nil
+}
+
+// instrumentedRows reports its query once the rows are closed.
+type instrumentedRows struct {
+	driver.Rows
+	once sync.Once
+	done func()
+}
+
+func (r *instrumentedRows) Close() error {
+	err := r.Rows.Close()
+	r.once.Do(r.done)
+	return err
+}
@@ -0,0 +1,227 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// ErrCorrupt is returned by New when the startup integrity check finds problems.
+var ErrCorrupt = errors.New("database integrity check failed")
+
+// Startup integrity checks, see WithIntegrityCheck.
+const (
+	IntegrityQuick = "quick" // PRAGMA quick_check: structure only, in about the time of a full scan
+	IntegrityFull  = "full"  // PRAGMA integrity_check: also checks that indexes match their tables
+)
+
+// Auto-vacuum modes, see WithAutoVacuum. They are in the order SQLite numbers them.
+const (
+	AutoVacuumNone        = "none"
+	AutoVacuumFull        = "full"
+	AutoVacuumIncremental = "incremental"
+)
+
+var autoVacuumModes = []string{AutoVacuumNone, AutoVacuumFull, AutoVacuumIncremental}
+
+// maxIntegrityProblems caps how many problems the integrity check reports.
+const maxIntegrityProblems = 10
+
+// WithIntegrityCheck makes New check the database file before migrating it, with
+// IntegrityQuick or IntegrityFull, and fail with ErrCorrupt if it finds problems. Both read
+// the whole file, so they add to startup time in proportion to the database's size.
+func WithIntegrityCheck(mode string) Option {
+	return func(s *Store) { s.integrity = mode }
+}
+
+// WithAutoVacuum sets the database's auto_vacuum mode, one of the AutoVacuum* modes. With
+// full, pages freed by deletes are returned to the filesystem at every commit; with
+// incremental, only when the incremental_vacuum maintenance operation runs.
+func WithAutoVacuum(mode string) Option {
+	return func(s *Store) { s.autoVacuum = mode }
+}
+
+// WithPageSize sets the database's page size in bytes, a power of two from 512 to 65536.
+//
+// A new database is created with the auto_vacuum mode and page size set; an existing one only
+// changes them when it is next vacuumed, which rewrites the whole file, so New logs the
+// difference and leaves the vacuum to the maintenance endpoint.
+func WithPageSize(size int) Option {
+	return func(s *Store) { s.pageSize = size }
+}
+
+// ValidPageSize reports whether size is a page size SQLite accepts.
+func ValidPageSize(size int) bool {
+	return size >= 512 && size <= 65536 && size&(size-1) == 0
+}
+
+// checkIntegrity runs the startup integrity check, if one is enabled.
+func (s *Store) checkIntegrity(ctx context.Context) error {
+	var pragma string
+	switch s.integrity {
+	case "":
+		return nil
+	case IntegrityQuick:
+		pragma = "quick_check"
+	case IntegrityFull:
+		pragma = "integrity_check"
+	default:
+		return fmt.Errorf("unknown integrity check %q", s.integrity)
+	}
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("PRAGMA %s(%d)", pragma, maxIntegrityProblems))
+	if err != nil {
+		return fmt.Errorf("failed to check database integrity: %w", err)
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return err
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to check database integrity: %w", err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrCorrupt, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// applyLayout applies the configured auto_vacuum mode and page size. They take effect on a
+// new database right away, by vacuuming it while it is still empty; on an existing one, at
+// the next vacuum.
+func (s *Store) applyLayout(ctx context.Context) error {
+	if s.autoVacuum == "" && s.pageSize == 0 {
+		return nil
+	}
+	// Both pragmas only hold for the connection they are set on.
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var objects int
+	if err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master").Scan(&objects); err != nil {
+		return err
+	}
+	if objects == 0 {
+		if err := s.setLayout(ctx, conn); err != nil {
+			return err
+		}
+		if _, err := conn.ExecContext(ctx, "VACUUM"); err != nil {
+			return fmt.Errorf("failed to apply database layout: %w", err)
+		}
+		return nil
+	}
+	mode, size, err := layout(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if (s.autoVacuum != "" && s.autoVacuum != mode) || (s.pageSize != 0 && s.pageSize != size) {
+		logging.Storage.Warnf("database has auto_vacuum %s and page size %d; the configured settings apply after the next vacuum", mode, size)
+	}
+	return nil
+}
+
+// setLayout sets the configured auto_vacuum mode and page size on conn, for its next vacuum.
+func (s *Store) setLayout(ctx context.Context, conn *sql.Conn) error {
+	if s.autoVacuum != "" {
+		if _, err := conn.ExecContext(ctx, "PRAGMA auto_vacuum = "+s.autoVacuum); err != nil {
+			return err
+		}
+	}
+	if s.pageSize != 0 {
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("PRAGMA page_size = %d", s.pageSize)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// layout returns the database's auto_vacuum mode and page size.
+func layout(ctx context.Context, conn *sql.Conn) (string, int, error) {
+	var mode, size int
+	if err := conn.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&mode); err != nil {
+		return "", 0, err
+	}
+	if err := conn.QueryRowContext(ctx, "PRAGMA page_size").Scan(&size); err != nil {
+		return "", 0, err
+	}
+	if mode < 0 || mode >= len(autoVacuumModes) {
+		return "", 0, fmt.Errorf("unknown auto_vacuum mode %d", mode)
+	}
+	return autoVacuumModes[mode], size, nil
+}
+
+// fileSize returns the size of the database and of its free pages, in bytes.
+func fileSize(ctx context.Context, conn *sql.Conn) (size, free int64, err error) {
+	var pages, freePages, pageSize int64
+	err = conn.QueryRowContext(ctx, "SELECT page_count, freelist_count, page_size FROM pragma_page_count(), pragma_freelist_count(), pragma_page_size()").Scan(&pages, &freePages, &pageSize)
+	return pages * pageSize, freePages * pageSize, err
+}
+
+// drain reads every row of rows, for pragmas that do their work a step at a time.
+func drain(rows *sql.Rows, err error) error {
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+// MaintainDatabase runs a maintenance operation on the primary database. A vacuum applies
+// the configured auto_vacuum mode and page size; the database is locked against writes while
+// it rewrites the file, so checks wait to store their results until it is done.
+func (s *Store) MaintainDatabase(ctx context.Context, operation string) (*models.DatabaseMaintenance, error) {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	res := &models.DatabaseMaintenance{Operation: operation}
+	if res.SizeBefore, res.FreeBefore, err = fileSize(ctx, conn); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	switch operation {
+	case models.MaintenanceVacuum:
+		if err := s.setLayout(ctx, conn); err != nil {
+			return nil, err
+		}
+		_, err = conn.ExecContext(ctx, "VACUUM")
+	case models.MaintenanceIncrementalVacuum:
+		mode, _, lerr := layout(ctx, conn)
+		if lerr != nil {
+			return nil, lerr
+		}
+		if mode != AutoVacuumIncremental {
+			return nil, fmt.Errorf("incremental_vacuum needs auto_vacuum %s, but the database has %s", AutoVacuumIncremental, mode)
+		}
+		err = drain(conn.QueryContext(ctx, "PRAGMA incremental_vacuum"))
+	case models.MaintenanceAnalyze:
+		_, err = conn.ExecContext(ctx, "ANALYZE")
+	default:
+		return nil, fmt.Errorf("unknown maintenance operation %q", operation)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w", operation, err)
+	}
+	res.DurationMS = time.Since(start).Milliseconds()
+	if res.SizeAfter, res.FreeAfter, err = fileSize(ctx, conn); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,285 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// Migration phases. Expand migrations only add to the schema (new tables, nullable or
+// defaulted columns, indexes), so the release before them keeps working against the migrated
+// database during a rolling or blue/green deploy. Contract migrations remove or change
+// something an older release still uses; they only run when enabled with
+// WithContractMigrations, once every instance has been upgraded.
+const (
+	PhaseExpand   = "expand"
+	PhaseContract = "contract"
+)
+
+// ErrIncompatibleSchema is returned by New when the database has been contracted past what
+// this build supports.
+var ErrIncompatibleSchema = errors.New("incompatible database schema")
+
+// migration is a numbered schema change applied once, in order, and recorded in
+// schema_migrations. minAppVersion is the oldest SchemaVersion that can run against the
+// schema once the migration is applied: 0 for expand migrations, and for contract
+// migrations the version of the first release that no longer needs what was removed.
+type migration struct {
+	version       int
+	phase         string
+	minAppVersion int
+	apply         func(ctx context.Context, s *Store) error
+}
+
+// addColumn is an expand migration adding a column to an existing table.
+func addColumn(version int, table, column, definition string) migration {
+	return migration{version: version, phase: PhaseExpand, apply: func(ctx context.Context, s *Store) error {
+		return s.addColumnIfMissing(ctx, table, column, definition)
+	}}
+}
+
+// expand is an expand migration running idempotent SQL, such as creating a table.
+func expand(version int, stmt string) migration {
+	return migration{version: version, phase: PhaseExpand, apply: func(ctx context.Context, s *Store) error {
+		_, err := s.db.ExecContext(ctx, stmt)
+		return err
+	}}
+}
+
+// reformatTimes is an expand migration rewriting a table's timestamp columns in timeLayout.
+// Rows written earlier used RFC3339Nano, whose variable width sorts "05Z" after "05.1Z".
+func reformatTimes(version int, table string, columns ...string) migration {
+	return migration{version: version, phase: PhaseExpand, apply: func(ctx context.Context, s *Store) error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		for _, col := range columns {
+			rows, err := tx.QueryContext(ctx, `SELECT rowid, `+col+` FROM `+table+` WHERE `+col+` IS NOT NULL`)
+			if err != nil {
+				return err
+			}
+			updates := make(map[int64]string)
+			for rows.Next() {
+				var rowid int64
+				var value string
+				if err := rows.Scan(&rowid, &value); err != nil {
+					rows.Close()
+					return err
+				}
+				if t, err := time.Parse(time.RFC3339Nano, value); err == nil && formatTime(t) != value {
+					updates[rowid] = formatTime(t)
+				}
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			for rowid, value := range updates {
+				if _, err := tx.ExecContext(ctx, `UPDATE `+table+` SET `+col+` = ? WHERE rowid = ?`, value, rowid); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Commit()
+	}}
+}
+
+// migrations lists every schema change after the initial schema. Append new ones with the
+// next version number; never renumber or edit an applied migration.
+var migrations = []migration{
+	addColumn(1, "targets", "type", "TEXT NOT NULL DEFAULT 'http'"),
+	addColumn(2, "targets", "heartbeat_token", "TEXT"),
+	addColumn(3, "targets", "grace_period_seconds", "INTEGER NOT NULL DEFAULT 0"),
+	addColumn(4, "targets", "last_ping_at", "TEXT"),
+	addColumn(5, "targets", "capture_headers", "TEXT"),
+	addColumn(6, "targets", "status_policy", "TEXT"),
+	addColumn(7, "check_results", "headers", "TEXT"),
+	addColumn(8, "check_results", "body_truncated", "INTEGER NOT NULL DEFAULT 0"),
+	addColumn(9, "check_results", "attempts", "TEXT"),
+	addColumn(10, "check_results", "error_category", "TEXT"),
+	addColumn(11, "check_results", "outcome", "TEXT"),
+	addColumn(12, "target_state_transitions", "cause", "TEXT"),
+	addColumn(13, "check_results", "cached_dns_failure", "INTEGER NOT NULL DEFAULT 0"),
+	addColumn(14, "targets", "timeout_budget_ms", "INTEGER NOT NULL DEFAULT 0"),
+	expand(15, `CREATE TABLE IF NOT EXISTS paused_hosts (host TEXT PRIMARY KEY, paused_at TEXT NOT NULL)`),
+	addColumn(16, "check_results", "latency_us", "INTEGER NOT NULL DEFAULT 0"),
+	expand(17, `UPDATE check_results SET latency_us = latency_ms * 1000 WHERE latency_us = 0`),
+	addColumn(18, "check_results", "timings", "TEXT"),
+	reformatTimes(19, "targets", "created_at", "last_ping_at"),
+	reformatTimes(20, "check_results", "checked_at"),
+	reformatTimes(21, "target_state_transitions", "at"),
+	reformatTimes(22, "idempotency_keys", "created_at"),
+	reformatTimes(23, "jobs", "created_at", "started_at", "finished_at"),
+	reformatTimes(24, "paused_hosts", "paused_at"),
+	reformatTimes(25, "schema_migrations", "applied_at"),
+	expand(26, `CREATE TABLE IF NOT EXISTS check_queue (
+		target_id   TEXT PRIMARY KEY,
+		host        TEXT NOT NULL,
+		seq         INTEGER NOT NULL, -- Position among the host's queued checks; claims go by (seq, enqueued_at)
+		enqueued_at TEXT NOT NULL,
+		claimed_at  TEXT
+	)`),
+	expand(27, `CREATE INDEX IF NOT EXISTS idx_check_queue_claim ON check_queue (claimed_at, seq, enqueued_at)`),
+	expand(28, `CREATE TABLE IF NOT EXISTS leases (name TEXT PRIMARY KEY, holder TEXT NOT NULL, expires_at TEXT NOT NULL)`),
+	addColumn(29, "targets", "metadata", "TEXT"),
+	expand(30, `CREATE TABLE IF NOT EXISTS target_aliases (
+		target_id     TEXT NOT NULL,
+		url           TEXT NOT NULL, -- As submitted, before canonicalization
+		first_seen_at TEXT NOT NULL,
+		PRIMARY KEY (target_id, url),
+		FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+	)`),
+	expand(31, `INSERT OR IGNORE INTO target_aliases (target_id, url, first_seen_at) SELECT id, url, created_at FROM targets`),
+	addColumn(32, "targets", "result_sampling", "TEXT"),
+	addColumn(33, "targets", "results_sampled_until", "TEXT"),
+	addColumn(34, "targets", "snoozed_until", "TEXT"),
+	addColumn(35, "check_results", "partial", "INTEGER NOT NULL DEFAULT 0"),
+	addColumn(36, "targets", "latency_threshold_ms", "INTEGER NOT NULL DEFAULT 0"),
+	expand(37, `CREATE TABLE IF NOT EXISTS system_events (
+		id      TEXT PRIMARY KEY,
+		type    TEXT NOT NULL,
+		at      TEXT NOT NULL,
+		message TEXT NOT NULL,
+		details TEXT -- JSON object of strings
+	)`),
+	expand(38, `CREATE INDEX IF NOT EXISTS idx_system_events_at ON system_events (at)`),
+	expand(39, `CREATE TABLE IF NOT EXISTS archived_days (
+		day         TEXT PRIMARY KEY, -- YYYY-MM-DD, UTC
+		object      TEXT NOT NULL,
+		results     INTEGER NOT NULL,
+		archived_at TEXT NOT NULL,
+		restored_at TEXT
+	)`),
+	addColumn(40, "targets", "dependencies", "TEXT"),   // JSON object, see models.Dependencies
+	addColumn(41, "targets", "schedule", "TEXT"),       // JSON object, see models.CheckSchedule
+	addColumn(42, "targets", "exec", "TEXT"),           // JSON object, see models.ExecCheck
+	addColumn(43, "targets", "security_audit", "TEXT"), // JSON object, see models.SecurityAudit
+	addColumn(44, "check_results", "security", "TEXT"), // JSON object, see models.SecurityReport
+	addColumn(45, "targets", "asset_check", "TEXT"),    // JSON object, see models.AssetCheck
+	addColumn(46, "check_results", "assets", "TEXT"),   // JSON object, see models.AssetReport
+	addColumn(47, "targets", "min_tls_version", "TEXT"),
+	addColumn(48, "check_results", "tls", "TEXT"), // JSON object, see models.TLSInfo
+	expand(49, `CREATE TABLE IF NOT EXISTS domains (
+		name           TEXT PRIMARY KEY,
+		registrar      TEXT NOT NULL DEFAULT '',
+		expires_at     TEXT,
+		targets        INTEGER NOT NULL,
+		checked_at     TEXT NOT NULL,
+		error          TEXT,
+		alerted_expiry TEXT
+	)`),
+	// A single counter bumped by every change to targets, so the scheduler can tell whether
+	// its cached target list is stale without reading it.
+	expand(50, `CREATE TABLE IF NOT EXISTS target_version (
+		id      INTEGER PRIMARY KEY CHECK (id = 1),
+		version INTEGER NOT NULL
+	);
+	INSERT OR IGNORE INTO target_version (id, version) VALUES (1, 0);
+	CREATE TRIGGER IF NOT EXISTS targets_version_insert AFTER INSERT ON targets
+	BEGIN UPDATE target_version SET version = version + 1; END;
+	CREATE TRIGGER IF NOT EXISTS targets_version_update AFTER UPDATE ON targets
+	BEGIN UPDATE target_version SET version = version + 1; END;
+	CREATE TRIGGER IF NOT EXISTS targets_version_delete AFTER DELETE ON targets
+	BEGIN UPDATE target_version SET version = version + 1; END`),
+	addColumn(51, "check_results", "started_at", "TEXT"),
+	addColumn(52, "check_results", "completed_at", "TEXT"),
+	addColumn(53, "check_results", "seq", "INTEGER"),
+	// Existing results are numbered in the order they were checked; those with equal
+	// checked_at by ID, which is as good as any order they could have had before.
+	expand(54, `UPDATE check_results SET started_at = COALESCE(started_at, checked_at), seq = numbered.n
+	FROM (SELECT id AS rid, ROW_NUMBER() OVER (PARTITION BY target_id ORDER BY checked_at, id) AS n FROM check_results) AS numbered
+	WHERE check_results.id = numbered.rid AND check_results.seq IS NULL;
+	CREATE INDEX IF NOT EXISTS idx_check_results_target_seq ON check_results (target_id, seq)`),
+	// Older results only know the final attempt's latency, which is all there is for most.
+	addColumn(55, "check_results", "total_duration_ms", "INTEGER NOT NULL DEFAULT 0"),
+	expand(56, `UPDATE check_results SET total_duration_ms = latency_ms WHERE total_duration_ms < latency_ms`),
+	expand(57, `CREATE TABLE IF NOT EXISTS client_certificates (
+		host       TEXT PRIMARY KEY,
+		cert_pem   TEXT NOT NULL,
+		key_sealed BLOB NOT NULL, -- AES-GCM nonce and ciphertext of the key PEM, see WithClientCertSecret
+		subject    TEXT NOT NULL,
+		not_after  TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	)`),
+	// Results stored before bandwidth was recorded count as having downloaded nothing.
+	addColumn(58, "check_results", "bytes_downloaded", "INTEGER NOT NULL DEFAULT 0"),
+	addColumn(59, "targets", "wasm", "TEXT"), // JSON object, see models.WasmCheck
+}
+
+// SchemaVersion is the newest migration this build knows about.
+var SchemaVersion = migrations[len(migrations)-1].version
+
+// runMigrations refuses to start against a schema contracted past SchemaVersion, then applies
+// the migrations the database hasn't seen. A database already migrated past SchemaVersion by
+// a newer release is fine as long as those migrations were expand-only.
+func (s *Store) runMigrations(ctx context.Context) error {
+	var minAppVersion, applied int
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(min_app_version), 0), COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&minAppVersion, &applied)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if minAppVersion > SchemaVersion {
+		return fmt.Errorf("%w: the database requires schema version %d or later, this build is at %d", ErrIncompatibleSchema, minAppVersion, SchemaVersion)
+	}
+	if applied > SchemaVersion {
+		logging.Storage.Warnf("database schema is at version %d, ahead of this build (%d); running against the expanded schema", applied, SchemaVersion)
+	}
+
+	done := make(map[int]bool)
+	rows, err := s.db.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan applied migration: %w", err)
+		}
+		done[v] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var appliedNow []string
+	for _, m := range migrations {
+		if done[m.version] {
+			continue
+		}
+		if m.phase == PhaseContract && !s.contract {
+			logging.Storage.Infof("skipping contract migration %d until contract migrations are enabled", m.version)
+			continue
+		}
+		if err := m.apply(ctx, s); err != nil {
+			return fmt.Errorf("migration %d failed: %w", m.version, err)
+		}
+		_, err := s.db.ExecContext(ctx, `INSERT INTO schema_migrations (version, phase, min_app_version, applied_at) VALUES (?, ?, ?, ?)`,
+			m.version, m.phase, m.minAppVersion, formatTime(time.Now()))
+		if err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", m.version, err)
+		}
+		appliedNow = append(appliedNow, strconv.Itoa(m.version))
+	}
+	if len(appliedNow) > 0 {
+		err := s.RecordSystemEvent(ctx, &models.SystemEvent{
+			Type:    models.SystemEventMigrationApplied,
+			At:      time.Now(),
+			Message: fmt.Sprintf("applied %d migrations", len(appliedNow)),
+			Details: map[string]string{"versions": strings.Join(appliedNow, ",")},
+		})
+		if err != nil {
+			logging.Storage.Errorf("error recording applied migrations: %v", err)
+		}
+	}
+	return nil
+}
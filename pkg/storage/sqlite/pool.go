@@ -0,0 +1,91 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/metrics"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// ErrUnreachable is returned by New when the database can't be connected to at all, as
+// opposed to when it is reached but can't be migrated. Only the former is worth retrying.
+var ErrUnreachable = errors.New("unable to reach database")
+
+// poolStatsInterval is how often pool metrics are reported.
+const poolStatsInterval = 10 * time.Second
+
+// PoolConfig sizes the connection pools of the primary and the replica. Zero values keep
+// database/sql's defaults: unlimited open connections, 2 idle ones, and no maximum lifetime.
+type PoolConfig struct {
+	MaxOpen     int           // Most connections open at once
+	MaxIdle     int           // Most idle connections kept for reuse
+	MaxLifetime time.Duration // Connections are closed and reopened once this old
+	MaxIdleTime time.Duration // Idle connections are closed after this
+}
+
+// WithPool sizes the connection pools. Connections that fail are discarded and redialled
+// by database/sql on the next query, so a database that comes back is used again without a
+// restart; until then, DatabaseHealth reports it unavailable.
+func WithPool(cfg PoolConfig) Option {
+	return func(s *Store) { s.pool = cfg }
+}
+
+// configurePool applies the pool configuration to db.
+func (s *Store) configurePool(db *sql.DB) {
+	if s.pool.MaxOpen > 0 {
+		db.SetMaxOpenConns(s.pool.MaxOpen)
+	}
+	if s.pool.MaxIdle > 0 {
+		db.SetMaxIdleConns(s.pool.MaxIdle)
+	}
+	if s.pool.MaxLifetime > 0 {
+		db.SetConnMaxLifetime(s.pool.MaxLifetime)
+	}
+	if s.pool.MaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(s.pool.MaxIdleTime)
+	}
+}
+
+// poolStats describes db's connection pool.
+func poolStats(db *sql.DB) *models.DatabasePool {
+	st := db.Stats()
+	return &models.DatabasePool{
+		MaxOpen: st.MaxOpenConnections,
+		Open:    st.OpenConnections,
+		InUse:   st.InUse,
+		Idle:    st.Idle,
+		Waits:   st.WaitCount,
+		WaitMS:  st.WaitDuration.Milliseconds(),
+	}
+}
+
+// reportPoolStats sends pool gauges, and how many queries had to wait for a connection,
+// every poolStatsInterval until stop is closed.
+func (s *Store) reportPoolStats(stop <-chan struct{}) {
+	ticker := time.NewTicker(poolStatsInterval)
+	defer ticker.Stop()
+	waits := map[string]int64{}
+	report := func(role string, db *sql.DB) {
+		st, tag := db.Stats(), metrics.T("db", role)
+		s.metrics.Gauge("db.pool.open", float64(st.OpenConnections), tag)
+		s.metrics.Gauge("db.pool.in_use", float64(st.InUse), tag)
+		s.metrics.Gauge("db.pool.idle", float64(st.Idle), tag)
+		if n := st.WaitCount - waits[role]; n > 0 {
+			s.metrics.Count("db.pool.waits", n, tag)
+		}
+		waits[role] = st.WaitCount
+	}
+	for {
+		select {
+		case <-ticker.C:
+			report("primary", s.db)
+			if s.replica != nil {
+				report("replica", s.replica.db)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// EnqueueCheck queues a check of target behind the host's other queued checks.
+func (s *Store) EnqueueCheck(ctx context.Context, target models.Target, at time.Time) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO check_queue (target_id, host, seq, enqueued_at)
+		SELECT ?, ?, COALESCE(MAX(seq), 0) + 1, ? FROM check_queue WHERE host = ?`,
+		target.ID, target.Host, formatTime(at), target.Host)
+	if err != nil {
+		return false, fmt.Errorf("failed to enqueue check: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// ClaimCheck claims the queued check with the lowest position among its host's checks, so
+// hosts are served in turn. Checks of targets that no longer exist are discarded.
+func (s *Store) ClaimCheck(ctx context.Context, at time.Time) (*models.Target, error) {
+	for {
+		var id string
+		err := s.db.QueryRowContext(ctx, `
+			UPDATE check_queue SET claimed_at = ?
+			WHERE target_id = (
+				SELECT target_id FROM check_queue WHERE claimed_at IS NULL
+				ORDER BY seq, enqueued_at, target_id LIMIT 1
+			)
+			RETURNING target_id`, formatTime(at)).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrNotFound
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim check: %w", err)
+		}
+		t, err := s.GetTargetByID(ctx, id)
+		if errors.Is(err, storage.ErrNotFound) {
+			if err := s.CompleteCheck(ctx, id); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return t, err
+	}
+}
+
+// CompleteCheck removes a claimed check.
+func (s *Store) CompleteCheck(ctx context.Context, targetID string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM check_queue WHERE target_id = ?`, targetID); err != nil {
+		return fmt.Errorf("failed to complete check: %w", err)
+	}
+	return nil
+}
+
+// ReleaseClaimedChecks returns every claimed check to the queue.
+func (s *Store) ReleaseClaimedChecks(ctx context.Context) (int, error) {
+	res, err := s.db.ExecContext(ctx, `UPDATE check_queue SET claimed_at = NULL WHERE claimed_at IS NOT NULL`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to release claimed checks: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// CountQueuedChecks counts queued checks that haven't been claimed.
+func (s *Store) CountQueuedChecks(ctx context.Context) (int, error) {
+	var n int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM check_queue WHERE claimed_at IS NULL`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count queued checks: %w", err)
+	}
+	return n, nil
+}
+
+// OldestQueuedCheck returns when the longest-waiting unclaimed check was queued.
+func (s *Store) OldestQueuedCheck(ctx context.Context) (time.Time, error) {
+	var oldest sql.NullString
+	if err := s.db.QueryRowContext(ctx, `SELECT MIN(enqueued_at) FROM check_queue WHERE claimed_at IS NULL`).Scan(&oldest); err != nil {
+		return time.Time{}, fmt.Errorf("failed to find the oldest queued check: %w", err)
+	}
+	if t := parseNullTime(oldest); t != nil {
+		return *t, nil
+	}
+	return time.Time{}, nil
+}
@@ -0,0 +1,100 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/clock"
+	"github.com/zeng-yichen/linkwatch/pkg/logging"
+)
+
+// replicaRetryInterval is how long reads stay on the primary after the replica fails.
+const replicaRetryInterval = 30 * time.Second
+
+// Option configures optional Store behavior.
+type Option func(*Store)
+
+// WithReadReplica routes list and statistics queries to a read-only replica database
+// (for example a LiteFS or Litestream replica of the primary file). Writes always go to
+// the primary, and reads fall back to the primary while the replica is unavailable.
+func WithReadReplica(dataSourceName string) Option {
+	return func(s *Store) { s.replicaDSN = dataSourceName }
+}
+
+// WithClock sets the clock that times how long reads stay on the primary after the replica
+// fails. It defaults to the real clock.
+func WithClock(clk clock.Clock) Option {
+	return func(s *Store) { s.clock = clk }
+}
+
+// WithContractMigrations lets New apply contract migrations, which remove or change schema an
+// older release still depends on. Enable it only once no instance of an older release is
+// running against the database.
+func WithContractMigrations() Option {
+	return func(s *Store) { s.contract = true }
+}
+
+// replica tracks the read connection and whether it is currently usable.
+type replica struct {
+	db        *sql.DB
+	clock     clock.Clock
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+// openReplica opens the replica in query-only mode. A replica that cannot be reached at
+// startup is not fatal; reads use the primary until it becomes available.
+func (s *Store) openReplica(ctx context.Context) error {
+	db, err := s.open(withParams(s.replicaDSN, "_pragma=query_only(1)"), "replica")
+	if err != nil {
+		return fmt.Errorf("unable to open read replica: %w", err)
+	}
+	s.replica = &replica{db: db, clock: s.clock}
+	if err := db.PingContext(ctx); err != nil {
+		logging.Storage.Warnf("read replica unavailable, reading from primary: %v", err)
+		s.replica.markDown()
+	}
+	return nil
+}
+
+// withParams adds the query parameters params to dsn, after any it already has.
+func withParams(dsn, params string) string {
+	if strings.Contains(dsn, "?") {
+		return dsn + "&" + params
+	}
+	return dsn + "?" + params
+}
+
+func (r *replica) available() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.clock.Now().After(r.downUntil)
+}
+
+func (r *replica) markDown() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.downUntil = r.clock.Now().Add(replicaRetryInterval)
+}
+
+// queryRead runs a read-only query against the replica when one is configured and healthy,
+// falling back to the primary if the replica query fails.
+func (s *Store) queryRead(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if s.replica != nil && s.replica.available() {
+		rows, err := s.replica.db.QueryContext(ctx, query, args...)
+		if err == nil {
+			return rows, nil
+		}
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+		logging.Storage.Warnf("read replica query failed, falling back to primary for %s: %v", replicaRetryInterval, err)
+		s.replica.markDown()
+	}
+	return s.db.QueryContext(ctx, query, args...)
+}
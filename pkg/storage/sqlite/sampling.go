@@ -0,0 +1,88 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+// SampleCheckResults thins each sampled target's results between its results_sampled_until
+// mark and the start of its window, then moves the mark up, so a result is only ever
+// considered once. The first of every KeepOneIn results in that span is kept.
+func (s *Store) SampleCheckResults(ctx context.Context, at time.Time) (int, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, result_sampling, results_sampled_until FROM targets WHERE result_sampling IS NOT NULL`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list sampled targets: %w", err)
+	}
+	type sampled struct {
+		id     string
+		policy models.ResultSampling
+		until  string
+	}
+	var targets []sampled
+	for rows.Next() {
+		var t sampled
+		var policy string
+		var until sql.NullString
+		if err := rows.Scan(&t.id, &policy, &until); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan sampled target: %w", err)
+		}
+		if json.Unmarshal([]byte(policy), &t.policy) != nil || t.policy.KeepOneIn < 2 {
+			continue
+		}
+		t.until = until.String
+		targets = append(targets, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to list sampled targets: %w", err)
+	}
+
+	deleted := 0
+	for _, t := range targets {
+		cutoff := formatTime(at.Add(-t.policy.Window()))
+		if cutoff <= t.until {
+			continue
+		}
+		n, err := s.sampleTarget(ctx, t.id, t.until, cutoff, t.policy.KeepOneIn)
+		if err != nil {
+			return deleted, err
+		}
+		deleted += n
+	}
+	return deleted, nil
+}
+
+// sampleTarget thins a target's results checked in (after, until] and marks them sampled.
+func (s *Store) sampleTarget(ctx context.Context, targetID, after, until string, keepOneIn int) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		DELETE FROM check_results WHERE id IN (
+			SELECT id FROM (
+				SELECT id, ROW_NUMBER() OVER (ORDER BY checked_at, seq, id) AS n FROM check_results
+				WHERE target_id = ? AND checked_at > ? AND checked_at <= ?
+			) WHERE (n - 1) % ? != 0
+		) AND id NOT IN (SELECT result_id FROM target_state_transitions WHERE target_id = ?)`,
+		targetID, after, until, keepOneIn, targetID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sample check results: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE targets SET results_sampled_until = ? WHERE id = ?`, until, targetID); err != nil {
+		return 0, fmt.Errorf("failed to mark check results sampled: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
@@ -0,0 +1,1693 @@
+// Package sqlite implements storage.Storer on SQLite, migrating the schema on open.
+package sqlite
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	moderncsqlite "modernc.org/sqlite" // SQLite driver for database/sql
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/zeng-yichen/linkwatch/pkg/clock"
+	"github.com/zeng-yichen/linkwatch/pkg/metrics"
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+	"github.com/zeng-yichen/linkwatch/pkg/storage"
+)
+
+// Store implements the storage.Storer interface for SQLite.
+type Store struct {
+	db         *sql.DB
+	replicaDSN string
+	replica    *replica // Optional read replica for list and stats queries
+	contract   bool     // Apply contract migrations
+	clock      clock.Clock
+
+	compressAbove int              // Gzip large result columns at least this long; zero disables
+	metrics       metrics.Recorder // Times every statement when set
+	slowQuery     time.Duration    // Log statements taking at least this; zero disables
+	pool          PoolConfig
+	stopPoolStats chan struct{} // Closed by Close to stop reporting pool metrics
+	integrity     string        // Startup integrity check; empty skips it
+	autoVacuum    string        // auto_vacuum mode; empty keeps the database's
+	pageSize      int           // Page size; zero keeps the database's
+	certKeys      cipher.AEAD   // Encrypts client certificate keys; nil refuses to store them
+}
+
+// New creates a new Store and establishes a connection to the database file.
+// It also runs migrations to ensure the schema is up to date.
+func New(ctx context.Context, dataSourceName string, opts ...Option) (*Store, error) {
+	store := &Store{clock: clock.Real}
+	for _, opt := range opts {
+		opt(store)
+	}
+	// busy_timeout makes concurrent writers (workers storing results and claiming queued
+	// checks) wait for the lock instead of failing with SQLITE_BUSY. Transactions take the
+	// lock when they begin: one that read first and then wrote would fail without waiting
+	// if another writer got in between, as racing creates with one Idempotency-Key did.
+	db, err := store.open(withParams(dataSourceName, "_foreign_keys=on&_journal_mode=WAL&_txlock=immediate&_pragma=busy_timeout(5000)"), "primary")
+	if err != nil {
+		return nil, fmt.Errorf("unable to open sqlite database: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%w: %w", ErrUnreachable, err)
+	}
+	store.db = db
+	if err := store.checkIntegrity(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := store.applyLayout(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := store.migrate(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+	if store.replicaDSN != "" {
+		if err := store.openReplica(ctx); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if store.metrics != nil {
+		store.stopPoolStats = make(chan struct{})
+		go store.reportPoolStats(store.stopPoolStats)
+	}
+	return store, nil
+}
+
+// Close closes the database connections.
+func (s *Store) Close() error {
+	if s.stopPoolStats != nil {
+		close(s.stopPoolStats)
+	}
+	if s.replica != nil {
+		s.replica.db.Close()
+	}
+	return s.db.Close()
+}
+
+// migrate creates the initial schema and applies the migrations after it (see migrations.go).
+func (s *Store) migrate(ctx context.Context) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS targets (
+	id            TEXT PRIMARY KEY,
+	url           TEXT NOT NULL,
+	canonical_url TEXT NOT NULL UNIQUE,
+	host          TEXT NOT NULL,
+	created_at    TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_targets_created_at_id ON targets (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_targets_host ON targets (host);
+
+CREATE TABLE IF NOT EXISTS check_results (
+	id           TEXT PRIMARY KEY,
+	target_id    TEXT NOT NULL,
+	checked_at   TEXT NOT NULL,
+	status_code  INTEGER,
+	latency_ms   INTEGER NOT NULL,
+	error        TEXT,
+	FOREIGN KEY(target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_check_results_target_id_checked_at ON check_results (target_id, checked_at DESC);
+
+CREATE TABLE IF NOT EXISTS target_state_transitions (
+	target_id    TEXT NOT NULL,
+	from_status  TEXT NOT NULL,
+	to_status    TEXT NOT NULL,
+	at           TEXT NOT NULL,
+	result_id    TEXT NOT NULL,
+	FOREIGN KEY(target_id) REFERENCES targets(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_target_state_transitions_target_id_at ON target_state_transitions (target_id, at DESC);
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	key          TEXT PRIMARY KEY,
+	target_id    TEXT NOT NULL,
+	created_at   TEXT NOT NULL,
+	FOREIGN KEY(target_id) REFERENCES targets(id)
+);
+
+CREATE TABLE IF NOT EXISTS jobs (
+	id           TEXT PRIMARY KEY,
+	type         TEXT NOT NULL,
+	status       TEXT NOT NULL,
+	progress     INTEGER NOT NULL DEFAULT 0,
+	result       TEXT,
+	error        TEXT,
+	created_at   TEXT NOT NULL,
+	started_at   TEXT,
+	finished_at  TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs (status);
+
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version         INTEGER PRIMARY KEY,
+	phase           TEXT NOT NULL,
+	min_app_version INTEGER NOT NULL DEFAULT 0,
+	applied_at      TEXT NOT NULL
+);
+`
+	if _, err := s.db.ExecContext(ctx, schema); err != nil {
+		return err
+	}
+
+	if err := s.runMigrations(ctx); err != nil {
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, `CREATE UNIQUE INDEX IF NOT EXISTS idx_targets_heartbeat_token ON targets (heartbeat_token) WHERE heartbeat_token IS NOT NULL;`)
+	return err
+}
+
+// addColumnIfMissing adds a column to an existing table unless it is already present.
+func (s *Store) addColumnIfMissing(ctx context.Context, table, column, definition string) error {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return fmt.Errorf("failed to scan table info: %w", err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+// targetColumns is the column list scanned by scanTarget.
+const targetColumns = "id, url, canonical_url, host, created_at, type, heartbeat_token, grace_period_seconds, last_ping_at, capture_headers, status_policy, timeout_budget_ms, metadata, result_sampling, snoozed_until, latency_threshold_ms, dependencies, schedule, exec, security_audit, asset_check, min_tls_version, wasm"
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTarget scans a row selected with targetColumns into a Target.
+func scanTarget(row rowScanner) (models.Target, error) {
+	var t models.Target
+	var createdAtStr string
+	var token, lastPingStr, captureHeaders, statusPolicy, metadata, sampling, snoozedUntil, dependencies, schedule, execCheck, securityAudit, assetCheck, minTLS, wasmCheck sql.NullString
+	if err := row.Scan(&t.ID, &t.URL, &t.CanonicalURL, &t.Host, &createdAtStr, &t.Type, &token, &t.GracePeriodSeconds, &lastPingStr, &captureHeaders, &statusPolicy, &t.TimeoutBudgetMS, &metadata, &sampling, &snoozedUntil, &t.LatencyThresholdMS, &dependencies, &schedule, &execCheck, &securityAudit, &assetCheck, &minTLS, &wasmCheck); err != nil {
+		return t, err
+	}
+	if sampling.Valid {
+		json.Unmarshal([]byte(sampling.String), &t.ResultSampling)
+	}
+	if metadata.Valid {
+		json.Unmarshal([]byte(metadata.String), &t.Metadata)
+	}
+	if captureHeaders.Valid {
+		json.Unmarshal([]byte(captureHeaders.String), &t.CaptureHeaders)
+	}
+	if statusPolicy.Valid {
+		json.Unmarshal([]byte(statusPolicy.String), &t.StatusPolicy)
+	}
+	if dependencies.Valid {
+		json.Unmarshal([]byte(dependencies.String), &t.Dependencies)
+	}
+	if schedule.Valid {
+		json.Unmarshal([]byte(schedule.String), &t.Schedule)
+	}
+	if execCheck.Valid {
+		json.Unmarshal([]byte(execCheck.String), &t.Exec)
+	}
+	if wasmCheck.Valid {
+		json.Unmarshal([]byte(wasmCheck.String), &t.Wasm)
+	}
+	if securityAudit.Valid {
+		json.Unmarshal([]byte(securityAudit.String), &t.SecurityAudit)
+	}
+	if assetCheck.Valid {
+		json.Unmarshal([]byte(assetCheck.String), &t.AssetCheck)
+	}
+	t.MinTLSVersion = minTLS.String
+	t.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAtStr)
+	t.HeartbeatToken = token.String
+	if lastPingStr.Valid {
+		if lastPing, err := time.Parse(time.RFC3339Nano, lastPingStr.String); err == nil {
+			t.LastPingAt = &lastPing
+		}
+	}
+	if snoozedUntil.Valid {
+		if until, err := time.Parse(time.RFC3339Nano, snoozedUntil.String); err == nil {
+			t.SnoozedUntil = &until
+		}
+	}
+	return t, nil
+}
+
+// resultColumns is the column list scanned by scanResult.
+var resultColumns = "target_id, " + strings.Join(storage.ResultFields, ", ")
+
+// scanResult scans a row selected with resultColumns into a CheckResult.
+func scanResult(row rowScanner) (models.CheckResult, error) {
+	return scanResultFields(row, storage.ResultFields)
+}
+
+// resultFields validates requested result fields, defaulting to all of them.
+func resultFields(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return storage.ResultFields, nil
+	}
+	for _, f := range requested {
+		if !slices.Contains(storage.ResultFields, f) {
+			return nil, fmt.Errorf("unknown result field %q", f)
+		}
+	}
+	return requested, nil
+}
+
+// scanResultFields scans a row selecting target_id followed by the given result fields, whose
+// column names match their JSON names.
+func scanResultFields(row rowScanner, fields []string) (models.CheckResult, error) {
+	var r models.CheckResult
+	var checkedAtStr string
+	var errText, headers, attempts, category, outcome, timings, security, assets, tlsInfo, startedAt, completedAt sql.NullString
+	var seq sql.NullInt64
+	dest := []interface{}{&r.TargetID}
+	for _, f := range fields {
+		switch f {
+		case "id":
+			dest = append(dest, &r.ID)
+		case "checked_at":
+			dest = append(dest, &checkedAtStr)
+		case "status_code":
+			dest = append(dest, &r.StatusCode)
+		case "latency_ms":
+			dest = append(dest, &r.LatencyMS)
+		case "latency_us":
+			dest = append(dest, &r.LatencyUS)
+		case "error":
+			dest = append(dest, &errText)
+		case "error_category":
+			dest = append(dest, &category)
+		case "outcome":
+			dest = append(dest, &outcome)
+		case "headers":
+			dest = append(dest, &headers)
+		case "body_truncated":
+			dest = append(dest, &r.BodyTruncated)
+		case "partial":
+			dest = append(dest, &r.Partial)
+		case "cached_dns_failure":
+			dest = append(dest, &r.CachedDNSFailure)
+		case "attempts":
+			dest = append(dest, &attempts)
+		case "timings":
+			dest = append(dest, &timings)
+		case "security":
+			dest = append(dest, &security)
+		case "assets":
+			dest = append(dest, &assets)
+		case "tls":
+			dest = append(dest, &tlsInfo)
+		case "started_at":
+			dest = append(dest, &startedAt)
+		case "completed_at":
+			dest = append(dest, &completedAt)
+		case "seq":
+			dest = append(dest, &seq)
+		case "total_duration_ms":
+			dest = append(dest, &r.TotalDurationMS)
+		case "bytes_downloaded":
+			dest = append(dest, &r.BytesDownloaded)
+		default:
+			return r, fmt.Errorf("unknown result field %q", f)
+		}
+	}
+	if err := row.Scan(dest...); err != nil {
+		return r, err
+	}
+	if checkedAtStr != "" {
+		r.CheckedAt, _ = time.Parse(time.RFC3339Nano, checkedAtStr)
+	}
+	r.ErrorCategory, r.Outcome, r.Seq = category.String, outcome.String, seq.Int64
+	r.StartedAt, r.CompletedAt = parseNullTime(startedAt), parseNullTime(completedAt)
+	if errText = unpack(errText); errText.Valid {
+		r.Error = &errText.String
+	}
+	attempts, timings, security, assets = unpack(attempts), unpack(timings), unpack(security), unpack(assets)
+	if headers.Valid {
+		json.Unmarshal([]byte(headers.String), &r.Headers)
+	}
+	if attempts.Valid {
+		json.Unmarshal([]byte(attempts.String), &r.Attempts)
+	}
+	if timings.Valid {
+		json.Unmarshal([]byte(timings.String), &r.Timings)
+	}
+	if security.Valid {
+		json.Unmarshal([]byte(security.String), &r.Security)
+	}
+	if assets.Valid {
+		json.Unmarshal([]byte(assets.String), &r.Assets)
+	}
+	if tlsInfo.Valid {
+		json.Unmarshal([]byte(tlsInfo.String), &r.TLS)
+	}
+	return r, nil
+}
+
+// nullJSON encodes v as JSON, storing nil and empty values as SQL NULL.
+func nullJSON(v interface{}) sql.NullString {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return sql.NullString{}
+	}
+	switch string(b) {
+	case "null", "[]", "{}":
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(b), Valid: true}
+}
+
+// nullString converts an empty string to a SQL NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// isUniqueViolation reports whether err is from inserting a row whose primary key or unique
+// column is already taken.
+func isUniqueViolation(err error) bool {
+	var e *moderncsqlite.Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	return e.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY || e.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+func randomID(prefix string) string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return prefix + time.Now().UTC().Format("20060102150405")
+	}
+	return prefix + hex.EncodeToString(b)
+}
+
+// CreateTarget saves a new target, handling idempotency. A create that loses a race to
+// record its idempotency key answers with the target of the one that won.
+func (s *Store) CreateTarget(ctx context.Context, target *models.Target, idempotencyKey *string) (*models.Target, error) {
+	created, err := s.createTarget(ctx, target, idempotencyKey)
+	if idempotencyKey != nil && isUniqueViolation(err) {
+		return s.createTarget(ctx, target, idempotencyKey)
+	}
+	return created, err
+}
+
+func (s *Store) createTarget(ctx context.Context, target *models.Target, idempotencyKey *string) (*models.Target, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if idempotencyKey != nil {
+		var existingTargetID string
+		query := `SELECT target_id FROM idempotency_keys WHERE key = ?`
+		err := tx.QueryRowContext(ctx, query, *idempotencyKey).Scan(&existingTargetID)
+		if err == nil {
+			return s.getTargetByIDTx(ctx, tx, existingTargetID)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to check idempotency key: %w", err)
+		}
+	}
+
+	// Insert target if not exists by canonical URL
+	if target.Type == "" {
+		target.Type = models.TargetTypeHTTP
+	}
+	query := `
+INSERT INTO targets (id, url, canonical_url, host, created_at, type, heartbeat_token, grace_period_seconds, capture_headers, status_policy, timeout_budget_ms, metadata, result_sampling, latency_threshold_ms, exec, wasm)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(canonical_url) DO NOTHING`
+	res, err := tx.ExecContext(ctx, query, target.ID, target.URL, target.CanonicalURL, target.Host, formatTime(target.CreatedAt),
+		target.Type, nullString(target.HeartbeatToken), target.GracePeriodSeconds, nullJSON(target.CaptureHeaders), nullJSON(target.StatusPolicy), target.TimeoutBudgetMS, nullJSON(target.Metadata), nullJSON(target.ResultSampling), target.LatencyThresholdMS, nullJSON(target.Exec), nullJSON(target.Wasm))
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert target: %w", err)
+	}
+	rowsAffected, _ := res.RowsAffected()
+	if rowsAffected == 0 {
+		findQuery := `SELECT ` + targetColumns + ` FROM targets WHERE canonical_url = ?`
+		existingTarget, err := scanTarget(tx.QueryRowContext(ctx, findQuery, target.CanonicalURL))
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve existing target: %w", err)
+		}
+		// The URL may be a different form of the stored one; keep it as an alias.
+		if err := insertAlias(ctx, tx, existingTarget.ID, target.URL, target.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return &existingTarget, storage.ErrDuplicateKey
+	}
+	if err := insertAlias(ctx, tx, target.ID, target.URL, target.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	if idempotencyKey != nil {
+		insertKeyQuery := `INSERT INTO idempotency_keys (key, target_id, created_at) VALUES (?, ?, ?)`
+		if _, err := tx.ExecContext(ctx, insertKeyQuery, *idempotencyKey, target.ID, formatTime(time.Now())); err != nil {
+			return nil, fmt.Errorf("failed to record idempotency key: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return target, nil
+}
+
+// insertAlias records url as submitted for a target, unless it was seen before.
+func insertAlias(ctx context.Context, tx *sql.Tx, targetID, url string, at time.Time) error {
+	query := `INSERT OR IGNORE INTO target_aliases (target_id, url, first_seen_at) VALUES (?, ?, ?)`
+	if _, err := tx.ExecContext(ctx, query, targetID, url, formatTime(at)); err != nil {
+		return fmt.Errorf("failed to record target alias: %w", err)
+	}
+	return nil
+}
+
+// ListTargetAliases retrieves every URL submitted for a target, oldest first.
+func (s *Store) ListTargetAliases(ctx context.Context, targetID string) ([]models.TargetAlias, error) {
+	query := `SELECT url, first_seen_at FROM target_aliases WHERE target_id = ? ORDER BY first_seen_at, url`
+	rows, err := s.queryRead(ctx, query, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list target aliases: %w", err)
+	}
+	defer rows.Close()
+
+	aliases := []models.TargetAlias{}
+	for rows.Next() {
+		var a models.TargetAlias
+		var at string
+		if err := rows.Scan(&a.URL, &at); err != nil {
+			return nil, fmt.Errorf("failed to scan target alias: %w", err)
+		}
+		if a.FirstSeenAt, err = time.Parse(time.RFC3339Nano, at); err != nil {
+			return nil, fmt.Errorf("failed to parse alias time: %w", err)
+		}
+		aliases = append(aliases, a)
+	}
+	return aliases, rows.Err()
+}
+
+// RecordHeartbeat stores a ping for the heartbeat target with the given token and
+// returns the target as it was before the ping.
+func (s *Store) RecordHeartbeat(ctx context.Context, token string, at time.Time) (*models.Target, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + targetColumns + ` FROM targets WHERE heartbeat_token = ? AND type = ?`
+	t, err := scanTarget(tx.QueryRowContext(ctx, query, token, models.TargetTypeHeartbeat))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get heartbeat target: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE targets SET last_ping_at = ? WHERE id = ?`, formatTime(at), t.ID); err != nil {
+		return nil, fmt.Errorf("failed to record heartbeat: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &t, nil
+}
+
+// UpdateTarget writes the settings the patch changes in one statement and returns the
+// updated target, read in the same transaction.
+func (s *Store) UpdateTarget(ctx context.Context, id string, patch storage.TargetPatch) (*models.Target, error) {
+	var sets []string
+	var args []interface{}
+	set := func(column string, value interface{}) {
+		sets = append(sets, column+` = ?`)
+		args = append(args, value)
+	}
+	if p := patch.CaptureHeaders; p.Set {
+		set(`capture_headers`, nullJSON(p.Value))
+	}
+	if p := patch.StatusPolicy; p.Set {
+		set(`status_policy`, nullJSON(p.Value))
+	}
+	if p := patch.TimeoutBudget; p.Set {
+		set(`timeout_budget_ms`, p.Value.Milliseconds())
+	}
+	if p := patch.LatencyThreshold; p.Set {
+		set(`latency_threshold_ms`, p.Value.Milliseconds())
+	}
+	if p := patch.Metadata; p.Set {
+		set(`metadata`, nullJSON(p.Value))
+	}
+	if p := patch.ResultSampling; p.Set {
+		set(`result_sampling`, nullJSON(p.Value))
+	}
+	if p := patch.Dependencies; p.Set {
+		set(`dependencies`, nullJSON(p.Value))
+	}
+	if p := patch.Schedule; p.Set {
+		set(`schedule`, nullJSON(p.Value))
+	}
+	if p := patch.SecurityAudit; p.Set {
+		set(`security_audit`, nullJSON(p.Value))
+	}
+	if p := patch.AssetCheck; p.Set {
+		set(`asset_check`, nullJSON(p.Value))
+	}
+	if p := patch.MinTLSVersion; p.Set {
+		set(`min_tls_version`, nullString(p.Value))
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if len(sets) > 0 {
+		res, err := tx.ExecContext(ctx, `UPDATE targets SET `+strings.Join(sets, `, `)+` WHERE id = ?`, append(args, id)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update target: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil, storage.ErrNotFound
+		}
+	}
+	target, err := s.getTargetByIDTx(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return target, nil
+}
+
+// SetSnooze sets when a target's snooze ends, or clears it, and returns the updated target.
+func (s *Store) SetSnooze(ctx context.Context, id string, until *time.Time) (*models.Target, error) {
+	var value sql.NullString
+	if until != nil {
+		value = sql.NullString{String: formatTime(*until), Valid: true}
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE targets SET snoozed_until = ? WHERE id = ?`, value, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set snooze: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, storage.ErrNotFound
+	}
+	target, err := s.getTargetByIDTx(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return target, nil
+}
+
+// getTargetByIDTx retrieves a target within a transaction.
+func (s *Store) getTargetByIDTx(ctx context.Context, tx *sql.Tx, id string) (*models.Target, error) {
+	query := `SELECT ` + targetColumns + ` FROM targets WHERE id = ?`
+	t, err := scanTarget(tx.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get target by id: %w", err)
+	}
+	return &t, nil
+}
+
+// GetTargetByID retrieves a single target by its unique ID.
+func (s *Store) GetTargetByID(ctx context.Context, id string) (*models.Target, error) {
+	query := `SELECT ` + targetColumns + ` FROM targets WHERE id = ?`
+	t, err := scanTarget(s.db.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get target by id: %w", err)
+	}
+	return &t, nil
+}
+
+// ListTargets retrieves a paginated list of targets.
+func (s *Store) ListTargets(ctx context.Context, params storage.ListTargetsParams) ([]models.Target, error) {
+	var args []interface{}
+	qb := strings.Builder{}
+	qb.WriteString("SELECT " + targetColumns + " FROM targets WHERE 1=1")
+	if params.Host != "" {
+		args = append(args, params.Host)
+		qb.WriteString(" AND host = ?")
+	}
+	args = appendMetadataFilter(&qb, args, params.Metadata)
+	if !params.AfterTime.IsZero() && params.AfterID != "" {
+		args = append(args, formatTime(params.AfterTime), params.AfterID)
+		qb.WriteString(" AND (created_at, id) > (?, ?)")
+	}
+	qb.WriteString(" ORDER BY created_at, id LIMIT ?")
+	args = append(args, params.Limit)
+
+	rows, err := s.queryRead(ctx, qb.String(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list targets: %w", err)
+	}
+	defer rows.Close()
+	var targets []models.Target
+	for rows.Next() {
+		t, err := scanTarget(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan target row: %w", err)
+		}
+		targets = append(targets, t)
+	}
+	return targets, rows.Err()
+}
+
+// healthRankExpr ranks a target, as storage.HealthRank does, by its latest result: the one
+// GetLatestResults returns. Its parameter is the degraded latency in nanoseconds.
+const healthRankExpr = `COALESCE((SELECT CASE
+		WHEN NOT ` + successCondition + ` THEN 0
+		WHEN outcome IN ('warning', 'partial', 'slow') OR latency_ms * 1000000 >= ? THEN 1
+		ELSE 2 END
+	FROM check_results WHERE target_id = targets.id ORDER BY checked_at DESC, seq DESC LIMIT 1), 3)`
+
+// ListTargetsByHealth ranks targets in the query, so a page reads only its own targets and
+// their latest results.
+func (s *Store) ListTargetsByHealth(ctx context.Context, params storage.ListTargetsByHealthParams) ([]storage.RankedTarget, error) {
+	args := []interface{}{int64(params.DegradedLatency)}
+	qb := strings.Builder{}
+	qb.WriteString("SELECT * FROM (SELECT " + targetColumns + ", " + healthRankExpr + " AS health_rank FROM targets WHERE 1=1")
+	if params.Host != "" {
+		args = append(args, params.Host)
+		qb.WriteString(" AND host = ?")
+	}
+	args = appendMetadataFilter(&qb, args, params.Metadata)
+	qb.WriteString(")")
+	if params.AfterID != "" {
+		args = append(args, params.AfterRank, formatTime(params.AfterTime), params.AfterID)
+		qb.WriteString(" WHERE (health_rank, created_at, id) > (?, ?, ?)")
+	}
+	qb.WriteString(" ORDER BY health_rank, created_at, id LIMIT ?")
+	args = append(args, params.Limit)
+
+	rows, err := s.queryRead(ctx, qb.String(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list targets by health: %w", err)
+	}
+	defer rows.Close()
+	var targets []storage.RankedTarget
+	for rows.Next() {
+		var rank int
+		t, err := scanTarget(rankScanner{rows, &rank})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan target row: %w", err)
+		}
+		targets = append(targets, storage.RankedTarget{Target: t, Rank: rank})
+	}
+	return targets, rows.Err()
+}
+
+// rankScanner scans a target row followed by its health rank.
+type rankScanner struct {
+	rows *sql.Rows
+	rank *int
+}
+
+func (r rankScanner) Scan(dest ...interface{}) error {
+	return r.rows.Scan(append(dest, r.rank)...)
+}
+
+// appendMetadataFilter adds a condition per metadata pair to a query, in key order so equal filters
+// give equal SQL.
+func appendMetadataFilter(qb *strings.Builder, args []interface{}, metadata map[string]string) []interface{} {
+	for _, k := range slices.Sorted(maps.Keys(metadata)) {
+		qb.WriteString(" AND json_extract(metadata, ?) = ?")
+		args = append(args, `$."`+k+`"`, metadata[k])
+	}
+	return args
+}
+
+// CountTargets counts targets, only those on host and with the metadata pairs when set.
+func (s *Store) CountTargets(ctx context.Context, host string, metadata map[string]string) (int, error) {
+	var args []interface{}
+	qb := strings.Builder{}
+	qb.WriteString(`SELECT COUNT(*) FROM targets WHERE 1=1`)
+	if host != "" {
+		qb.WriteString(` AND host = ?`)
+		args = append(args, host)
+	}
+	args = appendMetadataFilter(&qb, args, metadata)
+	rows, err := s.queryRead(ctx, qb.String(), args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count targets: %w", err)
+	}
+	defer rows.Close()
+	var n int
+	if rows.Next() {
+		if err := rows.Scan(&n); err != nil {
+			return 0, fmt.Errorf("failed to count targets: %w", err)
+		}
+	}
+	return n, rows.Err()
+}
+
+// targetChildTables lists the tables holding rows for a target, which DeleteTargets removes
+// along with it.
+var targetChildTables = []string{"check_results", "target_state_transitions", "idempotency_keys", "check_queue", "target_aliases"}
+
+// DeleteTargets deletes the targets on host with the metadata pairs, and their rows in every
+// table in targetChildTables, in one transaction.
+func (s *Store) DeleteTargets(ctx context.Context, host string, metadata map[string]string) ([]string, error) {
+	var args []interface{}
+	qb := strings.Builder{}
+	qb.WriteString(`SELECT id FROM targets WHERE 1=1`)
+	if host != "" {
+		qb.WriteString(` AND host = ?`)
+		args = append(args, host)
+	}
+	args = appendMetadataFilter(&qb, args, metadata)
+	matching := qb.String()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, matching, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find targets to delete: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan target id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to find targets to delete: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	for _, table := range targetChildTables {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE target_id IN (`+matching+`)`, args...); err != nil {
+			return nil, fmt.Errorf("failed to delete from %s: %w", table, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM targets WHERE id IN (`+matching+`)`, args...); err != nil {
+		return nil, fmt.Errorf("failed to delete targets: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return ids, nil
+}
+
+// UpdateTargets reads the selected targets and writes back the metadata and snooze of those
+// the mutation changes, all within one transaction.
+func (s *Store) UpdateTargets(ctx context.Context, filter storage.TargetFilter, mutation storage.TargetMutation) (int, int, error) {
+	var args []interface{}
+	qb := strings.Builder{}
+	qb.WriteString(`SELECT ` + targetColumns + ` FROM targets WHERE 1=1`)
+	if filter.Host != "" {
+		qb.WriteString(` AND host = ?`)
+		args = append(args, filter.Host)
+	}
+	if len(filter.IDs) > 0 {
+		qb.WriteString(` AND id IN (?` + strings.Repeat(`, ?`, len(filter.IDs)-1) + `)`)
+		for _, id := range filter.IDs {
+			args = append(args, id)
+		}
+	}
+	args = appendMetadataFilter(&qb, args, filter.Metadata)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, qb.String(), args...)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to find targets to update: %w", err)
+	}
+	var targets []models.Target
+	for rows.Next() {
+		t, err := scanTarget(rows)
+		if err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("failed to scan target row: %w", err)
+		}
+		targets = append(targets, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("failed to find targets to update: %w", err)
+	}
+
+	updated := 0
+	for _, t := range targets {
+		changed, err := mutation.Apply(&t)
+		if err != nil {
+			return 0, 0, err
+		}
+		if !changed {
+			continue
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE targets SET metadata = ?, snoozed_until = ? WHERE id = ?`,
+			nullJSON(t.Metadata), formatNullTime(t.SnoozedUntil), t.ID)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to update target %s: %w", t.ID, err)
+		}
+		updated++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(targets), updated, nil
+}
+
+// GetAllTargets retrieves all targets from the database.
+func (s *Store) GetAllTargets(ctx context.Context) ([]models.Target, error) {
+	query := `SELECT ` + targetColumns + ` FROM targets ORDER BY created_at, id`
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query all targets: %w", err)
+	}
+	defer rows.Close()
+	var targets []models.Target
+	for rows.Next() {
+		t, err := scanTarget(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan target row: %w", err)
+		}
+		targets = append(targets, t)
+	}
+	return targets, rows.Err()
+}
+
+// ListTargetsPage returns up to limit targets ordered by ID, starting after afterID.
+// Pass the last ID of the previous page to continue; an empty afterID starts from the beginning.
+func (s *Store) ListTargetsPage(ctx context.Context, afterID string, limit int) ([]models.Target, error) {
+	query := `SELECT ` + targetColumns + ` FROM targets WHERE id > ? ORDER BY id LIMIT ?`
+	rows, err := s.db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list targets page: %w", err)
+	}
+	defer rows.Close()
+	var targets []models.Target
+	for rows.Next() {
+		t, err := scanTarget(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan target row: %w", err)
+		}
+		targets = append(targets, t)
+	}
+	return targets, rows.Err()
+}
+
+// TargetVersion returns a counter that triggers on the targets table bump on every insert,
+// update, and delete, whichever process makes them.
+func (s *Store) TargetVersion(ctx context.Context) (int64, error) {
+	var version int64
+	if err := s.db.QueryRowContext(ctx, `SELECT version FROM target_version WHERE id = 1`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read target version: %w", err)
+	}
+	return version, nil
+}
+
+// CreateCheckResult saves a new check result to the database. Results without an ID get
+// a deterministic one (see models.ResultID), and writing a result whose ID already exists is
+// a no-op, so replayed or retried writes don't create duplicate rows. A result that changes
+// the target's status also records a state transition in the same transaction.
+func (s *Store) CreateCheckResult(ctx context.Context, result *models.CheckResult) error {
+	if result.ID == "" {
+		result.ID = models.ResultID(result.TargetID, result.CheckedAt, max(len(result.Attempts), 1))
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if result.LatencyUS == 0 {
+		// Results recorded without microsecond precision (e.g. heartbeat pings).
+		result.LatencyUS = result.LatencyMS * 1000
+	}
+	if result.StartedAt == nil {
+		started := result.CheckedAt
+		result.StartedAt = &started
+	}
+	// Checks made in one attempt take as long as it did.
+	result.TotalDurationMS = max(result.TotalDurationMS, result.LatencyMS)
+	if result.CompletedAt == nil {
+		// Writers that don't record when the check finished, such as heartbeat pings.
+		completed := result.StartedAt.Add(time.Duration(result.LatencyUS) * time.Microsecond)
+		result.CompletedAt = &completed
+	}
+
+	// seq is one more than the target's highest, which the write lock keeps unique. A
+	// duplicate inserts nothing and so returns no row.
+	query := `INSERT INTO check_results (id, target_id, checked_at, status_code, latency_ms, latency_us, error, error_category, outcome, headers, body_truncated, partial, cached_dns_failure, attempts, timings, security, assets, tls, started_at, completed_at, seq, total_duration_ms, bytes_downloaded)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, (SELECT COALESCE(MAX(seq), 0) + 1 FROM check_results WHERE target_id = ?), ?, ?)
+ON CONFLICT(id) DO NOTHING RETURNING seq`
+	err = tx.QueryRowContext(ctx, query, result.ID, result.TargetID, formatTime(result.CheckedAt), result.StatusCode, result.LatencyMS, result.LatencyUS, s.packString(result.Error),
+		nullString(result.ErrorCategory), nullString(result.Outcome), nullJSON(result.Headers), result.BodyTruncated, result.Partial, result.CachedDNSFailure,
+		s.pack(nullJSON(result.Attempts)), s.pack(nullJSON(result.Timings)), s.pack(nullJSON(result.Security)), s.pack(nullJSON(result.Assets)), nullJSON(result.TLS),
+		formatNullTime(result.StartedAt), formatNullTime(result.CompletedAt), result.TargetID, result.TotalDurationMS, result.BytesDownloaded).Scan(&result.Seq)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create check result: %w", err)
+	}
+
+	from, err := s.currentStatusTx(ctx, tx, result)
+	if err != nil {
+		return err
+	}
+	if to := models.StateFromResult(result).Status; to != from {
+		var cause string
+		if to == models.TargetStatusDown {
+			cause = result.FailureCause()
+		}
+		_, err := tx.ExecContext(ctx, `INSERT INTO target_state_transitions (target_id, from_status, to_status, at, result_id, cause) VALUES (?, ?, ?, ?, ?, ?)`,
+			result.TargetID, from, to, formatTime(result.CheckedAt), result.ID, nullString(cause))
+		if err != nil {
+			return fmt.Errorf("failed to record state transition: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// currentStatusTx returns a target's status before result: the status its latest transition
+// moved it to or, for targets whose results predate the transitions table, the status of its
+// latest earlier result.
+func (s *Store) currentStatusTx(ctx context.Context, tx *sql.Tx, result *models.CheckResult) (string, error) {
+	var status string
+	err := tx.QueryRowContext(ctx, `SELECT to_status FROM target_state_transitions WHERE target_id = ? ORDER BY rowid DESC LIMIT 1`, result.TargetID).Scan(&status)
+	if err == nil {
+		return status, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("failed to get target status: %w", err)
+	}
+
+	var prev models.CheckResult
+	var outcome sql.NullString
+	err = tx.QueryRowContext(ctx, `SELECT status_code, error, outcome FROM check_results WHERE target_id = ? AND id != ? ORDER BY checked_at DESC, seq DESC LIMIT 1`,
+		result.TargetID, result.ID).Scan(&prev.StatusCode, &prev.Error, &outcome)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.TargetStatusUnknown, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get previous result: %w", err)
+	}
+	prev.Outcome = outcome.String
+	return models.StateFromResult(&prev).Status, nil
+}
+
+// ListStateTransitions retrieves a target's state transitions, newest first.
+func (s *Store) ListStateTransitions(ctx context.Context, params storage.ListTransitionsParams) ([]models.StateTransition, error) {
+	query := `SELECT from_status, to_status, at, result_id, cause FROM target_state_transitions WHERE target_id = ?`
+	args := []interface{}{params.TargetID}
+	if params.Since != nil {
+		query += ` AND at >= ?`
+		args = append(args, formatTime(*params.Since))
+	}
+	if params.Until != nil {
+		query += ` AND at < ?`
+		args = append(args, formatTime(*params.Until))
+	}
+	query += ` ORDER BY at DESC, rowid DESC LIMIT ?`
+	args = append(args, params.Limit)
+
+	rows, err := s.queryRead(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list state transitions: %w", err)
+	}
+	defer rows.Close()
+
+	transitions := []models.StateTransition{}
+	for rows.Next() {
+		t := models.StateTransition{TargetID: params.TargetID}
+		var at string
+		var cause sql.NullString
+		if err := rows.Scan(&t.FromStatus, &t.ToStatus, &at, &t.ResultID, &cause); err != nil {
+			return nil, fmt.Errorf("failed to scan state transition: %w", err)
+		}
+		t.Cause = cause.String
+		if t.At, err = time.Parse(time.RFC3339Nano, at); err != nil {
+			return nil, fmt.Errorf("failed to parse transition time: %w", err)
+		}
+		transitions = append(transitions, t)
+	}
+	return transitions, rows.Err()
+}
+
+// ListCheckResultsByTargetID retrieves recent check results for a target.
+func (s *Store) ListCheckResultsByTargetID(ctx context.Context, params storage.ListCheckResultsParams) ([]models.CheckResult, error) {
+	args := []interface{}{params.TargetID}
+	qb := strings.Builder{}
+	fields, err := resultFields(params.Fields)
+	if err != nil {
+		return nil, err
+	}
+	qb.WriteString("SELECT target_id, " + strings.Join(fields, ", ") + " FROM check_results WHERE target_id = ?")
+	if params.Since != nil {
+		args = append(args, formatTime(*params.Since))
+		qb.WriteString(" AND checked_at > ?")
+	}
+	if params.Until != nil {
+		args = append(args, formatTime(*params.Until))
+		qb.WriteString(" AND checked_at < ?")
+	}
+	if params.HeaderName != "" {
+		args = append(args, `$."`+params.HeaderName+`"`, params.HeaderValue)
+		qb.WriteString(" AND json_extract(headers, ?) = ?")
+	}
+	if params.Audited {
+		qb.WriteString(" AND security IS NOT NULL")
+	}
+	qb.WriteString(" ORDER BY checked_at DESC, seq DESC LIMIT ?")
+	args = append(args, params.Limit)
+	rows, err := s.queryRead(ctx, qb.String(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list check results: %w", err)
+	}
+	defer rows.Close()
+	var results []models.CheckResult
+	for rows.Next() {
+		r, err := scanResultFields(rows, fields)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan check result row: %w", err)
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// ListRecentResults returns up to params.Limit of the most recent results for each target,
+// newest first, in a single query. Targets without results are absent from the map.
+func (s *Store) ListRecentResults(ctx context.Context, params storage.RecentResultsParams) (map[string][]models.CheckResult, error) {
+	grouped := make(map[string][]models.CheckResult, len(params.TargetIDs))
+	if len(params.TargetIDs) == 0 {
+		return grouped, nil
+	}
+	fields, err := resultFields(params.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(params.TargetIDs)), ", ")
+	args := make([]interface{}, 0, len(params.TargetIDs)+3)
+	for _, id := range params.TargetIDs {
+		args = append(args, id)
+	}
+	where := "target_id IN (" + placeholders + ")"
+	if params.Since != nil {
+		args = append(args, formatTime(*params.Since))
+		where += " AND checked_at > ?"
+	}
+	if params.Until != nil {
+		args = append(args, formatTime(*params.Until))
+		where += " AND checked_at < ?"
+	}
+	args = append(args, params.Limit)
+	query := `
+SELECT target_id, ` + strings.Join(fields, ", ") + ` FROM (
+	SELECT *, ROW_NUMBER() OVER (PARTITION BY target_id ORDER BY checked_at DESC, seq DESC) AS rn
+	FROM check_results
+	WHERE ` + where + `
+)
+WHERE rn <= ?
+ORDER BY target_id, checked_at DESC, seq DESC`
+	rows, err := s.queryRead(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recent results: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r, err := scanResultFields(rows, fields)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan check result row: %w", err)
+		}
+		grouped[r.TargetID] = append(grouped[r.TargetID], r)
+	}
+	return grouped, rows.Err()
+}
+
+// GetLatestResults returns the most recent check result for each of the given targets.
+// Targets without results are absent from the map.
+func (s *Store) GetLatestResults(ctx context.Context, targetIDs []string) (map[string]models.CheckResult, error) {
+	latest := make(map[string]models.CheckResult, len(targetIDs))
+	if len(targetIDs) == 0 {
+		return latest, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(targetIDs)), ", ")
+	query := `
+SELECT ` + resultColumns + ` FROM check_results
+WHERE id IN (
+	SELECT (SELECT c.id FROM check_results c WHERE c.target_id = t.id ORDER BY c.checked_at DESC, c.seq DESC LIMIT 1)
+	FROM targets t WHERE t.id IN (` + placeholders + `)
+)`
+	args := make([]interface{}, len(targetIDs))
+	for i, id := range targetIDs {
+		args[i] = id
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest results: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r, err := scanResult(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan check result row: %w", err)
+		}
+		latest[r.TargetID] = r
+	}
+	return latest, rows.Err()
+}
+
+// GetLatestResultsAt retrieves the latest result checked at or before at for each of the
+// given targets.
+func (s *Store) GetLatestResultsAt(ctx context.Context, targetIDs []string, at time.Time) (map[string]models.CheckResult, error) {
+	latest := make(map[string]models.CheckResult, len(targetIDs))
+	if len(targetIDs) == 0 {
+		return latest, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(targetIDs)), ", ")
+	query := `
+SELECT ` + resultColumns + ` FROM check_results
+WHERE id IN (
+	SELECT (SELECT c.id FROM check_results c WHERE c.target_id = t.id AND c.checked_at <= ? ORDER BY c.checked_at DESC, c.seq DESC LIMIT 1)
+	FROM targets t WHERE t.id IN (` + placeholders + `)
+)`
+	args := make([]interface{}, 0, len(targetIDs)+1)
+	args = append(args, formatTime(at))
+	for _, id := range targetIDs {
+		args = append(args, id)
+	}
+	rows, err := s.queryRead(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get results at %s: %w", formatTime(at), err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r, err := scanResult(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan check result row: %w", err)
+		}
+		latest[r.TargetID] = r
+	}
+	return latest, rows.Err()
+}
+
+// GetLatestTransitionsAt retrieves the latest state transition at or before at for each of
+// the given targets.
+func (s *Store) GetLatestTransitionsAt(ctx context.Context, targetIDs []string, at time.Time) (map[string]models.StateTransition, error) {
+	latest := make(map[string]models.StateTransition, len(targetIDs))
+	if len(targetIDs) == 0 {
+		return latest, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(targetIDs)), ", ")
+	query := `
+SELECT target_id, from_status, to_status, at, result_id, cause FROM target_state_transitions
+WHERE rowid IN (
+	SELECT (SELECT s.rowid FROM target_state_transitions s WHERE s.target_id = t.id AND s.at <= ? ORDER BY s.at DESC, s.rowid DESC LIMIT 1)
+	FROM targets t WHERE t.id IN (` + placeholders + `)
+)`
+	args := make([]interface{}, 0, len(targetIDs)+1)
+	args = append(args, formatTime(at))
+	for _, id := range targetIDs {
+		args = append(args, id)
+	}
+	rows, err := s.queryRead(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state transitions at %s: %w", formatTime(at), err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t models.StateTransition
+		var transitionAt string
+		var cause sql.NullString
+		if err := rows.Scan(&t.TargetID, &t.FromStatus, &t.ToStatus, &transitionAt, &t.ResultID, &cause); err != nil {
+			return nil, fmt.Errorf("failed to scan state transition: %w", err)
+		}
+		t.Cause = cause.String
+		if t.At, err = time.Parse(time.RFC3339Nano, transitionAt); err != nil {
+			return nil, fmt.Errorf("failed to parse transition time: %w", err)
+		}
+		latest[t.TargetID] = t
+	}
+	return latest, rows.Err()
+}
+
+// successCondition is the SQL predicate used to classify a check result as successful.
+// Results without a status code (e.g. heartbeat pings) succeed as long as no error was recorded,
+// and an outcome assigned by the target's status policy overrides the default 2xx/3xx rule.
+const successCondition = `(error IS NULL AND CASE WHEN outcome IS NOT NULL THEN outcome NOT IN ('failure', 'suppressed_by_dependency') ELSE (status_code IS NULL OR (status_code >= 200 AND status_code < 400)) END)`
+
+// GetTimeseries aggregates check results for a target into fixed-size time buckets.
+func (s *Store) GetTimeseries(ctx context.Context, params storage.TimeseriesParams) ([]models.TimeseriesBucket, error) {
+	bucketSecs := int64(params.Bucket / time.Second)
+	if bucketSecs <= 0 {
+		return nil, fmt.Errorf("bucket must be at least one second")
+	}
+	query := `
+SELECT (CAST(strftime('%s', checked_at) AS INTEGER) / ?) * ? AS bucket,
+	AVG(latency_ms), MAX(latency_ms),
+	SUM(CASE WHEN ` + successCondition + ` THEN 1 ELSE 0 END),
+	SUM(CASE WHEN ` + successCondition + ` THEN 0 ELSE 1 END)
+FROM check_results
+WHERE target_id = ? AND checked_at >= ? AND checked_at < ?
+GROUP BY bucket
+ORDER BY bucket`
+	rows, err := s.queryRead(ctx, query, bucketSecs, bucketSecs, params.TargetID,
+		formatTime(params.Since), formatTime(params.Until))
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate check results: %w", err)
+	}
+	defer rows.Close()
+	var buckets []models.TimeseriesBucket
+	for rows.Next() {
+		var b models.TimeseriesBucket
+		var start int64
+		if err := rows.Scan(&start, &b.AvgLatencyMS, &b.MaxLatencyMS, &b.SuccessCount, &b.FailureCount); err != nil {
+			return nil, fmt.Errorf("failed to scan timeseries row: %w", err)
+		}
+		b.BucketStart = time.Unix(start, 0).UTC()
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
+
+// ListHostStats aggregates check results per target within the window, then sums them per
+// host, so hosts whose targets have no results in the window still appear.
+func (s *Store) ListHostStats(ctx context.Context, params storage.HostStatsParams) ([]models.HostStats, error) {
+	query := `
+SELECT t.host, COUNT(*), COALESCE(SUM(a.checks), 0), COALESCE(SUM(a.successes), 0), COALESCE(SUM(a.latency), 0),
+	MAX(p.host IS NOT NULL)
+FROM targets t
+LEFT JOIN paused_hosts p ON p.host = t.host
+LEFT JOIN (
+	SELECT target_id, COUNT(*) AS checks,
+		SUM(CASE WHEN ` + successCondition + ` THEN 1 ELSE 0 END) AS successes,
+		SUM(latency_ms) AS latency
+	FROM check_results
+	WHERE checked_at >= ? AND checked_at < ?
+	GROUP BY target_id
+) a ON a.target_id = t.id
+GROUP BY t.host
+ORDER BY t.host`
+	rows, err := s.queryRead(ctx, query, formatTime(params.Since), formatTime(params.Until))
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate host stats: %w", err)
+	}
+	defer rows.Close()
+	var hosts []models.HostStats
+	for rows.Next() {
+		var h models.HostStats
+		var successes, latency int64
+		if err := rows.Scan(&h.Host, &h.TargetCount, &h.CheckCount, &successes, &latency, &h.Paused); err != nil {
+			return nil, fmt.Errorf("failed to scan host stats row: %w", err)
+		}
+		if h.CheckCount > 0 {
+			rate := float64(successes) / float64(h.CheckCount)
+			h.SuccessRate = &rate
+			h.AvgLatencyMS = float64(latency) / float64(h.CheckCount)
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, rows.Err()
+}
+
+// GetBandwidthUsage sums bytes_downloaded per day and target or host. Days are cut from the
+// stored UTC timestamps, so they are UTC days.
+func (s *Store) GetBandwidthUsage(ctx context.Context, params storage.BandwidthParams) ([]models.BandwidthUsage, error) {
+	group, cols := "t.host", "t.host, '', ''"
+	if params.GroupBy == storage.BandwidthByTarget {
+		group, cols = "t.id", "t.host, t.id, t.url"
+	}
+	query := `
+SELECT substr(r.checked_at, 1, 10) AS day, ` + cols + `, COUNT(*), SUM(r.bytes_downloaded) AS bytes
+FROM check_results r
+JOIN targets t ON t.id = r.target_id
+WHERE r.checked_at >= ? AND r.checked_at < ?`
+	args := []any{formatTime(params.Since), formatTime(params.Until)}
+	if params.Host != "" {
+		query += ` AND t.host = ?`
+		args = append(args, params.Host)
+	}
+	if params.TargetID != "" {
+		query += ` AND r.target_id = ?`
+		args = append(args, params.TargetID)
+	}
+	query += `
+GROUP BY day, ` + group + `
+ORDER BY day, bytes DESC, ` + group
+
+	rows, err := s.queryRead(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate bandwidth usage: %w", err)
+	}
+	defer rows.Close()
+	var usage []models.BandwidthUsage
+	for rows.Next() {
+		var u models.BandwidthUsage
+		if err := rows.Scan(&u.Day, &u.Host, &u.TargetID, &u.URL, &u.CheckCount, &u.BytesDownloaded); err != nil {
+			return nil, fmt.Errorf("failed to scan bandwidth usage row: %w", err)
+		}
+		u.AvgBytes = float64(u.BytesDownloaded) / float64(u.CheckCount)
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// PauseHost records a host pause, keeping the existing one if the host is already paused.
+func (s *Store) PauseHost(ctx context.Context, host string, at time.Time) (*models.HostPause, error) {
+	_, err := s.db.ExecContext(ctx, `INSERT OR IGNORE INTO paused_hosts (host, paused_at) VALUES (?, ?)`, host, formatTime(at))
+	if err != nil {
+		return nil, fmt.Errorf("failed to pause host: %w", err)
+	}
+	var pausedAt string
+	if err := s.db.QueryRowContext(ctx, `SELECT paused_at FROM paused_hosts WHERE host = ?`, host).Scan(&pausedAt); err != nil {
+		return nil, fmt.Errorf("failed to read host pause: %w", err)
+	}
+	p := &models.HostPause{Host: host}
+	p.PausedAt, _ = time.Parse(time.RFC3339Nano, pausedAt)
+	return p, nil
+}
+
+// ResumeHost removes a host pause.
+func (s *Store) ResumeHost(ctx context.Context, host string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM paused_hosts WHERE host = ?`, host)
+	if err != nil {
+		return fmt.Errorf("failed to resume host: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+// ListPausedHosts returns every paused host, ordered by host.
+func (s *Store) ListPausedHosts(ctx context.Context) ([]models.HostPause, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT host, paused_at FROM paused_hosts ORDER BY host`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list paused hosts: %w", err)
+	}
+	defer rows.Close()
+	var pauses []models.HostPause
+	for rows.Next() {
+		var p models.HostPause
+		var pausedAt string
+		if err := rows.Scan(&p.Host, &pausedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan paused host row: %w", err)
+		}
+		p.PausedAt, _ = time.Parse(time.RFC3339Nano, pausedAt)
+		pauses = append(pauses, p)
+	}
+	return pauses, rows.Err()
+}
+
+// GetStatusBreakdown counts a target's check results within a time window by status class
+// and error category. Results with neither (heartbeat pings) count only toward the total.
+func (s *Store) GetStatusBreakdown(ctx context.Context, params storage.StatusBreakdownParams) (*models.StatusBreakdown, error) {
+	query := `
+SELECT CASE WHEN error IS NOT NULL THEN 'error' WHEN status_code IS NOT NULL THEN 'status' ELSE '' END AS kind,
+	CASE WHEN error IS NOT NULL THEN COALESCE(error_category, 'uncategorized')
+		WHEN status_code IS NOT NULL THEN (status_code / 100) || 'xx'
+		ELSE '' END AS class,
+	COUNT(*)
+FROM check_results
+WHERE target_id = ? AND checked_at >= ? AND checked_at < ?
+GROUP BY kind, class`
+	rows, err := s.queryRead(ctx, query, params.TargetID, formatTime(params.Since), formatTime(params.Until))
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate status breakdown: %w", err)
+	}
+	defer rows.Close()
+	breakdown := &models.StatusBreakdown{StatusClasses: map[string]int64{}, ErrorCategories: map[string]int64{}}
+	for rows.Next() {
+		var kind, class string
+		var n int64
+		if err := rows.Scan(&kind, &class, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan status breakdown row: %w", err)
+		}
+		breakdown.Total += n
+		switch kind {
+		case "status":
+			breakdown.StatusClasses[class] = n
+		case "error":
+			breakdown.ErrorCategories[class] = n
+		}
+	}
+	return breakdown, rows.Err()
+}
+
+// GetResultSummary summarizes a target's check results within a time window. Percentiles
+// are nearest-rank: the pth is the smallest latency at or above p percent of the results.
+func (s *Store) GetResultSummary(ctx context.Context, params storage.ResultSummaryParams) (*models.ResultSummary, error) {
+	query := `
+WITH windowed AS (
+	SELECT latency_ms, CASE WHEN ` + successCondition + ` THEN 1 ELSE 0 END AS ok,
+		ROW_NUMBER() OVER (ORDER BY latency_ms) AS rn, COUNT(*) OVER () AS n
+	FROM check_results
+	WHERE target_id = ? AND checked_at >= ? AND checked_at < ?
+)
+SELECT COUNT(*), COALESCE(SUM(ok), 0), COALESCE(AVG(latency_ms), 0),
+	COALESCE(MIN(CASE WHEN rn * 100 >= n * 50 THEN latency_ms END), 0),
+	COALESCE(MIN(CASE WHEN rn * 100 >= n * 90 THEN latency_ms END), 0),
+	COALESCE(MIN(CASE WHEN rn * 100 >= n * 95 THEN latency_ms END), 0),
+	COALESCE(MIN(CASE WHEN rn * 100 >= n * 99 THEN latency_ms END), 0)
+FROM windowed`
+	rows, err := s.queryRead(ctx, query, params.TargetID, formatTime(params.Since), formatTime(params.Until))
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize check results: %w", err)
+	}
+	defer rows.Close()
+	var summary models.ResultSummary
+	var p models.LatencyPercentiles
+	if rows.Next() {
+		if err := rows.Scan(&summary.CheckCount, &summary.SuccessCount, &summary.AvgLatencyMS, &p.P50, &p.P90, &p.P95, &p.P99); err != nil {
+			return nil, fmt.Errorf("failed to scan result summary: %w", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to summarize check results: %w", err)
+	}
+	rows.Close()
+	if summary.CheckCount > 0 {
+		rate := float64(summary.SuccessCount) / float64(summary.CheckCount)
+		summary.SuccessRate = &rate
+		summary.LatencyMS = &p
+	}
+	breakdown, err := s.GetStatusBreakdown(ctx, storage.StatusBreakdownParams(params))
+	if err != nil {
+		return nil, err
+	}
+	summary.ErrorCategories = breakdown.ErrorCategories
+	return &summary, nil
+}
+
+// ListTargetStats aggregates check results per target within a time window, ordered by the requested metric.
+func (s *Store) ListTargetStats(ctx context.Context, params storage.TargetStatsParams) ([]models.TargetStats, error) {
+	var orderBy string
+	switch params.OrderBy {
+	case storage.StatsOrderByLatency:
+		orderBy = "avg_latency DESC, t.id"
+	case storage.StatsOrderByFailures:
+		orderBy = "failure_count DESC, t.id"
+	default:
+		return nil, fmt.Errorf("unsupported stats ordering %q", params.OrderBy)
+	}
+	args := []interface{}{formatTime(params.Since), formatTime(params.Until)}
+	qb := strings.Builder{}
+	// An incident is a run of consecutive failed checks, so count failures whose previous check succeeded.
+	qb.WriteString(`
+WITH windowed AS (
+	SELECT target_id, latency_ms,
+		CASE WHEN ` + successCondition + ` THEN 1 ELSE 0 END AS ok,
+		LAG(CASE WHEN ` + successCondition + ` THEN 1 ELSE 0 END) OVER (PARTITION BY target_id ORDER BY checked_at, seq) AS prev_ok
+	FROM check_results
+	WHERE checked_at >= ? AND checked_at < ?
+)
+SELECT t.id, t.url, COUNT(*), SUM(w.ok), SUM(1 - w.ok) AS failure_count,
+	SUM(CASE WHEN w.ok = 0 AND COALESCE(w.prev_ok, 1) = 1 THEN 1 ELSE 0 END),
+	AVG(w.latency_ms) AS avg_latency, MAX(w.latency_ms)
+FROM windowed w
+JOIN targets t ON t.id = w.target_id
+GROUP BY t.id
+ORDER BY ` + orderBy)
+	if params.Limit > 0 {
+		qb.WriteString(" LIMIT ?")
+		args = append(args, params.Limit)
+	}
+	rows, err := s.queryRead(ctx, qb.String(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate target stats: %w", err)
+	}
+	defer rows.Close()
+	var stats []models.TargetStats
+	for rows.Next() {
+		var st models.TargetStats
+		if err := rows.Scan(&st.TargetID, &st.URL, &st.CheckCount, &st.SuccessCount, &st.FailureCount, &st.IncidentCount, &st.AvgLatencyMS, &st.MaxLatencyMS); err != nil {
+			return nil, fmt.Errorf("failed to scan target stats row: %w", err)
+		}
+		stats = append(stats, st)
+	}
+	return stats, rows.Err()
+}
+
+// jobColumns is the column list scanned by scanJob.
+const jobColumns = "id, type, status, progress, result, error, created_at, started_at, finished_at"
+
+// scanJob scans a row selected with jobColumns into a Job.
+func scanJob(row rowScanner) (*models.Job, error) {
+	var j models.Job
+	var result, startedStr, finishedStr sql.NullString
+	var createdAtStr string
+	if err := row.Scan(&j.ID, &j.Type, &j.Status, &j.Progress, &result, &j.Error, &createdAtStr, &startedStr, &finishedStr); err != nil {
+		return nil, err
+	}
+	j.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAtStr)
+	if result.Valid {
+		j.Result = json.RawMessage(result.String)
+	}
+	j.StartedAt = parseNullTime(startedStr)
+	j.FinishedAt = parseNullTime(finishedStr)
+	return &j, nil
+}
+
+// parseNullTime parses an optional RFC 3339 timestamp column.
+func parseNullTime(s sql.NullString) *time.Time {
+	if !s.Valid {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// timeLayout is how timestamps are stored: RFC 3339 in UTC with all nine fractional digits.
+// Unlike RFC3339Nano, which drops trailing zeros, every value has the same width, so text
+// comparison and ORDER BY match chronological order.
+const timeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// formatTime formats a timestamp for storage.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+// formatNullTime formats an optional timestamp for storage.
+func formatNullTime(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: formatTime(*t), Valid: true}
+}
+
+// CreateJob saves a new background job.
+func (s *Store) CreateJob(ctx context.Context, job *models.Job) error {
+	if job.ID == "" {
+		job.ID = randomID("job_")
+	}
+	query := `INSERT INTO jobs (` + jobColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := s.db.ExecContext(ctx, query, job.ID, job.Type, job.Status, job.Progress, nullString(string(job.Result)), job.Error,
+		formatTime(job.CreatedAt), formatNullTime(job.StartedAt), formatNullTime(job.FinishedAt))
+	if err != nil {
+		return fmt.Errorf("failed to create job: %w", err)
+	}
+	return nil
+}
+
+// UpdateJob persists the mutable fields of an existing job.
+func (s *Store) UpdateJob(ctx context.Context, job *models.Job) error {
+	query := `UPDATE jobs SET status = ?, progress = ?, result = ?, error = ?, started_at = ?, finished_at = ? WHERE id = ?`
+	res, err := s.db.ExecContext(ctx, query, job.Status, job.Progress, nullString(string(job.Result)), job.Error,
+		formatNullTime(job.StartedAt), formatNullTime(job.FinishedAt), job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update job: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+// GetJob retrieves a single job by its ID.
+func (s *Store) GetJob(ctx context.Context, id string) (*models.Job, error) {
+	job, err := scanJob(s.db.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	return job, nil
+}
+
+// FailUnfinishedJobs marks every queued or running job as failed with the given reason.
+// It is used at startup to settle jobs orphaned by a previous process.
+func (s *Store) FailUnfinishedJobs(ctx context.Context, reason string, at time.Time) (int, error) {
+	query := `UPDATE jobs SET status = ?, error = ?, finished_at = ? WHERE status IN (?, ?)`
+	res, err := s.db.ExecContext(ctx, query, models.JobStatusFailed, reason, formatTime(at),
+		models.JobStatusQueued, models.JobStatusRunning)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail unfinished jobs: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
@@ -0,0 +1,356 @@
+// Package storage defines the Storer interface the checker and API persist through, and the
+// narrower reader and writer interfaces it is composed of. The sqlite subpackage provides
+// the implementation used by the linkwatch server.
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/zeng-yichen/linkwatch/pkg/models"
+)
+
+var (
+	// ErrDuplicateKey is returned when attempting to create a duplicate resource
+	ErrDuplicateKey = errors.New("duplicate")
+	// ErrNotFound is returned when a requested resource is not found
+	ErrNotFound = errors.New("not found")
+)
+
+// ListTargetsParams contains parameters for listing targets with filtering and pagination
+type ListTargetsParams struct {
+	Host      string
+	AfterTime time.Time
+	AfterID   string
+	Limit     int
+
+	// Metadata keeps only targets whose metadata has every one of these key/value pairs.
+	Metadata map[string]string
+}
+
+// ListCheckResultsParams contains parameters for listing check results with filtering and pagination
+type ListCheckResultsParams struct {
+	TargetID string
+	Since    *time.Time // Exclusive
+	Until    *time.Time // Exclusive
+	Limit    int
+
+	// HeaderName and HeaderValue, when set, keep only results whose captured header matches.
+	HeaderName  string
+	HeaderValue string
+
+	// Audited keeps only results with a security header audit.
+	Audited bool
+
+	// Fields limits which ResultFields are loaded; all fields are loaded when empty.
+	// TargetID is always populated.
+	Fields []string
+}
+
+// RecentResultsParams contains parameters for listing recent results across several targets
+type RecentResultsParams struct {
+	TargetIDs []string
+	Since     *time.Time // Exclusive
+	Until     *time.Time // Exclusive
+	Limit     int        // Maximum results per target
+	Fields    []string   // As in ListCheckResultsParams
+}
+
+// ListTransitionsParams contains parameters for listing a target's state transitions
+type ListTransitionsParams struct {
+	TargetID string
+	Since    *time.Time // Inclusive
+	Until    *time.Time // Exclusive
+	Limit    int
+}
+
+// ListSystemEventsParams contains parameters for listing system events
+type ListSystemEventsParams struct {
+	Type  string     // Keeps only events of this type when set
+	Since *time.Time // Inclusive
+	Until *time.Time // Exclusive
+	Limit int
+}
+
+// ResultFields lists the selectable check result fields by their JSON names.
+var ResultFields = []string{"id", "checked_at", "status_code", "latency_ms", "latency_us", "error", "error_category", "outcome", "headers", "body_truncated", "partial", "cached_dns_failure", "attempts", "timings", "security", "assets", "tls", "started_at", "completed_at", "seq", "total_duration_ms", "bytes_downloaded"}
+
+// TimeseriesParams contains parameters for aggregating check results into time buckets
+type TimeseriesParams struct {
+	TargetID string
+	Bucket   time.Duration
+	Since    time.Time
+	Until    time.Time
+}
+
+// StatusBreakdownParams contains parameters for counting a target's results by status class
+type StatusBreakdownParams struct {
+	TargetID string
+	Since    time.Time
+	Until    time.Time
+}
+
+// ResultSummaryParams contains parameters for summarizing a target's results over a time window
+type ResultSummaryParams struct {
+	TargetID string
+	Since    time.Time
+	Until    time.Time
+}
+
+// HostStatsParams contains parameters for aggregating check statistics per host
+type HostStatsParams struct {
+	Since time.Time
+	Until time.Time
+}
+
+// Orderings supported when listing target statistics
+const (
+	StatsOrderByLatency  = "latency"
+	StatsOrderByFailures = "failures"
+)
+
+// TargetStatsParams contains parameters for aggregating per-target statistics across all targets
+type TargetStatsParams struct {
+	Since   time.Time
+	Until   time.Time
+	OrderBy string
+	Limit   int
+}
+
+// Groupings supported when aggregating bandwidth usage
+const (
+	BandwidthByTarget = "target"
+	BandwidthByHost   = "host"
+)
+
+// BandwidthParams contains parameters for aggregating downloaded bytes per day
+type BandwidthParams struct {
+	Since    time.Time
+	Until    time.Time
+	GroupBy  string // BandwidthByTarget or BandwidthByHost
+	Host     string // Optional; only targets on this host
+	TargetID string // Optional; only this target
+}
+
+// CheckQueue persists scheduled checks, so scheduled work survives a restart. A target is
+// queued at most once; a worker claims it, and it is removed only when the check is done.
+type CheckQueue interface {
+	// EnqueueCheck queues a check of target, reporting false if one is already queued or claimed.
+	EnqueueCheck(ctx context.Context, target models.Target, at time.Time) (bool, error)
+	// ClaimCheck claims the next queued check, taking hosts in turn so one host's targets
+	// can't hold up the others. It returns ErrNotFound when nothing is queued.
+	ClaimCheck(ctx context.Context, at time.Time) (*models.Target, error)
+	// CompleteCheck removes a claimed check.
+	CompleteCheck(ctx context.Context, targetID string) error
+	// ReleaseClaimedChecks returns every claimed check to the queue, e.g. at startup after a
+	// crash left checks claimed but unfinished. It returns how many were released.
+	ReleaseClaimedChecks(ctx context.Context) (int, error)
+	// CountQueuedChecks returns how many checks are queued and not yet claimed.
+	CountQueuedChecks(ctx context.Context) (int, error)
+	// OldestQueuedCheck returns when the longest-waiting unclaimed check was queued, or the
+	// zero time when none is.
+	OldestQueuedCheck(ctx context.Context) (time.Time, error)
+}
+
+// Leases grants named leases that expire unless renewed, so that one of several processes
+// sharing a store can act alone, e.g. as the scheduler, and another takes over when it dies.
+type Leases interface {
+	// AcquireLease takes the lease name for holder, or renews it if holder has it already, until
+	// at plus ttl. It reports false while another holder's lease hasn't expired.
+	AcquireLease(ctx context.Context, name, holder string, at time.Time, ttl time.Duration) (bool, error)
+	// ReleaseLease gives up holder's lease, so another holder can take it straight away.
+	ReleaseLease(ctx context.Context, name, holder string) error
+}
+
+// ResultSampler thins the stored results of targets with a ResultSampling policy.
+type ResultSampler interface {
+	// SampleCheckResults deletes all but one in every KeepOneIn of each such target's results
+	// that were outside its window at at, never the ones that changed its status. Every
+	// result is sampled once, so running it again doesn't thin what was kept. It returns how
+	// many results were deleted.
+	SampleCheckResults(ctx context.Context, at time.Time) (int, error)
+}
+
+// ArchivedDay records a UTC day whose check results were exported to an archive.
+type ArchivedDay struct {
+	Day        time.Time // Midnight UTC
+	Object     string    // Where the day's results were written
+	Results    int       // How many results were exported
+	ArchivedAt time.Time
+	RestoredAt *time.Time // Set while the day's results are restored locally
+}
+
+// ResultArchiveStore is implemented by stores whose aged check results can be exported to
+// an archive, pruned, and restored again. Results are archived a whole UTC day at a time.
+type ResultArchiveStore interface {
+	// UnarchivedResultDays returns up to limit days, oldest first, with results checked
+	// before before that haven't been archived.
+	UnarchivedResultDays(ctx context.Context, before time.Time, limit int) ([]time.Time, error)
+	// ExportCheckResults calls fn with every result of every target checked in
+	// [since, until), oldest first, stopping at the first error fn returns.
+	ExportCheckResults(ctx context.Context, since, until time.Time, fn func(models.CheckResult) error) error
+	// MarkResultsArchived records the day as archived and deletes its results, apart from
+	// those that changed a target's status, in one transaction. It returns how many results
+	// were deleted.
+	MarkResultsArchived(ctx context.Context, day ArchivedDay) (int, error)
+	// ListArchivedDays returns the archived days overlapping [since, until), oldest first.
+	ListArchivedDays(ctx context.Context, since, until time.Time) ([]ArchivedDay, error)
+	// RestoreCheckResults inserts archived results without recording state transitions,
+	// skipping results already stored and those of deleted targets. It returns how many
+	// results were inserted.
+	RestoreCheckResults(ctx context.Context, results []models.CheckResult) (int, error)
+	// MarkDayRestored records that every archived result of day was restored at at.
+	MarkDayRestored(ctx context.Context, day, at time.Time) error
+	// PruneRestoredResults deletes the results of days restored before before, as
+	// MarkResultsArchived did, and clears their restored mark.
+	PruneRestoredResults(ctx context.Context, before time.Time) (int, error)
+}
+
+// DomainStore is implemented by stores that keep the RDAP registrations of target domains.
+type DomainStore interface {
+	// UpsertDomain saves a domain's registration, replacing the one stored for its name.
+	UpsertDomain(ctx context.Context, domain models.Domain) error
+	// ListDomains returns every stored domain, ordered by name.
+	ListDomains(ctx context.Context) ([]models.Domain, error)
+	// DeleteDomain forgets a domain, e.g. once no target is on it.
+	DeleteDomain(ctx context.Context, name string) error
+}
+
+// ClientCertStore is implemented by stores that keep the client certificates presented to
+// hosts that require mutual TLS.
+type ClientCertStore interface {
+	// PutClientCertificate saves a host's certificate and key, replacing any stored for it.
+	PutClientCertificate(ctx context.Context, cert models.ClientCertificate) error
+	// GetClientCertificate returns a host's certificate with its key, or ErrNotFound.
+	GetClientCertificate(ctx context.Context, host string) (*models.ClientCertificate, error)
+	// ListClientCertificates returns every stored certificate without its key, ordered by host.
+	ListClientCertificates(ctx context.Context) ([]models.ClientCertificate, error)
+	// DeleteClientCertificate forgets a host's certificate, returning ErrNotFound if it has none.
+	DeleteClientCertificate(ctx context.Context, host string) error
+}
+
+// TargetReader reads targets and host pauses.
+type TargetReader interface {
+	GetTargetByID(ctx context.Context, id string) (*models.Target, error)
+	ListTargets(ctx context.Context, params ListTargetsParams) ([]models.Target, error)
+	// ListTargetsByHealth returns a page of targets ranked by their latest result, as
+	// HealthRank ranks them.
+	ListTargetsByHealth(ctx context.Context, params ListTargetsByHealthParams) ([]RankedTarget, error)
+	// CountTargets returns how many targets there are on host with all of the metadata
+	// key/value pairs; an empty host or metadata doesn't filter.
+	CountTargets(ctx context.Context, host string, metadata map[string]string) (int, error)
+	GetAllTargets(ctx context.Context) ([]models.Target, error)
+	ListTargetsPage(ctx context.Context, afterID string, limit int) ([]models.Target, error)
+	// ListTargetAliases returns every URL submitted for a target, oldest first. CreateTarget
+	// records one for each distinct URL that canonicalizes to the target.
+	ListTargetAliases(ctx context.Context, targetID string) ([]models.TargetAlias, error)
+	ListPausedHosts(ctx context.Context) ([]models.HostPause, error)
+}
+
+// TargetWriter creates, changes, and deletes targets, and pauses hosts.
+type TargetWriter interface {
+	CreateTarget(ctx context.Context, target *models.Target, idempotencyKey *string) (*models.Target, error)
+	// DeleteTargets deletes every target on host with all of the metadata key/value pairs,
+	// together with their results, transitions, and aliases, in one transaction. It returns the
+	// IDs of the deleted targets.
+	DeleteTargets(ctx context.Context, host string, metadata map[string]string) ([]string, error)
+	// UpdateTargets makes the mutation to every target the filter selects, in one
+	// transaction, and returns how many were selected and how many of them changed. If the
+	// mutation fails for any target, none are changed.
+	UpdateTargets(ctx context.Context, filter TargetFilter, mutation TargetMutation) (matched, updated int, err error)
+	RecordHeartbeat(ctx context.Context, token string, at time.Time) (*models.Target, error)
+	// UpdateTarget makes the patch to a target in one transaction and returns the updated
+	// target. If any setting can't be written, none are.
+	UpdateTarget(ctx context.Context, id string, patch TargetPatch) (*models.Target, error)
+	// SetSnooze suspends a target's checks and alerts until until (nil resumes it) and returns the updated target.
+	SetSnooze(ctx context.Context, id string, until *time.Time) (*models.Target, error)
+
+	// PauseHost suspends checks for every target on host. Pausing a paused host keeps its
+	// original pause time.
+	PauseHost(ctx context.Context, host string, at time.Time) (*models.HostPause, error)
+	// ResumeHost lifts a host pause, returning ErrNotFound if the host isn't paused.
+	ResumeHost(ctx context.Context, host string) error
+}
+
+// ResultReader reads check results, the statistics aggregated from them, and state transitions.
+type ResultReader interface {
+	ListCheckResultsByTargetID(ctx context.Context, params ListCheckResultsParams) ([]models.CheckResult, error)
+	ListRecentResults(ctx context.Context, params RecentResultsParams) (map[string][]models.CheckResult, error)
+	GetLatestResults(ctx context.Context, targetIDs []string) (map[string]models.CheckResult, error)
+	// GetLatestResultsAt returns each target's latest result checked at or before at, keyed by
+	// target ID. Targets without one are left out.
+	GetLatestResultsAt(ctx context.Context, targetIDs []string, at time.Time) (map[string]models.CheckResult, error)
+	GetTimeseries(ctx context.Context, params TimeseriesParams) ([]models.TimeseriesBucket, error)
+	ListTargetStats(ctx context.Context, params TargetStatsParams) ([]models.TargetStats, error)
+	GetStatusBreakdown(ctx context.Context, params StatusBreakdownParams) (*models.StatusBreakdown, error)
+	// GetResultSummary returns the success rate, latency percentiles over every result, and
+	// error categories of a target's results within the window.
+	GetResultSummary(ctx context.Context, params ResultSummaryParams) (*models.ResultSummary, error)
+	// ListHostStats returns every host with targets, ordered by host, with its target count
+	// and check statistics within the window. Load fields are left zero.
+	ListHostStats(ctx context.Context, params HostStatsParams) ([]models.HostStats, error)
+	// GetBandwidthUsage returns the bytes downloaded by results within the window per UTC day
+	// and target or host, ordered by day and then by bytes, most first.
+	GetBandwidthUsage(ctx context.Context, params BandwidthParams) ([]models.BandwidthUsage, error)
+	// ListStateTransitions returns a target's state transitions, newest first. Transitions are
+	// recorded by CreateCheckResult whenever a result changes the target's status.
+	ListStateTransitions(ctx context.Context, params ListTransitionsParams) ([]models.StateTransition, error)
+	// GetLatestTransitionsAt returns each target's latest state transition at or before at,
+	// which is the status the target was in then, keyed by target ID. Targets without one
+	// are left out.
+	GetLatestTransitionsAt(ctx context.Context, targetIDs []string, at time.Time) (map[string]models.StateTransition, error)
+}
+
+// ResultWriter stores check results.
+type ResultWriter interface {
+	// CreateCheckResult stores a result, recording a state transition when it changes the
+	// target's status.
+	CreateCheckResult(ctx context.Context, result *models.CheckResult) error
+}
+
+// TargetStore reads and writes targets.
+type TargetStore interface {
+	TargetReader
+	TargetWriter
+}
+
+// ResultStore reads and writes check results.
+type ResultStore interface {
+	ResultReader
+	ResultWriter
+}
+
+// JobStore persists background jobs.
+type JobStore interface {
+	CreateJob(ctx context.Context, job *models.Job) error
+	UpdateJob(ctx context.Context, job *models.Job) error
+	GetJob(ctx context.Context, id string) (*models.Job, error)
+	FailUnfinishedJobs(ctx context.Context, reason string, at time.Time) (int, error)
+}
+
+// EventStore persists system events.
+type EventStore interface {
+	// RecordSystemEvent stores a system event, assigning its ID when empty.
+	RecordSystemEvent(ctx context.Context, event *models.SystemEvent) error
+	// ListSystemEvents returns system events, newest first.
+	ListSystemEvents(ctx context.Context, params ListSystemEventsParams) ([]models.SystemEvent, error)
+}
+
+// Storer combines every storage operation on targets, check results, background jobs, and
+// system events. Backends implement it; consumers that need less depend on the narrower
+// interfaces it is composed of, so read-only replicas, caches, and decorators need only
+// implement what those consumers use.
+type Storer interface {
+	TargetStore
+	ResultStore
+	JobStore
+	EventStore
+}
+
+// DatabaseMaintainer is implemented by stores whose database can be compacted and analyzed on
+// demand.
+type DatabaseMaintainer interface {
+	// MaintainDatabase runs one of the models.Maintenance* operations.
+	MaintainDatabase(ctx context.Context, operation string) (*models.DatabaseMaintenance, error)
+}
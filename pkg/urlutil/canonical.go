@@ -1,3 +1,4 @@
+// Package urlutil canonicalizes target URLs so equivalent URLs map to a single target.
 package urlutil
 
 import (
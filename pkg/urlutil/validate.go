@@ -0,0 +1,102 @@
+package urlutil
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Validation rules, reported in ValidationError.Rule.
+const (
+	RuleMaxLength      = "max_length"
+	RuleUserinfo       = "userinfo"
+	RuleHost           = "host"
+	RuleTLD            = "tld"
+	RuleMaxPathLength  = "max_path_length"
+	RuleMaxQueryLength = "max_query_length"
+)
+
+// ValidationError reports which rule a URL broke.
+type ValidationError struct {
+	Rule    string
+	Message string
+}
+
+func (e *ValidationError) Error() string { return e.Message }
+
+// Limits restricts which URLs are accepted as targets, on top of what Canonicalize requires.
+// Zero lengths are unbounded.
+type Limits struct {
+	MaxLength      int
+	MaxPathLength  int
+	MaxQueryLength int
+	RequireTLD     bool // Reject hosts without a top-level domain, such as "localhost"; IP addresses are allowed
+}
+
+// DefaultLimits bounds URLs at 2048 bytes, the longest many browsers and proxies handle.
+var DefaultLimits = Limits{MaxLength: 2048}
+
+// maxHostLength and maxLabelLength are the DNS limits (RFC 1035).
+const (
+	maxHostLength  = 253
+	maxLabelLength = 63
+)
+
+// Check reports the first limit raw breaks as a *ValidationError. A URL that doesn't parse
+// passes, leaving Canonicalize to reject it.
+func (l Limits) Check(raw string) error {
+	if l.MaxLength > 0 && len(raw) > l.MaxLength {
+		return &ValidationError{RuleMaxLength, fmt.Sprintf("url must not be longer than %d bytes", l.MaxLength)}
+	}
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() {
+		return nil
+	}
+	if u.User != nil {
+		return &ValidationError{RuleUserinfo, "url must not contain a username or password"}
+	}
+	host := strings.TrimSuffix(u.Hostname(), ".")
+	if err := checkHost(host, l.RequireTLD); err != nil {
+		return err
+	}
+	if l.MaxPathLength > 0 && len(u.EscapedPath()) > l.MaxPathLength {
+		return &ValidationError{RuleMaxPathLength, fmt.Sprintf("url path must not be longer than %d bytes", l.MaxPathLength)}
+	}
+	if l.MaxQueryLength > 0 && len(u.RawQuery) > l.MaxQueryLength {
+		return &ValidationError{RuleMaxQueryLength, fmt.Sprintf("url query must not be longer than %d bytes", l.MaxQueryLength)}
+	}
+	return nil
+}
+
+// checkHost accepts IP addresses and DNS names made of labels of letters, digits, and inner
+// hyphens. Internationalized names must be given in their punycode (xn--) form.
+func checkHost(host string, requireTLD bool) error {
+	if host == "" {
+		return &ValidationError{RuleHost, "url must have a host"}
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if len(host) > maxHostLength {
+		return &ValidationError{RuleHost, fmt.Sprintf("host must not be longer than %d bytes", maxHostLength)}
+	}
+	labels := strings.Split(host, ".")
+	for _, label := range labels {
+		if label == "" || len(label) > maxLabelLength || label[0] == '-' || label[len(label)-1] == '-' {
+			return &ValidationError{RuleHost, fmt.Sprintf("invalid host label %q, expected 1 to %d characters not starting or ending with a hyphen", label, maxLabelLength)}
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return &ValidationError{RuleHost, fmt.Sprintf("invalid host label %q, expected letters, digits, and hyphens (punycode for internationalized names)", label)}
+			}
+		}
+	}
+	if requireTLD {
+		tld := strings.ToLower(labels[len(labels)-1])
+		if len(labels) < 2 || !(strings.HasPrefix(tld, "xn--") || strings.Trim(tld, "abcdefghijklmnopqrstuvwxyz") == "") {
+			return &ValidationError{RuleTLD, fmt.Sprintf("host %q must end in a top-level domain", host)}
+		}
+	}
+	return nil
+}